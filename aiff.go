@@ -0,0 +1,513 @@
+package flacgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// AIFFFormat selects the container written by WriteAIFF and ExportAIFF
+type AIFFFormat int
+
+const (
+	// AIFFFormatAIFF is the original Audio Interchange File Format
+	AIFFFormatAIFF AIFFFormat = iota
+	// AIFFFormatAIFC is AIFF-C with uncompressed big-endian samples ("NONE")
+	AIFFFormatAIFC
+)
+
+// aifcVersion is the only version of the AIFF-C format, stored in the FVER chunk
+const aifcVersion = 0xA2805140
+
+// extendedFloat encodes value as an 80 bits IEEE 754 extended precision number, as AIFF stores sample rates
+func extendedFloat(value float64) [10]byte {
+	var encoded [10]byte
+	if value <= 0 {
+		return encoded
+	}
+
+	fraction, exponent := math.Frexp(value)
+	// Frexp returns a fraction in [0.5, 1), the extended format has an explicit integer bit
+	binary.BigEndian.PutUint16(encoded[0:2], uint16(16383+exponent-1))
+	binary.BigEndian.PutUint64(encoded[2:10], uint64(math.Ldexp(fraction, 64)))
+	return encoded
+}
+
+// parseExtendedFloat decodes an 80 bits IEEE 754 extended precision number
+func parseExtendedFloat(encoded []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(encoded[0:2]) & 0x7FFF)
+	mantissa := binary.BigEndian.Uint64(encoded[2:10])
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	return math.Ldexp(float64(mantissa), exponent-16383-63)
+}
+
+// aiffFormat holds the content of the COMM chunk of an AIFF file
+type aiffFormat struct {
+	channels      uint16
+	frames        uint32
+	bitsPerSample uint16
+	sampleRate    float64
+	// littleEndian is set for the "sowt" AIFF-C encoding
+	littleEndian bool
+}
+
+// aiffTags maps the text chunks of AIFF files to Vorbis comment names
+var aiffTags = map[string]string{
+	"NAME": "TITLE",
+	"AUTH": "ARTIST",
+	"(c) ": "COPYRIGHT",
+	"ANNO": "COMMENT",
+}
+
+// aiffMaxCommSize bounds the COMM chunk: 22 bytes of AIFF-C format and a compression name of up to 255 bytes
+const aiffMaxCommSize = 22 + 256
+
+// aiffMaxTextSize bounds the text chunks read as tags, larger ones are skipped
+const aiffMaxTextSize = 64 * 1024
+
+// ReadAIFF reads an uncompressed AIFF or AIFF-C file in memory
+func ReadAIFF(r io.Reader) (*PCM, error) {
+	var pcm *PCM
+	comments, err := readAIFFChunks(r, func(format *aiffFormat, data io.Reader, _ []VorbisComment) (bool, error) {
+		parsed, err := readAIFFData(data, format)
+		pcm = parsed
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	pcm.Comments = comments
+
+	return pcm, nil
+}
+
+// EncodeAIFF compresses the AIFF or AIFF-C file read from r into a FLAC stream written to w as the
+// samples are read, like EncodeWAV. The samples of a SSND chunk coming before the COMM chunk are
+// buffered until the format is known.
+func EncodeAIFF(r io.Reader, w io.Writer, opts EncoderOptions) error {
+	_, err := readAIFFChunks(r, func(format *aiffFormat, data io.Reader, comments []VorbisComment) (bool, error) {
+		if opts.Comments == nil {
+			opts.Comments = comments
+		}
+		return false, encodeAIFFData(data, w, format, opts)
+	})
+	return err
+}
+
+// readAIFFChunks reads the chunks of an AIFF file, calling data with the COMM chunk and the tags found so far
+// to consume the samples of the SSND chunk. The chunks following it are read only if data returns true.
+// It returns the tags of all the chunks read.
+func readAIFFChunks(r io.Reader, data func(format *aiffFormat, data io.Reader, comments []VorbisComment) (bool, error)) ([]VorbisComment, error) {
+	var form [12]byte
+	if _, err := io.ReadFull(r, form[:]); err != nil {
+		return nil, fmt.Errorf("unable to read FORM header: %w", err)
+	}
+	formType := string(form[8:12])
+	if string(form[0:4]) != "FORM" || (formType != "AIFF" && formType != "AIFC") {
+		return nil, errors.New("not an AIFF file")
+	}
+
+	var format *aiffFormat
+	read := false
+	// Chunks can come in any order, samples found before the COMM chunk are kept until it's read
+	var pending []byte
+	var comments []VorbisComment
+
+chunks:
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF || ((read || pending != nil) && err == io.ErrUnexpectedEOF) {
+				break chunks
+			}
+			return nil, fmt.Errorf("unable to read chunk header: %w", err)
+		}
+		id := string(header[0:4])
+		size := binary.BigEndian.Uint32(header[4:8])
+
+		switch {
+		case id == "COMM":
+			if size > aiffMaxCommSize {
+				return nil, fmt.Errorf("COMM chunk is %d bytes, expected at most %d", size, aiffMaxCommSize)
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("unable to read COMM chunk: %w", err)
+			}
+			parsed, err := parseAIFFFormat(body, formType == "AIFC")
+			if err != nil {
+				return nil, err
+			}
+			format = parsed
+		case id == "SSND":
+			if size < 8 {
+				return nil, errors.New("SSND chunk is too short")
+			}
+			var offset [8]byte
+			if _, err := io.ReadFull(r, offset[:]); err != nil {
+				return nil, fmt.Errorf("unable to read SSND chunk: %w", err)
+			}
+			skip := binary.BigEndian.Uint32(offset[0:4])
+			if skip > size-8 {
+				return nil, errors.New("SSND data offset is past the end of the chunk")
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(skip)); err != nil {
+				return nil, fmt.Errorf("unable to read SSND chunk: %w", err)
+			}
+
+			samples := io.LimitReader(r, int64(size-8-skip))
+			if format == nil {
+				buffered, err := io.ReadAll(samples)
+				if err != nil {
+					return nil, fmt.Errorf("unable to read SSND chunk: %w", err)
+				}
+				pending = buffered
+				break
+			}
+
+			more, err := data(format, samples, comments)
+			if err != nil {
+				return nil, err
+			}
+			if !more {
+				return comments, nil
+			}
+			read = true
+			if _, err := io.Copy(io.Discard, samples); err != nil {
+				break chunks
+			}
+		case aiffTags[id] != "" && size <= aiffMaxTextSize:
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				if read {
+					break chunks
+				}
+				return nil, fmt.Errorf("unable to read %q chunk: %w", id, err)
+			}
+			value := strings.TrimSpace(strings.TrimRight(string(body), "\x00"))
+			if !utf8.ValidString(value) {
+				value = decodeLatin1(value)
+			}
+			if value != "" {
+				comments = append(comments, VorbisComment{Title: aiffTags[id], Value: value})
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+				if read {
+					break chunks
+				}
+				return nil, fmt.Errorf("unable to skip %q chunk: %w", id, err)
+			}
+		}
+
+		// Chunks are word aligned
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				break chunks
+			}
+		}
+	}
+
+	if format == nil {
+		return nil, errors.New("AIFF file has no COMM chunk")
+	}
+	if !read && pending != nil {
+		if _, err := data(format, bytes.NewReader(pending), comments); err != nil {
+			return nil, err
+		}
+		read = true
+	}
+	if !read {
+		return nil, errors.New("AIFF file has no SSND chunk")
+	}
+	return comments, nil
+}
+
+// parseAIFFFormat parses the body of a COMM chunk, only uncompressed integer samples are supported
+func parseAIFFFormat(body []byte, aifc bool) (*aiffFormat, error) {
+	if len(body) < 18 {
+		return nil, fmt.Errorf("COMM chunk is %d bytes, expected at least 18", len(body))
+	}
+
+	format := &aiffFormat{
+		channels:      binary.BigEndian.Uint16(body[0:2]),
+		frames:        binary.BigEndian.Uint32(body[2:6]),
+		bitsPerSample: binary.BigEndian.Uint16(body[6:8]),
+		sampleRate:    parseExtendedFloat(body[8:18]),
+	}
+
+	if aifc {
+		if len(body) < 22 {
+			return nil, errors.New("AIFF-C COMM chunk has no compression type")
+		}
+		switch compression := string(body[18:22]); compression {
+		case "NONE", "twos":
+		case "sowt":
+			format.littleEndian = true
+		default:
+			return nil, fmt.Errorf("unsupported AIFF-C compression %q", compression)
+		}
+	}
+
+	if format.channels == 0 {
+		return nil, errors.New("AIFF file has no channels")
+	}
+	if format.bitsPerSample == 0 || format.bitsPerSample > 32 {
+		return nil, fmt.Errorf("unsupported AIFF sample size of %d bits", format.bitsPerSample)
+	}
+	if format.sampleRate != math.Trunc(format.sampleRate) || format.sampleRate < 1 || format.sampleRate > math.MaxUint32 {
+		return nil, fmt.Errorf("unsupported AIFF sample rate %g", format.sampleRate)
+	}
+
+	return format, nil
+}
+
+// readAIFFData reads the interleaved samples of a SSND chunk. The samples are appended as they are read,
+// the frame count of the COMM chunk is not trusted.
+func readAIFFData(r io.Reader, format *aiffFormat) (*PCM, error) {
+	pcm := &PCM{
+		SampleRate:    uint32(format.sampleRate),
+		BitsPerSample: uint8(format.bitsPerSample),
+		Samples:       make([][]int32, format.channels),
+	}
+
+	err := readAIFFSamples(r, format, func(samples [][]int32) error {
+		for ch := range samples {
+			pcm.Samples[ch] = append(pcm.Samples[ch], samples[ch]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pcm, nil
+}
+
+// encodeAIFFData encodes the interleaved samples of a SSND chunk read from r to w
+func encodeAIFFData(r io.Reader, w io.Writer, format *aiffFormat, opts EncoderOptions) error {
+	encoder, err := NewEncoder(w, uint32(format.sampleRate), uint8(format.bitsPerSample), int(format.channels), opts)
+	if err != nil {
+		return err
+	}
+
+	interleaved := make([]int32, 0, aiffBlockFrames*int(format.channels))
+	err = readAIFFSamples(r, format, func(samples [][]int32) error {
+		interleaved = interleaved[:0]
+		for i := range samples[0] {
+			for ch := range samples {
+				interleaved = append(interleaved, samples[ch][i])
+			}
+		}
+		return encoder.Write(interleaved)
+	})
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// aiffBlockFrames is the number of frames readAIFFSamples decodes at once
+const aiffBlockFrames = 4096
+
+// readAIFFSamples decodes the frames of a SSND chunk read from r, up to the frame count of the COMM chunk,
+// and calls fn with every block of at most aiffBlockFrames frames
+func readAIFFSamples(r io.Reader, format *aiffFormat, fn func(samples [][]int32) error) error {
+	channels := int(format.channels)
+	bytesPerSample := int(format.bitsPerSample+7) / 8
+	// Samples are left-justified in their container
+	shift := uint(bytesPerSample*8) - uint(format.bitsPerSample)
+
+	samples := make([][]int32, channels)
+	for ch := range samples {
+		samples[ch] = make([]int32, 0, aiffBlockFrames)
+	}
+
+	br := bufio.NewReader(r)
+	buf := make([]byte, channels*bytesPerSample)
+	for remaining := int64(format.frames); remaining > 0; {
+		for ch := range samples {
+			samples[ch] = samples[ch][:0]
+		}
+		for i := 0; i < aiffBlockFrames && remaining > 0; i++ {
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return fmt.Errorf("unable to read AIFF samples: %w", err)
+			}
+			remaining--
+
+			for ch := 0; ch < channels; ch++ {
+				b := buf[ch*bytesPerSample : (ch+1)*bytesPerSample]
+				var container [4]byte
+				if format.littleEndian {
+					for j := range b {
+						container[j] = b[len(b)-1-j]
+					}
+				} else {
+					copy(container[:], b)
+				}
+				// Place the container in the top bytes so the shift sign-extends it
+				sample := int32(binary.BigEndian.Uint32(container[:]))
+				samples[ch] = append(samples[ch], sample>>(uint(32-8*bytesPerSample)+shift))
+			}
+		}
+		if err := fn(samples); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAIFFHeader writes the FORM header, the COMM chunk and the header of a SSND chunk holding size bytes of samples
+func writeAIFFHeader(w io.Writer, format AIFFFormat, channels int, bitsPerSample uint8, sampleRate uint32, frames uint32, size uint32) error {
+	var comm bytes.Buffer
+	binary.Write(&comm, binary.BigEndian, uint16(channels))
+	binary.Write(&comm, binary.BigEndian, frames)
+	binary.Write(&comm, binary.BigEndian, uint16(bitsPerSample))
+	rate := extendedFloat(float64(sampleRate))
+	comm.Write(rate[:])
+	if format == AIFFFormatAIFC {
+		comm.WriteString("NONE")
+		// The compression name is a Pascal string padded to an even length
+		name := "not compressed"
+		comm.WriteByte(byte(len(name)))
+		comm.WriteString(name)
+		if (len(name)+1)%2 == 1 {
+			comm.WriteByte(0)
+		}
+	}
+
+	formType := "AIFF"
+	formSize := 4 + 8 + comm.Len() + 8 + 8 + int(size) + int(size%2)
+	if format == AIFFFormatAIFC {
+		formType = "AIFC"
+		formSize += 8 + 4
+	}
+
+	var header bytes.Buffer
+	header.WriteString("FORM")
+	binary.Write(&header, binary.BigEndian, uint32(formSize))
+	header.WriteString(formType)
+	if format == AIFFFormatAIFC {
+		header.WriteString("FVER")
+		binary.Write(&header, binary.BigEndian, uint32(4))
+		binary.Write(&header, binary.BigEndian, uint32(aifcVersion))
+	}
+	header.WriteString("COMM")
+	binary.Write(&header, binary.BigEndian, uint32(comm.Len()))
+	comm.WriteTo(&header)
+	header.WriteString("SSND")
+	binary.Write(&header, binary.BigEndian, uint32(8+size))
+	// Sample data offset and block size, both unused
+	binary.Write(&header, binary.BigEndian, uint32(0))
+	binary.Write(&header, binary.BigEndian, uint32(0))
+
+	_, err := header.WriteTo(w)
+	return err
+}
+
+// writeAIFFSamples writes samples interleaved as big-endian signed integers, left-justified in containers of whole bytes
+func writeAIFFSamples(w *bufio.Writer, samples [][]int32, bitsPerSample uint8) error {
+	bytesPerSample := int(bitsPerSample+7) / 8
+	shift := uint(bytesPerSample*8) - uint(bitsPerSample)
+
+	var buf [4]byte
+	for i := range samples[0] {
+		for ch := range samples {
+			binary.BigEndian.PutUint32(buf[:], uint32(samples[ch][i]<<shift)<<(32-8*bytesPerSample))
+			if _, err := w.Write(buf[:bytesPerSample]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// aiffDataSize returns the size in bytes of the samples of the SSND chunk
+func aiffDataSize(frames uint64, channels int, bitsPerSample uint8) (uint32, error) {
+	size := frames * uint64(channels) * uint64((bitsPerSample+7)/8)
+	if size > 0xFFFFFFFF-128 {
+		return 0, errors.New("audio is larger than 4 GB")
+	}
+	return uint32(size), nil
+}
+
+// WriteAIFF writes pcm as an AIFF or AIFF-C file
+func WriteAIFF(w io.Writer, pcm *PCM, format AIFFFormat) error {
+	if err := pcm.validate(); err != nil {
+		return fmt.Errorf("unable to write AIFF: %w", err)
+	}
+
+	size, err := aiffDataSize(uint64(pcm.Length()), pcm.Channels(), pcm.BitsPerSample)
+	if err != nil {
+		return fmt.Errorf("unable to write AIFF: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeAIFFHeader(bw, format, pcm.Channels(), pcm.BitsPerSample, pcm.SampleRate, uint32(pcm.Length()), size); err != nil {
+		return fmt.Errorf("unable to write AIFF header: %w", err)
+	}
+	if err := writeAIFFSamples(bw, pcm.Samples, pcm.BitsPerSample); err != nil {
+		return fmt.Errorf("unable to write AIFF samples: %w", err)
+	}
+	if size%2 == 1 {
+		bw.WriteByte(0)
+	}
+
+	return bw.Flush()
+}
+
+// ExportAIFF decodes the audio and writes it as an AIFF or AIFF-C file, one frame at a time
+func (flac *Flac) ExportAIFF(w io.Writer, format AIFFFormat) error {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return err
+	}
+
+	// The frame count is written first, without a sample count it's only known after decoding
+	if streamInfo.TotalSamples == 0 {
+		pcm, err := flac.DecodePCM()
+		if err != nil {
+			return err
+		}
+		return WriteAIFF(w, pcm, format)
+	}
+
+	mixer, err := flac.newChannelMixer(streamInfo)
+	if err != nil {
+		return err
+	}
+	size, err := aiffDataSize(streamInfo.TotalSamples, mixer.channels, streamInfo.BitsPerSample)
+	if err != nil {
+		return fmt.Errorf("unable to write AIFF: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeAIFFHeader(bw, format, mixer.channels, streamInfo.BitsPerSample, streamInfo.SampleRate, uint32(streamInfo.TotalSamples), size); err != nil {
+		return fmt.Errorf("unable to write AIFF header: %w", err)
+	}
+
+	err = flac.decodeFrames(streamInfo, mixer, func(samples [][]int32) error {
+		if err := writeAIFFSamples(bw, samples, streamInfo.BitsPerSample); err != nil {
+			return fmt.Errorf("unable to write AIFF samples: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if size%2 == 1 {
+		bw.WriteByte(0)
+	}
+
+	return bw.Flush()
+}
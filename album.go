@@ -0,0 +1,233 @@
+package flacgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// AlbumArtworkKey is the key used in album reports for the front cover, whose values are picture hashes
+const AlbumArtworkKey = "ARTWORK"
+
+// DefaultAlbumTags are the tags CheckAlbum compares when no tags are given
+var DefaultAlbumTags = []string{"ALBUM", "ALBUMARTIST", "DATE", "DISCNUMBER"}
+
+// AlbumOptions configures CheckAlbum
+type AlbumOptions struct {
+	// Tags are the tags expected to be the same in all the tracks, DefaultAlbumTags if empty
+	Tags []string
+	// SkipArtwork disables the comparison of the front covers
+	SkipArtwork bool
+}
+
+// AlbumValue is one of the values a tag has across the tracks of an album
+type AlbumValue struct {
+	// Value holds the tag values joined by "; ", or the cover hash for AlbumArtworkKey.
+	// It's empty for the tracks missing the tag.
+	Value string
+	// Paths are the tracks having this value, in input order
+	Paths []string
+
+	values []string
+}
+
+// AlbumConflict lists the different values of a tag that should be the same across an album
+type AlbumConflict struct {
+	Key string
+	// Values are sorted by number of tracks, the most common first, ties keeping the input order
+	Values []AlbumValue
+}
+
+// Majority returns the value shared by most tracks
+func (conflict AlbumConflict) Majority() AlbumValue {
+	return conflict.Values[0]
+}
+
+func (conflict AlbumConflict) String() string {
+	values := make([]string, len(conflict.Values))
+	for i, value := range conflict.Values {
+		values[i] = fmt.Sprintf("%q (%d)", value.Value, len(value.Paths))
+	}
+	return fmt.Sprintf("%s differs across the album: %s", conflict.Key, strings.Join(values, ", "))
+}
+
+// AlbumReport is the result of CheckAlbum
+type AlbumReport struct {
+	Paths     []string
+	Conflicts []AlbumConflict
+}
+
+// Consistent reports whether all the tracks agree on every checked tag
+func (report *AlbumReport) Consistent() bool {
+	return len(report.Conflicts) == 0
+}
+
+// CheckAlbum reads the files at paths, the tracks of a single album, and reports the tags and front
+// covers that differ between them. A track missing a tag counts as having an empty value.
+func CheckAlbum(paths []string, opts AlbumOptions) (*AlbumReport, error) {
+	keys := opts.Tags
+	if len(keys) == 0 {
+		keys = DefaultAlbumTags
+	}
+	if !opts.SkipArtwork {
+		keys = append(keys[:len(keys):len(keys)], AlbumArtworkKey)
+	}
+
+	// Values found for each key, in order of first appearance
+	found := make(map[string][]AlbumValue)
+	add := func(key string, path string, values []string) {
+		value := strings.Join(values, "; ")
+		for i := range found[key] {
+			if found[key][i].Value == value {
+				found[key][i].Paths = append(found[key][i].Paths, path)
+				return
+			}
+		}
+		found[key] = append(found[key], AlbumValue{Value: value, Paths: []string{path}, values: values})
+	}
+
+	for _, path := range paths {
+		flac, err := Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read '%s': %w", path, err)
+		}
+
+		for _, key := range keys {
+			if key != AlbumArtworkKey {
+				add(key, path, flac.MetadataValues(key))
+				continue
+			}
+
+			cover, err := flac.CoverPicture()
+			if err != nil {
+				flac.Close()
+				return nil, fmt.Errorf("unable to read the cover of '%s': %w", path, err)
+			}
+			var hash []string
+			if cover != nil {
+				sum := cover.Hash()
+				hash = []string{fmt.Sprintf("%x", sum[:8])}
+			}
+			add(key, path, hash)
+		}
+		flac.Close()
+	}
+
+	report := &AlbumReport{Paths: paths, Conflicts: make([]AlbumConflict, 0)}
+	for _, key := range keys {
+		if len(found[key]) < 2 {
+			continue
+		}
+		values := found[key]
+		sort.SliceStable(values, func(i, j int) bool { return len(values[i].Paths) > len(values[j].Paths) })
+		report.Conflicts = append(report.Conflicts, AlbumConflict{Key: key, Values: values})
+	}
+
+	return report, nil
+}
+
+// CheckAlbumDir runs CheckAlbum on the FLAC files directly inside dir
+func CheckAlbumDir(dir string, opts AlbumOptions) (*AlbumReport, error) {
+	paths, err := listFLACFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return CheckAlbum(paths, opts)
+}
+
+// listFLACFiles returns the FLAC files directly inside dir, sorted by name
+func listFLACFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list '%s': %w", dir, err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".flac") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// Harmonize sets the majority value of every conflict on the tracks that differ and saves them.
+// Conflicts whose majority is a missing tag or cover are left alone, nothing is ever removed.
+// Failures of single files are reported in the results, tracks already matching are not included.
+func (report *AlbumReport) Harmonize() []TransformResult {
+	// Changes to stage on each file, in the order of the report paths
+	staged := make(map[string][]func(flac *Flac) (TagChange, error))
+
+	for _, conflict := range report.Conflicts {
+		majority := conflict.Majority()
+		if majority.Value == "" {
+			continue
+		}
+
+		var cover []byte
+		for _, other := range conflict.Values[1:] {
+			for _, path := range other.Paths {
+				staged[path] = append(staged[path], func(flac *Flac) (TagChange, error) {
+					change := TagChange{Key: conflict.Key, Old: other.Value, New: majority.Value}
+					if conflict.Key != AlbumArtworkKey {
+						return change, flac.SetMetadataValues(conflict.Key, majority.values)
+					}
+
+					if cover == nil {
+						data, err := readAlbumCover(majority.Paths[0])
+						if err != nil {
+							return change, err
+						}
+						cover = data
+					}
+					return change, flac.SetCoverPictureFromBytes(cover)
+				})
+			}
+		}
+	}
+
+	results := make([]TransformResult, 0)
+	for _, path := range report.Paths {
+		fns := staged[path]
+		if len(fns) == 0 {
+			continue
+		}
+		delete(staged, path)
+
+		changes, err := transformFile(path, func(flac *Flac) ([]TagChange, error) {
+			changes := make([]TagChange, 0, len(fns))
+			for _, fn := range fns {
+				change, err := fn(flac)
+				if err != nil {
+					return nil, fmt.Errorf("unable to set %s: %w", change.Key, err)
+				}
+				changes = append(changes, change)
+			}
+			return changes, nil
+		})
+		results = append(results, TransformResult{Path: path, Changes: changes, Err: err})
+	}
+
+	return results
+}
+
+// readAlbumCover returns the image data of the cover of the file at path
+func readAlbumCover(path string) ([]byte, error) {
+	flac, err := Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", path, err)
+	}
+	defer flac.Close()
+
+	cover, err := flac.CoverPicture()
+	if err != nil {
+		return nil, err
+	}
+	if cover == nil {
+		return nil, fmt.Errorf("'%s' has no cover picture", path)
+	}
+	return slices.Clone(cover.Data), nil
+}
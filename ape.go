@@ -0,0 +1,224 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// APEv2Mapping maps APEv2 item keys to the matching Vorbis comment field names.
+// Keys are compared case-insensitively, unknown keys are imported upper-cased.
+var APEv2Mapping = map[string]string{
+	"title":        "TITLE",
+	"artist":       "ARTIST",
+	"album":        "ALBUM",
+	"album artist": "ALBUMARTIST",
+	"albumartist":  "ALBUMARTIST",
+	"year":         "DATE",
+	"genre":        "GENRE",
+	"comment":      "COMMENT",
+	"composer":     "COMPOSER",
+	"conductor":    "CONDUCTOR",
+	"copyright":    "COPYRIGHT",
+	"publisher":    "ORGANIZATION",
+	"isrc":         "ISRC",
+	"lyrics":       "LYRICS",
+	"track":        "TRACKNUMBER",
+	"disc":         "DISCNUMBER",
+}
+
+// apeTag stores where an APEv2 tag is located in the file and its parsed text items
+type apeTag struct {
+	offset int64
+	length int64
+	items  []VorbisComment
+}
+
+// findAPETag looks for an APEv2 tag at the end of the file, optionally followed by an ID3v1 tag.
+// It returns nil if the file doesn't have one.
+func findAPETag(f source, fileSize int64) (*apeTag, error) {
+	footerEnd := fileSize
+
+	// An ID3v1 tag, if any, is always the last 128 bytes and comes after the APEv2 one
+	if fileSize >= 128 {
+		id3 := make([]byte, 3)
+		if _, err := f.ReadAt(id3, fileSize-128); err == nil && string(id3) == "TAG" {
+			footerEnd -= 128
+		}
+	}
+
+	if footerEnd < 32 {
+		return nil, nil
+	}
+
+	footer := make([]byte, 32)
+	if _, err := f.ReadAt(footer, footerEnd-32); err != nil {
+		return nil, fmt.Errorf("unable to read APEv2 footer: %w", err)
+	}
+	if string(footer[0:8]) != "APETAGEX" {
+		return nil, nil
+	}
+
+	// Tag size includes the items and the footer but not the optional header
+	tagSize := int64(binary.LittleEndian.Uint32(footer[12:16]))
+	itemCount := binary.LittleEndian.Uint32(footer[16:20])
+	flags := binary.LittleEndian.Uint32(footer[20:24])
+
+	if tagSize < 32 || tagSize > footerEnd {
+		return nil, fmt.Errorf("invalid APEv2 tag size %d", tagSize)
+	}
+
+	itemsStart := footerEnd - tagSize
+	tagStart := itemsStart
+	if flags&0x80000000 != 0 {
+		tagStart -= 32
+	}
+	if tagStart < 0 {
+		return nil, fmt.Errorf("invalid APEv2 tag size %d", tagSize)
+	}
+
+	itemsData := make([]byte, tagSize-32)
+	if _, err := f.ReadAt(itemsData, itemsStart); err != nil {
+		return nil, fmt.Errorf("unable to read APEv2 items: %w", err)
+	}
+
+	items, err := parseAPEItems(itemsData, itemCount)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse APEv2 items: %w", err)
+	}
+
+	return &apeTag{
+		offset: tagStart,
+		length: footerEnd - tagStart,
+		items:  items,
+	}, nil
+}
+
+// parseAPEItems converts the text items of an APEv2 tag to Vorbis comments, binary and
+// external link items are skipped.
+func parseAPEItems(data []byte, itemCount uint32) ([]VorbisComment, error) {
+	comments := make([]VorbisComment, 0)
+	offset := 0
+
+	for i := 0; i < int(itemCount); i++ {
+		if len(data) < offset+8 {
+			return nil, fmt.Errorf("unexpected end of tag while reading item %d header", i)
+		}
+
+		valueLength := int(binary.LittleEndian.Uint32(data[offset : offset+4]))
+		itemFlags := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
+		offset += 8
+
+		keyEnd := bytes.IndexByte(data[offset:], 0)
+		if keyEnd < 0 {
+			return nil, fmt.Errorf("unterminated key for item %d", i)
+		}
+		key := string(data[offset : offset+keyEnd])
+		offset += keyEnd + 1
+
+		if len(data) < offset+valueLength {
+			return nil, fmt.Errorf("unexpected end of tag while reading item '%s'", key)
+		}
+		value := data[offset : offset+valueLength]
+		offset += valueLength
+
+		// Bits 1-2 hold the item type, 0 means UTF-8 text
+		if (itemFlags>>1)&0x03 != 0 {
+			continue
+		}
+
+		comments = append(comments, mapAPEItem(key, string(value))...)
+	}
+
+	return comments, nil
+}
+
+// mapAPEItem maps a single APEv2 text item to one or more Vorbis comments
+func mapAPEItem(key string, value string) []VorbisComment {
+	title, known := APEv2Mapping[strings.ToLower(key)]
+	if !known {
+		title = strings.ToUpper(key)
+	}
+
+	comments := make([]VorbisComment, 0)
+	// Multiple values of the same item are separated by a null byte
+	for _, v := range strings.Split(value, "\x00") {
+		// Track and disc may be stored as "number/total"
+		if number, total, found := strings.Cut(v, "/"); found && (title == "TRACKNUMBER" || title == "DISCNUMBER") {
+			totalTitle := strings.Replace(title, "NUMBER", "TOTAL", 1)
+			comments = append(comments, VorbisComment{title, number}, VorbisComment{totalTitle, total})
+			continue
+		}
+		comments = append(comments, VorbisComment{title, v})
+	}
+
+	return comments
+}
+
+// HasAPETag reports whether the file carries an APEv2 tag appended after the audio
+func (flac *Flac) HasAPETag() bool {
+	return flac.apeTag != nil
+}
+
+// APETags returns the text items of the APEv2 tag mapped to Vorbis comments
+func (flac *Flac) APETags() []VorbisComment {
+	if flac.apeTag == nil {
+		return nil
+	}
+	return flac.apeTag.items
+}
+
+// ImportAPETags stages the APEv2 items as Vorbis comments and the removal of the APEv2 tag on Save.
+// Existing comments are kept unless overwrite is set to true.
+func (flac *Flac) ImportAPETags(overwrite bool) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	if flac.apeTag == nil {
+		return fmt.Errorf("unable to import APEv2 tags: opened flac file doesn't have any")
+	}
+
+	// Multi-value items are split in one comment per value, they are set together
+	var titles []string
+	values := make(map[string][]string)
+	for _, item := range flac.apeTag.items {
+		key := strings.ToUpper(item.Title)
+		if _, found := values[key]; !found {
+			titles = append(titles, item.Title)
+		}
+		values[key] = append(values[key], item.Value)
+	}
+
+	existing := flac.Comments()
+	for _, title := range titles {
+		if _, found := findComment(existing, title); found && !overwrite {
+			continue
+		}
+		if err := flac.SetMetadataValues(title, values[strings.ToUpper(title)]); err != nil {
+			return fmt.Errorf("unable to import APEv2 item '%s': %w", title, err)
+		}
+	}
+
+	flac.stripAPETag = true
+
+	return nil
+}
+
+// RemoveAPETag stages the removal of the APEv2 tag on Save without importing its items
+func (flac *Flac) RemoveAPETag() error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	if flac.apeTag == nil {
+		return fmt.Errorf("unable to remove APEv2 tag: opened flac file doesn't have one")
+	}
+
+	flac.stripAPETag = true
+
+	return nil
+}
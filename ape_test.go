@@ -0,0 +1,51 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"os"
+	"slices"
+	"testing"
+)
+
+// appendAPETag appends an APEv2 tag without header holding the text items to data
+func appendAPETag(data []byte, items map[string]string) []byte {
+	var body []byte
+	for key, value := range items {
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(value)))
+		body = binary.LittleEndian.AppendUint32(body, 0)
+		body = append(body, key...)
+		body = append(body, 0)
+		body = append(body, value...)
+	}
+
+	data = append(data, body...)
+	data = append(data, "APETAGEX"...)
+	data = binary.LittleEndian.AppendUint32(data, 2000)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(body)+32))
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(items)))
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	return append(data, make([]byte, 8)...)
+}
+
+func TestImportAPETagsKeepsEveryValue(t *testing.T) {
+	path := copyFixture(t, "examples/sample.flac")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, appendAPETag(data, map[string]string{"Artist": "A\x00B"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flac, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+	if err := flac.ImportAPETags(true); err != nil {
+		t.Fatal(err)
+	}
+	if artists := flac.MetadataValues("ARTIST"); !slices.Equal(artists, []string{"A", "B"}) {
+		t.Errorf("imported ARTIST values are %q, expected [\"A\" \"B\"]", artists)
+	}
+}
@@ -0,0 +1,169 @@
+package flacgo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Window is an apodization window, weighting the samples of a block before the LPC analysis
+type Window struct {
+	// Name is the name of the window as given to `flac -A`, e.g. "tukey" or "hann"
+	Name string
+	// Parameter is the fraction of the window inside the cosine tapers for tukey, and the standard
+	// deviation relative to half the window for gauss. Other windows have no parameter.
+	Parameter float64
+}
+
+// DefaultWindow is the window of the reference encoder presets, used when EncoderOptions.Apodization is nil
+var DefaultWindow = Window{Name: "tukey", Parameter: 0.5}
+
+// windowFunctions compute the value of the windows at x, from 0 to 1 along the window
+var windowFunctions = map[string]func(x, parameter float64) float64{
+	"bartlett": func(x, _ float64) float64 {
+		return 1 - math.Abs(2*x-1)
+	},
+	"bartlett_hann": func(x, _ float64) float64 {
+		return 0.62 - 0.48*math.Abs(x-0.5) - 0.38*math.Cos(2*math.Pi*x)
+	},
+	"blackman": func(x, _ float64) float64 {
+		return cosineSum(x, 0.42, 0.5, 0.08)
+	},
+	"blackman_harris_4term_92db": func(x, _ float64) float64 {
+		return cosineSum(x, 0.35875, 0.48829, 0.14128, 0.01168)
+	},
+	"connes": func(x, _ float64) float64 {
+		d := 2*x - 1
+		return (1 - d*d) * (1 - d*d)
+	},
+	"flattop": func(x, _ float64) float64 {
+		return cosineSum(x, 0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368)
+	},
+	"gauss": func(x, stddev float64) float64 {
+		d := (2*x - 1) / stddev
+		return math.Exp(-0.5 * d * d)
+	},
+	"hamming": func(x, _ float64) float64 {
+		return cosineSum(x, 0.54, 0.46)
+	},
+	"hann": func(x, _ float64) float64 {
+		return cosineSum(x, 0.5, 0.5)
+	},
+	"kaiser_bessel": func(x, _ float64) float64 {
+		return cosineSum(x, 0.402, 0.498, 0.098, 0.001)
+	},
+	"nuttall": func(x, _ float64) float64 {
+		return cosineSum(x, 0.3635819, 0.4891775, 0.1365995, 0.0106411)
+	},
+	"rectangle": func(_, _ float64) float64 {
+		return 1
+	},
+	"welch": func(x, _ float64) float64 {
+		d := 2*x - 1
+		return 1 - d*d
+	},
+}
+
+// cosineSum returns a0 - a1 cos(2πx) + a2 cos(4πx) - a3 cos(6πx)... for the coefficients a
+func cosineSum(x float64, a ...float64) float64 {
+	sum := 0.0
+	for k, coefficient := range a {
+		sum += math.Pow(-1, float64(k)) * coefficient * math.Cos(2*math.Pi*float64(k)*x)
+	}
+	return sum
+}
+
+// validate checks the window is known and its parameter is in range
+func (window Window) validate() error {
+	switch window.Name {
+	case "tukey":
+		if window.Parameter < 0 || window.Parameter > 1 {
+			return fmt.Errorf("invalid tukey parameter %g, expected 0 to 1", window.Parameter)
+		}
+	case "gauss":
+		if window.Parameter <= 0 || window.Parameter > 0.5 {
+			return fmt.Errorf("invalid gauss parameter %g, expected more than 0 and up to 0.5", window.Parameter)
+		}
+	default:
+		if _, ok := windowFunctions[window.Name]; !ok {
+			return fmt.Errorf("unknown apodization window '%s'", window.Name)
+		}
+	}
+	return nil
+}
+
+// coefficients returns the window over length samples
+func (window Window) coefficients(length int) []float64 {
+	if window.Name == "tukey" {
+		return tukeyWindow(length, window.Parameter)
+	}
+
+	coefficients := make([]float64, length)
+	fn := windowFunctions[window.Name]
+	for i := range coefficients {
+		x := 0.5
+		if length > 1 {
+			x = float64(i) / float64(length-1)
+		}
+		coefficients[i] = fn(x, window.Parameter)
+	}
+	return coefficients
+}
+
+// String returns the window as given to `flac -A`, e.g. "tukey(0.5)"
+func (window Window) String() string {
+	switch window.Name {
+	case "tukey", "gauss":
+		return fmt.Sprintf("%s(%s)", window.Name, strconv.FormatFloat(window.Parameter, 'g', -1, 64))
+	}
+	return window.Name
+}
+
+// ParseApodization parses apodization windows separated by semicolons as given to `flac -A`,
+// e.g. "tukey(0.5);hann;gauss(0.2)". The encoder computes the predictors of every window and
+// keeps the best one for each subframe: more windows compress better but encode slower.
+func ParseApodization(spec string) ([]Window, error) {
+	var windows []Window
+	for _, field := range strings.Split(spec, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		name, argument, hasParameter := strings.Cut(field, "(")
+		window := Window{Name: name}
+		if hasParameter {
+			argument, ok := strings.CutSuffix(argument, ")")
+			parameter, err := strconv.ParseFloat(argument, 64)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid apodization window '%s'", field)
+			}
+			window.Parameter = parameter
+		}
+
+		switch {
+		case name == "tukey" && !hasParameter:
+			window.Parameter = DefaultWindow.Parameter
+		case name == "gauss" && !hasParameter:
+			return nil, fmt.Errorf("apodization window gauss needs a parameter, e.g. gauss(0.2)")
+		case name != "tukey" && name != "gauss" && hasParameter:
+			return nil, fmt.Errorf("apodization window %s has no parameter", name)
+		}
+		if err := window.validate(); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no apodization window in '%s'", spec)
+	}
+	if len(windows) > maxApodizationWindows {
+		return nil, fmt.Errorf("too many apodization windows %d, at most %d are allowed", len(windows), maxApodizationWindows)
+	}
+	return windows, nil
+}
+
+// maxApodizationWindows is the largest number of windows tried, as in the reference encoder
+const maxApodizationWindows = 32
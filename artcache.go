@@ -0,0 +1,188 @@
+package flacgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CachedPicture is a picture of a file stored in an ArtworkCache
+type CachedPicture struct {
+	// Picture holds the fields of the picture, its Data is nil
+	Picture *Picture
+	// Hash is the hex SHA-256 of the image data, the name of its file in the cache
+	Hash string
+}
+
+// artworkEntry is the pictures of a file along with the state of the file they were read from
+type artworkEntry struct {
+	ModTime  time.Time
+	Size     int64
+	Pictures []CachedPicture
+}
+
+// ArtworkCache stores the images embedded in files in a directory, once per content: every image is
+// a file named after the SHA-256 of its data, so the thousands of tracks of a library sharing an
+// album cover take a single file. The pictures of every file are remembered by path, modification
+// time and size like MetadataCache does, so unchanged files are not read again. ArtworkCache is an
+// http.Handler serving the images by hash. It's safe for concurrent use.
+type ArtworkCache struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]artworkEntry
+}
+
+// NewArtworkCache returns an empty cache storing the images in dir, created if missing
+func NewArtworkCache(dir string) (*ArtworkCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create artwork cache: %w", err)
+	}
+	return &ArtworkCache{dir: dir, entries: make(map[string]artworkEntry)}, nil
+}
+
+// ReadArtworkCache reads the pictures of the files remembered by a cache written by
+// ArtworkCache.WriteTo, whose images are stored in dir
+func ReadArtworkCache(dir string, r io.Reader) (*ArtworkCache, error) {
+	cache, err := NewArtworkCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := gob.NewDecoder(r).Decode(&cache.entries); err != nil {
+		return nil, fmt.Errorf("unable to decode artwork cache: %w", err)
+	}
+	return cache, nil
+}
+
+// WriteTo writes the pictures of the files remembered by the cache to w with encoding/gob,
+// the images are already in the directory of the cache
+func (cache *ArtworkCache) WriteTo(w io.Writer) (int64, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cache.entries); err != nil {
+		return 0, fmt.Errorf("unable to encode artwork cache: %w", err)
+	}
+	return buf.WriteTo(w)
+}
+
+// Load returns the pictures of the file at path, from the cache if the file didn't change since it
+// was cached. Otherwise the image data of every picture is hashed while streamed from the file and
+// only written to the cache when no image with the same hash is stored yet.
+func (cache *ArtworkCache) Load(path string) ([]CachedPicture, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat file %w", err)
+	}
+
+	cache.mu.Lock()
+	entry, found := cache.entries[path]
+	cache.mu.Unlock()
+	if found && entry.Size == fileInfo.Size() && entry.ModTime.Equal(fileInfo.ModTime()) {
+		return entry.Pictures, nil
+	}
+
+	flac, err := Open(path, WithHeaderOnly())
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	streams, err := flac.StreamPictures()
+	if err != nil {
+		return nil, err
+	}
+	pictures := make([]CachedPicture, 0, len(streams))
+	for _, stream := range streams {
+		hash, err := cache.store(stream.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to cache picture of '%s': %w", path, err)
+		}
+		pictures = append(pictures, CachedPicture{Picture: stream.Picture, Hash: hash})
+	}
+
+	cache.mu.Lock()
+	cache.entries[path] = artworkEntry{ModTime: fileInfo.ModTime(), Size: fileInfo.Size(), Pictures: pictures}
+	cache.mu.Unlock()
+
+	return pictures, nil
+}
+
+// store hashes the image read from data and writes it to the cache unless it's there already,
+// it returns the hash
+func (cache *ArtworkCache) store(data *io.SectionReader) (string, error) {
+	digest := sha256.New()
+	if _, err := io.Copy(digest, io.NewSectionReader(data, 0, data.Size())); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(digest.Sum(nil))
+
+	target := filepath.Join(cache.dir, hash)
+	if _, err := os.Stat(target); err == nil {
+		return hash, nil
+	}
+
+	// Written aside and renamed, so concurrent loads of the same image never see it half written
+	f, err := os.CreateTemp(cache.dir, ".flacgo-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, io.NewSectionReader(data, 0, data.Size()))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), target); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Open opens the image of the given hash
+func (cache *ArtworkCache) Open(hash string) (*os.File, error) {
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+		return nil, fmt.Errorf("invalid artwork hash %q", hash)
+	}
+	return os.Open(filepath.Join(cache.dir, hash))
+}
+
+// Forget removes path from the cache, its images stay stored since other files may share them
+func (cache *ArtworkCache) Forget(path string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.entries, path)
+}
+
+// ServeHTTP serves the image whose hash is the last element of the request path, e.g. mounted with
+// http.StripPrefix("/art/", cache). Images never change for a hash, so they are served with the hash
+// as ETag and cached by clients for a year.
+func (cache *ArtworkCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hash := path.Base(r.URL.Path)
+	f, err := cache.Open(hash)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "unable to read artwork", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, hash, info.ModTime(), f)
+}
@@ -0,0 +1,289 @@
+package flacgo
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// SharedArtwork is an album whose tracks all embed the same cover picture
+type SharedArtwork struct {
+	Dir    string
+	Tracks []string
+	// Cover holds the fields of the cover picture of the first track, its Data is nil
+	Cover *Picture
+	// Size is the size of the image data embedded in every track
+	Size int64
+	Hash [32]byte
+}
+
+// Waste returns the bytes taken by the copies of the cover beyond the first
+func (art *SharedArtwork) Waste() int64 {
+	return int64(len(art.Tracks)-1) * art.Size
+}
+
+// FindSharedArtwork walks the folders under root for albums of at least two tracks all embedding the
+// same cover picture of at least minSize bytes, the largest waste first.
+func FindSharedArtwork(root string, minSize int64) ([]*SharedArtwork, error) {
+	var albums []*SharedArtwork
+	err := filepath.WalkDir(root, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+
+		paths, err := listFLACFiles(dir)
+		if err != nil {
+			return err
+		}
+		if len(paths) < 2 {
+			return nil
+		}
+		album, err := readSharedArtwork(dir, paths)
+		if err != nil {
+			return err
+		}
+		if album != nil && album.Size >= minSize {
+			albums = append(albums, album)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan '%s': %w", root, err)
+	}
+
+	slices.SortStableFunc(albums, func(a, b *SharedArtwork) int {
+		return cmp.Compare(b.Waste(), a.Waste())
+	})
+	return albums, nil
+}
+
+// readSharedArtwork returns the cover shared by the tracks at paths, nil if a track has none or
+// a different one
+func readSharedArtwork(dir string, paths []string) (*SharedArtwork, error) {
+	album := &SharedArtwork{Dir: dir, Tracks: paths}
+	for i, path := range paths {
+		cover, err := readCoverHeaderOnly(path)
+		if err != nil {
+			return nil, err
+		}
+		if cover == nil {
+			return nil, nil
+		}
+		hash := cover.Hash()
+		if i == 0 {
+			album.Hash, album.Size = hash, int64(len(cover.Data))
+			cover.Data = nil
+			album.Cover = cover
+		} else if hash != album.Hash {
+			return nil, nil
+		}
+	}
+	return album, nil
+}
+
+// readCoverHeaderOnly returns the cover picture of the file at path, reading nothing but its metadata
+func readCoverHeaderOnly(path string) (*Picture, error) {
+	flac, err := Open(path, WithHeaderOnly())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", path, err)
+	}
+	defer flac.Close()
+	return flac.CoverPicture()
+}
+
+// ShareArtworkOptions selects what ShareArtwork does with a shared cover
+type ShareArtworkOptions struct {
+	// External writes the cover once next to the tracks, as FolderName plus the extension of the image
+	// type, then the embedded covers are replaced by copies scaled to MaxDimension or removed if it's 0
+	External   bool
+	FolderName string
+	// MaxDimension is the largest width or height of the embedded covers, scaled down keeping their
+	// aspect ratio and encoded as JPEG of the given Quality, jpeg.DefaultQuality if 0
+	MaxDimension int
+	Quality      int
+	// DryRun reports the savings without writing anything
+	DryRun bool
+}
+
+// ShareArtworkResult is the outcome of ShareArtwork on an album
+type ShareArtworkResult struct {
+	// Image is the path of the external image written, if any
+	Image   string
+	Results []TransformResult
+	// Saved is the number of bytes freed, the size of the external image deducted
+	Saved int64
+}
+
+// ShareArtwork shrinks the cover shared by the tracks of an album found by FindSharedArtwork: the
+// embedded covers are scaled down and, with opts.External, the original is kept once as folder image.
+// Tracks are rewritten whole so the space freed is given back instead of becoming padding. A track
+// whose cover changed since the album was found is left untouched and reported in the results.
+func ShareArtwork(album *SharedArtwork, opts ShareArtworkOptions) (*ShareArtworkResult, error) {
+	if !opts.External && opts.MaxDimension <= 0 {
+		return nil, fmt.Errorf("unable to share artwork: nothing to do without an external image or a maximum dimension")
+	}
+	if opts.FolderName == "" {
+		opts.FolderName = DefaultFolderArtName
+	}
+
+	cover, err := readSharedCover(album)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedded []byte
+	if opts.MaxDimension > 0 {
+		if embedded, err = resizeJPEG(cover.Data, opts.MaxDimension, opts.Quality); err != nil {
+			return nil, fmt.Errorf("unable to scale the cover of '%s': %w", album.Dir, err)
+		}
+		if embedded == nil && !opts.External {
+			return nil, fmt.Errorf("the cover of '%s' is %dx%d already", album.Dir, cover.Width, cover.Height)
+		}
+		if embedded != nil && len(embedded) >= len(cover.Data) {
+			return nil, fmt.Errorf("the scaled cover of '%s' is not smaller than the original", album.Dir)
+		}
+	}
+
+	result := &ShareArtworkResult{}
+	if opts.External {
+		result.Image = filepath.Join(album.Dir, opts.FolderName+imageExtension(cover.MimeType))
+		written, err := writeFolderImage(result.Image, cover.Data, opts.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		if written {
+			result.Saved -= int64(len(cover.Data))
+		}
+	}
+
+	change := TagChange{Key: AlbumArtworkKey, Old: fmt.Sprintf("%dx%d", cover.Width, cover.Height)}
+	if embedded == nil && opts.External {
+		change.Removed = true
+	} else if embedded == nil {
+		return result, nil
+	} else {
+		config, _, err := image.DecodeConfig(bytes.NewReader(embedded))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode picture: %w", err)
+		}
+		change.New = fmt.Sprintf("%dx%d", config.Width, config.Height)
+	}
+
+	for _, path := range album.Tracks {
+		saved, err := shareTrackArtwork(path, album.Hash, embedded, opts.DryRun)
+		trackResult := TransformResult{Path: path, Err: err}
+		if err == nil {
+			trackResult.Changes = []TagChange{change}
+			result.Saved += saved
+		}
+		result.Results = append(result.Results, trackResult)
+	}
+	return result, nil
+}
+
+// readSharedCover returns the shared cover of album along with its data, read from its first track
+func readSharedCover(album *SharedArtwork) (*Picture, error) {
+	flac, err := Open(album.Tracks[0], WithHeaderOnly())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", album.Tracks[0], err)
+	}
+	defer flac.Close()
+
+	cover, err := flac.CoverPicture()
+	if err != nil {
+		return nil, err
+	}
+	if cover == nil || cover.Hash() != album.Hash {
+		return nil, fmt.Errorf("the cover of '%s' changed since it was scanned", album.Tracks[0])
+	}
+	return cover, nil
+}
+
+// writeFolderImage writes data to path unless the same image is there already, it reports whether
+// the file was written, or would be with dryRun. A different existing image is an error wrapping fs.ErrExist.
+func writeFolderImage(path string, data []byte, dryRun bool) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err == nil {
+		if bytes.Equal(existing, data) {
+			return false, nil
+		}
+		return false, fmt.Errorf("'%s': %w", path, fs.ErrExist)
+	}
+	if !os.IsNotExist(err) {
+		return false, fmt.Errorf("unable to read '%s': %w", path, err)
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return false, fmt.Errorf("unable to create '%s': %w", path, err)
+	}
+	return true, nil
+}
+
+// shareTrackArtwork replaces the cover of the file at path by embedded, or removes it if embedded is
+// nil, and returns the number of bytes saved. The file is rewritten whole, through a temporary file.
+func shareTrackArtwork(path string, hash [32]byte, embedded []byte, dryRun bool) (int64, error) {
+	flac, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer flac.Close()
+
+	cover, err := flac.CoverPicture()
+	if err != nil {
+		return 0, err
+	}
+	if cover == nil || cover.Hash() != hash {
+		return 0, fmt.Errorf("the cover changed since it was scanned")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to stat file %w", err)
+	}
+	if embedded == nil {
+		err = flac.RemoveCoverPicture(false)
+	} else {
+		err = flac.SetCoverPictureFromBytes(embedded)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if dryRun {
+		blocks, err := flac.outputBlocks()
+		if err != nil {
+			return 0, err
+		}
+		return flac.audioOffset - 4 - blocksSize(blocks), nil
+	}
+
+	// Saving over the file would turn the space freed into padding
+	temp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".flacgo-art")
+	defer os.Remove(temp)
+	if err := flac.Save(&temp); err != nil {
+		return 0, fmt.Errorf("unable to save: %w", err)
+	}
+	flac.Close()
+	if err := os.Chmod(temp, info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		return 0, fmt.Errorf("unable to replace '%s': %w", path, err)
+	}
+
+	saved, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to stat file %w", err)
+	}
+	return info.Size() - saved.Size(), nil
+}
@@ -0,0 +1,100 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// JPEGEncoding describes how a JPEG image is encoded, as far as players are concerned
+type JPEGEncoding struct {
+	// Progressive is set for images encoded in several scans, which many car stereos and DAPs can't render
+	Progressive bool
+	// CMYK is set for images of four components, CMYK or YCCK, made for print and rendered with
+	// wrong colors or not at all by most players
+	CMYK bool
+}
+
+// ReadJPEGEncoding reads the frame header of a JPEG image. It fails if data is not a JPEG image.
+func ReadJPEGEncoding(data []byte) (JPEGEncoding, error) {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return JPEGEncoding{}, fmt.Errorf("not a JPEG image")
+	}
+
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return JPEGEncoding{}, fmt.Errorf("invalid JPEG segment at offset %d", offset)
+		}
+		marker := data[offset+1]
+		if marker == 0xFF {
+			offset++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+
+		// Start of frame markers, but DHT (C4), JPG (C8) and DAC (CC)
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			if offset+10 > len(data) {
+				break
+			}
+			return JPEGEncoding{
+				Progressive: marker == 0xC2 || marker == 0xC6 || marker == 0xCA || marker == 0xCE,
+				CMYK:        data[offset+9] == 4,
+			}, nil
+		}
+		if marker == 0xDA {
+			break
+		}
+		offset += 2 + length
+	}
+	return JPEGEncoding{}, fmt.Errorf("missing JPEG frame header")
+}
+
+// BaselineJPEG returns a progressive or CMYK JPEG image re-encoded as a baseline RGB JPEG image of the
+// given quality (jpeg.DefaultQuality if 0), which every player can render. CMYK colors are converted
+// without color profile. It returns nil if the image is neither progressive nor CMYK.
+func BaselineJPEG(data []byte, quality int) ([]byte, error) {
+	encoding, err := ReadJPEGEncoding(data)
+	if err != nil {
+		return nil, err
+	}
+	if !encoding.Progressive && !encoding.CMYK {
+		return nil, nil
+	}
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode picture: %w", err)
+	}
+	if encoding.CMYK {
+		bounds := img.Bounds()
+		rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+		img = rgba
+	}
+
+	// The standard library only writes baseline JPEG images
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("unable to encode picture: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertArtworkToBaseline stages the progressive and CMYK JPEG pictures stored in the file re-encoded
+// with BaselineJPEG, keeping their type, description and order, and returns how many were converted.
+func (flac *Flac) ConvertArtworkToBaseline(quality int) (int, error) {
+	return flac.restagePictures(func(picture *Picture) ([]byte, error) {
+		if !bytes.HasPrefix(picture.Data, []byte{0xFF, 0xD8}) {
+			return nil, nil
+		}
+		return BaselineJPEG(picture.Data, quality)
+	})
+}
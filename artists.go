@@ -0,0 +1,163 @@
+package flacgo
+
+import (
+	"strings"
+)
+
+// DefaultArtistSeparators are the separators SplitArtists splits artist credits on, matched ignoring case.
+// "&", "and" and "/" without spaces are left out since they are part of many band names, like "AC/DC".
+var DefaultArtistSeparators = []string{" feat. ", " feat ", " ft. ", " featuring ", ";", " / "}
+
+// DefaultArtistJoiner is the separator JoinArtists puts between the artists
+const DefaultArtistJoiner = "; "
+
+// artistsTags pairs the artist tags with the multi-valued tags Picard uses to list every artist
+var artistsTags = [][2]string{
+	{"ARTIST", "ARTISTS"},
+	{"ALBUMARTIST", "ALBUMARTISTS"},
+}
+
+// SplitArtistCredit splits an artist credit like "Artist A feat. Artist B" into the single artists,
+// using DefaultArtistSeparators if separators is nil. Empty names and duplicates are dropped.
+func SplitArtistCredit(credit string, separators []string) []string {
+	if separators == nil {
+		separators = DefaultArtistSeparators
+	}
+
+	artists := make([]string, 0)
+	seen := make(map[string]bool)
+	for rest := credit; ; {
+		// Cut the credit at the earliest separator, the longest one winning on ties
+		cut, length := -1, 0
+		for _, separator := range separators {
+			if index := indexFold(rest, separator); index >= 0 && (cut < 0 || index < cut || index == cut && len(separator) > length) {
+				cut, length = index, len(separator)
+			}
+		}
+
+		artist := rest
+		if cut >= 0 {
+			artist = rest[:cut]
+		}
+		if artist = strings.TrimSpace(artist); artist != "" && !seen[strings.ToLower(artist)] {
+			seen[strings.ToLower(artist)] = true
+			artists = append(artists, artist)
+		}
+
+		if cut < 0 {
+			return artists
+		}
+		rest = rest[cut+length:]
+	}
+}
+
+// indexFold is like strings.Index but ignores the case of ASCII letters
+func indexFold(s string, substr string) int {
+	if substr == "" {
+		return -1
+	}
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SplitArtistsTransform returns a Transform splitting the ARTIST and ALBUMARTIST credits on separators
+// into one comment per artist, also listing them in the ARTISTS and ALBUMARTISTS tags like Picard does.
+// Tags crediting a single artist are left alone.
+func SplitArtistsTransform(separators []string) Transform {
+	return TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		for _, tags := range artistsTags {
+			key, listKey := tags[0], tags[1]
+			var artists []string
+			seen := make(map[string]bool)
+			for _, comment := range comments {
+				if !strings.EqualFold(comment.Title, key) {
+					continue
+				}
+				for _, artist := range SplitArtistCredit(comment.Value, separators) {
+					if !seen[strings.ToLower(artist)] {
+						seen[strings.ToLower(artist)] = true
+						artists = append(artists, artist)
+					}
+				}
+			}
+			if len(artists) < 2 {
+				continue
+			}
+
+			comments = replaceValues(comments, key, artists)
+			comments = replaceValues(comments, listKey, artists)
+		}
+		return comments
+	})
+}
+
+// JoinArtistsTransform returns a Transform joining the values of the ARTIST and ALBUMARTIST tags into a
+// single credit, separated by joiner or DefaultArtistJoiner if empty, for players showing only the first
+// value. The single artists are kept in the ARTISTS and ALBUMARTISTS tags.
+func JoinArtistsTransform(joiner string) Transform {
+	if joiner == "" {
+		joiner = DefaultArtistJoiner
+	}
+
+	return TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		for _, tags := range artistsTags {
+			key, listKey := tags[0], tags[1]
+			var artists []string
+			for _, comment := range comments {
+				if strings.EqualFold(comment.Title, key) {
+					artists = append(artists, comment.Value)
+				}
+			}
+			if len(artists) < 2 {
+				continue
+			}
+
+			comments = replaceValues(comments, listKey, artists)
+			comments = replaceValues(comments, key, []string{strings.Join(artists, joiner)})
+		}
+		return comments
+	})
+}
+
+// replaceValues replaces the comments with the given key by one comment per value, at the position of
+// the first one and keeping its spelling, or at the end if there are none
+func replaceValues(comments []VorbisComment, key string, values []string) []VorbisComment {
+	replaced := make([]VorbisComment, 0, len(comments)+len(values))
+	title, found := key, false
+	emit := func() {
+		for _, value := range values {
+			replaced = append(replaced, VorbisComment{Title: title, Value: value})
+		}
+	}
+
+	for _, comment := range comments {
+		if !strings.EqualFold(comment.Title, key) {
+			replaced = append(replaced, comment)
+			continue
+		}
+		if !found {
+			title, found = comment.Title, true
+			emit()
+		}
+	}
+	if !found {
+		emit()
+	}
+	return replaced
+}
+
+// SplitArtists stages one ARTIST (and ALBUMARTIST) comment per artist of the credits, listing them
+// in ARTISTS (and ALBUMARTISTS) too, see SplitArtistsTransform. It returns the changes sorted by key.
+func (flac *Flac) SplitArtists(separators []string) ([]TagChange, error) {
+	return flac.ApplyTransform(SplitArtistsTransform(separators))
+}
+
+// JoinArtists stages a single ARTIST (and ALBUMARTIST) credit joining their values, see JoinArtistsTransform.
+// It returns the changes sorted by key.
+func (flac *Flac) JoinArtists(joiner string) ([]TagChange, error) {
+	return flac.ApplyTransform(JoinArtistsTransform(joiner))
+}
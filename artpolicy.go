@@ -0,0 +1,107 @@
+package flacgo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// ArtworkPolicy lists the requirements embedded pictures are checked against, zero values disable a check.
+// File icons are exempt since the format fixes their size.
+type ArtworkPolicy struct {
+	// MinWidth and MinHeight are the smallest accepted size in pixels
+	MinWidth  int
+	MinHeight int
+	// MaxAspectRatio is the largest accepted ratio between the longest and the shortest side, e.g. 1.1
+	MaxAspectRatio float64
+	// MimeTypes are the accepted MIME types, any type is accepted if empty
+	MimeTypes []string
+	// MaxSize is the largest accepted image in bytes
+	MaxSize int
+	// Baseline rejects progressive JPEG images and RGB rejects CMYK JPEG images, which many car stereos
+	// and DAPs can't render, see BaselineJPEG
+	Baseline bool
+	RGB      bool
+}
+
+// DefaultArtworkPolicy accepts JPEG and PNG pictures of at least 500x500, nearly square and up to 4 MB,
+// JPEG pictures being baseline RGB
+var DefaultArtworkPolicy = ArtworkPolicy{
+	MinWidth:       500,
+	MinHeight:      500,
+	MaxAspectRatio: 1.1,
+	MimeTypes:      []string{"image/jpeg", "image/png"},
+	MaxSize:        4 * 1000 * 1000,
+	Baseline:       true,
+	RGB:            true,
+}
+
+// WithArtworkPolicy makes Validate check the embedded pictures against policy
+func WithArtworkPolicy(policy ArtworkPolicy) Option {
+	return func(o *options) {
+		o.artworkPolicy = &policy
+	}
+}
+
+// Check returns the issues of picture against the policy, all of them warnings
+func (policy ArtworkPolicy) Check(picture *Picture) []Issue {
+	var issues []Issue
+	if picture.PictureType == PictureTypeFileIcon || picture.PictureType == PictureTypeOtherFileIcon {
+		return issues
+	}
+	add := func(code string, format string, args ...any) {
+		message := fmt.Sprintf("%s picture %s", PictureTypeName(picture.PictureType), fmt.Sprintf(format, args...))
+		issues = append(issues, Issue{SeverityWarning, code, message})
+	}
+
+	if len(policy.MimeTypes) > 0 && !containsIgnoreCase(policy.MimeTypes, picture.MimeType) {
+		add("art-mime-type", "has MIME type %q, expected %s", picture.MimeType, strings.Join(policy.MimeTypes, " or "))
+	}
+	if policy.MaxSize > 0 && len(picture.Data) > policy.MaxSize {
+		add("large-art", "is %d bytes, above %d", len(picture.Data), policy.MaxSize)
+	}
+
+	if policy.Baseline || policy.RGB {
+		if encoding, err := ReadJPEGEncoding(picture.Data); err == nil {
+			if policy.Baseline && encoding.Progressive {
+				add("progressive-art", "is a progressive JPEG image, which some players can't render")
+			}
+			if policy.RGB && encoding.CMYK {
+				add("cmyk-art", "is a CMYK JPEG image, which most players render wrong")
+			}
+		}
+	}
+
+	width, height := int(picture.Width), int(picture.Height)
+	// Prefer the real image size since some taggers don't fill the header fields correctly
+	if config, _, err := image.DecodeConfig(bytes.NewReader(picture.Data)); err == nil {
+		width, height = config.Width, config.Height
+	}
+	if width < policy.MinWidth || height < policy.MinHeight {
+		add("low-res-art", "is %dx%d, below %dx%d", width, height, policy.MinWidth, policy.MinHeight)
+	}
+	if policy.MaxAspectRatio > 0 && width > 0 && height > 0 {
+		ratio := float64(max(width, height)) / float64(min(width, height))
+		if ratio > policy.MaxAspectRatio {
+			add("non-square-art", "is %dx%d, its aspect ratio %.2f is above %.2f", width, height, ratio, policy.MaxAspectRatio)
+		}
+	}
+
+	return issues
+}
+
+// ValidateArtwork checks all the pictures stored in the file against policy.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) ValidateArtwork(policy ArtworkPolicy) ([]Issue, error) {
+	pictures, err := flac.Pictures()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	for _, picture := range pictures {
+		issues = append(issues, policy.Check(picture)...)
+	}
+	return issues, nil
+}
@@ -0,0 +1,443 @@
+package flacgo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConvertOptions configures ConvertTree
+type ConvertOptions struct {
+	// Encoder configures the encoding, its Comments are added to every file replacing the tags with the same keys
+	Encoder EncoderOptions
+	// Overwrite re-encodes files whose destination already exists and is newer than the source
+	Overwrite bool
+	// Workers is the number of files encoded in parallel, the number of CPUs if 0
+	Workers int
+	// Progress is called after each file in walk order, from a single goroutine
+	Progress func(ConvertResult)
+	// Journal, if set, records the sources converted or up to date, and the sources it reports as
+	// completed are skipped without looking at their destination
+	Journal Journal
+}
+
+// ConvertResult is the outcome of converting a single file
+type ConvertResult struct {
+	Source      string
+	Destination string
+	// Skipped is set when the destination was already up to date
+	Skipped bool
+	Err     error
+	// InputSize and OutputSize are file sizes in bytes
+	InputSize  int64
+	OutputSize int64
+	// Audio is the duration of the audio
+	Audio   time.Duration
+	Elapsed time.Duration
+}
+
+// ConvertReport sums up a ConvertTree run
+type ConvertReport struct {
+	Results   []ConvertResult
+	Converted int
+	Skipped   int
+	Failed    int
+	// InputBytes, OutputBytes and Audio only account for converted files
+	InputBytes  int64
+	OutputBytes int64
+	Audio       time.Duration
+	Elapsed     time.Duration
+	// Canceled are the sources not converted because the context of ConvertTreeContext was canceled
+	Canceled []string
+}
+
+// Throughput returns the number of input bytes encoded per second
+func (report *ConvertReport) Throughput() float64 {
+	if report.Elapsed <= 0 {
+		return 0
+	}
+	return float64(report.InputBytes) / report.Elapsed.Seconds()
+}
+
+// Speed returns how many times faster than real time the audio was encoded
+func (report *ConvertReport) Speed() float64 {
+	if report.Elapsed <= 0 {
+		return 0
+	}
+	return report.Audio.Seconds() / report.Elapsed.Seconds()
+}
+
+func (report *ConvertReport) String() string {
+	ratio := 0.0
+	if report.InputBytes > 0 {
+		ratio = float64(report.OutputBytes) * 100 / float64(report.InputBytes)
+	}
+	canceled := ""
+	if len(report.Canceled) > 0 {
+		canceled = fmt.Sprintf(", %d canceled", len(report.Canceled))
+	}
+	return fmt.Sprintf("%d converted, %d skipped, %d failed%s in %s: %s/s, %.1fx real time, output is %.1f%% of input",
+		report.Converted, report.Skipped, report.Failed, canceled, report.Elapsed.Round(time.Millisecond),
+		formatSize(int64(report.Throughput())), report.Speed(), ratio)
+}
+
+// Sidecar files looked up next to the sources, see ConvertTree
+var (
+	sidecarAlbumTags = "album.tags"
+	sidecarArtNames  = []string{"cover", "folder", "front"}
+	sidecarArtExts   = []string{".jpg", ".jpeg", ".png"}
+)
+
+// ConvertTree walks src and encodes every WAVE and AIFF file to FLAC under dst, keeping the relative paths.
+// The audio is encoded as it's read, the sources are never held in memory.
+//
+// Tags are read from the LIST INFO or text chunks of the sources and from sidecar files with one
+// KEY=VALUE per line, as written by metaflac --export-tags-to: "album.tags" applies to every file of
+// its folder and "<name>.tags" to "<name>.wav" only, a key set by a later source replaces the earlier values.
+// The front cover is read from "<name>.jpg" or "<name>.png", or from a cover, folder or front image of the folder.
+//
+// Failures of single files are reported in the results, the returned error is only set when src can't be walked.
+func ConvertTree(src string, dst string, opts ConvertOptions) (*ConvertReport, error) {
+	return ConvertTreeContext(context.Background(), src, dst, opts)
+}
+
+// ConvertTreeContext is ConvertTree stopping when ctx is canceled: the files being encoded are
+// finished and the ones not started yet are listed in the Canceled field of the report, returned
+// along with an error wrapping ctx.Err().
+func ConvertTreeContext(ctx context.Context, src string, dst string, opts ConvertOptions) (*ConvertReport, error) {
+	// A journal failure cancels the files left
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var journalErr error
+
+	var sources []string
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && isConvertible(path) {
+			sources = append(sources, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to walk '%s': %w", src, err)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	// Every file gets its own channel so results are reported in order as soon as they are ready,
+	// nil for the files skipped once ctx is canceled
+	done := make([]chan *ConvertResult, len(sources))
+	for i := range done {
+		done[i] = make(chan *ConvertResult, 1)
+	}
+
+	started := time.Now()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					done[i] <- nil
+					continue
+				}
+				result := convertSource(src, sources[i], dst, opts)
+				done[i] <- &result
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range sources {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				for ; i < len(sources); i++ {
+					done[i] <- nil
+				}
+				return
+			}
+		}
+	}()
+
+	report := &ConvertReport{Results: make([]ConvertResult, 0, len(sources))}
+	for i := range sources {
+		converted := <-done[i]
+		if converted == nil {
+			report.Canceled = append(report.Canceled, sources[i])
+			continue
+		}
+		result := *converted
+		report.Results = append(report.Results, result)
+
+		switch {
+		case result.Err != nil:
+			report.Failed++
+		case result.Skipped:
+			report.Skipped++
+		default:
+			report.Converted++
+			report.InputBytes += result.InputSize
+			report.OutputBytes += result.OutputSize
+			report.Audio += result.Audio
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(result)
+		}
+
+		if opts.Journal != nil && result.Err == nil && journalErr == nil {
+			if journalErr = opts.Journal.Record(result.Source); journalErr != nil {
+				cancel()
+			}
+		}
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(started)
+
+	if journalErr != nil {
+		return report, journalErr
+	}
+	if len(report.Canceled) > 0 {
+		return report, fmt.Errorf("conversion canceled, %d of %d files left: %w", len(report.Canceled), len(sources), ctx.Err())
+	}
+	return report, nil
+}
+
+// isConvertible reports whether ConvertTree encodes the file at path
+func isConvertible(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".wave", ".aif", ".aiff", ".aifc":
+		return true
+	}
+	return false
+}
+
+// convertSource encodes the file at path, found under root, to the same relative path under dst
+func convertSource(root string, path string, dst string, opts ConvertOptions) ConvertResult {
+	started := time.Now()
+	result := ConvertResult{Source: path}
+
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Destination = filepath.Join(dst, strings.TrimSuffix(relative, filepath.Ext(relative))+".flac")
+
+	if opts.Journal != nil && opts.Journal.Completed(path) {
+		result.Skipped = true
+		return result
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.InputSize = info.Size()
+
+	if !opts.Overwrite {
+		if existing, err := os.Stat(result.Destination); err == nil && !existing.ModTime().Before(info.ModTime()) {
+			result.Skipped = true
+			result.OutputSize = existing.Size()
+			return result
+		}
+	}
+
+	result.OutputSize, result.Audio, result.Err = encodeSource(path, result.Destination, opts.Encoder)
+	result.Elapsed = time.Since(started)
+
+	return result
+}
+
+// encodeSource encodes the file at path along with its sidecars to destination,
+// it returns the size of the output and the duration of the audio. The audio is encoded as it's read
+// to a temporary file next to destination, renamed once the tags and cover are set.
+func encodeSource(path string, destination string, opts EncoderOptions) (int64, time.Duration, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return 0, 0, err
+	}
+	out, err := os.CreateTemp(filepath.Dir(destination), ".flacgo-*")
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to create file '%s': %w", destination, err)
+	}
+	tempName := out.Name()
+	defer os.Remove(tempName)
+
+	// The tags are only all known once every chunk is read, they are set after encoding
+	encoderOpts := opts
+	encoderOpts.Comments = []VorbisComment{}
+	var comments []VorbisComment
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".aif", ".aiff", ".aifc":
+		comments, err = readAIFFChunks(bufio.NewReader(in), func(format *aiffFormat, data io.Reader, _ []VorbisComment) (bool, error) {
+			return true, encodeAIFFData(data, out, format, encoderOpts)
+		})
+	default:
+		r := bufio.NewReader(in)
+		comments, err = readWAVChunks(r, func(format *wavFormat, size uint32, _ []VorbisComment) (bool, error) {
+			return true, encodeWAVData(r, out, format, size, encoderOpts)
+		})
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, sidecar := range []string{filepath.Join(filepath.Dir(path), sidecarAlbumTags), base + ".tags"} {
+		tags, err := readSidecarTags(sidecar)
+		if err != nil {
+			return 0, 0, err
+		}
+		comments = mergeComments(comments, tags)
+	}
+	comments = mergeComments(comments, opts.Comments)
+
+	audio, err := tagEncodedSource(tempName, comments, findSidecarArt(path))
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.Chmod(tempName, 0644); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tempName, destination); err != nil {
+		return 0, 0, fmt.Errorf("unable to create file '%s': %w", destination, err)
+	}
+
+	info, err := os.Stat(destination)
+	if err != nil {
+		return 0, 0, err
+	}
+	return info.Size(), audio, nil
+}
+
+// tagEncodedSource sets comments and the front cover art, if any, on the FLAC file at path
+// and returns the duration of its audio
+func tagEncodedSource(path string, comments []VorbisComment, art string) (time.Duration, error) {
+	flac, err := Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open encoded stream: %w", err)
+	}
+	defer flac.Close()
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := flac.ApplyTransform(TransformFunc(func([]VorbisComment) []VorbisComment {
+		return comments
+	})); err != nil {
+		return 0, err
+	}
+	if art != "" {
+		if err := flac.SetCoverPictureFromPath(art); err != nil {
+			return 0, fmt.Errorf("unable to embed '%s': %w", art, err)
+		}
+	}
+	if err := flac.Save(nil); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(float64(streamInfo.TotalSamples) / float64(streamInfo.SampleRate) * float64(time.Second)), nil
+}
+
+// readSidecarTags reads a file of KEY=VALUE lines, a missing file has no tags
+func readSidecarTags(path string) ([]VorbisComment, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []VorbisComment
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		title, value, found := strings.Cut(line, "=")
+		if !found || title == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		comments = append(comments, VorbisComment{Title: strings.ToUpper(title), Value: value})
+	}
+
+	return comments, nil
+}
+
+// mergeComments returns comments with every key found in overrides replaced by its values there
+func mergeComments(comments []VorbisComment, overrides []VorbisComment) []VorbisComment {
+	if len(overrides) == 0 {
+		return comments
+	}
+
+	replaced := make(map[string]bool)
+	for _, comment := range overrides {
+		replaced[strings.ToUpper(comment.Title)] = true
+	}
+
+	merged := make([]VorbisComment, 0, len(comments)+len(overrides))
+	for _, comment := range comments {
+		if !replaced[strings.ToUpper(comment.Title)] {
+			merged = append(merged, comment)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// findSidecarArt returns the image to embed as front cover of the file at path, if any
+func findSidecarArt(path string) string {
+	base := filepath.Base(path)
+	return findFolderImage(filepath.Dir(path), append([]string{strings.TrimSuffix(base, filepath.Ext(base))}, sidecarArtNames...))
+}
+
+// findFolderImage returns the first image of dir named after one of the candidates, if any.
+// Names are matched ignoring case, so "Folder.JPG" is found too.
+func findFolderImage(dir string, candidates []string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			files[strings.ToLower(entry.Name())] = entry.Name()
+		}
+	}
+
+	for _, candidate := range candidates {
+		for _, ext := range sidecarArtExts {
+			if name, found := files[strings.ToLower(candidate+ext)]; found {
+				return filepath.Join(dir, name)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package flacgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// FrameStat describes the size of an audio frame
+type FrameStat struct {
+	// Offset of the frame from the beginning of the file
+	Offset int64 `json:"offset"`
+	// Length of the encoded frame in bytes
+	Length    int `json:"length"`
+	BlockSize int `json:"block_size"`
+	// Start is the position of the first sample of the frame
+	Start uint64 `json:"start"`
+	// Bitrate is the bitrate of the frame in bits per second
+	Bitrate float64 `json:"bitrate"`
+}
+
+// BitrateBucket is the bitrate over an interval of the audio
+type BitrateBucket struct {
+	Start    time.Duration
+	Duration time.Duration
+	// Bitrate is the average bitrate in bits per second
+	Bitrate float64
+}
+
+// FrameStats decodes the audio and returns the size, block size and bitrate of every frame in order,
+// e.g. to plot a bitrate graph or spot frames the encoder couldn't compress
+func (flac *Flac) FrameStats() ([]FrameStat, error) {
+	var stats []FrameStat
+	var start uint64
+	err := flac.forEachFrame(func(frame *Frame) error {
+		stat := FrameStat{
+			Offset:    frame.Header.Offset,
+			Length:    frame.Length,
+			BlockSize: frame.Header.BlockSize,
+			Start:     start,
+		}
+		if frame.Header.SampleRate > 0 {
+			stat.Bitrate = float64(frame.Length*8) * float64(frame.Header.SampleRate) / float64(frame.Header.BlockSize)
+		}
+		stats = append(stats, stat)
+		start += uint64(frame.Header.BlockSize)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// BucketBitrate returns the bitrate of frames, as returned by FrameStats, over intervals of the given
+// duration of audio at sampleRate. Frames spanning several intervals are split between them in
+// proportion to their samples, so intervals can be shorter than a frame. The last one can be shorter.
+func BucketBitrate(frames []FrameStat, sampleRate uint32, interval time.Duration) ([]BitrateBucket, error) {
+	if sampleRate == 0 {
+		return nil, fmt.Errorf("invalid sample rate 0")
+	}
+	bucketSamples := uint64(interval.Seconds() * float64(sampleRate))
+	if bucketSamples == 0 {
+		return nil, fmt.Errorf("interval %s is shorter than a sample", interval)
+	}
+	if len(frames) == 0 {
+		return nil, nil
+	}
+
+	last := frames[len(frames)-1]
+	total := last.Start + uint64(last.BlockSize)
+	bits := make([]float64, (total+bucketSamples-1)/bucketSamples)
+	for _, frame := range frames {
+		end := frame.Start + uint64(frame.BlockSize)
+		perSample := float64(frame.Length*8) / float64(frame.BlockSize)
+		for start := frame.Start; start < end; {
+			index := start / bucketSamples
+			next := min(end, (index+1)*bucketSamples)
+			bits[index] += perSample * float64(next-start)
+			start = next
+		}
+	}
+
+	toDuration := func(samples uint64) time.Duration {
+		return time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second))
+	}
+	buckets := make([]BitrateBucket, len(bits))
+	for i := range buckets {
+		start := uint64(i) * bucketSamples
+		samples := min(bucketSamples, total-start)
+		buckets[i] = BitrateBucket{
+			Start:    toDuration(start),
+			Duration: toDuration(samples),
+			Bitrate:  bits[i] * float64(sampleRate) / float64(samples),
+		}
+	}
+
+	return buckets, nil
+}
@@ -0,0 +1,154 @@
+package flacgo
+
+import (
+	"io"
+	"math/bits"
+)
+
+// bitReader reads big-endian bit fields from a stream, keeping the CRCs of the bytes read so far
+type bitReader struct {
+	// r fills buf, nil when buf holds the whole stream
+	r   io.Reader
+	buf []byte
+	// pos is the next byte of buf to load in the cache
+	pos int
+	// cache holds the bits read from the stream but not consumed yet in its lowest n bits
+	cache uint64
+	n     uint
+	// The CRCs are computed in bulk: they cover the loaded bytes up to crc8Pos and crc16Pos,
+	// crc8Pos is -1 once the CRC-8 of the frame header was taken
+	crc8     uint8
+	crc8Pos  int
+	crc16    uint16
+	crc16Pos int
+	// consumed is the number of bytes read from the stream
+	consumed int64
+}
+
+func newBitReader(r io.Reader) *bitReader {
+	return &bitReader{r: r, buf: make([]byte, 0, 64*1024)}
+}
+
+// newBitReaderBytes returns a bitReader reading data in place
+func newBitReaderBytes(data []byte) *bitReader {
+	return &bitReader{buf: data}
+}
+
+// resetCRC restarts the CRC computation, it's called at the beginning of every frame
+func (br *bitReader) resetCRC() {
+	br.crc8, br.crc8Pos = 0, br.pos
+	br.crc16, br.crc16Pos = 0, br.pos
+}
+
+// updateCRC adds the bytes loaded since the last update to the CRCs
+func (br *bitReader) updateCRC() {
+	if br.crc8Pos >= 0 {
+		br.crc8 = updateCRC8(br.crc8, br.buf[br.crc8Pos:br.pos])
+		br.crc8Pos = br.pos
+	}
+	br.crc16 = updateCRC16(br.crc16, br.buf[br.crc16Pos:br.pos])
+	br.crc16Pos = br.pos
+}
+
+// headerCRC returns the CRC-8 of the bytes loaded since resetCRC and stops computing it until the next frame
+func (br *bitReader) headerCRC() uint8 {
+	br.updateCRC()
+	br.crc8Pos = -1
+	return br.crc8
+}
+
+// frameCRC returns the CRC-16 of the bytes loaded since resetCRC
+func (br *bitReader) frameCRC() uint16 {
+	br.updateCRC()
+	return br.crc16
+}
+
+// fill replaces the content of buf with the next bytes of the stream
+func (br *bitReader) fill() error {
+	br.updateCRC()
+	if br.r == nil {
+		return io.EOF
+	}
+	n, err := io.ReadAtLeast(br.r, br.buf[:cap(br.buf)], 1)
+	if err != nil {
+		return err
+	}
+	br.buf, br.pos = br.buf[:n], 0
+	if br.crc8Pos >= 0 {
+		br.crc8Pos = 0
+	}
+	br.crc16Pos = 0
+	return nil
+}
+
+func (br *bitReader) loadByte() error {
+	if br.pos == len(br.buf) {
+		if err := br.fill(); err != nil {
+			return err
+		}
+	}
+	br.cache = br.cache<<8 | uint64(br.buf[br.pos])
+	br.pos++
+	br.consumed++
+	br.n += 8
+	return nil
+}
+
+// readBits reads an unsigned value of n bits, n must be at most 56
+func (br *bitReader) readBits(n uint) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	for br.n < n {
+		if err := br.loadByte(); err != nil {
+			return 0, unexpectedEOF(err)
+		}
+	}
+	br.n -= n
+	return (br.cache >> br.n) & (1<<n - 1), nil
+}
+
+// readSigned reads a two's complement value of n bits
+func (br *bitReader) readSigned(n uint) (int64, error) {
+	value, err := br.readBits(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	shift := 64 - n
+	return int64(value<<shift) >> shift, nil
+}
+
+// readUnary counts the zero bits preceding the next one bit
+func (br *bitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if br.n == 0 {
+			if err := br.loadByte(); err != nil {
+				return 0, unexpectedEOF(err)
+			}
+		}
+		available := br.cache & (1<<br.n - 1)
+		if available == 0 {
+			count += uint64(br.n)
+			br.n = 0
+			continue
+		}
+		zeros := br.n - uint(bits.Len64(available))
+		count += uint64(zeros)
+		br.n -= zeros + 1
+		return count, nil
+	}
+}
+
+// align discards the bits left before the next byte boundary
+func (br *bitReader) align() {
+	br.n -= br.n % 8
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF, it's used where the stream can't end
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package flacgo
+
+// bitWriter writes big-endian bit fields into an in-memory buffer
+type bitWriter struct {
+	buf []byte
+	// cache holds the bits written but not flushed to buf yet in its lowest n bits
+	cache uint64
+	n     uint
+}
+
+// writeBits writes the lowest n bits of value
+func (bw *bitWriter) writeBits(value uint64, n uint) {
+	for n > 32 {
+		n -= 32
+		bw.writeBits(value>>n, 32)
+	}
+	if n == 0 {
+		return
+	}
+
+	bw.cache = bw.cache<<n | value&(1<<n-1)
+	bw.n += n
+	for bw.n >= 8 {
+		bw.n -= 8
+		bw.buf = append(bw.buf, byte(bw.cache>>bw.n))
+	}
+}
+
+// writeSigned writes a two's complement value of n bits
+func (bw *bitWriter) writeSigned(value int64, n uint) {
+	bw.writeBits(uint64(value), n)
+}
+
+// writeUnary writes count zero bits followed by a one bit
+func (bw *bitWriter) writeUnary(count uint64) {
+	for count >= 32 {
+		bw.writeBits(0, 32)
+		count -= 32
+	}
+	bw.writeBits(1, uint(count)+1)
+}
+
+// align pads with zero bits up to the next byte boundary
+func (bw *bitWriter) align() {
+	if bw.n > 0 {
+		bw.writeBits(0, 8-bw.n)
+	}
+}
+
+// bytes returns the bytes written so far, the writer must be aligned
+func (bw *bitWriter) bytes() []byte {
+	return bw.buf
+}
+
+// reset empties the writer keeping its buffer
+func (bw *bitWriter) reset() {
+	bw.buf = bw.buf[:0]
+	bw.cache = 0
+	bw.n = 0
+}
@@ -0,0 +1,218 @@
+package flacgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// BlockSummary describes a metadata block as stored in a file
+type BlockSummary struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	Offset int64  `json:"offset"`
+	Length uint32 `json:"length"`
+	// SHA256 is the hex encoded SHA-256 of the block body
+	SHA256 string `json:"sha256"`
+}
+
+// String returns the block as "#1 VORBIS_COMMENT (87 bytes)"
+func (block BlockSummary) String() string {
+	return fmt.Sprintf("#%d %s (%d bytes)", block.Index, block.Type, block.Length)
+}
+
+// BlockSummaries returns the type, position, length and hash of every metadata block of the file.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) BlockSummaries() ([]BlockSummary, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+
+	summaries := make([]BlockSummary, len(blocks))
+	for i, block := range blocks {
+		// Pictures are hashed straight from the file rather than loaded
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(flac.file, block.Index+4, int64(block.BlockHeader.BlockLength))); err != nil {
+			return nil, fmt.Errorf("unable to read metadata block with offset %d: %w", block.Index, err)
+		}
+		summaries[i] = BlockSummary{
+			Index:  i,
+			Type:   block.BlockType,
+			Offset: block.Index,
+			Length: block.BlockHeader.BlockLength,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		}
+	}
+	return summaries, nil
+}
+
+// BlockChangeKind tells how a block differs between two files
+type BlockChangeKind string
+
+const (
+	BlockUnchanged BlockChangeKind = "unchanged"
+	// BlockChanged is a block whose body changed, paired with the block of the same type at the same place
+	BlockChanged BlockChangeKind = "changed"
+	// BlockMoved is a block with the same body at another place in the block order
+	BlockMoved   BlockChangeKind = "moved"
+	BlockAdded   BlockChangeKind = "added"
+	BlockRemoved BlockChangeKind = "removed"
+)
+
+// BlockChange is the difference of a block between two files, Old is nil for added blocks and
+// New for removed ones
+type BlockChange struct {
+	Kind BlockChangeKind `json:"kind"`
+	Old  *BlockSummary   `json:"old,omitempty"`
+	New  *BlockSummary   `json:"new,omitempty"`
+}
+
+func (change BlockChange) String() string {
+	switch change.Kind {
+	case BlockAdded:
+		return fmt.Sprintf("added %s", change.New)
+	case BlockRemoved:
+		return fmt.Sprintf("removed %s", change.Old)
+	}
+	return fmt.Sprintf("%s %s -> %s", change.Kind, change.Old, change.New)
+}
+
+// DiffBlocks compares the blocks of two files, in the order of the new ones with the removed
+// blocks where they were. Identical blocks are matched first, keeping their order, then the
+// remaining blocks of the same type between two matches are paired as changed.
+func DiffBlocks(before []BlockSummary, after []BlockSummary) []BlockChange {
+	same := func(i, j int) bool {
+		return before[i].Type == after[j].Type && before[i].SHA256 == after[j].SHA256
+	}
+
+	// Longest common subsequence of identical blocks
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if same(i, j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []BlockChange
+	// gap pairs the unmatched blocks of the same type, in order
+	gap := func(removed []BlockSummary, added []BlockSummary) {
+		paired := make([]bool, len(removed))
+		for j := range added {
+			change := BlockChange{Kind: BlockAdded, New: &added[j]}
+			for i := range removed {
+				if !paired[i] && removed[i].Type == added[j].Type {
+					paired[i] = true
+					change = BlockChange{Kind: BlockChanged, Old: &removed[i], New: &added[j]}
+					break
+				}
+			}
+			changes = append(changes, change)
+		}
+		for i := range removed {
+			if !paired[i] {
+				changes = append(changes, BlockChange{Kind: BlockRemoved, Old: &removed[i]})
+			}
+		}
+	}
+
+	i, j := 0, 0
+	gapOld, gapNew := i, j
+	for i < len(before) && j < len(after) {
+		switch {
+		case same(i, j):
+			gap(before[gapOld:i], after[gapNew:j])
+			changes = append(changes, BlockChange{Kind: BlockUnchanged, Old: &before[i], New: &after[j]})
+			i, j = i+1, j+1
+			gapOld, gapNew = i, j
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	gap(before[gapOld:], after[gapNew:])
+
+	// Identical blocks added and removed were moved
+	for a := range changes {
+		if changes[a].Kind != BlockAdded {
+			continue
+		}
+		for r := range changes {
+			if changes[r].Kind == BlockRemoved && changes[r].Old.Type == changes[a].New.Type && changes[r].Old.SHA256 == changes[a].New.SHA256 {
+				changes[a] = BlockChange{Kind: BlockMoved, Old: changes[r].Old, New: changes[a].New}
+				changes[r].Kind = ""
+				break
+			}
+		}
+	}
+
+	kept := changes[:0]
+	for _, change := range changes {
+		if change.Kind != "" {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// MetadataDiff is the structural difference between two FLAC files
+type MetadataDiff struct {
+	Blocks []BlockChange `json:"blocks"`
+	// AudioChanged is set when the audio frames, or anything following them like an APEv2 tag, differ
+	AudioChanged bool `json:"audio_changed"`
+}
+
+// Changed reports whether anything differs besides the offsets of the blocks
+func (diff *MetadataDiff) Changed() bool {
+	for _, change := range diff.Blocks {
+		if change.Kind != BlockUnchanged {
+			return true
+		}
+	}
+	return diff.AudioChanged
+}
+
+// DiffFiles compares the metadata blocks and the audio of the files at oldPath and newPath,
+// e.g. to see what a tagger did to a file
+func DiffFiles(oldPath string, newPath string) (*MetadataDiff, error) {
+	summarize := func(path string) ([]BlockSummary, string, error) {
+		flac, err := Open(path, WithHeaderOnly())
+		if err != nil {
+			return nil, "", err
+		}
+		defer flac.Close()
+
+		blocks, err := flac.BlockSummaries()
+		if err != nil {
+			return nil, "", err
+		}
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(flac.file, flac.audioOffset, flac.fileSize-flac.audioOffset)); err != nil {
+			return nil, "", fmt.Errorf("unable to read audio: %w", err)
+		}
+		return blocks, hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	oldBlocks, oldAudio, err := summarize(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", oldPath, err)
+	}
+	newBlocks, newAudio, err := summarize(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", newPath, err)
+	}
+
+	return &MetadataDiff{
+		Blocks:       DiffBlocks(oldBlocks, newBlocks),
+		AudioChanged: oldAudio != newAudio,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package flacgo
+
+import (
+	"fmt"
+	"io"
+)
+
+// BlockSource is random access storage holding a FLAC file, such as an object in cloud storage.
+// bytes.Reader, strings.Reader and io.SectionReader already implement it.
+type BlockSource interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// OpenSource opens the FLAC file held by src, reading only the byte ranges needed.
+// If src implements io.Closer it's closed by Flac.Close.
+// Since there is no local file backing the stream, Save requires an output path.
+func OpenSource(src BlockSource, opts ...Option) (*Flac, error) {
+	return newFlac(src, "", src.Size(), opts)
+}
+
+// RangeReader adapts storage APIs returning a reader over a byte range of an object to a BlockSource,
+// e.g. S3 GetObject with a Range header or GCS ObjectHandle.NewRangeReader.
+type RangeReader struct {
+	// Length is the size of the object in bytes
+	Length int64
+	// Open returns a reader over length bytes of the object starting at offset
+	Open func(offset, length int64) (io.ReadCloser, error)
+}
+
+// ReadAt implements io.ReaderAt with one Open call per read
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.Length {
+		return 0, io.EOF
+	}
+
+	want := p
+	if remaining := r.Length - off; int64(len(want)) > remaining {
+		want = want[:remaining]
+	}
+
+	body, err := r.Open(off, int64(len(want)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to open range at offset %d: %w", off, err)
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, want)
+	if err != nil {
+		return n, fmt.Errorf("unable to read range at offset %d: %w", off, err)
+	}
+
+	if len(want) < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Size returns the size of the object
+func (r *RangeReader) Size() int64 {
+	return r.Length
+}
@@ -0,0 +1,147 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Metadata is a serializable snapshot of the parsed metadata of a file.
+// Blocks hold the header of every block but only the body of STREAMINFO and VORBIS_COMMENT,
+// as read with WithHeaderOnly, so snapshots stay small even for files with large pictures.
+type Metadata struct {
+	Blocks     []MetadataBlock
+	Comments   []VorbisComment
+	StreamInfo *StreamInfo
+}
+
+// Metadata returns a snapshot of the metadata of the file, including the staged comments
+func (flac *Flac) Metadata() (*Metadata, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+
+	for i := range blocks {
+		if blocks[i].BlockType != "STREAMINFO" && blocks[i].BlockType != "VORBIS_COMMENT" {
+			blocks[i].BlockData = nil
+		}
+	}
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Metadata{
+		Blocks:     blocks,
+		Comments:   flac.Comments(),
+		StreamInfo: streamInfo,
+	}, nil
+}
+
+// gobMetadata has the fields of Metadata without its methods, so gob doesn't call MarshalBinary recursively
+type gobMetadata Metadata
+
+// MarshalBinary encodes the metadata with encoding/gob
+func (metadata Metadata) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobMetadata(metadata)); err != nil {
+		return nil, fmt.Errorf("unable to encode metadata: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes metadata encoded by MarshalBinary
+func (metadata *Metadata) UnmarshalBinary(data []byte) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode((*gobMetadata)(metadata)); err != nil {
+		return fmt.Errorf("unable to decode metadata: %w", err)
+	}
+	return nil
+}
+
+// cacheEntry is a Metadata snapshot along with the state of the file it was taken from
+type cacheEntry struct {
+	ModTime  time.Time
+	Size     int64
+	Metadata Metadata
+}
+
+// MetadataCache keeps the metadata of files keyed by path, modification time and size,
+// so scanners can skip reparsing files unchanged since the previous run.
+// It's safe for concurrent use.
+type MetadataCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewMetadataCache returns an empty cache
+func NewMetadataCache() *MetadataCache {
+	return &MetadataCache{entries: make(map[string]cacheEntry)}
+}
+
+// ReadMetadataCache reads a cache written by MetadataCache.WriteTo
+func ReadMetadataCache(r io.Reader) (*MetadataCache, error) {
+	cache := NewMetadataCache()
+	if err := gob.NewDecoder(r).Decode(&cache.entries); err != nil {
+		return nil, fmt.Errorf("unable to decode metadata cache: %w", err)
+	}
+	return cache, nil
+}
+
+// WriteTo writes the cache to w with encoding/gob
+func (cache *MetadataCache) WriteTo(w io.Writer) (int64, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cache.entries); err != nil {
+		return 0, fmt.Errorf("unable to encode metadata cache: %w", err)
+	}
+	return buf.WriteTo(w)
+}
+
+// Load returns the metadata of the file at path, from the cache if the file didn't change
+// since it was cached, parsing the file and caching the result otherwise
+func (cache *MetadataCache) Load(path string) (*Metadata, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat file %w", err)
+	}
+
+	cache.mu.Lock()
+	entry, found := cache.entries[path]
+	cache.mu.Unlock()
+	if found && entry.Size == fileInfo.Size() && entry.ModTime.Equal(fileInfo.ModTime()) {
+		metadata := entry.Metadata
+		return &metadata, nil
+	}
+
+	flac, err := Open(path, WithHeaderOnly())
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	metadata, err := flac.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.entries[path] = cacheEntry{ModTime: fileInfo.ModTime(), Size: fileInfo.Size(), Metadata: *metadata}
+	cache.mu.Unlock()
+
+	return metadata, nil
+}
+
+// Forget removes path from the cache
+func (cache *MetadataCache) Forget(path string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.entries, path)
+}
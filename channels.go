@@ -0,0 +1,134 @@
+package flacgo
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// channelMapping selects or mixes the decoded channels, see WithChannels and WithDownmix
+type channelMapping struct {
+	channels []int
+	downmix  bool
+}
+
+// WithChannels makes DecodePCM, ExportWAV and ExportAIFF return only the given channels, in the given
+// order, e.g. 0 and 1 for the front left and right of a surround master. Channels are numbered in
+// the FLAC order. It replaces WithDownmix.
+func WithChannels(channels ...int) Option {
+	return func(o *options) {
+		o.channelMapping = channelMapping{channels: channels}
+	}
+}
+
+// WithDownmix makes DecodePCM, ExportWAV and ExportAIFF mix the channels down to stereo with the
+// ITU-R BS.775 coefficients: the center and the surround channels are added to the front ones at
+// -3 dB and the LFE channel is dropped. The result is scaled so it never clips, mono is duplicated
+// on both channels and stereo is left as it is. It replaces WithChannels.
+func WithDownmix() Option {
+	return func(o *options) {
+		o.channelMapping = channelMapping{downmix: true}
+	}
+}
+
+// downmixCoefficients are the coefficients of the left channel for every number of channels in the
+// FLAC order, the right channel mirrors them
+var downmixCoefficients = map[int][]float64{
+	// FL FR C
+	3: {1, 0, math.Sqrt2 / 2},
+	// FL FR BL BR
+	4: {1, 0, math.Sqrt2 / 2, 0},
+	// FL FR C BL BR
+	5: {1, 0, math.Sqrt2 / 2, math.Sqrt2 / 2, 0},
+	// FL FR C LFE BL BR
+	6: {1, 0, math.Sqrt2 / 2, 0, math.Sqrt2 / 2, 0},
+	// FL FR C LFE BC SL SR
+	7: {1, 0, math.Sqrt2 / 2, 0, 0.5, math.Sqrt2 / 2, 0},
+	// FL FR C LFE BL BR SL SR
+	8: {1, 0, math.Sqrt2 / 2, 0, math.Sqrt2 / 2, 0, math.Sqrt2 / 2, 0},
+}
+
+// mirroredChannels maps every channel to its counterpart on the other side, in the FLAC order
+var mirroredChannels = map[int][]int{
+	3: {1, 0, 2},
+	4: {1, 0, 3, 2},
+	5: {1, 0, 2, 4, 3},
+	6: {1, 0, 2, 3, 5, 4},
+	7: {1, 0, 2, 3, 4, 6, 5},
+	8: {1, 0, 2, 3, 5, 4, 7, 6},
+}
+
+// channelMixer turns the channels of the decoded frames into the exported ones
+type channelMixer struct {
+	// selection are the channels exported as they are, matrix the coefficients of every exported
+	// channel, both nil when the channels are left as they are
+	selection []int
+	matrix    [][]float64
+	maxSample float64
+	channels  int
+}
+
+// newChannelMixer returns the mixer of the channel mapping set by WithChannels or WithDownmix
+func (flac *Flac) newChannelMixer(streamInfo *StreamInfo) (*channelMixer, error) {
+	input := int(streamInfo.Channels)
+	mixer := &channelMixer{channels: input, maxSample: float64(int64(1)<<(streamInfo.BitsPerSample-1) - 1)}
+	mapping := flac.options.channelMapping
+
+	switch {
+	case len(mapping.channels) > 0:
+		for _, ch := range mapping.channels {
+			if ch < 0 || ch >= input {
+				return nil, fmt.Errorf("unable to select channel %d, the audio has %d channels", ch, input)
+			}
+		}
+		mixer.selection = mapping.channels
+		mixer.channels = len(mapping.channels)
+	case mapping.downmix && input == 1:
+		mixer.selection = []int{0, 0}
+		mixer.channels = 2
+	case mapping.downmix && input > 2:
+		sum := 0.0
+		for _, coefficient := range downmixCoefficients[input] {
+			sum += coefficient
+		}
+		left := make([]float64, input)
+		for ch, coefficient := range downmixCoefficients[input] {
+			left[ch] = coefficient / sum
+		}
+		right := make([]float64, input)
+		for ch, mirrored := range mirroredChannels[input] {
+			right[ch] = left[mirrored]
+		}
+		mixer.matrix = [][]float64{left, right}
+		mixer.channels = 2
+	}
+
+	return mixer, nil
+}
+
+// mix returns the exported channels of the samples of a frame
+func (mixer *channelMixer) mix(samples [][]int32) [][]int32 {
+	switch {
+	case mixer.selection != nil:
+		out := make([][]int32, len(mixer.selection))
+		// Copied since a channel can be exported more than once
+		for i, ch := range mixer.selection {
+			out[i] = slices.Clone(samples[ch])
+		}
+		return out
+	case mixer.matrix != nil:
+		out := make([][]int32, len(mixer.matrix))
+		for i, coefficients := range mixer.matrix {
+			out[i] = make([]int32, len(samples[0]))
+			for j := range out[i] {
+				sum := 0.0
+				for ch, coefficient := range coefficients {
+					sum += coefficient * float64(samples[ch][j])
+				}
+				out[i][j] = int32(math.Max(-mixer.maxSample-1, math.Min(mixer.maxSample, math.Round(sum))))
+			}
+		}
+		return out
+	}
+	return samples
+}
@@ -0,0 +1,242 @@
+package flacgo
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapter is a named position in the audio stream, e.g. a chapter of an audiobook
+type Chapter struct {
+	Start time.Duration
+	Title string
+}
+
+// ChapterFormat is a text format chapters can be exported to
+type ChapterFormat int
+
+const (
+	// ChapterFormatSimple is one "HH:MM:SS.mmm Title" line per chapter, as used by mp4chaps
+	ChapterFormatSimple ChapterFormat = iota
+	// ChapterFormatFFMetadata is the FFmpeg metadata file format (;FFMETADATA1)
+	ChapterFormatFFMetadata
+	// ChapterFormatWebVTT is a WebVTT file with one cue per chapter
+	ChapterFormatWebVTT
+)
+
+// chapterCommentPattern matches the CHAPTERxxx and CHAPTERxxxNAME comments
+var chapterCommentPattern = regexp.MustCompile(`^(?i)CHAPTER(\d{3})(NAME)?$`)
+
+// Chapters returns the chapters of the file. CHAPTERxxx comments are used when present,
+// otherwise the audio tracks of the CUESHEET block are returned as chapters.
+func (flac *Flac) Chapters() ([]Chapter, error) {
+	starts := make(map[int]time.Duration)
+	names := make(map[int]string)
+
+	for _, cmt := range flac.Comments() {
+		match := chapterCommentPattern.FindStringSubmatch(cmt.Title)
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		if match[2] != "" {
+			names[number] = cmt.Value
+			continue
+		}
+		start, err := parseChapterTime(cmt.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time for %s: %w", cmt.Title, err)
+		}
+		starts[number] = start
+	}
+
+	if len(starts) > 0 {
+		numbers := make([]int, 0, len(starts))
+		for number := range starts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+
+		chapters := make([]Chapter, 0, len(numbers))
+		for _, number := range numbers {
+			chapters = append(chapters, Chapter{Start: starts[number], Title: names[number]})
+		}
+		return chapters, nil
+	}
+
+	return flac.cueSheetChapters()
+}
+
+// cueSheetChapters converts the audio tracks of the CUESHEET block to chapters
+func (flac *Flac) cueSheetChapters() ([]Chapter, error) {
+	chapters := make([]Chapter, 0)
+
+	cueSheet, err := flac.CueSheet()
+	if err != nil || cueSheet == nil {
+		return chapters, err
+	}
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	if streamInfo.SampleRate == 0 {
+		return nil, fmt.Errorf("unable to compute chapters start: sample rate is 0")
+	}
+
+	// The last track is the lead-out
+	for i, track := range cueSheet.Tracks {
+		if i == len(cueSheet.Tracks)-1 || !track.IsAudio {
+			continue
+		}
+		offset := track.Offset
+		// Index 01 is where the track actually starts, index 00 marks the pregap
+		for _, index := range track.Indices {
+			if index.Number == 1 {
+				offset += index.Offset
+			}
+		}
+		// Titles tagged from a cue file, see TagFromCue
+		title := fmt.Sprintf("Track %02d", track.Number)
+		if values := flac.MetadataValues(cueTrackKey(int(track.Number), "TITLE")); len(values) > 0 {
+			title = values[0]
+		}
+		chapters = append(chapters, Chapter{
+			Start: time.Duration(offset) * time.Second / time.Duration(streamInfo.SampleRate),
+			Title: title,
+		})
+	}
+
+	return chapters, nil
+}
+
+// SetChapters replaces the chapters of the file, storing them as CHAPTERxxx and CHAPTERxxxNAME comments
+func (flac *Flac) SetChapters(chapters []Chapter) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	if len(chapters) > 999 {
+		return fmt.Errorf("unable to set %d chapters: at most 999 are supported", len(chapters))
+	}
+
+	for _, cmt := range flac.Comments() {
+		if chapterCommentPattern.MatchString(cmt.Title) {
+			if err := flac.RemoveMetadata(cmt.Title, true); err != nil {
+				return fmt.Errorf("unable to remove chapter comment '%s': %w", cmt.Title, err)
+			}
+		}
+	}
+
+	sorted := append([]Chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	for i, chapter := range sorted {
+		key := fmt.Sprintf("CHAPTER%03d", i+1)
+		if err := flac.SetMetadata(key, formatChapterTime(chapter.Start)); err != nil {
+			return fmt.Errorf("unable to set %s: %w", key, err)
+		}
+		if err := flac.SetMetadata(key+"NAME", chapter.Title); err != nil {
+			return fmt.Errorf("unable to set %sNAME: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// ExportChapters writes the chapters of the file to w in the given format
+func (flac *Flac) ExportChapters(w io.Writer, format ChapterFormat) error {
+	chapters, err := flac.Chapters()
+	if err != nil {
+		return fmt.Errorf("unable to read chapters: %w", err)
+	}
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return fmt.Errorf("unable to read stream duration: %w", err)
+	}
+
+	return WriteChapters(w, chapters, format, streamInfo.Duration())
+}
+
+// WriteChapters writes chapters to w in the given format. Each chapter ends where the next one
+// starts, the last one ends at duration.
+func WriteChapters(w io.Writer, chapters []Chapter, format ChapterFormat, duration time.Duration) error {
+	var out strings.Builder
+
+	end := func(i int) time.Duration {
+		if i+1 < len(chapters) {
+			return chapters[i+1].Start
+		}
+		if duration > chapters[i].Start {
+			return duration
+		}
+		return chapters[i].Start
+	}
+
+	switch format {
+	case ChapterFormatSimple:
+		for _, chapter := range chapters {
+			fmt.Fprintf(&out, "%s %s\n", formatChapterTime(chapter.Start), chapter.Title)
+		}
+	case ChapterFormatFFMetadata:
+		out.WriteString(";FFMETADATA1\n")
+		for i, chapter := range chapters {
+			fmt.Fprintf(&out, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+				chapter.Start.Milliseconds(), end(i).Milliseconds(), escapeFFMetadata(chapter.Title))
+		}
+	case ChapterFormatWebVTT:
+		out.WriteString("WEBVTT\n")
+		for i, chapter := range chapters {
+			fmt.Fprintf(&out, "\n%d\n%s --> %s\n%s\n", i+1, formatChapterTime(chapter.Start), formatChapterTime(end(i)), chapter.Title)
+		}
+	default:
+		return fmt.Errorf("unknown chapter format %d", format)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// formatChapterTime formats a duration as HH:MM:SS.mmm
+func formatChapterTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// parseChapterTime parses a HH:MM:SS.mmm or MM:SS.mmm duration, the fractional part is optional
+func parseChapterTime(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("malformed time %q", value)
+	}
+
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("malformed seconds in %q", value)
+	}
+
+	total := time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	multiplier := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("malformed time %q", value)
+		}
+		total += time.Duration(n) * multiplier
+		multiplier *= 60
+	}
+
+	return total, nil
+}
+
+// escapeFFMetadata escapes the characters having a special meaning in FFmpeg metadata files
+func escapeFFMetadata(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+	return replacer.Replace(value)
+}
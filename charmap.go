@@ -0,0 +1,131 @@
+package flacgo
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charmap decodes text stored in a legacy encoding to UTF-8. Encodings not provided by the package,
+// e.g. the ones of golang.org/x/text/encoding/charmap, can be used through CharmapFunc.
+type Charmap interface {
+	Decode(data []byte) (string, error)
+}
+
+// CharmapFunc is a function usable as Charmap
+type CharmapFunc func(data []byte) (string, error)
+
+func (fn CharmapFunc) Decode(data []byte) (string, error) {
+	return fn(data)
+}
+
+// singleByteCharmap maps the bytes from 0x80 to 0xFF to runes, lower bytes are ASCII
+type singleByteCharmap [128]rune
+
+func (charmap *singleByteCharmap) Decode(data []byte) (string, error) {
+	var decoded strings.Builder
+	decoded.Grow(len(data))
+	for _, b := range data {
+		if b < 0x80 {
+			decoded.WriteByte(b)
+		} else {
+			decoded.WriteRune(charmap[b-0x80])
+		}
+	}
+	return decoded.String(), nil
+}
+
+// shiftJISTable holds the runes of the double byte characters of code page 932, 0 for the
+// unassigned ones. It's indexed by lead byte (0x81-0x9F then 0xE0-0xFC) and trail byte (0x40-0xFC).
+//
+//go:embed shiftjis.bin
+var shiftJISTable []byte
+
+type shiftJISCharmap struct{}
+
+func (shiftJISCharmap) Decode(data []byte) (string, error) {
+	var decoded strings.Builder
+	decoded.Grow(len(data))
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			decoded.WriteByte(b)
+		case b >= 0xA1 && b <= 0xDF:
+			// Half-width katakana
+			decoded.WriteRune(0xFF61 + rune(b-0xA1))
+		case (b >= 0x81 && b <= 0x9F || b >= 0xE0 && b <= 0xFC) && i+1 < len(data) && data[i+1] >= 0x40 && data[i+1] <= 0xFC:
+			lead := int(b) - 0x81
+			if b >= 0xE0 {
+				lead -= 0xE0 - 0xA0
+			}
+			index := (lead*(0xFD-0x40) + int(data[i+1]) - 0x40) * 2
+			i++
+			if r := rune(binary.BigEndian.Uint16(shiftJISTable[index:])); r != 0 {
+				decoded.WriteRune(r)
+				continue
+			}
+			decoded.WriteRune(utf8.RuneError)
+		default:
+			decoded.WriteRune(utf8.RuneError)
+		}
+	}
+	return decoded.String(), nil
+}
+
+// Legacy encodings usable with WithFallbackEncoding, bytes without a mapping decode to U+FFFD
+var (
+	// Latin1 is ISO-8859-1
+	Latin1 Charmap = CharmapFunc(func(data []byte) (string, error) {
+		return decodeLatin1(string(data)), nil
+	})
+	// Windows1251 is the Cyrillic code page CP1251
+	Windows1251 Charmap = &singleByteCharmap{
+		0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+		0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0xFFFD, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+		0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+		0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+		0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+		0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+		0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+		0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+		0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+		0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+		0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+		0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+		0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+		0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+	}
+	// Windows1252 is the Western European code page CP1252, a superset of the printable ISO-8859-1
+	Windows1252 Charmap = &singleByteCharmap{
+		0x20AC, 0xFFFD, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0xFFFD, 0x017D, 0xFFFD,
+		0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0xFFFD, 0x017E, 0x0178,
+		0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x00A4, 0x00A5, 0x00A6, 0x00A7,
+		0x00A8, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+		0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+		0x00B8, 0x00B9, 0x00BA, 0x00BB, 0x00BC, 0x00BD, 0x00BE, 0x00BF,
+		0x00C0, 0x00C1, 0x00C2, 0x00C3, 0x00C4, 0x00C5, 0x00C6, 0x00C7,
+		0x00C8, 0x00C9, 0x00CA, 0x00CB, 0x00CC, 0x00CD, 0x00CE, 0x00CF,
+		0x00D0, 0x00D1, 0x00D2, 0x00D3, 0x00D4, 0x00D5, 0x00D6, 0x00D7,
+		0x00D8, 0x00D9, 0x00DA, 0x00DB, 0x00DC, 0x00DD, 0x00DE, 0x00DF,
+		0x00E0, 0x00E1, 0x00E2, 0x00E3, 0x00E4, 0x00E5, 0x00E6, 0x00E7,
+		0x00E8, 0x00E9, 0x00EA, 0x00EB, 0x00EC, 0x00ED, 0x00EE, 0x00EF,
+		0x00F0, 0x00F1, 0x00F2, 0x00F3, 0x00F4, 0x00F5, 0x00F6, 0x00F7,
+		0x00F8, 0x00F9, 0x00FA, 0x00FB, 0x00FC, 0x00FD, 0x00FE, 0x00FF,
+	}
+	// ShiftJIS is the Japanese Shift-JIS encoding, with the Windows code page 932 extensions
+	ShiftJIS Charmap = shiftJISCharmap{}
+)
+
+// WithFallbackEncoding makes Open decode the comment values that aren't valid UTF-8 with charmap,
+// as written by taggers using the system code page. Decoded values are written back as UTF-8 on Save.
+func WithFallbackEncoding(charmap Charmap) Option {
+	return func(o *options) {
+		o.fallbackEncoding = charmap
+	}
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+type artResult struct {
+	fileResult
+	Pictures []pictureEntry `json:"pictures"`
+}
+
+type pictureEntry struct {
+	Type        string `json:"type"`
+	MimeType    string `json:"mime_type"`
+	Description string `json:"description,omitempty"`
+	Width       uint32 `json:"width"`
+	Height      uint32 `json:"height"`
+	Depth       uint32 `json:"depth"`
+	Colors      uint32 `json:"colors,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+func runArt(args []string) error {
+	if len(args) == 0 {
+		artUsage()
+		return fmt.Errorf("missing art subcommand")
+	}
+
+	switch args[0] {
+	case "import":
+		return runArtImport(args[1:])
+	case "export":
+		return runArtExport(args[1:])
+	case "list":
+		return runArtList(args[1:])
+	case "remove":
+		return runArtRemove(args[1:])
+	case "thumbnail":
+		return runArtThumbnail(args[1:])
+	case "strip":
+		return runArtStrip(args[1:])
+	case "baseline":
+		return runArtBaseline(args[1:])
+	case "folder":
+		return runArtFolder(args[1:])
+	case "dedup":
+		return runArtDedup(args[1:])
+	default:
+		artUsage()
+		return fmt.Errorf("unknown art subcommand '%s'", args[0])
+	}
+}
+
+func artUsage() {
+	fmt.Fprintln(os.Stderr, "usage: flacgo art import [--type TYPE] [--description TEXT] [--strip-metadata] IMAGE path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art export [--out-dir DIR] [--type TYPE] path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art list [--json] path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art remove --type TYPE path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art thumbnail [--size PIXELS] path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art strip path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art baseline [--quality Q] path...")
+	fmt.Fprintln(os.Stderr, "       flacgo art folder [--embed] [--name NAME] [--overwrite] dir...")
+	fmt.Fprintln(os.Stderr, "       flacgo art dedup [--min-size BYTES] [--max-size PIXELS] [--external] [--dry-run] dir...")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "All subcommands but folder accept -r, --include and --exclude to select files.")
+	fmt.Fprintln(os.Stderr, "The folder subcommand writes the front cover of the tracks of each folder to folder.jpg,")
+	fmt.Fprintln(os.Stderr, "or with --embed embeds the folder image into the tracks without artwork.")
+	fmt.Fprintln(os.Stderr, "The dedup subcommand lists the albums whose tracks all embed the same large cover; with")
+	fmt.Fprintln(os.Stderr, "--max-size it scales the embedded covers down, with --external it keeps the original once as folder image.")
+	fmt.Fprintf(os.Stderr, "TYPE is a picture type number or one of: %s.\n", strings.Join(pictureTypeNames(), ", "))
+}
+
+func pictureTypeNames() []string {
+	var names []string
+	for pictureType := flacgo.PictureTypeOther; pictureType <= flacgo.PictureTypePublisherLogo; pictureType++ {
+		names = append(names, flacgo.PictureTypeName(pictureType))
+	}
+	return names
+}
+
+// parsePictureTypeFlag parses the --type flag, empty meaning any type when allowAny is set
+func parsePictureTypeFlag(value string, allowAny bool) (uint32, bool, error) {
+	if value == "" {
+		if allowAny {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("missing --type")
+	}
+	pictureType, err := flacgo.ParsePictureType(value)
+	return pictureType, true, err
+}
+
+func runArtImport(args []string) error {
+	var selection fileSelection
+	var typeName, description string
+	var strip bool
+	flags := flag.NewFlagSet("art import", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.StringVar(&typeName, "type", "front", "picture type")
+	flags.StringVar(&description, "description", "", "picture description")
+	flags.BoolVar(&strip, "strip-metadata", false, "strip EXIF, XMP, ICC profiles and comments from the image")
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	if len(rest) < 2 {
+		artUsage()
+		return fmt.Errorf("expected an image and at least one path")
+	}
+	pictureType, _, err := parsePictureTypeFlag(typeName, false)
+	if err != nil {
+		return err
+	}
+
+	imagePath := rest[0]
+	files, err := selection.expand(rest[1:])
+	if err != nil {
+		return err
+	}
+
+	var opts []flacgo.Option
+	if strip {
+		opts = append(opts, flacgo.WithStripImageMetadata())
+	}
+
+	return forEachFile(files, func(path string) error {
+		flac, err := flacgo.Open(path, opts...)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		if err := flac.SetPictureFromPath(imagePath, pictureType, description); err != nil {
+			return err
+		}
+		return flac.Save(nil)
+	})
+}
+
+func runArtExport(args []string) error {
+	var selection fileSelection
+	var typeName, outDir string
+	flags := flag.NewFlagSet("art export", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.StringVar(&outDir, "out-dir", ".", "folder to write the pictures to")
+	flags.StringVar(&typeName, "type", "", "only export pictures of this type")
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	pictureType, filtered, err := parsePictureTypeFlag(typeName, true)
+	if err != nil {
+		return err
+	}
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		artUsage()
+		return fmt.Errorf("no files given")
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	return forEachFile(files, func(path string) error {
+		flac, err := flacgo.Open(path)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		pictures, err := flac.StreamPictures()
+		if err != nil {
+			return err
+		}
+
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		seen := make(map[uint32]int)
+		for _, picture := range pictures {
+			if filtered && picture.Picture.PictureType != pictureType {
+				continue
+			}
+
+			seen[picture.Picture.PictureType]++
+			name := base + "-" + flacgo.PictureTypeName(picture.Picture.PictureType)
+			if count := seen[picture.Picture.PictureType]; count > 1 {
+				name += fmt.Sprintf("-%d", count)
+			}
+			name += pictureExtension(picture.Picture.MimeType)
+
+			if err := writePicture(filepath.Join(outDir, name), picture.Data); err != nil {
+				return err
+			}
+			fmt.Println(filepath.Join(outDir, name))
+		}
+
+		return nil
+	})
+}
+
+func runArtList(args []string) error {
+	var selection fileSelection
+	var asJSON bool
+	flags := flag.NewFlagSet("art list", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		artUsage()
+		return fmt.Errorf("no files given")
+	}
+
+	results := make([]artResult, 0, len(files))
+	err = forEachFile(files, func(path string) error {
+		result := artResult{fileResult: fileResult{Path: path}, Pictures: []pictureEntry{}}
+		pictures, err := readPictures(path)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Pictures = pictures
+		results = append(results, result)
+
+		if !asJSON && err == nil {
+			if len(files) > 1 {
+				fmt.Printf("%s:\n", path)
+			}
+			for i, picture := range pictures {
+				fmt.Printf("%3d  %-18s %-10s %dx%d %dbit %d bytes", i, picture.Type, picture.MimeType, picture.Width, picture.Height, picture.Depth, picture.Size)
+				if picture.Description != "" {
+					fmt.Printf(" %q", picture.Description)
+				}
+				fmt.Println()
+			}
+		}
+		return err
+	})
+
+	if asJSON {
+		if jsonErr := printJSON(results); jsonErr != nil {
+			return jsonErr
+		}
+	}
+
+	return err
+}
+
+func runArtRemove(args []string) error {
+	var selection fileSelection
+	var typeName string
+	flags := flag.NewFlagSet("art remove", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.StringVar(&typeName, "type", "", "type of the pictures to remove")
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	pictureType, _, err := parsePictureTypeFlag(typeName, false)
+	if err != nil {
+		return err
+	}
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		artUsage()
+		return fmt.Errorf("no files given")
+	}
+
+	return forEachFile(files, func(path string) error {
+		flac, err := flacgo.Open(path)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		removed, err := flac.RemovePictures(pictureType)
+		if err != nil || removed == 0 {
+			return err
+		}
+		return flac.Save(nil)
+	})
+}
+
+func runArtThumbnail(args []string) error {
+	var selection fileSelection
+	var size int
+	flags := flag.NewFlagSet("art thumbnail", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.IntVar(&size, "size", 128, "largest width and height of the thumbnail, 32 for a PNG file icon")
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		artUsage()
+		return fmt.Errorf("no files given")
+	}
+
+	return forEachFile(files, func(path string) error {
+		flac, err := flacgo.Open(path)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		if err := flac.AddThumbnail(size); err != nil {
+			return err
+		}
+		return flac.Save(nil)
+	})
+}
+
+func runArtStrip(args []string) error {
+	var selection fileSelection
+	flags := flag.NewFlagSet("art strip", flag.ExitOnError)
+	flags.Usage = artUsage
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		artUsage()
+		return fmt.Errorf("no files given")
+	}
+
+	return forEachFile(files, func(path string) error {
+		flac, err := flacgo.Open(path)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		stripped, err := flac.StripPictureMetadata()
+		if err != nil || stripped == 0 {
+			return err
+		}
+		return flac.Save(nil)
+	})
+}
+
+func runArtBaseline(args []string) error {
+	var selection fileSelection
+	var quality int
+	flags := flag.NewFlagSet("art baseline", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.IntVar(&quality, "quality", 90, "JPEG quality of the re-encoded pictures")
+	selection.register(flags)
+	rest := parseInterspersed(flags, args)
+
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		artUsage()
+		return fmt.Errorf("no files given")
+	}
+
+	return forEachFile(files, func(path string) error {
+		flac, err := flacgo.Open(path)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		converted, err := flac.ConvertArtworkToBaseline(quality)
+		if err != nil || converted == 0 {
+			return err
+		}
+		fmt.Printf("%s: %d pictures re-encoded\n", path, converted)
+		return flac.Save(nil)
+	})
+}
+
+func runArtFolder(args []string) error {
+	var embed, overwrite bool
+	var name string
+	flags := flag.NewFlagSet("art folder", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.BoolVar(&embed, "embed", false, "embed the folder image into the tracks without artwork")
+	flags.StringVar(&name, "name", flacgo.DefaultFolderArtName, "name of the exported image, the extension is added when missing")
+	flags.BoolVar(&overwrite, "overwrite", false, "replace an existing folder image")
+	dirs := parseInterspersed(flags, args)
+
+	if len(dirs) == 0 {
+		artUsage()
+		return fmt.Errorf("no folders given")
+	}
+
+	return forEachFile(dirs, func(dir string) error {
+		if !embed {
+			path, err := flacgo.ExportFolderArt(dir, name, overwrite)
+			if err != nil {
+				return err
+			}
+			if path == "" {
+				return fmt.Errorf("no track has a cover picture")
+			}
+			fmt.Println(path)
+			return nil
+		}
+
+		image, results, err := flacgo.EmbedFolderArt(dir)
+		if err != nil {
+			return err
+		}
+		if image == "" {
+			return fmt.Errorf("no folder image found")
+		}
+
+		failed := 0
+		for _, result := range results {
+			if result.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", result.Path, result.Err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: embedded %s\n", result.Path, filepath.Base(image))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d files failed", failed, len(results))
+		}
+		return nil
+	})
+}
+
+func runArtDedup(args []string) error {
+	var minSize int64
+	var opts flacgo.ShareArtworkOptions
+	flags := flag.NewFlagSet("art dedup", flag.ExitOnError)
+	flags.Usage = artUsage
+	flags.Int64Var(&minSize, "min-size", 1000000, "smallest cover size in bytes to report")
+	flags.IntVar(&opts.MaxDimension, "max-size", 0, "scale the embedded covers down to this width and height")
+	flags.IntVar(&opts.Quality, "quality", 0, "JPEG quality of the scaled covers (default 75)")
+	flags.BoolVar(&opts.External, "external", false, "write the original cover next to the tracks, removing it from them without --max-size")
+	flags.StringVar(&opts.FolderName, "name", flacgo.DefaultFolderArtName, "name of the external image, without extension")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "report the savings without writing anything")
+	dirs := parseInterspersed(flags, args)
+
+	if len(dirs) == 0 {
+		artUsage()
+		return fmt.Errorf("no folders given")
+	}
+	apply := opts.External || opts.MaxDimension > 0
+
+	var total int64
+	err := forEachFile(dirs, func(root string) error {
+		albums, err := flacgo.FindSharedArtwork(root, minSize)
+		if err != nil {
+			return err
+		}
+
+		for _, album := range albums {
+			fmt.Printf("%s: %d tracks share a %dx%d cover of %s, %s wasted\n", album.Dir, len(album.Tracks),
+				album.Cover.Width, album.Cover.Height, formatBytes(album.Size), formatBytes(album.Waste()))
+			if !apply {
+				total += album.Waste()
+				continue
+			}
+
+			result, err := flacgo.ShareArtwork(album, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", album.Dir, err)
+				continue
+			}
+			for _, track := range result.Results {
+				if track.Err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", track.Path, track.Err)
+				}
+			}
+			if result.Image != "" {
+				fmt.Printf("  cover kept as %s\n", result.Image)
+			}
+			fmt.Printf("  %s saved\n", formatBytes(result.Saved))
+			total += result.Saved
+		}
+		return nil
+	})
+
+	switch {
+	case !apply:
+		fmt.Printf("%s wasted in total\n", formatBytes(total))
+	case opts.DryRun:
+		fmt.Printf("%s would be saved in total\n", formatBytes(total))
+	default:
+		fmt.Printf("%s saved in total\n", formatBytes(total))
+	}
+	return err
+}
+
+func readPictures(path string) ([]pictureEntry, error) {
+	flac, err := flacgo.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	pictures, err := flac.StreamPictures()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]pictureEntry, 0, len(pictures))
+	for _, picture := range pictures {
+		entries = append(entries, pictureEntry{
+			Type:        flacgo.PictureTypeName(picture.Picture.PictureType),
+			MimeType:    picture.Picture.MimeType,
+			Description: picture.Picture.Description,
+			Width:       picture.Picture.Width,
+			Height:      picture.Picture.Height,
+			Depth:       picture.Picture.Depth,
+			Colors:      picture.Picture.Colors,
+			Size:        picture.Data.Size(),
+		})
+	}
+
+	return entries, nil
+}
+
+// pictureExtension returns the file extension matching a picture mime type
+func pictureExtension(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case "image/jpeg", "image/jpg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/bmp":
+		return ".bmp"
+	case "-->":
+		return ".url"
+	}
+	return ".bin"
+}
+
+func writePicture(path string, data io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, data); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write '%s': %w", path, err)
+	}
+	return f.Close()
+}
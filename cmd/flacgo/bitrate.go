@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+// bitrateEntry is a bitrate interval printed as JSON, times are in seconds
+type bitrateEntry struct {
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	Bitrate  float64 `json:"bitrate"`
+}
+
+func runBitrate(args []string) error {
+	var asJSON, frames bool
+	var interval time.Duration
+
+	flags := flag.NewFlagSet("bitrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo bitrate [--json] [--interval DURATION | --frames] file.flac")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Prints the bitrate of the audio over time, or the size and block size of every frame.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.BoolVar(&frames, "frames", false, "print every frame instead of intervals")
+	flags.DurationVar(&interval, "interval", time.Second, "length of the intervals the bitrate is averaged over")
+	rest := parseInterspersed(flags, args)
+
+	if len(rest) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	flac, err := flacgo.OpenReadOnly(rest[0])
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return err
+	}
+	stats, err := flac.FrameStats()
+	if err != nil {
+		return err
+	}
+
+	if frames {
+		if asJSON {
+			return printJSON(stats)
+		}
+		for i, stat := range stats {
+			fmt.Printf("%6d  offset=%-10d length=%-6d block_size=%-5d %7.1f kbps\n", i, stat.Offset, stat.Length, stat.BlockSize, stat.Bitrate/1000)
+		}
+		return nil
+	}
+
+	buckets, err := flacgo.BucketBitrate(stats, streamInfo.SampleRate, interval)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		entries := make([]bitrateEntry, len(buckets))
+		for i, bucket := range buckets {
+			entries[i] = bitrateEntry{Start: bucket.Start.Seconds(), Duration: bucket.Duration.Seconds(), Bitrate: bucket.Bitrate}
+		}
+		return printJSON(entries)
+	}
+	for _, bucket := range buckets {
+		fmt.Printf("%12s  %7.1f kbps\n", bucket.Start, bucket.Bitrate/1000)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+// levelFlag is a boolean flag like -8 selecting a compression level, the last one given wins
+type levelFlag struct {
+	level  int
+	target *int
+}
+
+func (f *levelFlag) String() string {
+	return "false"
+}
+
+func (f *levelFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (f *levelFlag) Set(value string) error {
+	set, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if set {
+		*f.target = f.level
+	}
+	return nil
+}
+
+func convertUsage(flags *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo convert [-0..-8] [--block-size N] [-A WINDOWS] [--verify] [--replay-gain MODE [--preamp DB]] [--channels LIST | --downmix]")
+		fmt.Fprintln(os.Stderr, "                      [--sample-rate HZ] [--bits N [--dither]] input output")
+		fmt.Fprintln(os.Stderr, "       flacgo convert [-0..-8] [--block-size N] [-A WINDOWS] [-j WORKERS] [--overwrite] source-dir destination-dir")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Converts between WAVE and FLAC, the formats are chosen by the file extensions.")
+		fmt.Fprintln(os.Stderr, "FLAC files can also be decoded to AIFF (.aif, .aiff) and AIFF-C (.aifc).")
+		fmt.Fprintln(os.Stderr, "Converting FLAC to FLAC re-encodes the audio keeping the tags.")
+		fmt.Fprintln(os.Stderr, "-A sets the apodization windows of the LPC analysis like flac -A, e.g. \"tukey(0.5);hann\".")
+		fmt.Fprintln(os.Stderr, "With --replay-gain track or album, decoded FLAC audio is normalized with its ReplayGain tags.")
+		fmt.Fprintln(os.Stderr, "--channels keeps some channels of decoded FLAC audio, --downmix mixes it down to stereo.")
+		fmt.Fprintln(os.Stderr, "--sample-rate and --bits resample and requantize decoded FLAC audio, e.g. --sample-rate 44100")
+		fmt.Fprintln(os.Stderr, "--bits 16 --dither for a distribution copy of a high resolution master.")
+		fmt.Fprintln(os.Stderr, "Given a directory, every WAVE and AIFF file in it is encoded to the same relative path")
+		fmt.Fprintln(os.Stderr, "under the destination, with tags from album.tags and <name>.tags and the front cover")
+		fmt.Fprintln(os.Stderr, "from <name>.jpg or the cover, folder or front image of its folder.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+}
+
+func runConvert(args []string) error {
+	level := 5
+	var blockSize int
+	var apodization string
+	var verify, overwrite bool
+	var workers int
+	var replayGain string
+	var preamp float64
+	var channels string
+	var downmix bool
+	var sampleRate uint
+	var bits uint
+	var dither bool
+
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.Usage = convertUsage(flags)
+	for l := 0; l <= 8; l++ {
+		flags.Var(&levelFlag{level: l, target: &level}, strconv.Itoa(l), fmt.Sprintf("compression level %d", l))
+	}
+	flags.IntVar(&blockSize, "block-size", 0, "samples per frame, defaults to the one of the compression level")
+	flags.StringVar(&apodization, "A", "", "apodization `windows` separated by semicolons, defaults to tukey(0.5)")
+	flags.BoolVar(&verify, "verify", false, "decode the output checking it matches the input")
+	flags.BoolVar(&overwrite, "overwrite", false, "re-encode directory files whose output is already up to date")
+	flags.IntVar(&workers, "j", runtime.NumCPU(), "number of directory files encoded in parallel")
+	flags.StringVar(&replayGain, "replay-gain", "", "apply the ReplayGain of the `mode` track or album when decoding FLAC")
+	flags.Float64Var(&preamp, "preamp", 0, "dB added to the ReplayGain")
+	flags.StringVar(&channels, "channels", "", "comma separated `list` of the channels kept when decoding FLAC, from 0")
+	flags.BoolVar(&downmix, "downmix", false, "mix the channels down to stereo when decoding FLAC")
+	flags.UintVar(&sampleRate, "sample-rate", 0, "resample decoded FLAC audio to this rate in Hz")
+	flags.UintVar(&bits, "bits", 0, "requantize decoded FLAC audio to this bit depth")
+	flags.BoolVar(&dither, "dither", false, "add triangular dither when lowering the bit depth")
+	rest := parseInterspersed(flags, args)
+
+	if len(rest) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an input and an output file")
+	}
+	input, output := rest[0], rest[1]
+
+	opts := flacgo.EncoderLevel(level)
+	if blockSize != 0 {
+		opts.BlockSize = blockSize
+	}
+	if apodization != "" {
+		windows, err := flacgo.ParseApodization(apodization)
+		if err != nil {
+			return err
+		}
+		opts.Apodization = windows
+	}
+
+	if sampleRate >= 1<<20 {
+		return fmt.Errorf("invalid --sample-rate %d", sampleRate)
+	}
+	if bits > 32 {
+		return fmt.Errorf("invalid --bits %d", bits)
+	}
+	if dither && bits == 0 {
+		return fmt.Errorf("--dither requires --bits")
+	}
+	conversion := flacgo.TranscodeOptions{Encoder: opts, SampleRate: uint32(sampleRate), BitsPerSample: uint8(bits), Dither: dither}
+	converts := sampleRate != 0 || bits != 0
+
+	var decodeOpts []flacgo.Option
+	switch replayGain {
+	case "":
+	case "track":
+		decodeOpts = append(decodeOpts, flacgo.WithReplayGain(flacgo.ReplayGainTrack, preamp))
+	case "album":
+		decodeOpts = append(decodeOpts, flacgo.WithReplayGain(flacgo.ReplayGainAlbum, preamp))
+	default:
+		return fmt.Errorf("invalid --replay-gain %q, expected track or album", replayGain)
+	}
+	switch {
+	case channels != "" && downmix:
+		return fmt.Errorf("--channels and --downmix can't be used together")
+	case channels != "":
+		var selected []int
+		for _, field := range strings.Split(channels, ",") {
+			ch, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return fmt.Errorf("invalid --channels %q, expected channel numbers separated by commas", channels)
+			}
+			selected = append(selected, ch)
+		}
+		decodeOpts = append(decodeOpts, flacgo.WithChannels(selected...))
+	case downmix:
+		decodeOpts = append(decodeOpts, flacgo.WithDownmix())
+	}
+
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		if decodeOpts != nil || converts {
+			return fmt.Errorf("--replay-gain, --channels, --downmix, --sample-rate and --bits only apply to FLAC input files")
+		}
+		return convertTree(input, output, flacgo.ConvertOptions{Encoder: opts, Overwrite: overwrite, Workers: workers})
+	}
+
+	inputFormat, err := audioFormat(input)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := audioFormat(output)
+	if err != nil {
+		return err
+	}
+
+	if (decodeOpts != nil || converts) && inputFormat != "flac" {
+		return fmt.Errorf("--replay-gain, --channels, --downmix, --sample-rate and --bits only apply to FLAC input files")
+	}
+
+	if err := convertFile(input, inputFormat, output, outputFormat, conversion, decodeOpts); err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	if verify && outputFormat == "flac" {
+		if _, err := verifyFile(output, false); err != nil {
+			return fmt.Errorf("%s: %w", output, err)
+		}
+	}
+
+	return nil
+}
+
+// audioFormat returns "wav", "aiff", "aifc" or "flac" according to the extension of path
+func audioFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".wave":
+		return "wav", nil
+	case ".aif", ".aiff":
+		return "aiff", nil
+	case ".aifc":
+		return "aifc", nil
+	case ".flac":
+		return "flac", nil
+	}
+	return "", fmt.Errorf("%s: unsupported file extension, expected .wav, .aiff, .aifc or .flac", path)
+}
+
+// convertFile converts input to output, the FLAC input is opened with decodeOpts and its audio
+// converted to the sample rate and bit depth of conversion
+func convertFile(input string, inputFormat string, output string, outputFormat string, conversion flacgo.TranscodeOptions, decodeOpts []flacgo.Option) error {
+	if inputFormat != "flac" && outputFormat != "flac" {
+		return fmt.Errorf("either the input or the output must be a FLAC file")
+	}
+	if inputFormat == "aiff" || inputFormat == "aifc" {
+		return fmt.Errorf("%s: AIFF files can only be written", input)
+	}
+
+	if inputFormat == "wav" {
+		return convertWAV(input, output, conversion.Encoder)
+	}
+
+	source, err := flacgo.Open(input, decodeOpts...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	defer source.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if outputFormat == "flac" {
+		if err := source.Transcode(out, conversion); err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+		return out.Close()
+	}
+
+	format := flacgo.AIFFFormatAIFF
+	if outputFormat == "aifc" {
+		format = flacgo.AIFFFormatAIFC
+	}
+	if conversion.SampleRate == 0 && conversion.BitsPerSample == 0 {
+		if outputFormat == "wav" {
+			err = source.ExportWAV(out)
+		} else {
+			err = source.ExportAIFF(out, format)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+		return out.Close()
+	}
+
+	// Converted audio is decoded in memory, resampling needs the samples around every frame boundary
+	pcm, err := source.DecodePCM()
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	if err := pcm.Convert(conversion.SampleRate, conversion.BitsPerSample, conversion.Dither); err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	if outputFormat == "wav" {
+		err = flacgo.WriteWAV(out, pcm)
+	} else {
+		err = flacgo.WriteAIFF(out, pcm, format)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", output, err)
+	}
+	return out.Close()
+}
+
+// convertWAV encodes the WAVE file input to output as it's read
+func convertWAV(input string, output string, opts flacgo.EncoderOptions) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := flacgo.EncodeWAV(bufio.NewReader(in), out, opts); err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	return out.Close()
+}
+
+func convertTree(source string, destination string, opts flacgo.ConvertOptions) error {
+	opts.Progress = func(result flacgo.ConvertResult) {
+		switch {
+		case result.Err != nil:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", result.Source, result.Err)
+		case result.Skipped:
+			fmt.Printf("%s: up to date\n", result.Destination)
+		default:
+			fmt.Printf("%s -> %s\n", result.Source, result.Destination)
+		}
+	}
+
+	// On Ctrl-C the files being encoded are finished and the rest is reported as not converted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := flacgo.ConvertTreeContext(ctx, source, destination, opts)
+	if report == nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, report)
+	if err != nil {
+		for _, canceled := range report.Canceled {
+			fmt.Fprintf(os.Stderr, "%s: not converted\n", canceled)
+		}
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d files failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
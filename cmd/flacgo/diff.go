@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func runDiff(args []string) error {
+	var asJSON, all bool
+
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo diff [--json] [--all] old.flac new.flac")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Compares the metadata blocks (types, lengths and hashes) and the audio of two files,")
+		fmt.Fprintln(os.Stderr, "e.g. to see what a tagger did to a file. It exits with 1 when they differ.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.BoolVar(&all, "all", false, "also print the unchanged blocks")
+	positional := parseInterspersed(flags, args)
+
+	if len(positional) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly two files")
+	}
+
+	diff, err := flacgo.DiffFiles(positional[0], positional[1])
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if err := printJSON(diff); err != nil {
+			return err
+		}
+	} else {
+		for _, change := range diff.Blocks {
+			if all || change.Kind != flacgo.BlockUnchanged {
+				fmt.Println(change)
+			}
+		}
+		if diff.AudioChanged {
+			fmt.Println("audio changed")
+		}
+	}
+
+	if diff.Changed() {
+		return fmt.Errorf("files differ")
+	}
+	return nil
+}
@@ -0,0 +1,697 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+const editHelp = `keys:
+  up/down, j/k   select a tag or a picture
+  enter          edit the value of the tag or the description of the picture
+  a              add a tag
+  d              delete the tag value or the picture
+  p              add a picture from an image file
+  i              replace the image of the picture
+  t              change the type of the picture
+  u              undo the last change
+  v              preview the staged changes
+  s              save the changes and exit
+  w              save the changes to another file and exit
+  q, esc         exit without saving
+  ?              show this help
+
+while editing a field, enter confirms it and esc cancels it`
+
+// editor is the terminal UI editing the tags and pictures of a single file
+type editor struct {
+	flac             *flacgo.Flac
+	path             string
+	original         []flacgo.VorbisComment
+	originalPictures []*flacgo.Picture
+	// comments and pictures are the current ones, staged changes included
+	comments []flacgo.VorbisComment
+	pictures []*flacgo.Picture
+	// selected is the index of the selected item, the tags first and then the pictures
+	selected int
+	scroll   int
+	// status is shown below the list, e.g. the error of the last change
+	status string
+	// field is the field being edited, if any
+	field *lineEdit
+	// result is printed once the screen is restored
+	result string
+	in     *bufio.Reader
+	out    *bufio.Writer
+}
+
+// lineEdit is a field being edited, inline on the selected row or on the status line
+type lineEdit struct {
+	label  string
+	value  []rune
+	cursor int
+	inline bool
+}
+
+// key is a key pressed, name is set for the keys not inserting a rune
+type key struct {
+	r    rune
+	name string
+}
+
+func runEdit(args []string) error {
+	flags := flag.NewFlagSet("edit", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo edit file.flac")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, editHelp)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	path := flags.Arg(0)
+	flac, err := flacgo.Open(path)
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	pictures, err := flac.StagedPictures()
+	if err != nil {
+		return err
+	}
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("flacgo edit needs a terminal, use flacgo tag to script changes: %w", err)
+	}
+	defer restore()
+
+	ed := &editor{
+		flac:             flac,
+		path:             path,
+		original:         flac.Comments(),
+		originalPictures: pictures,
+		in:               bufio.NewReader(os.Stdin),
+		out:              bufio.NewWriter(os.Stdout),
+	}
+	if err := ed.run(); err != nil {
+		return err
+	}
+	restore()
+	fmt.Println(ed.result)
+	return nil
+}
+
+// run shows the editor on the alternate screen until the changes are saved or discarded
+func (ed *editor) run() error {
+	ed.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		ed.out.WriteString("\x1b[?25h\x1b[?1049l")
+		ed.out.Flush()
+	}()
+
+	ed.refresh()
+	for {
+		ed.draw()
+		k, err := ed.readKey()
+		if err == io.EOF {
+			ed.result = "no changes saved"
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if done, err := ed.handle(k); done || err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs the command of a key and reports whether the editor is done
+func (ed *editor) handle(k key) (bool, error) {
+	ed.status = ""
+	tag, picture := ed.selectedTag(), ed.selectedPicture()
+	items := len(ed.comments) + len(ed.pictures)
+
+	switch {
+	case k.name == "up" || k.r == 'k':
+		ed.selected = max(ed.selected-1, 0)
+	case k.name == "down" || k.r == 'j':
+		ed.selected = min(ed.selected+1, max(items-1, 0))
+	case k.name == "home" || k.r == 'g':
+		ed.selected = 0
+	case k.name == "end" || k.r == 'G':
+		ed.selected = max(items-1, 0)
+
+	case k.name == "enter" && tag >= 0:
+		comment := ed.comments[tag]
+		value, ok, err := ed.edit(comment.Title+"=", comment.Value, true)
+		if err != nil || !ok || value == comment.Value {
+			return false, err
+		}
+		ed.apply(ed.setValue(tag, &value))
+	case k.name == "enter" && picture >= 0:
+		description, ok, err := ed.edit("description: ", ed.pictures[picture].Description, true)
+		if err != nil || !ok {
+			return false, err
+		}
+		ed.apply(ed.updatePicture(picture, func(p *flacgo.Picture) error {
+			p.Description = description
+			return nil
+		}))
+
+	case k.r == 'a':
+		title, ok, err := ed.edit("new tag key: ", "", false)
+		if err != nil || !ok || title == "" {
+			return false, err
+		}
+		value, ok, err := ed.edit(title+"=", "", false)
+		if err != nil || !ok {
+			return false, err
+		}
+		ed.apply(ed.flac.SetMetadataValues(title, append(ed.flac.MetadataValues(title), value)))
+		if added := slices.IndexFunc(ed.comments, func(comment flacgo.VorbisComment) bool {
+			return strings.EqualFold(comment.Title, title) && comment.Value == value
+		}); added >= 0 {
+			ed.selected = added
+		}
+
+	case (k.r == 'd' || k.name == "delete") && tag >= 0:
+		ed.apply(ed.setValue(tag, nil))
+	case (k.r == 'd' || k.name == "delete") && picture >= 0:
+		pictures := slices.Delete(slices.Clone(ed.pictures), picture, picture+1)
+		ed.apply(ed.flac.SetPictures(pictures))
+
+	case k.r == 'p':
+		path, ok, err := ed.edit("image path: ", "", false)
+		if err != nil || !ok || path == "" {
+			return false, err
+		}
+		pictureType := flacgo.PictureTypeFrontCover
+		if slices.ContainsFunc(ed.pictures, func(p *flacgo.Picture) bool { return p.PictureType == flacgo.PictureTypeFrontCover }) {
+			pictureType = flacgo.PictureTypeOther
+		}
+		added, err := readPicture(path, pictureType, "")
+		if err != nil {
+			ed.status = err.Error()
+			return false, nil
+		}
+		ed.apply(ed.flac.SetPictures(append(slices.Clone(ed.pictures), added)))
+		ed.selected = len(ed.comments) + len(ed.pictures) - 1
+	case k.r == 'i' && picture >= 0:
+		path, ok, err := ed.edit("image path: ", "", false)
+		if err != nil || !ok || path == "" {
+			return false, err
+		}
+		ed.apply(ed.updatePicture(picture, func(p *flacgo.Picture) error {
+			replaced, err := readPicture(path, p.PictureType, p.Description)
+			if err != nil {
+				return err
+			}
+			*p = *replaced
+			return nil
+		}))
+	case k.r == 't' && picture >= 0:
+		name, ok, err := ed.edit("picture type: ", flacgo.PictureTypeName(ed.pictures[picture].PictureType), false)
+		if err != nil || !ok {
+			return false, err
+		}
+		ed.apply(ed.updatePicture(picture, func(p *flacgo.Picture) error {
+			pictureType, err := flacgo.ParsePictureType(name)
+			p.PictureType = pictureType
+			return err
+		}))
+
+	case k.r == 'u':
+		if ed.flac.Undo() {
+			ed.status = "undone"
+		} else {
+			ed.status = "nothing to undo"
+		}
+		ed.refresh()
+	case k.r == 'v':
+		return false, ed.page(ed.diff())
+	case k.r == '?':
+		return false, ed.page(strings.Split(editHelp, "\n"))
+
+	case k.r == 's':
+		if ed.flac.ChangedOnDisk() {
+			if ok, err := ed.confirm("the file changed on disk since it was opened, overwrite it? [y/N] "); err != nil || !ok {
+				return false, err
+			}
+		}
+		return ed.save(nil), nil
+	case k.r == 'w':
+		path, ok, err := ed.edit("save to: ", "", false)
+		if err != nil || !ok || path == "" {
+			return false, err
+		}
+		return ed.save(&path), nil
+	case k.r == 'q' || k.name == "esc" || k.name == "ctrl-c":
+		if ed.flac.HasChanges() {
+			if ok, err := ed.confirm("discard the staged changes? [y/N] "); err != nil || !ok {
+				return false, err
+			}
+		}
+		ed.result = "no changes saved"
+		return true, nil
+
+	case k.name == "enter" || k.r == 'd' || k.r == 'i' || k.r == 't':
+		ed.status = "nothing selected"
+	default:
+		ed.status = "type '?' for the list of keys"
+	}
+	return false, nil
+}
+
+// selectedTag returns the index of the selected tag, or -1 if a picture is selected
+func (ed *editor) selectedTag() int {
+	if ed.selected < len(ed.comments) {
+		return ed.selected
+	}
+	return -1
+}
+
+// selectedPicture returns the index of the selected picture, or -1 if a tag is selected
+func (ed *editor) selectedPicture() int {
+	if picture := ed.selected - len(ed.comments); picture >= 0 && picture < len(ed.pictures) {
+		return picture
+	}
+	return -1
+}
+
+// apply shows the error of a change, if any, and reloads the tags and pictures
+func (ed *editor) apply(err error) {
+	if err != nil {
+		ed.status = err.Error()
+	}
+	ed.refresh()
+}
+
+// refresh reloads the tags and pictures with the staged changes
+func (ed *editor) refresh() {
+	ed.comments = ed.flac.Comments()
+	pictures, err := ed.flac.StagedPictures()
+	if err != nil {
+		ed.status = err.Error()
+	} else {
+		ed.pictures = pictures
+	}
+	ed.selected = max(min(ed.selected, len(ed.comments)+len(ed.pictures)-1), 0)
+}
+
+// setValue stages value in place of the tag number index, keeping the other values of its key,
+// or removes the tag value if value is nil
+func (ed *editor) setValue(index int, value *string) error {
+	comment := ed.comments[index]
+	var values []string
+	for i, other := range ed.comments {
+		switch {
+		case !strings.EqualFold(other.Title, comment.Title):
+		case i != index:
+			values = append(values, other.Value)
+		case value != nil:
+			values = append(values, *value)
+		}
+	}
+	return ed.flac.SetMetadataValues(comment.Title, values)
+}
+
+// updatePicture stages the pictures with a copy of the picture number index changed by update
+func (ed *editor) updatePicture(index int, update func(p *flacgo.Picture) error) error {
+	pictures := slices.Clone(ed.pictures)
+	updated := *pictures[index]
+	if err := update(&updated); err != nil {
+		return err
+	}
+	pictures[index] = &updated
+	return ed.flac.SetPictures(pictures)
+}
+
+// readPicture reads the image at path as a picture of the given type
+func readPicture(path string, pictureType uint32, description string) (*flacgo.Picture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	picture, err := flacgo.NewPicture(data, pictureType, description)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", path, err)
+	}
+	return picture, nil
+}
+
+// save writes the changes and reports whether the editor is done, failures are shown on the status line
+func (ed *editor) save(outputPath *string) bool {
+	if err := ed.flac.Save(outputPath); err != nil {
+		ed.status = err.Error()
+		return false
+	}
+	ed.result = "saved"
+	if outputPath != nil {
+		ed.result = fmt.Sprintf("saved to '%s'", *outputPath)
+	}
+	return true
+}
+
+// edit lets the user edit value, inline on the selected row or on the status line, and reports
+// whether it was confirmed
+func (ed *editor) edit(label string, value string, inline bool) (string, bool, error) {
+	field := &lineEdit{label: label, value: []rune(value), cursor: len([]rune(value)), inline: inline}
+	ed.field = field
+	defer func() { ed.field = nil }()
+
+	for {
+		ed.draw()
+		k, err := ed.readKey()
+		if err != nil {
+			return "", false, err
+		}
+		switch k.name {
+		case "enter":
+			return string(field.value), true, nil
+		case "esc", "ctrl-c":
+			return "", false, nil
+		case "left":
+			field.cursor = max(field.cursor-1, 0)
+		case "right":
+			field.cursor = min(field.cursor+1, len(field.value))
+		case "home":
+			field.cursor = 0
+		case "end":
+			field.cursor = len(field.value)
+		case "backspace":
+			if field.cursor > 0 {
+				field.value = slices.Delete(field.value, field.cursor-1, field.cursor)
+				field.cursor--
+			}
+		case "delete":
+			if field.cursor < len(field.value) {
+				field.value = slices.Delete(field.value, field.cursor, field.cursor+1)
+			}
+		case "ctrl-u":
+			field.value = field.value[field.cursor:]
+			field.cursor = 0
+		case "":
+			if unicode.IsPrint(k.r) {
+				field.value = slices.Insert(field.value, field.cursor, k.r)
+				field.cursor++
+			}
+		}
+	}
+}
+
+// confirm asks a yes or no question on the status line
+func (ed *editor) confirm(question string) (bool, error) {
+	ed.status = question
+	ed.draw()
+	k, err := ed.readKey()
+	ed.status = ""
+	return k.r == 'y' || k.r == 'Y', err
+}
+
+// page shows lines in place of the editor until a key is pressed
+func (ed *editor) page(lines []string) error {
+	width, height := ed.size()
+	ed.out.WriteString("\x1b[H")
+	for i := 0; i < height-1; i++ {
+		if i < len(lines) {
+			ed.out.WriteString(truncate(lines[i], width))
+		}
+		ed.out.WriteString("\x1b[K\r\n")
+	}
+	ed.out.WriteString("\x1b[7m" + pad("press any key to go back", width) + "\x1b[0m\x1b[J")
+	ed.out.Flush()
+	_, err := ed.readKey()
+	return err
+}
+
+// row is a line of the list, item is the index of the tag or picture it shows or -1.
+// The field edited inline replaces the text following prefix.
+type row struct {
+	prefix string
+	text   string
+	item   int
+}
+
+// rows returns the lines of the list with markers for the changes: + for the tag values and pictures
+// added, - for the ones removed
+func (ed *editor) rows() []row {
+	rows := []row{{text: "tags:", item: -1}}
+	if len(ed.comments) == 0 {
+		rows = append(rows, row{text: "    (none)", item: -1})
+	}
+	for i, cmt := range ed.comments {
+		marker := " "
+		if !slices.Contains(ed.original, cmt) {
+			marker = "+"
+		}
+		rows = append(rows, row{fmt.Sprintf("%s %3d  ", marker, i+1), cmt.Title + "=" + displayValue(cmt.Value), i})
+	}
+	for _, cmt := range ed.original {
+		if !slices.Contains(ed.comments, cmt) {
+			rows = append(rows, row{"-       ", cmt.Title + "=" + displayValue(cmt.Value), -1})
+		}
+	}
+
+	rows = append(rows, row{item: -1}, row{text: "pictures:", item: -1})
+	if len(ed.pictures) == 0 {
+		rows = append(rows, row{text: "    (none)", item: -1})
+	}
+	for i, picture := range ed.pictures {
+		marker := " "
+		if !containsPicture(ed.originalPictures, picture) {
+			marker = "+"
+		}
+		rows = append(rows, row{fmt.Sprintf("%s %3d  ", marker, i+1), describePicture(picture), len(ed.comments) + i})
+	}
+	for _, picture := range ed.originalPictures {
+		if !containsPicture(ed.pictures, picture) {
+			rows = append(rows, row{"-       ", describePicture(picture), -1})
+		}
+	}
+	return rows
+}
+
+// draw redraws the whole screen: the file name, the list of tags and pictures, the status line and the keys
+func (ed *editor) draw() {
+	width, height := ed.size()
+	rows := ed.rows()
+	listHeight := max(height-4, 1)
+
+	selectedRow := slices.IndexFunc(rows, func(r row) bool { return r.item == ed.selected })
+	if selectedRow >= 0 {
+		ed.scroll = min(ed.scroll, selectedRow)
+		ed.scroll = max(ed.scroll, selectedRow-listHeight+1)
+	}
+	ed.scroll = max(min(ed.scroll, len(rows)-listHeight), 0)
+
+	ed.out.WriteString("\x1b[H")
+	title := "flacgo edit " + ed.path
+	if ed.flac.HasChanges() {
+		title += " (modified)"
+	}
+	ed.out.WriteString("\x1b[1m" + truncate(title, width) + "\x1b[0m\x1b[K\r\n\x1b[K\r\n")
+
+	for i := ed.scroll; i < ed.scroll+listHeight; i++ {
+		switch {
+		case i >= len(rows):
+		case i == selectedRow && ed.field != nil && ed.field.inline:
+			ed.out.WriteString(rows[i].prefix + ed.field.render(width-len(rows[i].prefix)))
+		case i == selectedRow:
+			ed.out.WriteString("\x1b[7m" + pad(rows[i].prefix+rows[i].text, width) + "\x1b[0m")
+		default:
+			ed.out.WriteString(truncate(rows[i].prefix+rows[i].text, width))
+		}
+		ed.out.WriteString("\x1b[K\r\n")
+	}
+
+	switch {
+	case ed.field != nil && !ed.field.inline:
+		ed.out.WriteString(ed.field.render(width))
+	default:
+		ed.out.WriteString(truncate(ed.status, width))
+	}
+	ed.out.WriteString("\x1b[K\r\n")
+	ed.out.WriteString("\x1b[7m" + pad("enter edit  a add  d delete  p picture  t type  u undo  v preview  s save  q quit  ? help", width) + "\x1b[0m\x1b[J")
+	ed.out.Flush()
+}
+
+// size returns the size of the terminal, 80x24 if unknown
+func (ed *editor) size() (int, int) {
+	width, height, err := terminalSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// render returns the label and value of the field in width columns, scrolled to show the cursor,
+// which is drawn in reverse video
+func (field *lineEdit) render(width int) string {
+	text := append([]rune(field.label), []rune(displayValue(string(field.value)))...)
+	cursor := len([]rune(field.label)) + field.cursor
+	start := max(cursor-width+2, 0)
+	end := min(len(text), start+width-1)
+
+	under := " "
+	if cursor < len(text) {
+		under = string(text[cursor])
+	}
+	if cursor >= end {
+		return string(text[start:end]) + "\x1b[7m" + under + "\x1b[0m"
+	}
+	return string(text[start:cursor]) + "\x1b[7m" + under + "\x1b[0m" + string(text[cursor+1:end])
+}
+
+// readKey reads a key press, decoding the escape sequences of the arrow and editing keys
+func (ed *editor) readKey() (key, error) {
+	r, _, err := ed.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return key{name: "enter"}, nil
+	case 0x7f, 0x08:
+		return key{name: "backspace"}, nil
+	case 0x01:
+		return key{name: "home"}, nil
+	case 0x05:
+		return key{name: "end"}, nil
+	case 0x03:
+		return key{name: "ctrl-c"}, nil
+	case 0x15:
+		return key{name: "ctrl-u"}, nil
+	case 0x1b:
+	default:
+		return key{r: r}, nil
+	}
+
+	// A lone escape is the Esc key, sequences arrive at once
+	if ed.in.Buffered() == 0 {
+		return key{name: "esc"}, nil
+	}
+	var sequence []byte
+	for ed.in.Buffered() > 0 {
+		b, _ := ed.in.ReadByte()
+		sequence = append(sequence, b)
+		if len(sequence) > 1 && b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	switch string(sequence) {
+	case "[A", "OA":
+		return key{name: "up"}, nil
+	case "[B", "OB":
+		return key{name: "down"}, nil
+	case "[C", "OC":
+		return key{name: "right"}, nil
+	case "[D", "OD":
+		return key{name: "left"}, nil
+	case "[H", "OH", "[1~", "[7~":
+		return key{name: "home"}, nil
+	case "[F", "OF", "[4~", "[8~":
+		return key{name: "end"}, nil
+	case "[3~":
+		return key{name: "delete"}, nil
+	}
+	return key{name: "unknown"}, nil
+}
+
+// diff returns the staged changes: the tag values removed and added key by key, then the pictures
+func (ed *editor) diff() []string {
+	var keys []string
+	for _, cmt := range slices.Concat(ed.original, ed.comments) {
+		if !slices.ContainsFunc(keys, func(key string) bool { return strings.EqualFold(key, cmt.Title) }) {
+			keys = append(keys, cmt.Title)
+		}
+	}
+
+	var lines []string
+	changed := func(marker string, comments []flacgo.VorbisComment, other []flacgo.VorbisComment, key string) {
+		for _, cmt := range comments {
+			if strings.EqualFold(cmt.Title, key) && !slices.Contains(other, cmt) {
+				lines = append(lines, fmt.Sprintf("%s %s=%s", marker, cmt.Title, displayValue(cmt.Value)))
+			}
+		}
+	}
+	for _, key := range keys {
+		changed("-", ed.original, ed.comments, key)
+		changed("+", ed.comments, ed.original, key)
+	}
+	for _, picture := range ed.originalPictures {
+		if !containsPicture(ed.pictures, picture) {
+			lines = append(lines, "- "+describePicture(picture))
+		}
+	}
+	for _, picture := range ed.pictures {
+		if !containsPicture(ed.originalPictures, picture) {
+			lines = append(lines, "+ "+describePicture(picture))
+		}
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "no changes")
+	}
+	return lines
+}
+
+// displayValue shows the line breaks of a value on a single line
+func displayValue(value string) string {
+	return strings.ReplaceAll(value, "\n", "↵")
+}
+
+// truncate cuts text to width columns, counting a column per rune
+func truncate(text string, width int) string {
+	if runes := []rune(text); len(runes) > width {
+		return string(runes[:max(width-1, 0)]) + "…"
+	}
+	return text
+}
+
+// pad truncates text to width columns and fills the rest with spaces
+func pad(text string, width int) string {
+	text = truncate(text, width)
+	return text + strings.Repeat(" ", max(width-len([]rune(text)), 0))
+}
+
+func findComment(comments []flacgo.VorbisComment, title string) (flacgo.VorbisComment, bool) {
+	for _, cmt := range comments {
+		if strings.EqualFold(cmt.Title, title) {
+			return cmt, true
+		}
+	}
+	return flacgo.VorbisComment{}, false
+}
+
+// containsPicture reports whether pictures has one with the fields and image of picture
+func containsPicture(pictures []*flacgo.Picture, picture *flacgo.Picture) bool {
+	return slices.ContainsFunc(pictures, func(other *flacgo.Picture) bool {
+		return other.PictureType == picture.PictureType && other.MimeType == picture.MimeType &&
+			other.Description == picture.Description && other.Width == picture.Width &&
+			other.Height == picture.Height && bytes.Equal(other.Data, picture.Data)
+	})
+}
+
+func describePicture(picture *flacgo.Picture) string {
+	description := fmt.Sprintf("%s %s %dx%d (%d bytes)", flacgo.PictureTypeName(picture.PictureType), picture.MimeType, picture.Width, picture.Height, len(picture.Data))
+	if picture.Description != "" {
+		description += fmt.Sprintf(" %q", picture.Description)
+	}
+	return description
+}
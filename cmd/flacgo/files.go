@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stringList is a flag.Value collecting every occurrence of a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// fileSelection holds the flags controlling which files a command applies to
+type fileSelection struct {
+	recursive bool
+	include   stringList
+	exclude   stringList
+}
+
+// register adds the file selection flags to the given flag set, the current values are used as defaults
+func (sel *fileSelection) register(flags *flag.FlagSet) {
+	flags.BoolVar(&sel.recursive, "r", sel.recursive, "descend into directories")
+	flags.Var(&sel.include, "include", "only select files whose name matches the pattern (repeatable, default *.flac)")
+	flags.Var(&sel.exclude, "exclude", "skip files whose name matches the pattern (repeatable)")
+}
+
+// parseInterspersed parses args allowing flags after positional arguments, like
+// "flacgo art import cover.jpg --type front *.flac", and returns the positional ones.
+// A "--" argument ends flag parsing.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// matches reports whether name matches any of the given patterns, ignoring case
+func matches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expand resolves paths, globs and, if recursive is set, directories into the list of files to process.
+// Files found while walking directories must match the include patterns, explicitly listed
+// files are always selected; exclude patterns apply to both.
+func (sel *fileSelection) expand(args []string) ([]string, error) {
+	include := sel.include
+	if len(include) == 0 {
+		include = stringList{"*.flac"}
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] && !matches(sel.exclude, filepath.Base(path)) {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			globbed, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", arg, err)
+			}
+			if len(globbed) == 0 {
+				return nil, fmt.Errorf("no files matching '%s'", arg)
+			}
+			paths = globbed
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if !info.IsDir() {
+				add(path)
+				continue
+			}
+			if !sel.recursive {
+				return nil, fmt.Errorf("'%s' is a directory (use -r to descend into it)", path)
+			}
+
+			err = filepath.WalkDir(path, func(walked string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() {
+					if walked != path && matches(sel.exclude, entry.Name()) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if matches(include, entry.Name()) {
+					add(walked)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to walk '%s': %w", path, err)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// forEachFile runs fn on every file, reporting failures as they happen and a summary at the end
+func forEachFile(files []string, fn func(path string) error) error {
+	failed := 0
+	for _, path := range files {
+		if err := fn(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+		}
+	}
+
+	if len(files) > 1 || failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d succeeded, %d failed\n", len(files)-failed, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(files))
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+type lintResult struct {
+	fileResult
+	Issues []lintIssue `json:"issues"`
+}
+
+type lintIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+
+	severity flacgo.Severity
+}
+
+type lintOptions struct {
+	required []string
+	artwork  flacgo.ArtworkPolicy
+}
+
+func runLint(args []string) error {
+	selection := fileSelection{recursive: true}
+	var asJSON bool
+	var required, failOn, artTypes string
+	var minArtDim int
+	var options lintOptions
+	policy := flacgo.DefaultArtworkPolicy
+
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo lint [--json] [--require TAGS] [--min-art PIXELS] [--art-aspect RATIO] [--art-types TYPES] [--max-art-size BYTES] [--art-baseline=false] [--art-rgb=false] [--fail-on LEVEL] path...")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Exit status is 0 when no issues are found, 1 for warnings and 2 for errors.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.StringVar(&required, "require", "ARTIST,TITLE,ALBUM", "comma separated list of tags every file must have")
+	flags.IntVar(&minArtDim, "min-art", policy.MinWidth, "minimum width and height in pixels of the pictures")
+	flags.Float64Var(&policy.MaxAspectRatio, "art-aspect", policy.MaxAspectRatio, "maximum ratio between the longest and the shortest side of the pictures, 0 to disable")
+	flags.StringVar(&artTypes, "art-types", strings.Join(policy.MimeTypes, ","), "comma separated list of accepted picture MIME types, empty to accept all")
+	flags.IntVar(&policy.MaxSize, "max-art-size", policy.MaxSize, "maximum size in bytes of the pictures, 0 to disable")
+	flags.BoolVar(&policy.Baseline, "art-baseline", policy.Baseline, "flag progressive JPEG pictures")
+	flags.BoolVar(&policy.RGB, "art-rgb", policy.RGB, "flag CMYK JPEG pictures")
+	flags.StringVar(&failOn, "fail-on", "warning", "lowest severity making the exit status non-zero, 'warning' or 'error'")
+	selection.register(flags)
+	flags.Parse(args)
+
+	threshold := flacgo.SeverityWarning
+	switch failOn {
+	case "warning":
+	case "error":
+		threshold = flacgo.SeverityError
+	default:
+		return fmt.Errorf("invalid --fail-on value '%s'", failOn)
+	}
+
+	for _, tag := range strings.Split(required, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			options.required = append(options.required, tag)
+		}
+	}
+
+	policy.MinWidth, policy.MinHeight = minArtDim, minArtDim
+	policy.MimeTypes = nil
+	for _, mimeType := range strings.Split(artTypes, ",") {
+		if mimeType = strings.TrimSpace(mimeType); mimeType != "" {
+			policy.MimeTypes = append(policy.MimeTypes, mimeType)
+		}
+	}
+	options.artwork = policy
+
+	files, err := selection.expand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	results := make([]lintResult, 0, len(files))
+	// Readable files of each folder, used for the album consistency check
+	folders := make(map[string][]string)
+	var folderOrder []string
+
+	for _, path := range files {
+		issues, readable := lintFile(path, options)
+		results = append(results, lintResult{fileResult: fileResult{Path: path}, Issues: issues})
+
+		if readable {
+			dir := filepath.Dir(path)
+			if _, ok := folders[dir]; !ok {
+				folderOrder = append(folderOrder, dir)
+			}
+			folders[dir] = append(folders[dir], path)
+		}
+	}
+
+	for _, dir := range folderOrder {
+		if issues := lintFolder(folders[dir]); len(issues) > 0 {
+			results = append(results, lintResult{fileResult: fileResult{Path: dir}, Issues: issues})
+		}
+	}
+
+	worst := flacgo.SeverityInfo
+	found := 0
+	for _, result := range results {
+		for _, issue := range result.Issues {
+			found++
+			if issue.severity > worst {
+				worst = issue.severity
+			}
+			if !asJSON {
+				fmt.Printf("%s: %s: %s (%s)\n", result.Path, issue.Severity, issue.Message, issue.Code)
+			}
+		}
+	}
+
+	if asJSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	}
+
+	if found == 0 || worst < threshold {
+		return nil
+	}
+
+	code := 1
+	if worst == flacgo.SeverityError {
+		code = 2
+	}
+	return &exitError{code, fmt.Errorf("found %d issues in %d files", found, len(files))}
+}
+
+func newLintIssue(severity flacgo.Severity, code string, format string, args ...any) lintIssue {
+	return lintIssue{
+		Severity: severity.String(),
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		severity: severity,
+	}
+}
+
+// lintFile checks a single file, it also reports whether the file could be read for the folder level checks
+func lintFile(path string, options lintOptions) ([]lintIssue, bool) {
+	issues := []lintIssue{}
+
+	flac, err := flacgo.OpenReadOnly(path, flacgo.WithArtworkPolicy(options.artwork))
+	if err != nil {
+		return append(issues, newLintIssue(flacgo.SeverityError, "unreadable", "%v", err)), false
+	}
+	defer flac.Close()
+
+	for _, issue := range flac.Validate() {
+		issues = append(issues, newLintIssue(issue.Severity, issue.Code, "%s", issue.Message))
+	}
+
+	comments := flac.Comments()
+	for _, tag := range options.required {
+		if cmt, found := findComment(comments, tag); !found || strings.TrimSpace(cmt.Value) == "" {
+			issues = append(issues, newLintIssue(flacgo.SeverityWarning, "missing-tag", "missing required tag %s", tag))
+		}
+	}
+
+	if _, err := flac.Languages(); err != nil {
+		issues = append(issues, newLintIssue(flacgo.SeverityWarning, "invalid-language", "%v", err))
+	}
+	if _, _, err := flac.ReleaseCountry(); err != nil {
+		issues = append(issues, newLintIssue(flacgo.SeverityWarning, "invalid-country", "%v", err))
+	}
+
+	// Errors reading the cover are already reported by Validate, like the pictures breaking the artwork policy
+	if cover, err := flac.CoverPicture(); err == nil && cover == nil {
+		issues = append(issues, newLintIssue(flacgo.SeverityWarning, "missing-art", "no cover picture"))
+	}
+
+	return issues, true
+}
+
+// lintFolder reports the album tags and covers that differ between the files of a folder
+func lintFolder(paths []string) []lintIssue {
+	var issues []lintIssue
+	if len(paths) < 2 {
+		return issues
+	}
+
+	report, err := flacgo.CheckAlbum(paths, flacgo.AlbumOptions{})
+	if err != nil {
+		return append(issues, newLintIssue(flacgo.SeverityError, "unreadable", "%v", err))
+	}
+
+	for _, conflict := range report.Conflicts {
+		code := "inconsistent-album"
+		if conflict.Key == flacgo.AlbumArtworkKey {
+			code = "inconsistent-art"
+		}
+		issues = append(issues, newLintIssue(flacgo.SeverityWarning, code, "%s", conflict))
+	}
+
+	return issues
+}
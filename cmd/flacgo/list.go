@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+type listResult struct {
+	fileResult
+	Blocks []blockEntry `json:"blocks"`
+}
+
+type blockEntry struct {
+	Type   string `json:"type"`
+	Offset int64  `json:"offset"`
+	Length uint32 `json:"length"`
+	IsLast bool   `json:"is_last"`
+}
+
+func runList(args []string) error {
+	var selection fileSelection
+	var asJSON bool
+	var dump int
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo list [--json] [--dump N] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.IntVar(&dump, "dump", -1, "print the fields and a hex dump of block number `N` instead of the list")
+	selection.register(flags)
+	flags.Parse(args)
+
+	files, err := selection.expand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	if dump >= 0 {
+		if asJSON {
+			return fmt.Errorf("--dump can't be used with --json")
+		}
+		return forEachFile(files, func(path string) error {
+			if len(files) > 1 {
+				fmt.Printf("%s:\n", path)
+			}
+			return dumpBlock(path, dump)
+		})
+	}
+
+	results := make([]listResult, 0, len(files))
+	err = forEachFile(files, func(path string) error {
+		result := listResult{fileResult: fileResult{Path: path}, Blocks: []blockEntry{}}
+		blocks, err := readBlocks(path)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		for _, block := range blocks {
+			result.Blocks = append(result.Blocks, blockEntry{
+				Type:   block.BlockType,
+				Offset: block.Index,
+				Length: block.BlockHeader.BlockLength,
+				IsLast: block.IsLastBlock,
+			})
+		}
+		results = append(results, result)
+
+		if !asJSON && err == nil {
+			if len(files) > 1 {
+				fmt.Printf("%s:\n", path)
+			}
+			for i, block := range result.Blocks {
+				fmt.Printf("%3d  %-15s offset=%-10d length=%d\n", i, block.Type, block.Offset, block.Length)
+			}
+		}
+		return err
+	})
+
+	if asJSON {
+		if jsonErr := printJSON(results); jsonErr != nil {
+			return jsonErr
+		}
+	}
+
+	return err
+}
+
+func readBlocks(path string) ([]flacgo.MetadataBlock, error) {
+	flac, err := flacgo.OpenReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	return flac.Blocks()
+}
+
+func dumpBlock(path string, index int) error {
+	flac, err := flacgo.OpenReadOnly(path, flacgo.WithHeaderOnly())
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	return flac.DumpBlock(os.Stdout, index)
+}
@@ -0,0 +1,79 @@
+// Command flacgo is a command line tool to inspect and edit FLAC files
+// built on top of the flacgo library.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// command describes a flacgo subcommand
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"edit", "edit the tags and pictures of a file in a terminal UI", runEdit},
+	{"tags", "print the tags of files", runTags},
+	{"list", "list the metadata blocks of files", runList},
+	{"diff", "compare the metadata blocks of two files", runDiff},
+	{"lint", "check files for missing tags, artwork problems and invalid layouts", runLint},
+	{"manifest", "create and verify checksum manifests of a library", runManifest},
+	{"verify", "decode files checking frame CRCs and the audio MD5", runVerify},
+	{"repair", "fix the structural problems of files", runRepair},
+	{"tag", "set tags of a file, use '-' to stream from stdin to stdout", runTag},
+	{"art", "import, export, list and remove pictures", runArt},
+	{"rename", "rename files after their tags", runRename},
+	{"convert", "convert between WAVE and FLAC", runConvert},
+	{"record", "encode raw PCM from stdin as it arrives", runRecord},
+	{"trim", "detect and trim the silence at the edges of the audio", runTrim},
+	{"stats", "print statistics about the audio, metadata and artwork of a library", runStats},
+	{"bitrate", "print the bitrate of the audio over time or per frame", runBitrate},
+}
+
+// exitError makes the command exit with a specific status code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: flacgo <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "flacgo %s: %v\n", cmd.name, err)
+				var exit *exitError
+				if errors.As(err, &exit) {
+					os.Exit(exit.code)
+				}
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "flacgo: unknown command '%s'\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
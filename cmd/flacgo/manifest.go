@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+type manifestResult struct {
+	fileResult
+	Status string `json:"status"`
+}
+
+func manifestUsage() {
+	fmt.Fprintln(os.Stderr, "usage: flacgo manifest create [-o FILE] dir")
+	fmt.Fprintln(os.Stderr, "       flacgo manifest verify [--json] [--strict] manifest [dir]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "verify reports files whose tags changed separately from files whose audio changed.")
+	fmt.Fprintln(os.Stderr, "dir defaults to the folder containing the manifest.")
+}
+
+func runManifest(args []string) error {
+	if len(args) == 0 {
+		manifestUsage()
+		return fmt.Errorf("missing manifest subcommand")
+	}
+
+	switch args[0] {
+	case "create":
+		return runManifestCreate(args[1:])
+	case "verify":
+		return runManifestVerify(args[1:])
+	default:
+		manifestUsage()
+		return fmt.Errorf("unknown manifest subcommand '%s'", args[0])
+	}
+}
+
+func runManifestCreate(args []string) error {
+	var output string
+	flags := flag.NewFlagSet("manifest create", flag.ExitOnError)
+	flags.Usage = manifestUsage
+	flags.StringVar(&output, "o", "", "write the manifest to this file instead of stdout")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		manifestUsage()
+		return fmt.Errorf("expected exactly one directory")
+	}
+
+	manifest, err := flacgo.BuildManifest(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err = manifest.WriteTo(w)
+	return err
+}
+
+func runManifestVerify(args []string) error {
+	var asJSON, strict bool
+	flags := flag.NewFlagSet("manifest verify", flag.ExitOnError)
+	flags.Usage = manifestUsage
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.BoolVar(&strict, "strict", false, "also fail when only tags or layout changed")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		manifestUsage()
+		return fmt.Errorf("expected a manifest and an optional directory")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := flacgo.ReadManifest(f)
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Dir(flags.Arg(0))
+	if flags.NArg() == 2 {
+		root = flags.Arg(1)
+	}
+
+	failed := 0
+	results := make([]manifestResult, 0, len(manifest.Entries))
+	for _, result := range manifest.Verify(root) {
+		entry := manifestResult{fileResult: fileResult{Path: result.Entry.Path}, Status: result.Status.String()}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+		}
+		results = append(results, entry)
+
+		if result.Status >= flacgo.ManifestAudioChanged || strict && result.Status != flacgo.ManifestOK {
+			failed++
+		}
+		if !asJSON && result.Status != flacgo.ManifestOK {
+			fmt.Printf("%s: %s\n", result.Entry.Path, result.Status)
+		}
+	}
+
+	if asJSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed verification", failed, len(results))
+	}
+
+	return nil
+}
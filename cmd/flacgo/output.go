@@ -0,0 +1,19 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// fileResult is the common shape of the per-file JSON output, Error is set when the file failed
+type fileResult struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
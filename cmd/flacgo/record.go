@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func runRecord(args []string) error {
+	level := 5
+	var sampleRate, bitsPerSample, channels int
+	var tags stringList
+
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo record [-0..-8] [--rate HZ] [--bits N] [--channels N] [--tag KEY=VALUE] output.flac")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Encodes raw signed little-endian interleaved PCM read from stdin as it arrives, e.g.")
+		fmt.Fprintln(os.Stderr, "arecord -f cd -t raw | flacgo record live.flac. Recording stops at the end of the input")
+		fmt.Fprintln(os.Stderr, "or on Ctrl-C, then the length and MD5 signature are written back to the file.")
+		fmt.Fprintln(os.Stderr, "Use '-' as output to write the stream to stdout, with an unknown length.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	for l := 0; l <= 8; l++ {
+		flags.Var(&levelFlag{level: l, target: &level}, strconv.Itoa(l), fmt.Sprintf("compression level %d", l))
+	}
+	flags.IntVar(&sampleRate, "rate", 44100, "sample rate in Hz")
+	flags.IntVar(&bitsPerSample, "bits", 16, "bits per sample, stored in whole bytes")
+	flags.IntVar(&channels, "channels", 2, "number of channels")
+	flags.Var(&tags, "tag", "tag the recording with `KEY=VALUE` (repeatable)")
+	rest := parseInterspersed(flags, args)
+
+	if len(rest) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one output file")
+	}
+	if sampleRate <= 0 || bitsPerSample < 4 || bitsPerSample > 32 || channels < 1 {
+		return fmt.Errorf("invalid format %d Hz, %d bits, %d channels", sampleRate, bitsPerSample, channels)
+	}
+
+	opts := flacgo.EncoderLevel(level)
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid tag %q, expected KEY=VALUE", tag)
+		}
+		opts.Comments = append(opts.Comments, flacgo.VorbisComment{Title: strings.ToUpper(key), Value: value})
+	}
+
+	var out io.Writer = os.Stdout
+	if rest[0] != "-" {
+		file, err := os.Create(rest[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	// Frames are written as soon as they are encoded, buffering only spares the syscalls
+	w := bufio.NewWriter(out)
+	encoder, err := flacgo.NewEncoder(seekableWriter{w, out}, uint32(sampleRate), uint8(bitsPerSample), channels, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := recordPCM(ctx, encoder, os.Stdin, bitsPerSample, channels); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	streamInfo := encoder.StreamInfo()
+	fmt.Fprintf(os.Stderr, "recorded %d samples (%.1fs)\n", streamInfo.TotalSamples, float64(streamInfo.TotalSamples)/float64(sampleRate))
+	return nil
+}
+
+// recordPCM feeds the encoder with the samples read from r until its end or until ctx is canceled
+func recordPCM(ctx context.Context, encoder *flacgo.Encoder, r io.Reader, bitsPerSample, channels int) error {
+	bytesPerSample := (bitsPerSample + 7) / 8
+	frameSize := bytesPerSample * channels
+
+	// Reads block, they are done aside so an interrupt stops the recording right away
+	chunks := make(chan []byte)
+	failed := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 64*1024/frameSize*frameSize)
+			n, err := io.ReadAtLeast(r, buf, frameSize)
+			if n > 0 {
+				select {
+				case chunks <- buf[:n]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					failed <- fmt.Errorf("unable to read PCM: %w", err)
+				}
+				return
+			}
+		}
+	}()
+
+	var leftover []byte
+	samples := make([]int32, 0, 64*1024)
+	for {
+		var chunk []byte
+		var ok bool
+		select {
+		case chunk, ok = <-chunks:
+		case <-ctx.Done():
+			return nil
+		}
+		if !ok {
+			select {
+			case err := <-failed:
+				return err
+			default:
+				return nil
+			}
+		}
+
+		// A read can end in the middle of a sample, the rest comes with the next one
+		data := append(leftover, chunk...)
+		whole := len(data) / frameSize * frameSize
+		samples = samples[:0]
+		for i := 0; i < whole; i += bytesPerSample {
+			var value uint32
+			for b := 0; b < bytesPerSample; b++ {
+				value |= uint32(data[i+b]) << (8 * b)
+			}
+			shift := 32 - 8*bytesPerSample
+			samples = append(samples, int32(value<<shift)>>shift)
+		}
+		leftover = append(leftover[:0], data[whole:]...)
+
+		if err := encoder.Write(samples); err != nil {
+			return err
+		}
+	}
+}
+
+// seekableWriter writes through a buffer to a file, flushing it before seeking so STREAMINFO can be
+// updated on Close; it doesn't seek when the file can't
+type seekableWriter struct {
+	*bufio.Writer
+	file io.Writer
+}
+
+func (w seekableWriter) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := w.file.(io.Seeker)
+	if !ok {
+		return 0, fmt.Errorf("output can't seek")
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	return seeker.Seek(offset, whence)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+// renameResult is the JSON output of a planned rename
+type renameResult struct {
+	fileResult
+	New string `json:"new"`
+}
+
+func runRename(args []string) error {
+	var selection fileSelection
+	var template string
+	var dryRun, asJSON bool
+
+	flags := flag.NewFlagSet("rename", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo rename -t TEMPLATE [--dry-run] [--json] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Renames files after their tags, e.g. -t '{ALBUMARTIST|ARTIST}/{ALBUM}/{TRACKNUMBER:2} {TITLE}'.")
+		fmt.Fprintln(os.Stderr, "Relative templates are relative to the directory of each file, nothing is moved")
+		fmt.Fprintln(os.Stderr, "if a file is missing a tag or two files would collide.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&template, "t", "", "template of the new paths, tags in braces")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the planned renames and collisions without moving files")
+	flags.BoolVar(&asJSON, "json", false, "print the planned renames as JSON")
+	selection.register(flags)
+	positional := parseInterspersed(flags, args)
+
+	files, err := selection.expand(positional)
+	if err != nil {
+		return err
+	}
+	if template == "" || len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected a template and at least one file")
+	}
+
+	plan, err := flacgo.PlanRename(files, template)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		results := make([]renameResult, len(plan.Renames))
+		for i, rename := range plan.Renames {
+			results[i] = renameResult{fileResult: fileResult{Path: rename.Old}, New: rename.New}
+			if rename.Err != nil {
+				results[i].Error = rename.Err.Error()
+			}
+		}
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, rename := range plan.Renames {
+			switch {
+			case rename.Err != nil:
+				fmt.Fprintf(os.Stderr, "%s: %v\n", rename.Old, rename.Err)
+			case rename.New != rename.Old:
+				fmt.Println(rename)
+			}
+		}
+	}
+
+	if conflicts := plan.Conflicts(); len(conflicts) > 0 {
+		return fmt.Errorf("%d of %d files can't be renamed, nothing was moved", len(conflicts), len(plan.Renames))
+	}
+	if dryRun {
+		return nil
+	}
+	return plan.Apply()
+}
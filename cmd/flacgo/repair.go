@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func runRepair(args []string) error {
+	var selection fileSelection
+	var dryRun, streamInfo bool
+
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo repair [--dry-run] [--streaminfo] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Fixes misplaced STREAMINFO, duplicate blocks, wrong block lengths, missing last-block")
+		fmt.Fprintln(os.Stderr, "flags and unreadable blocks in place, the audio is kept unchanged.")
+		fmt.Fprintln(os.Stderr, "With --streaminfo, the audio is also decoded to update the block and frame sizes,")
+		fmt.Fprintln(os.Stderr, "the length and a missing MD5 signature of STREAMINFO, e.g. after cutting the audio.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&dryRun, "dry-run", false, "print the fixes without writing the files")
+	flags.BoolVar(&streamInfo, "streaminfo", false, "recompute the stale values of STREAMINFO from the frames")
+	selection.register(flags)
+	flags.Parse(args)
+
+	files, err := selection.expand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	return forEachFile(files, func(path string) error {
+		repair := func(path string) ([]flacgo.Issue, error) { return flacgo.Repair(path, nil) }
+		if dryRun {
+			repair = flacgo.PlanRepair
+		}
+
+		fixes, err := repair(path)
+		if err != nil {
+			return err
+		}
+		if streamInfo {
+			updates, err := updateStreamInfo(path, dryRun)
+			if err != nil {
+				return err
+			}
+			fixes = append(fixes, updates...)
+		}
+		for _, fix := range fixes {
+			fmt.Printf("%s: %s (%s)\n", path, fix.Message, fix.Code)
+		}
+		return nil
+	})
+}
+
+// updateStreamInfo recomputes the STREAMINFO of the file at path, saving it unless dryRun is set
+func updateStreamInfo(path string, dryRun bool) ([]flacgo.Issue, error) {
+	flac, err := flacgo.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	updates, err := flac.UpdateStreamInfo()
+	if err != nil || dryRun || len(updates) == 0 {
+		return updates, err
+	}
+	return updates, flac.Save(nil)
+}
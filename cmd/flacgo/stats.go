@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+// libraryStats aggregates the figures of all the files of a library
+type libraryStats struct {
+	Files  int `json:"files"`
+	Failed int `json:"failed"`
+	// Size is the total size of the files in bytes
+	Size int64 `json:"size"`
+	// Duration is the total audio duration in seconds
+	Duration float64 `json:"duration"`
+	// Metadata is the total size of the metadata blocks in bytes, Padding and Pictures are part of it
+	Metadata     int64          `json:"metadata"`
+	Padding      int64          `json:"padding"`
+	Pictures     int64          `json:"pictures"`
+	SampleRates  map[string]int `json:"sample_rates"`
+	BitDepths    map[string]int `json:"bit_depths"`
+	Channels     map[string]int `json:"channels"`
+	WithCover    int            `json:"with_cover"`
+	WithArtwork  int            `json:"with_artwork"`
+	BiggestFiles []statsFile    `json:"biggest_files"`
+	FailedFiles  []fileResult   `json:"failed_files,omitempty"`
+	sizes        []statsFile
+}
+
+type statsFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+func runStats(args []string) error {
+	selection := fileSelection{recursive: true}
+	var asJSON bool
+	var top int
+
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo stats [--json] [--top N] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Prints the total audio duration, sample rate and bit depth distribution, metadata")
+		fmt.Fprintln(os.Stderr, "overhead, artwork coverage and biggest files of a library.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.IntVar(&top, "top", 10, "number of biggest files listed")
+	selection.register(flags)
+	flags.Parse(args)
+
+	files, err := selection.expand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	stats := &libraryStats{
+		SampleRates: make(map[string]int),
+		BitDepths:   make(map[string]int),
+		Channels:    make(map[string]int),
+	}
+	for _, path := range files {
+		if err := stats.add(path); err != nil {
+			stats.Failed++
+			stats.FailedFiles = append(stats.FailedFiles, fileResult{Path: path, Error: err.Error()})
+		}
+	}
+
+	sort.SliceStable(stats.sizes, func(i, j int) bool {
+		return stats.sizes[i].Size > stats.sizes[j].Size
+	})
+	stats.BiggestFiles = stats.sizes[:min(max(top, 0), len(stats.sizes))]
+
+	if asJSON {
+		return printJSON(stats)
+	}
+
+	stats.print()
+	return nil
+}
+
+// add reads the metadata of a file and adds it to the totals
+func (stats *libraryStats) add(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	flac, err := flacgo.OpenReadOnly(path, flacgo.WithHeaderOnly())
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	metadata, err := flac.Metadata()
+	if err != nil {
+		return err
+	}
+	pictures, err := flac.StreamPictures()
+	if err != nil {
+		return err
+	}
+
+	streamInfo := metadata.StreamInfo
+	stats.Files++
+	stats.Size += info.Size()
+	stats.Duration += streamInfo.Duration().Seconds()
+	stats.SampleRates[fmt.Sprintf("%gkHz", float64(streamInfo.SampleRate)/1000)]++
+	stats.BitDepths[fmt.Sprintf("%dbit", streamInfo.BitsPerSample)]++
+	stats.Channels[channelsName(streamInfo.Channels)]++
+	stats.sizes = append(stats.sizes, statsFile{Path: path, Size: info.Size()})
+
+	// The "fLaC" marker is part of the metadata overhead too
+	stats.Metadata += 4
+	for _, block := range metadata.Blocks {
+		length := int64(block.BlockHeader.BlockLength)
+		stats.Metadata += 4 + length
+		switch block.BlockType {
+		case "PADDING":
+			stats.Padding += length
+		case "PICTURE":
+			stats.Pictures += length
+		}
+	}
+
+	if len(pictures) > 0 {
+		stats.WithArtwork++
+	}
+	for _, picture := range pictures {
+		if picture.Picture.PictureType == flacgo.PictureTypeFrontCover {
+			stats.WithCover++
+			break
+		}
+	}
+
+	return nil
+}
+
+func (stats *libraryStats) print() {
+	fmt.Printf("Files:        %d", stats.Files)
+	if stats.Failed > 0 {
+		fmt.Printf(" (%d unreadable)", stats.Failed)
+	}
+	fmt.Println()
+	if stats.Files == 0 {
+		return
+	}
+
+	fmt.Printf("Total size:   %s\n", formatBytes(stats.Size))
+	fmt.Printf("Audio:        %s (%.1f hours)\n", formatHours(stats.Duration), stats.Duration/3600)
+	fmt.Printf("Sample rates: %s\n", stats.distribution(stats.SampleRates))
+	fmt.Printf("Bit depths:   %s\n", stats.distribution(stats.BitDepths))
+	fmt.Printf("Channels:     %s\n", stats.distribution(stats.Channels))
+	fmt.Printf("Metadata:     %s (%.2f%% of total), %s padding, %s pictures\n",
+		formatBytes(stats.Metadata), percent(stats.Metadata, stats.Size), formatBytes(stats.Padding), formatBytes(stats.Pictures))
+	fmt.Printf("Artwork:      %d of %d files with a front cover (%.0f%%), %d without any picture\n",
+		stats.WithCover, stats.Files, percent(int64(stats.WithCover), int64(stats.Files)), stats.Files-stats.WithArtwork)
+
+	if len(stats.BiggestFiles) > 0 {
+		fmt.Println()
+		fmt.Println("Biggest files:")
+		for _, file := range stats.BiggestFiles {
+			fmt.Printf("  %10s  %s\n", formatBytes(file.Size), file.Path)
+		}
+	}
+
+	if len(stats.FailedFiles) > 0 {
+		fmt.Println()
+		fmt.Println("Unreadable files:")
+		for _, file := range stats.FailedFiles {
+			fmt.Printf("  %s: %s\n", file.Path, file.Error)
+		}
+	}
+}
+
+// distribution formats counts as "44.1kHz 90 (75%), 96kHz 30 (25%)", the most common first
+func (stats *libraryStats) distribution(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s %d (%.0f%%)", key, counts[key], percent(int64(counts[key]), int64(stats.Files)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func channelsName(channels uint8) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	}
+	return fmt.Sprintf("%dch", channels)
+}
+
+func percent(part int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// formatBytes formats a size with decimal units, e.g. "116.6 kB"
+func formatBytes(size int64) string {
+	if size < 1000 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	for _, unit := range []string{"kB", "MB", "GB", "TB"} {
+		value /= 1000
+		if value < 1000 || unit == "TB" {
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+	}
+	return ""
+}
+
+// formatHours formats seconds as H:MM:SS
+func formatHours(seconds float64) string {
+	duration := time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	hours := int(duration.Hours())
+	return fmt.Sprintf("%d:%02d:%02d", hours, int(duration.Minutes())%60, int(duration.Seconds())%60)
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func runTag(args []string) error {
+	if len(args) == 0 {
+		tagUsage()
+		return fmt.Errorf("missing tag subcommand")
+	}
+
+	switch args[0] {
+	case "set":
+		return runTagSet(args[1:])
+	case "cue":
+		return runTagCue(args[1:])
+	case "album-gain":
+		return runTagAlbumGain(args[1:])
+	case "sanitize":
+		return runTagSanitize(args[1:])
+	case "import":
+		return runTagImport(args[1:])
+	case "manifest":
+		return runTagManifest(args[1:])
+	case "apply":
+		return runTagApply(args[1:])
+	default:
+		tagUsage()
+		return fmt.Errorf("unknown tag subcommand '%s'", args[0])
+	}
+}
+
+func tagUsage() {
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag set [-r] [--include PATTERN] [--exclude PATTERN] [--sanitize] [--empty POLICY] [KEY=VALUE...] [--delete KEY...] path...")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "KEY=VALUE pairs and --delete KEY operations can be mixed and are applied in order to every file.")
+	fmt.Fprintln(os.Stderr, "With --sanitize, values are trimmed, runs of spaces collapsed and control characters dropped.")
+	fmt.Fprintln(os.Stderr, "--empty tells what KEY= does: 'write' an empty value (default), 'delete' the tag or fail with 'error'.")
+	fmt.Fprintln(os.Stderr, "Paths can be files, globs or, with -r, directories.")
+	fmt.Fprintln(os.Stderr, "Use '-' as the only path to read the FLAC stream from stdin and write the result to stdout.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag cue [--dry-run] album.cue image.flac")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Tags a disc image with an embedded CUESHEET after the titles and performers of a .cue file,")
+	fmt.Fprintln(os.Stderr, "as CUE_TRACKxx_TITLE and CUE_TRACKxx_PERFORMER tags. Tags already present are kept.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag album-gain [--frame-workers N] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Measures the files as a single album and writes the same REPLAYGAIN_ALBUM_GAIN and")
+	fmt.Fprintln(os.Stderr, "REPLAYGAIN_ALBUM_PEAK to all of them (ReplayGain 2.0, -18 LUFS reference).")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag sanitize [--dry-run] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Cleans the values of all the tags like tag set --sanitize and prints the changes.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag import [--dry-run] [-r] [--include PATTERN] [--exclude PATTERN] tags.yaml path...")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Sets the tags of a YAML document, e.g. written by tags --yaml or the front matter of a")
+	fmt.Fprintln(os.Stderr, "Markdown file, on every file and prints the changes. KEY: ~ or KEY: [] removes a tag,")
+	fmt.Fprintln(os.Stderr, "the tags missing from the document are kept.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag manifest dir")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Prints a YAML manifest of the tags of the FLAC files of dir: a tags section with those")
+	fmt.Fprintln(os.Stderr, "shared by every file and a files section with the others, by file name.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "usage: flacgo tag apply [--dry-run] manifest.yaml [dir]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Applies a manifest to the files of dir, the directory of the manifest by default:")
+	fmt.Fprintln(os.Stderr, "the tags section to every FLAC file, then the files section to each file listed.")
+}
+
+func runTagSet(args []string) error {
+	var selection fileSelection
+	var deletes stringList
+	var sanitize bool
+	var empty string
+	flags := flag.NewFlagSet("tag set", flag.ExitOnError)
+	flags.Usage = tagUsage
+	selection.register(flags)
+	flags.Var(&deletes, "delete", "remove all the values of the tag (repeatable)")
+	flags.BoolVar(&sanitize, "sanitize", false, "trim values, collapse runs of spaces and drop control characters")
+	flags.StringVar(&empty, "empty", "write", "what to do with empty values: write, delete or error")
+	flags.Parse(args)
+
+	operations, rest, err := parseTagOperations(deletes, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	if len(operations) == 0 || len(rest) == 0 {
+		tagUsage()
+		return fmt.Errorf("expected at least one KEY=VALUE or --delete KEY and one path")
+	}
+
+	var opts []flacgo.Option
+	if sanitize {
+		opts = append(opts, flacgo.WithSanitizedValues())
+	}
+	switch empty {
+	case "write":
+	case "delete":
+		opts = append(opts, flacgo.WithEmptyValues(flacgo.EmptyValuesDelete))
+	case "error":
+		opts = append(opts, flacgo.WithEmptyValues(flacgo.EmptyValuesError))
+	default:
+		return fmt.Errorf("invalid --empty value '%s'", empty)
+	}
+
+	apply := func(path string) error {
+		flac, err := openInput(path, opts...)
+		if err != nil {
+			return err
+		}
+		defer flac.Close()
+
+		for _, operation := range operations {
+			if err := operation.apply(flac); err != nil {
+				return err
+			}
+		}
+
+		return saveOutput(flac, path)
+	}
+
+	if len(rest) == 1 && rest[0] == "-" {
+		return apply("-")
+	}
+
+	files, err := selection.expand(rest)
+	if err != nil {
+		return err
+	}
+
+	return forEachFile(files, apply)
+}
+
+func runTagCue(args []string) error {
+	var dryRun bool
+	flags := flag.NewFlagSet("tag cue", flag.ExitOnError)
+	flags.Usage = tagUsage
+	flags.BoolVar(&dryRun, "dry-run", false, "print the tags without saving")
+	positional := parseInterspersed(flags, args)
+
+	if len(positional) != 2 {
+		tagUsage()
+		return fmt.Errorf("expected a cue file and a FLAC file")
+	}
+
+	cue, err := flacgo.ReadCueFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	flac, err := flacgo.Open(positional[1])
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	changes, err := flac.TagFromCue(cue)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+	return flac.Save(nil)
+}
+
+func runTagAlbumGain(args []string) error {
+	var selection fileSelection
+	var frameWorkers int
+	flags := flag.NewFlagSet("tag album-gain", flag.ExitOnError)
+	flags.Usage = tagUsage
+	flags.IntVar(&frameWorkers, "frame-workers", 1, "number of goroutines decoding the frames of each file, 0 for the number of CPUs")
+	selection.register(flags)
+	positional := parseInterspersed(flags, args)
+
+	if len(positional) == 0 {
+		tagUsage()
+		return fmt.Errorf("expected at least one path")
+	}
+	files, err := selection.expand(positional)
+	if err != nil {
+		return err
+	}
+
+	album, err := flacgo.ScanAlbumGain(files, decodeOptions(frameWorkers)...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("album: %.2f LUFS, gain %.2f dB, peak %.6f\n", album.Loudness, album.Gain, album.Peak)
+
+	failed := 0
+	for _, result := range album.Results {
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", result.Path, result.Err)
+			failed++
+			continue
+		}
+		for _, change := range result.Changes {
+			fmt.Printf("%s: %s\n", result.Path, change)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(album.Results))
+	}
+	return nil
+}
+
+func runTagSanitize(args []string) error {
+	var selection fileSelection
+	var dryRun bool
+	flags := flag.NewFlagSet("tag sanitize", flag.ExitOnError)
+	flags.Usage = tagUsage
+	flags.BoolVar(&dryRun, "dry-run", false, "print the changes without saving")
+	selection.register(flags)
+	positional := parseInterspersed(flags, args)
+
+	files, err := selection.expand(positional)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		tagUsage()
+		return fmt.Errorf("expected at least one path")
+	}
+
+	return forEachFile(files, func(path string) error {
+		return applyTagTransform(path, flacgo.SanitizeTransform(), dryRun)
+	})
+}
+
+func runTagImport(args []string) error {
+	var selection fileSelection
+	var dryRun bool
+	flags := flag.NewFlagSet("tag import", flag.ExitOnError)
+	flags.Usage = tagUsage
+	flags.BoolVar(&dryRun, "dry-run", false, "print the changes without saving")
+	selection.register(flags)
+	positional := parseInterspersed(flags, args)
+	if len(positional) < 2 {
+		tagUsage()
+		return fmt.Errorf("expected a YAML file and at least one path")
+	}
+
+	document, err := os.Open(positional[0])
+	if err != nil {
+		return err
+	}
+	patch, err := flacgo.ReadYAMLTags(document)
+	document.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", positional[0], err)
+	}
+
+	files, err := selection.expand(positional[1:])
+	if err != nil {
+		return err
+	}
+	return forEachFile(files, func(path string) error {
+		return applyTagTransform(path, patch, dryRun)
+	})
+}
+
+func runTagManifest(args []string) error {
+	flags := flag.NewFlagSet("tag manifest", flag.ExitOnError)
+	flags.Usage = tagUsage
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		tagUsage()
+		return fmt.Errorf("expected a directory")
+	}
+	return flacgo.WriteTagManifest(os.Stdout, flags.Arg(0))
+}
+
+func runTagApply(args []string) error {
+	var dryRun bool
+	flags := flag.NewFlagSet("tag apply", flag.ExitOnError)
+	flags.Usage = tagUsage
+	flags.BoolVar(&dryRun, "dry-run", false, "print the changes without saving")
+	positional := parseInterspersed(flags, args)
+	if len(positional) < 1 || len(positional) > 2 {
+		tagUsage()
+		return fmt.Errorf("expected a manifest and an optional directory")
+	}
+
+	document, err := os.Open(positional[0])
+	if err != nil {
+		return err
+	}
+	manifest, err := flacgo.ReadTagManifest(document)
+	document.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", positional[0], err)
+	}
+
+	dir := filepath.Dir(positional[0])
+	if len(positional) == 2 {
+		dir = positional[1]
+	}
+	files, err := manifest.Paths(dir)
+	if err != nil {
+		return err
+	}
+	return forEachFile(files, func(path string) error {
+		return applyTagTransform(path, manifest.Transform(dir, path), dryRun)
+	})
+}
+
+// applyTagTransform applies transform to the file at path, prints the changes and saves them unless dryRun is set
+func applyTagTransform(path string, transform flacgo.Transform, dryRun bool) error {
+	flac, err := flacgo.Open(path)
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	changes, err := flac.ApplyTransform(transform)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Printf("%s: %s\n", path, change)
+	}
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+	return flac.Save(nil)
+}
+
+// tagOperation is a single tag change requested on the command line
+type tagOperation struct {
+	key    string
+	value  string
+	delete bool
+}
+
+func (operation tagOperation) apply(flac *flacgo.Flac) error {
+	if operation.delete {
+		return flac.RemoveMetadata(operation.key, true)
+	}
+	return flac.SetMetadata(operation.key, operation.value)
+}
+
+// parseTagOperations reads the leading KEY=VALUE and --delete KEY arguments, the remaining ones are the paths.
+// Keys given with --delete before the first pair are applied first.
+func parseTagOperations(deletes []string, args []string) ([]tagOperation, []string, error) {
+	var operations []tagOperation
+	for _, key := range deletes {
+		operations = append(operations, tagOperation{key: key, delete: true})
+	}
+
+	rest := args
+	for len(rest) > 0 {
+		arg := rest[0]
+		switch {
+		case arg == "--delete" || arg == "-delete":
+			if len(rest) < 2 {
+				return nil, nil, fmt.Errorf("missing key after %s", arg)
+			}
+			operations = append(operations, tagOperation{key: rest[1], delete: true})
+			rest = rest[2:]
+			continue
+		case strings.HasPrefix(arg, "--delete=") || strings.HasPrefix(arg, "-delete="):
+			_, key, _ := strings.Cut(arg, "=")
+			operations = append(operations, tagOperation{key: key, delete: true})
+			rest = rest[1:]
+			continue
+		case len(rest) > 1 && strings.Contains(arg, "="):
+			key, value, _ := strings.Cut(arg, "=")
+			if key == "" {
+				return nil, nil, fmt.Errorf("invalid tag '%s', expected KEY=VALUE", arg)
+			}
+			operations = append(operations, tagOperation{key: key, value: value})
+			rest = rest[1:]
+			continue
+		}
+		break
+	}
+
+	for _, operation := range operations {
+		if operation.key == "" {
+			return nil, nil, fmt.Errorf("invalid empty tag key")
+		}
+	}
+
+	return operations, rest, nil
+}
+
+// openInput opens the FLAC file at path, or reads it from stdin when path is '-'
+func openInput(path string, opts ...flacgo.Option) (*flacgo.Flac, error) {
+	if path == "-" {
+		return flacgo.OpenReader(os.Stdin, opts...)
+	}
+	return flacgo.Open(path, opts...)
+}
+
+// saveOutput overwrites the FLAC file at path, or writes it to stdout when path is '-'
+func saveOutput(flac *flacgo.Flac, path string) error {
+	if path == "-" {
+		_, err := flac.WriteTo(os.Stdout)
+		return err
+	}
+	return flac.Save(nil)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+type tagsResult struct {
+	fileResult
+	Tags []tagEntry `json:"tags"`
+}
+
+type tagEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func runTags(args []string) error {
+	var selection fileSelection
+	var asJSON, asYAML bool
+	flags := flag.NewFlagSet("tags", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo tags [--json | --yaml] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.BoolVar(&asYAML, "yaml", false, "print the tags of every file as YAML front matter, see tag import")
+	selection.register(flags)
+	flags.Parse(args)
+	if asJSON && asYAML {
+		flags.Usage()
+		return fmt.Errorf("--json and --yaml can't be used together")
+	}
+
+	files, err := selection.expand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	if asYAML {
+		return forEachFile(files, func(path string) error {
+			flac, err := flacgo.OpenReadOnly(path, flacgo.WithHeaderOnly())
+			if err != nil {
+				return err
+			}
+			defer flac.Close()
+
+			if len(files) > 1 {
+				fmt.Printf("# %s\n", path)
+			}
+			return flac.ExportYAML(os.Stdout)
+		})
+	}
+
+	results := make([]tagsResult, 0, len(files))
+	err = forEachFile(files, func(path string) error {
+		result := tagsResult{fileResult: fileResult{Path: path}, Tags: []tagEntry{}}
+		comments, err := readTags(path)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		for _, cmt := range comments {
+			result.Tags = append(result.Tags, tagEntry{Key: cmt.Title, Value: cmt.Value})
+		}
+		results = append(results, result)
+
+		if !asJSON && err == nil {
+			if len(files) > 1 {
+				fmt.Printf("%s:\n", path)
+			}
+			for _, cmt := range comments {
+				fmt.Printf("%s=%s\n", cmt.Title, cmt.Value)
+			}
+		}
+		return err
+	})
+
+	if asJSON {
+		if jsonErr := printJSON(results); jsonErr != nil {
+			return jsonErr
+		}
+	}
+
+	return err
+}
+
+func readTags(path string) ([]flacgo.VorbisComment, error) {
+	flac, err := flacgo.OpenReadOnly(path, flacgo.WithHeaderOnly())
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	return flac.Comments(), nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package main
+
+import "errors"
+
+// makeRaw is not supported on this platform
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+// terminalSize is not supported on this platform
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errors.New("terminal size is not supported on this platform")
+}
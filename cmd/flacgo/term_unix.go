@@ -0,0 +1,47 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal fd in raw mode, keys are read one by one without echo, and returns
+// a function restoring its previous mode
+func makeRaw(fd int) (func(), error) {
+	var state syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&state)); err != nil {
+		return nil, fmt.Errorf("not a terminal: %w", err)
+	}
+
+	raw := state
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, fmt.Errorf("unable to set raw mode: %w", err)
+	}
+
+	return func() { ioctl(fd, ioctlSetTermios, unsafe.Pointer(&state)) }, nil
+}
+
+// terminalSize returns the number of columns and rows of the terminal fd
+func terminalSize(fd int) (int, int, error) {
+	var size struct{ rows, cols, x, y uint16 }
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, 0, err
+	}
+	return int(size.cols), int(size.rows), nil
+}
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func runTrim(args []string) error {
+	var threshold float64
+
+	flags := flag.NewFlagSet("trim", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo trim [--threshold DB] input.flac [output.flac]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Reports the silence at the start and at the end of the audio and, given an output,")
+		fmt.Fprintln(os.Stderr, "writes a copy without it, re-encoded keeping the tags and pictures.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.Float64Var(&threshold, "threshold", -60, "samples at or below this level in dBFS are silence")
+	rest := parseInterspersed(flags, args)
+
+	if len(rest) != 1 && len(rest) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an input and an optional output file")
+	}
+
+	flac, err := flacgo.OpenReadOnly(rest[0])
+	if err != nil {
+		return err
+	}
+	defer flac.Close()
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return err
+	}
+
+	var silence *flacgo.Silence
+	if len(rest) == 1 {
+		if silence, err = flac.DetectSilence(threshold); err != nil {
+			return err
+		}
+	} else {
+		out, err := os.Create(rest[1])
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		if silence, err = flac.TrimSilence(out, threshold, flacgo.DefaultEncoderOptions); err != nil {
+			os.Remove(rest[1])
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+
+	duration := func(samples uint64) time.Duration {
+		return time.Duration(float64(samples) / float64(streamInfo.SampleRate) * float64(time.Second)).Round(time.Millisecond)
+	}
+	fmt.Printf("leading silence: %s (%d samples)\n", duration(silence.Leading), silence.Leading)
+	fmt.Printf("trailing silence: %s (%d samples)\n", duration(silence.Trailing), silence.Trailing)
+	return nil
+}
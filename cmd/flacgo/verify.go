@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+type verifyResult struct {
+	fileResult
+	OK       bool                   `json:"ok"`
+	Lossless *flacgo.LosslessReport `json:"lossless,omitempty"`
+}
+
+func runVerify(args []string) error {
+	var selection fileSelection
+	var asJSON, quiet, lossless bool
+	var workers, frameWorkers int
+
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: flacgo verify [--json] [--lossless] [-j WORKERS] [--frame-workers N] [-q] [-r] [--include PATTERN] [--exclude PATTERN] path...")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Decodes every file checking frame CRCs and the MD5 signature of the audio.")
+		fmt.Fprintln(os.Stderr, "With --lossless, files whose bandwidth or bit depth suggest a lossy source fail too.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&asJSON, "json", false, "print the result as JSON")
+	flags.BoolVar(&quiet, "q", false, "only print the files failing verification")
+	flags.BoolVar(&lossless, "lossless", false, "also analyze the spectrum and bit depth to detect transcodes from lossy sources")
+	flags.IntVar(&workers, "j", runtime.NumCPU(), "number of files verified in parallel")
+	flags.IntVar(&frameWorkers, "frame-workers", 1, "number of goroutines decoding the frames of each file, 0 for the number of CPUs")
+	selection.register(flags)
+	flags.Parse(args)
+
+	files, err := selection.expand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no files given")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Every file gets its own channel so results are printed in order as soon as they are ready
+	done := make([]chan verifyResult, len(files))
+	for i := range done {
+		done[i] = make(chan verifyResult, 1)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result := verifyResult{fileResult: fileResult{Path: files[i]}, OK: true}
+				report, err := verifyFile(files[i], lossless, decodeOptions(frameWorkers)...)
+				result.Lossless = report
+				if err == nil && report != nil && report.Suspicious {
+					err = fmt.Errorf("suspected transcode: %s", strings.Join(report.Reasons, ", "))
+				}
+				if err != nil {
+					result.OK = false
+					result.Error = err.Error()
+				}
+				done[i] <- result
+			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	failed := 0
+	results := make([]verifyResult, 0, len(files))
+	for i := range files {
+		result := <-done[i]
+		results = append(results, result)
+		if !result.OK {
+			failed++
+		}
+		if asJSON {
+			continue
+		}
+		if result.OK && !quiet {
+			fmt.Printf("%s: ok\n", result.Path)
+		} else if !result.OK {
+			fmt.Printf("%s: FAILED: %s\n", result.Path, result.Error)
+		}
+	}
+	wg.Wait()
+
+	if asJSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed verification", failed, len(files))
+	}
+
+	return nil
+}
+
+// verifyFile verifies the file at path opened with opts, with lossless it also returns the analysis of its audio
+func verifyFile(path string, lossless bool, opts ...flacgo.Option) (*flacgo.LosslessReport, error) {
+	flac, err := flacgo.OpenReadOnly(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	if err := flac.Verify(); err != nil || !lossless {
+		return nil, err
+	}
+	return flac.AnalyzeLossless()
+}
+
+// decodeOptions returns the options decoding the frames on workers goroutines, set by --frame-workers
+func decodeOptions(workers int) []flacgo.Option {
+	if workers == 1 {
+		return nil
+	}
+	return []flacgo.Option{flacgo.WithParallelDecode(workers)}
+}
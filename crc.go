@@ -0,0 +1,74 @@
+package flacgo
+
+// crc8Tables are the slicing-by-8 lookup tables of the CRC-8 protecting frame headers
+// (polynomial x^8 + x^2 + x^1 + x^0): crc8Tables[k][b] is the CRC of byte b followed by k zero bytes
+var crc8Tables = func() [8][256]uint8 {
+	var tables [8][256]uint8
+	for i := range tables[0] {
+		crc := uint8(i)
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+		tables[0][i] = crc
+	}
+	for k := 1; k < len(tables); k++ {
+		for i := range tables[k] {
+			tables[k][i] = tables[0][tables[k-1][i]]
+		}
+	}
+	return tables
+}()
+
+// crc16Tables are the slicing-by-8 lookup tables of the CRC-16 protecting whole frames
+// (polynomial x^16 + x^15 + x^2 + x^0): crc16Tables[k][b] is the CRC of byte b followed by k zero bytes
+var crc16Tables = func() [8][256]uint16 {
+	var tables [8][256]uint16
+	for i := range tables[0] {
+		crc := uint16(i) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+		tables[0][i] = crc
+	}
+	for k := 1; k < len(tables); k++ {
+		for i := range tables[k] {
+			previous := tables[k-1][i]
+			tables[k][i] = previous<<8 ^ tables[0][byte(previous>>8)]
+		}
+	}
+	return tables
+}()
+
+// updateCRC8 adds data to the running CRC-8, 8 bytes at a time
+func updateCRC8(crc uint8, data []byte) uint8 {
+	t := &crc8Tables
+	for ; len(data) >= 8; data = data[8:] {
+		crc = t[7][data[0]^crc] ^ t[6][data[1]] ^ t[5][data[2]] ^ t[4][data[3]] ^
+			t[3][data[4]] ^ t[2][data[5]] ^ t[1][data[6]] ^ t[0][data[7]]
+	}
+	for _, b := range data {
+		crc = t[0][crc^b]
+	}
+	return crc
+}
+
+// updateCRC16 adds data to the running CRC-16, 8 bytes at a time
+func updateCRC16(crc uint16, data []byte) uint16 {
+	t := &crc16Tables
+	for ; len(data) >= 8; data = data[8:] {
+		crc = t[7][data[0]^byte(crc>>8)] ^ t[6][data[1]^byte(crc)] ^ t[5][data[2]] ^ t[4][data[3]] ^
+			t[3][data[4]] ^ t[2][data[5]] ^ t[1][data[6]] ^ t[0][data[7]]
+	}
+	for _, b := range data {
+		crc = crc<<8 ^ t[0][byte(crc>>8)^b]
+	}
+	return crc
+}
@@ -0,0 +1,90 @@
+package flacgo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// bitwiseCRC8 is the reference CRC-8 of frame headers, one bit at a time
+func bitwiseCRC8(crc uint8, data []byte) uint8 {
+	for _, b := range data {
+		crc ^= b
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// bitwiseCRC16 is the reference CRC-16 of frames, one bit at a time
+func bitwiseCRC16(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func TestCRCMatchesBitwiseReference(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		// Lengths around multiples of 8 exercise both the sliced and the bytewise loops
+		data := make([]byte, random.Intn(100))
+		random.Read(data)
+		crc8, crc16 := uint8(random.Intn(256)), uint16(random.Intn(65536))
+
+		if got, want := updateCRC8(crc8, data), bitwiseCRC8(crc8, data); got != want {
+			t.Fatalf("CRC-8 of %d bytes from 0x%02X is 0x%02X, expected 0x%02X", len(data), crc8, got, want)
+		}
+		if got, want := updateCRC16(crc16, data), bitwiseCRC16(crc16, data); got != want {
+			t.Fatalf("CRC-16 of %d bytes from 0x%04X is 0x%04X, expected 0x%04X", len(data), crc16, got, want)
+		}
+	}
+}
+
+// benchmarkCRCData is a frame-sized buffer of random bytes
+func benchmarkCRCData(b *testing.B) []byte {
+	data := make([]byte, 16*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	return data
+}
+
+func BenchmarkCRC16(b *testing.B) {
+	data := benchmarkCRCData(b)
+	for i := 0; i < b.N; i++ {
+		updateCRC16(0, data)
+	}
+}
+
+func BenchmarkCRC16Bitwise(b *testing.B) {
+	data := benchmarkCRCData(b)
+	for i := 0; i < b.N; i++ {
+		bitwiseCRC16(0, data)
+	}
+}
+
+func BenchmarkCRC8(b *testing.B) {
+	data := benchmarkCRCData(b)
+	for i := 0; i < b.N; i++ {
+		updateCRC8(0, data)
+	}
+}
+
+func BenchmarkCRC8Bitwise(b *testing.B) {
+	data := benchmarkCRCData(b)
+	for i := 0; i < b.N; i++ {
+		bitwiseCRC8(0, data)
+	}
+}
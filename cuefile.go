@@ -0,0 +1,202 @@
+package flacgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CueFile holds the disc and track information of a .cue file
+type CueFile struct {
+	Title      string
+	Performer  string
+	Songwriter string
+	Catalog    string
+	// Rem maps the names of the REM comments, upper case, to their value, e.g. GENRE and DATE
+	Rem    map[string]string
+	Tracks []CueFileTrack
+}
+
+// CueFileTrack is a TRACK of a .cue file
+type CueFileTrack struct {
+	Number     int
+	Title      string
+	Performer  string
+	Songwriter string
+	ISRC       string
+	// Start is the position of INDEX 01 in its FILE
+	Start time.Duration
+}
+
+// ReadCueFile parses the .cue file at path, see ParseCueFile
+func ReadCueFile(path string) (*CueFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cue file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	return ParseCueFile(f)
+}
+
+// ParseCueFile parses the content of a .cue file. The commands not describing the disc or its tracks,
+// like FILE, FLAGS or PREGAP, are ignored.
+func ParseCueFile(r io.Reader) (*CueFile, error) {
+	cue := &CueFile{Rem: make(map[string]string)}
+	var track *CueFileTrack
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+
+		command, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch strings.ToUpper(command) {
+		case "TITLE", "PERFORMER", "SONGWRITER":
+			// Before the first TRACK they describe the disc
+			title, performer, songwriter := &cue.Title, &cue.Performer, &cue.Songwriter
+			if track != nil {
+				title, performer, songwriter = &track.Title, &track.Performer, &track.Songwriter
+			}
+			switch strings.ToUpper(command) {
+			case "TITLE":
+				*title = unquoteCue(rest)
+			case "PERFORMER":
+				*performer = unquoteCue(rest)
+			default:
+				*songwriter = unquoteCue(rest)
+			}
+		case "CATALOG":
+			cue.Catalog = rest
+		case "REM":
+			name, value, _ := strings.Cut(rest, " ")
+			if track == nil && name != "" {
+				cue.Rem[strings.ToUpper(name)] = unquoteCue(strings.TrimSpace(value))
+			}
+		case "TRACK":
+			numberField, _, _ := strings.Cut(rest, " ")
+			number, err := strconv.Atoi(numberField)
+			if err != nil || number < 1 || number > 99 {
+				return nil, fmt.Errorf("invalid track number %q at line %d", numberField, lineNumber)
+			}
+			cue.Tracks = append(cue.Tracks, CueFileTrack{Number: number})
+			track = &cue.Tracks[len(cue.Tracks)-1]
+		case "ISRC":
+			if track != nil {
+				track.ISRC = rest
+			}
+		case "INDEX":
+			numberField, position, _ := strings.Cut(rest, " ")
+			if track == nil || numberField != "01" {
+				continue
+			}
+			start, err := parseCueTime(strings.TrimSpace(position))
+			if err != nil {
+				return nil, fmt.Errorf("invalid index at line %d: %w", lineNumber, err)
+			}
+			track.Start = start
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read cue file: %w", err)
+	}
+
+	return cue, nil
+}
+
+// unquoteCue removes the double quotes around a value of a .cue file
+func unquoteCue(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseCueTime parses a mm:ss:ff position, with 75 frames per second
+func parseCueTime(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("position %q is not mm:ss:ff", value)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("position %q is not mm:ss:ff", value)
+		}
+		numbers[i] = number
+	}
+	if numbers[1] >= 60 || numbers[2] >= 75 {
+		return 0, fmt.Errorf("position %q is out of range", value)
+	}
+
+	frames := (numbers[0]*60+numbers[1])*75 + numbers[2]
+	return time.Duration(frames) * time.Second / 75, nil
+}
+
+// cueTrackKey returns the comment holding a field of a track described by the CUESHEET block,
+// e.g. CUE_TRACK01_TITLE
+func cueTrackKey(number int, field string) string {
+	return fmt.Sprintf("CUE_TRACK%02d_%s", number, field)
+}
+
+// TagFromCue stages tags taken from cue for a file with an embedded CUESHEET block, typically a
+// whole disc image: CUE_TRACKxx_TITLE, CUE_TRACKxx_PERFORMER and CUE_TRACKxx_SONGWRITER for every
+// audio track of the CUESHEET, plus ALBUM, ALBUMARTIST, GENRE and DATE from the disc information.
+// Tags already present are kept. It returns the resulting changes sorted by key.
+func (flac *Flac) TagFromCue(cue *CueFile) ([]TagChange, error) {
+	cueSheet, err := flac.CueSheet()
+	if err != nil {
+		return nil, err
+	}
+	if cueSheet == nil {
+		return nil, fmt.Errorf("unable to tag from cue file: the file has no CUESHEET block")
+	}
+
+	tracks := make(map[int]CueFileTrack)
+	for _, track := range cue.Tracks {
+		tracks[track.Number] = track
+	}
+
+	tags := []VorbisComment{
+		{Title: "ALBUM", Value: cue.Title},
+		{Title: "ALBUMARTIST", Value: cue.Performer},
+		{Title: "GENRE", Value: cue.Rem["GENRE"]},
+		{Title: "DATE", Value: cue.Rem["DATE"]},
+	}
+	// The last track is the lead-out
+	for i, sheetTrack := range cueSheet.Tracks {
+		track, found := tracks[int(sheetTrack.Number)]
+		if i == len(cueSheet.Tracks)-1 || !sheetTrack.IsAudio || !found {
+			continue
+		}
+		tags = append(tags,
+			VorbisComment{Title: cueTrackKey(track.Number, "TITLE"), Value: track.Title},
+			VorbisComment{Title: cueTrackKey(track.Number, "PERFORMER"), Value: track.Performer},
+			VorbisComment{Title: cueTrackKey(track.Number, "SONGWRITER"), Value: track.Songwriter},
+		)
+	}
+
+	return flac.ApplyTransform(TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		present := make(map[string]bool)
+		for _, comment := range comments {
+			present[strings.ToUpper(comment.Title)] = true
+		}
+		for _, tag := range tags {
+			if tag.Value != "" && !present[tag.Title] {
+				comments = append(comments, tag)
+			}
+		}
+		return comments
+	}))
+}
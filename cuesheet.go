@@ -0,0 +1,97 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// CueSheetIndex is an index point of a CUESHEET track
+type CueSheetIndex struct {
+	// Offset in samples relative to the track offset
+	Offset uint64
+	Number uint8
+}
+
+// CueSheetTrack is a single track of a CUESHEET block
+type CueSheetTrack struct {
+	// Offset in samples relative to the beginning of the audio stream
+	Offset      uint64
+	Number      uint8
+	ISRC        string
+	IsAudio     bool
+	PreEmphasis bool
+	Indices     []CueSheetIndex
+}
+
+// CueSheet holds the parsed content of a CUESHEET metadata block
+type CueSheet struct {
+	MediaCatalogNumber string
+	LeadIn             uint64
+	IsCD               bool
+	// Tracks includes the lead-out track, which is always the last one
+	Tracks []CueSheetTrack
+}
+
+// parseCueSheetBlock tries to parse bytes from a CUESHEET block into a CueSheet structure
+func parseCueSheetBlock(cueSheetBlock []byte) (*CueSheet, error) {
+	// Catalog number (128), lead-in (8), flags and reserved (1 + 258), number of tracks (1)
+	if len(cueSheetBlock) < 396 {
+		return nil, fmt.Errorf("cuesheet block is too short")
+	}
+
+	cueSheet := &CueSheet{
+		MediaCatalogNumber: strings.TrimRight(string(cueSheetBlock[0:128]), "\x00"),
+		LeadIn:             binary.BigEndian.Uint64(cueSheetBlock[128:136]),
+		IsCD:               cueSheetBlock[136]&0x80 != 0,
+	}
+
+	numberOfTracks := int(cueSheetBlock[395])
+	offset := 396
+
+	for i := 0; i < numberOfTracks; i++ {
+		// Offset (8), number (1), ISRC (12), flags and reserved (1 + 13), number of indices (1)
+		if len(cueSheetBlock) < offset+36 {
+			return nil, fmt.Errorf("unexpected end of cuesheet block while reading track %d", i)
+		}
+
+		track := CueSheetTrack{
+			Offset:      binary.BigEndian.Uint64(cueSheetBlock[offset : offset+8]),
+			Number:      cueSheetBlock[offset+8],
+			ISRC:        strings.TrimRight(string(cueSheetBlock[offset+9:offset+21]), "\x00"),
+			IsAudio:     cueSheetBlock[offset+21]&0x80 == 0,
+			PreEmphasis: cueSheetBlock[offset+21]&0x40 != 0,
+		}
+		numberOfIndices := int(cueSheetBlock[offset+35])
+		offset += 36
+
+		for j := 0; j < numberOfIndices; j++ {
+			// Offset (8), number (1), reserved (3)
+			if len(cueSheetBlock) < offset+12 {
+				return nil, fmt.Errorf("unexpected end of cuesheet block while reading index %d of track %d", j, i)
+			}
+			track.Indices = append(track.Indices, CueSheetIndex{
+				Offset: binary.BigEndian.Uint64(cueSheetBlock[offset : offset+8]),
+				Number: cueSheetBlock[offset+8],
+			})
+			offset += 12
+		}
+
+		cueSheet.Tracks = append(cueSheet.Tracks, track)
+	}
+
+	return cueSheet, nil
+}
+
+// CueSheet returns the parsed CUESHEET block of the file, nil if the file doesn't have one
+func (flac *Flac) CueSheet() (*CueSheet, error) {
+	block, err := flac.getBlock("CUESHEET")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CUESHEET block: %w", err)
+	}
+	if block == nil {
+		return nil, nil
+	}
+
+	return parseCueSheetBlock(block.BlockData)
+}
@@ -0,0 +1,612 @@
+package flacgo
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+var (
+	// ErrFrameCRC is returned when the CRC of a frame header or of a whole frame doesn't match its content
+	ErrFrameCRC = errors.New("frame CRC mismatch")
+	// ErrMD5Mismatch is returned when the decoded audio doesn't match the MD5 signature stored in STREAMINFO
+	ErrMD5Mismatch = errors.New("audio MD5 mismatch")
+)
+
+// Channel assignments of a frame, values up to 7 mean independent channels
+const (
+	channelLeftSide  = 8
+	channelSideRight = 9
+	channelMidSide   = 10
+)
+
+// FrameHeader holds the parsed header of an audio frame
+type FrameHeader struct {
+	// Offset of the frame from the beginning of the file
+	Offset            int64
+	VariableBlockSize bool
+	BlockSize         int
+	SampleRate        uint32
+	Channels          int
+	ChannelAssignment uint8
+	BitsPerSample     uint8
+	// Number is the frame number for fixed block size streams, the first sample number otherwise
+	Number uint64
+}
+
+// Frame is a decoded audio frame
+type Frame struct {
+	Header FrameHeader
+	// Length of the encoded frame in bytes
+	Length int
+	// Samples holds BlockSize samples for each channel
+	Samples [][]int32
+}
+
+// Decoder decodes the audio frames of a FLAC file one at a time
+type Decoder struct {
+	streamInfo *StreamInfo
+	br         *bitReader
+	// start is the offset of the first frame in the file
+	start    int64
+	residual []int64
+	work     [][]int64
+}
+
+// NewDecoder returns a Decoder reading the audio frames of the file from the beginning
+func (flac *Flac) NewDecoder() (*Decoder, error) {
+	return flac.newDecoderAt(0)
+}
+
+// newDecoderAt returns a Decoder reading the audio frames from the frame at offset bytes from the first one
+func (flac *Flac) newDecoderAt(offset int64) (*Decoder, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	metadataEnd, err := flac.getMetadataEndOffset()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get metadata end offset: %w", err)
+	}
+
+	start := metadataEnd + offset
+	audioEnd := flac.getAudioEndOffset()
+	if start > audioEnd {
+		return nil, fmt.Errorf("offset %d is beyond the end of the audio", offset)
+	}
+	audio := io.NewSectionReader(flac.file, start, audioEnd-start)
+
+	return &Decoder{
+		streamInfo: streamInfo,
+		br:         newBitReader(audio),
+		start:      start,
+	}, nil
+}
+
+// Next decodes the next frame, it returns io.EOF when there are no more frames
+func (decoder *Decoder) Next() (*Frame, error) {
+	offset := decoder.start + decoder.br.consumed
+
+	header, err := decoder.readFrameHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("unable to read frame header at offset %d: %w", offset, err)
+	}
+	header.Offset = offset
+
+	if len(decoder.work) < header.Channels {
+		decoder.work = make([][]int64, header.Channels)
+	}
+	for ch := 0; ch < header.Channels; ch++ {
+		if cap(decoder.work[ch]) < header.BlockSize {
+			decoder.work[ch] = make([]int64, header.BlockSize)
+		}
+		decoder.work[ch] = decoder.work[ch][:header.BlockSize]
+
+		bitsPerSample := uint(header.BitsPerSample)
+		// The side channel needs one more bit
+		switch {
+		case header.ChannelAssignment == channelLeftSide && ch == 1,
+			header.ChannelAssignment == channelSideRight && ch == 0,
+			header.ChannelAssignment == channelMidSide && ch == 1:
+			bitsPerSample++
+		}
+
+		if err := decoder.readSubframe(decoder.work[ch], bitsPerSample); err != nil {
+			return nil, fmt.Errorf("unable to decode subframe %d of frame at offset %d: %w", ch, offset, err)
+		}
+	}
+
+	decoder.br.align()
+	expected := decoder.br.frameCRC()
+	crc, err := decoder.br.readBits(16)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CRC of frame at offset %d: %w", offset, err)
+	}
+	if uint16(crc) != expected {
+		return nil, fmt.Errorf("%w: frame at offset %d has CRC-16 %04x, computed %04x", ErrFrameCRC, offset, crc, expected)
+	}
+
+	frame := &Frame{
+		Header:  *header,
+		Length:  int(decoder.start + decoder.br.consumed - offset),
+		Samples: make([][]int32, header.Channels),
+	}
+	decorrelate(frame, decoder.work)
+
+	return frame, nil
+}
+
+// readFrameHeader parses a frame header and checks its CRC-8
+func (decoder *Decoder) readFrameHeader() (*FrameHeader, error) {
+	br := decoder.br
+	br.resetCRC()
+
+	sync, err := br.readBits(8)
+	if err != nil {
+		// A clean end of stream only happens before the first byte of a frame
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	rest, err := br.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if sync != 0xFF || rest>>1 != 0x7C {
+		return nil, fmt.Errorf("invalid frame sync code %02x%02x", sync, rest)
+	}
+
+	header := &FrameHeader{VariableBlockSize: rest&0x01 == 1}
+
+	codes, err := br.readBits(16)
+	if err != nil {
+		return nil, err
+	}
+	blockSizeCode := uint8(codes >> 12)
+	sampleRateCode := uint8(codes>>8) & 0x0F
+	header.ChannelAssignment = uint8(codes>>4) & 0x0F
+	sampleSizeCode := uint8(codes>>1) & 0x07
+
+	if header.Number, err = readUTF8Number(br); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case blockSizeCode == 0:
+		return nil, fmt.Errorf("reserved block size code")
+	case blockSizeCode == 1:
+		header.BlockSize = 192
+	case blockSizeCode <= 5:
+		header.BlockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		value, err := br.readBits(8)
+		if err != nil {
+			return nil, err
+		}
+		header.BlockSize = int(value) + 1
+	case blockSizeCode == 7:
+		value, err := br.readBits(16)
+		if err != nil {
+			return nil, err
+		}
+		header.BlockSize = int(value) + 1
+	default:
+		header.BlockSize = 256 << (blockSizeCode - 8)
+	}
+
+	sampleRates := []uint32{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+	switch {
+	case sampleRateCode == 0:
+		header.SampleRate = decoder.streamInfo.SampleRate
+	case sampleRateCode < 12:
+		header.SampleRate = sampleRates[sampleRateCode]
+	case sampleRateCode == 12:
+		value, err := br.readBits(8)
+		if err != nil {
+			return nil, err
+		}
+		header.SampleRate = uint32(value) * 1000
+	case sampleRateCode == 13:
+		value, err := br.readBits(16)
+		if err != nil {
+			return nil, err
+		}
+		header.SampleRate = uint32(value)
+	case sampleRateCode == 14:
+		value, err := br.readBits(16)
+		if err != nil {
+			return nil, err
+		}
+		header.SampleRate = uint32(value) * 10
+	default:
+		return nil, fmt.Errorf("invalid sample rate code")
+	}
+
+	switch {
+	case header.ChannelAssignment < 8:
+		header.Channels = int(header.ChannelAssignment) + 1
+	case header.ChannelAssignment <= channelMidSide:
+		header.Channels = 2
+	default:
+		return nil, fmt.Errorf("reserved channel assignment %d", header.ChannelAssignment)
+	}
+
+	sampleSizes := []uint8{0, 8, 12, 0, 16, 20, 24, 32}
+	switch {
+	case sampleSizeCode == 0:
+		header.BitsPerSample = decoder.streamInfo.BitsPerSample
+	case sampleSizeCode == 3:
+		return nil, fmt.Errorf("reserved sample size code")
+	default:
+		header.BitsPerSample = sampleSizes[sampleSizeCode]
+	}
+
+	expected := br.headerCRC()
+	crc, err := br.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if uint8(crc) != expected {
+		return nil, fmt.Errorf("%w: header CRC-8 %02x, computed %02x", ErrFrameCRC, crc, expected)
+	}
+
+	return header, nil
+}
+
+// readUTF8Number reads a frame or sample number coded like an UTF-8 character, up to 36 bits
+func readUTF8Number(br *bitReader) (uint64, error) {
+	first, err := br.readBits(8)
+	if err != nil {
+		return 0, err
+	}
+
+	// The number of leading ones tells how many continuation bytes follow
+	length := 0
+	for mask := uint64(0x80); first&mask != 0 && length < 8; mask >>= 1 {
+		length++
+	}
+	if length == 1 || length > 7 {
+		return 0, fmt.Errorf("invalid coded number")
+	}
+	if length == 0 {
+		return first, nil
+	}
+
+	value := first & (0xFF >> (length + 1))
+	for i := 1; i < length; i++ {
+		next, err := br.readBits(8)
+		if err != nil {
+			return 0, err
+		}
+		if next&0xC0 != 0x80 {
+			return 0, fmt.Errorf("invalid coded number")
+		}
+		value = value<<6 | next&0x3F
+	}
+
+	return value, nil
+}
+
+// readSubframe decodes a subframe into samples
+func (decoder *Decoder) readSubframe(samples []int64, bitsPerSample uint) error {
+	br := decoder.br
+
+	header, err := br.readBits(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return fmt.Errorf("invalid subframe padding bit")
+	}
+	subframeType := uint8(header>>1) & 0x3F
+
+	wasted := uint(0)
+	if header&0x01 != 0 {
+		count, err := br.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = uint(count) + 1
+		if wasted >= bitsPerSample {
+			return fmt.Errorf("invalid wasted bits %d", wasted)
+		}
+		bitsPerSample -= wasted
+	}
+
+	switch {
+	case subframeType == 0:
+		value, err := br.readSigned(bitsPerSample)
+		if err != nil {
+			return err
+		}
+		for i := range samples {
+			samples[i] = value
+		}
+	case subframeType == 1:
+		for i := range samples {
+			if samples[i], err = br.readSigned(bitsPerSample); err != nil {
+				return err
+			}
+		}
+	case subframeType >= 8 && subframeType <= 12:
+		if err := decoder.readFixed(samples, bitsPerSample, int(subframeType&0x07)); err != nil {
+			return err
+		}
+	case subframeType >= 32:
+		if err := decoder.readLPC(samples, bitsPerSample, int(subframeType&0x1F)+1); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("reserved subframe type %d", subframeType)
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+
+	return nil
+}
+
+// readWarmup reads the unencoded samples preceding the predicted ones
+func (decoder *Decoder) readWarmup(samples []int64, bitsPerSample uint, order int) error {
+	if order > len(samples) {
+		return fmt.Errorf("predictor order %d is larger than the block size %d", order, len(samples))
+	}
+	for i := 0; i < order; i++ {
+		value, err := decoder.br.readSigned(bitsPerSample)
+		if err != nil {
+			return err
+		}
+		samples[i] = value
+	}
+	return nil
+}
+
+func (decoder *Decoder) readFixed(samples []int64, bitsPerSample uint, order int) error {
+	if err := decoder.readWarmup(samples, bitsPerSample, order); err != nil {
+		return err
+	}
+
+	residual, err := decoder.readResidual(len(samples), order)
+	if err != nil {
+		return err
+	}
+
+	for i := order; i < len(samples); i++ {
+		r := residual[i-order]
+		switch order {
+		case 0:
+			samples[i] = r
+		case 1:
+			samples[i] = r + samples[i-1]
+		case 2:
+			samples[i] = r + 2*samples[i-1] - samples[i-2]
+		case 3:
+			samples[i] = r + 3*samples[i-1] - 3*samples[i-2] + samples[i-3]
+		case 4:
+			samples[i] = r + 4*samples[i-1] - 6*samples[i-2] + 4*samples[i-3] - samples[i-4]
+		}
+	}
+
+	return nil
+}
+
+func (decoder *Decoder) readLPC(samples []int64, bitsPerSample uint, order int) error {
+	br := decoder.br
+
+	if err := decoder.readWarmup(samples, bitsPerSample, order); err != nil {
+		return err
+	}
+
+	precision, err := br.readBits(4)
+	if err != nil {
+		return err
+	}
+	if precision == 0x0F {
+		return fmt.Errorf("invalid LPC coefficient precision")
+	}
+	precision++
+
+	shift, err := br.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return fmt.Errorf("negative LPC shift %d", shift)
+	}
+
+	coefficients := make([]int64, order)
+	for i := range coefficients {
+		if coefficients[i], err = br.readSigned(uint(precision)); err != nil {
+			return err
+		}
+	}
+
+	residual, err := decoder.readResidual(len(samples), order)
+	if err != nil {
+		return err
+	}
+
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, coefficient := range coefficients {
+			prediction += coefficient * samples[i-j-1]
+		}
+		samples[i] = residual[i-order] + prediction>>shift
+	}
+
+	return nil
+}
+
+// readResidual decodes the Rice coded residual of a predicted subframe
+func (decoder *Decoder) readResidual(blockSize int, order int) ([]int64, error) {
+	br := decoder.br
+
+	method, err := br.readBits(2)
+	if err != nil {
+		return nil, err
+	}
+	if method > 1 {
+		return nil, fmt.Errorf("reserved residual coding method %d", method)
+	}
+	parameterBits := uint(4)
+	if method == 1 {
+		parameterBits = 5
+	}
+	escape := uint64(1)<<parameterBits - 1
+
+	partitionOrder, err := br.readBits(4)
+	if err != nil {
+		return nil, err
+	}
+	partitions := 1 << partitionOrder
+	if blockSize%partitions != 0 || blockSize>>partitionOrder < order {
+		return nil, fmt.Errorf("invalid partition order %d for block size %d", partitionOrder, blockSize)
+	}
+
+	if cap(decoder.residual) < blockSize {
+		decoder.residual = make([]int64, blockSize)
+	}
+	residual := decoder.residual[:blockSize-order]
+
+	i := 0
+	for partition := 0; partition < partitions; partition++ {
+		count := blockSize >> partitionOrder
+		if partition == 0 {
+			count -= order
+		}
+
+		parameter, err := br.readBits(parameterBits)
+		if err != nil {
+			return nil, err
+		}
+
+		if parameter == escape {
+			rawBits, err := br.readBits(5)
+			if err != nil {
+				return nil, err
+			}
+			for end := i + count; i < end; i++ {
+				if residual[i], err = br.readSigned(uint(rawBits)); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		for end := i + count; i < end; i++ {
+			quotient, err := br.readUnary()
+			if err != nil {
+				return nil, err
+			}
+			remainder, err := br.readBits(uint(parameter))
+			if err != nil {
+				return nil, err
+			}
+			folded := quotient<<parameter | remainder
+			residual[i] = int64(folded>>1) ^ -int64(folded&1)
+		}
+	}
+
+	return residual, nil
+}
+
+// decorrelate restores the left and right channels from the decoded subframes
+func decorrelate(frame *Frame, work [][]int64) {
+	blockSize := frame.Header.BlockSize
+	for ch := range frame.Samples {
+		frame.Samples[ch] = make([]int32, blockSize)
+	}
+
+	switch frame.Header.ChannelAssignment {
+	case channelLeftSide:
+		left, side := work[0], work[1]
+		for i := 0; i < blockSize; i++ {
+			frame.Samples[0][i] = int32(left[i])
+			frame.Samples[1][i] = int32(left[i] - side[i])
+		}
+	case channelSideRight:
+		side, right := work[0], work[1]
+		for i := 0; i < blockSize; i++ {
+			frame.Samples[0][i] = int32(side[i] + right[i])
+			frame.Samples[1][i] = int32(right[i])
+		}
+	case channelMidSide:
+		mid, side := work[0], work[1]
+		for i := 0; i < blockSize; i++ {
+			m := mid[i]<<1 | side[i]&1
+			frame.Samples[0][i] = int32((m + side[i]) >> 1)
+			frame.Samples[1][i] = int32((m - side[i]) >> 1)
+		}
+	default:
+		for ch := range frame.Samples {
+			for i := 0; i < blockSize; i++ {
+				frame.Samples[ch][i] = int32(work[ch][i])
+			}
+		}
+	}
+}
+
+// writeSamplesMD5 adds the samples of a frame to h the way the MD5 signature of STREAMINFO is computed:
+// interleaved, little-endian, using the smallest whole number of bytes holding bitsPerSample bits
+func writeSamplesMD5(h hash.Hash, frame *Frame, bitsPerSample uint8) {
+	bytesPerSample := int(bitsPerSample+7) / 8
+	var buf bytes.Buffer
+	buf.Grow(frame.Header.BlockSize * len(frame.Samples) * bytesPerSample)
+
+	for i := 0; i < frame.Header.BlockSize; i++ {
+		for ch := range frame.Samples {
+			sample := frame.Samples[ch][i]
+			for b := 0; b < bytesPerSample; b++ {
+				buf.WriteByte(byte(sample >> (8 * b)))
+			}
+		}
+	}
+
+	h.Write(buf.Bytes())
+}
+
+// Verify decodes the whole audio stream checking the CRC of every frame and, when STREAMINFO has one,
+// the MD5 signature of the decoded audio. Errors wrap ErrFrameCRC or ErrMD5Mismatch when the audio is corrupted.
+func (flac *Flac) Verify() error {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return err
+	}
+
+	signature := md5.New()
+	var totalSamples uint64
+
+	err = flac.forEachFrame(func(frame *Frame) error {
+		totalSamples += uint64(frame.Header.BlockSize)
+		writeSamplesMD5(signature, frame, streamInfo.BitsPerSample)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if streamInfo.TotalSamples != 0 && totalSamples != streamInfo.TotalSamples {
+		return fmt.Errorf("decoded %d samples but STREAMINFO declares %d", totalSamples, streamInfo.TotalSamples)
+	}
+
+	if streamInfo.HasMD5() {
+		var computed [16]byte
+		copy(computed[:], signature.Sum(nil))
+		if computed != streamInfo.MD5 {
+			return fmt.Errorf("%w: computed %x, STREAMINFO has %x", ErrMD5Mismatch, computed, streamInfo.MD5)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// maxPreallocatedSamples is the number of samples per channel DecodeRange allocates before decoding
+const maxPreallocatedSamples = 1 << 16
+
+// DecodeRange decodes the samples from startSample included to endSample excluded, interleaved like
+// in a WAVE file: the first sample of every channel, then the second one and so on. The decoding
+// starts from the closest point of the SEEKTABLE, if any, and the frames overlapping the range are
+// cut to return exactly endSample-startSample samples per channel, as stored in the file.
+func (flac *Flac) DecodeRange(startSample, endSample uint64) ([]int32, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	if startSample > endSample {
+		return nil, fmt.Errorf("invalid range from sample %d to %d", startSample, endSample)
+	}
+	if streamInfo.TotalSamples != 0 && endSample > streamInfo.TotalSamples {
+		return nil, fmt.Errorf("sample %d is beyond the end of the audio, %d samples long", endSample, streamInfo.TotalSamples)
+	}
+
+	// The range can't be trusted to fit the audio when TotalSamples is unknown, the samples
+	// grow with every decoded frame past the first preallocated ones
+	channels := int(streamInfo.Channels)
+	samples := make([]int32, 0, int(min(endSample-startSample, maxPreallocatedSamples))*channels)
+	if startSample == endSample {
+		return samples, nil
+	}
+
+	position, offset, err := flac.seekPoint(startSample)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := flac.newDecoderAt(int64(offset))
+	if err != nil {
+		return nil, err
+	}
+
+	for first := true; position < endSample; first = false {
+		frame, err := decoder.Next()
+		if first && (position != 0 || offset != 0) && (err != nil || !frameStartsAt(streamInfo, frame, position)) {
+			// The seek point doesn't lead to the right frame, start over from the first one
+			position, offset = 0, 0
+			if decoder, err = flac.NewDecoder(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return nil, fmt.Errorf("the audio ends at sample %d, before sample %d", position, endSample)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(frame.Samples) != channels {
+			return nil, fmt.Errorf("frame at offset %d has %d channels, STREAMINFO declares %d", frame.Header.Offset, len(frame.Samples), channels)
+		}
+
+		frameEnd := position + uint64(frame.Header.BlockSize)
+		from, to := max(position, startSample), min(frameEnd, endSample)
+		if from < to {
+			samples = slices.Grow(samples, int(to-from)*channels)
+		}
+		for i := from; i < to; i++ {
+			for ch := range frame.Samples {
+				samples = append(samples, frame.Samples[ch][i-position])
+			}
+		}
+		position = frameEnd
+	}
+
+	return samples, nil
+}
+
+// seekPoint returns the sample and the offset from the first frame of the last point of the SEEKTABLE
+// at or before sample, or the first frame if there is none
+func (flac *Flac) seekPoint(sample uint64) (uint64, uint64, error) {
+	block, err := flac.getBlock("SEEKTABLE")
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to read SEEKTABLE block: %w", err)
+	}
+
+	var pointSample, pointOffset uint64
+	if block == nil {
+		return pointSample, pointOffset, nil
+	}
+	for offset := 0; offset+18 <= len(block.BlockData); offset += 18 {
+		candidate := binary.BigEndian.Uint64(block.BlockData[offset:])
+		// Points are sorted, placeholders come last
+		if candidate == 0xFFFFFFFFFFFFFFFF || candidate > sample {
+			break
+		}
+		pointSample, pointOffset = candidate, binary.BigEndian.Uint64(block.BlockData[offset+8:])
+	}
+	return pointSample, pointOffset, nil
+}
+
+// frameStartsAt reports whether the header of frame agrees with it starting at sample, when it tells
+func frameStartsAt(streamInfo *StreamInfo, frame *Frame, sample uint64) bool {
+	switch {
+	case frame.Header.VariableBlockSize:
+		return frame.Header.Number == sample
+	case streamInfo.MinBlockSize == streamInfo.MaxBlockSize:
+		return frame.Header.Number*uint64(streamInfo.MaxBlockSize) == sample
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package flacgo
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cdSectorSamples is the number of samples per channel in a CD sector, 1/75 of a second at 44.1 kHz
+	cdSectorSamples = 588
+	// cdPregapSectors is the 2 seconds pregap before the first track, not part of the cuesheet offsets
+	cdPregapSectors = 150
+	// cdDataTrackGap is the gap between the last audio track and a data track of an enhanced CD
+	cdDataTrackGap = 11400
+	// cdLeadOutTrack is the track number of the lead-out of a CD cuesheet
+	cdLeadOutTrack = 170
+)
+
+// DiscTOC is the table of contents of a CD, offsets are in sectors and include the 150 sectors pregap
+type DiscTOC struct {
+	FirstTrack int
+	LastTrack  int
+	LeadOut    int
+	// Offsets are the start offsets of the tracks from FirstTrack to LastTrack
+	Offsets []int
+}
+
+// TOC returns the table of contents of the CD the cuesheet was ripped from.
+// Data tracks are left out, as MusicBrainz does, so the lead-out of an enhanced CD is the start of
+// its data track minus the 11400 sectors gap.
+func (cueSheet *CueSheet) TOC() (*DiscTOC, error) {
+	return cueSheet.toc(false)
+}
+
+// toc builds the table of contents of the cuesheet, leaving out the trailing data tracks unless withData is set
+func (cueSheet *CueSheet) toc(withData bool) (*DiscTOC, error) {
+	if !cueSheet.IsCD {
+		return nil, fmt.Errorf("cuesheet is not from a CD")
+	}
+	if len(cueSheet.Tracks) < 2 || cueSheet.Tracks[len(cueSheet.Tracks)-1].Number != cdLeadOutTrack {
+		return nil, fmt.Errorf("cuesheet has no tracks or no lead-out")
+	}
+
+	sectors := func(samples uint64) (int, error) {
+		if samples%cdSectorSamples != 0 {
+			return 0, fmt.Errorf("offset %d is not on a CD sector boundary", samples)
+		}
+		return int(samples/cdSectorSamples) + cdPregapSectors, nil
+	}
+
+	tracks := cueSheet.Tracks[:len(cueSheet.Tracks)-1]
+	leadOut, err := sectors(cueSheet.Tracks[len(cueSheet.Tracks)-1].Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	toc := &DiscTOC{LeadOut: leadOut}
+	for i, track := range tracks {
+		// A track starts at its index 1, index 0 being the pregap
+		start := track.Offset
+		for _, index := range track.Indices {
+			if index.Number == 1 {
+				start += index.Offset
+				break
+			}
+		}
+		offset, err := sectors(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid track %d: %w", track.Number, err)
+		}
+
+		if !track.IsAudio && !withData && i > 0 {
+			// Tracks after the first data track are left out
+			if toc.LeadOut = offset - cdDataTrackGap; toc.LeadOut <= toc.Offsets[len(toc.Offsets)-1] {
+				return nil, fmt.Errorf("data track %d is too close to the audio tracks", track.Number)
+			}
+			break
+		}
+
+		if i == 0 {
+			toc.FirstTrack = int(track.Number)
+		}
+		toc.LastTrack = int(track.Number)
+		toc.Offsets = append(toc.Offsets, offset)
+	}
+
+	if toc.LastTrack-toc.FirstTrack+1 != len(toc.Offsets) {
+		return nil, fmt.Errorf("cuesheet tracks are not numbered consecutively")
+	}
+	return toc, nil
+}
+
+// String returns the TOC in the space separated format accepted by the MusicBrainz web service:
+// first track, last track, lead-out and track offsets
+func (toc *DiscTOC) String() string {
+	fields := []string{strconv.Itoa(toc.FirstTrack), strconv.Itoa(toc.LastTrack), strconv.Itoa(toc.LeadOut)}
+	for _, offset := range toc.Offsets {
+		fields = append(fields, strconv.Itoa(offset))
+	}
+	return strings.Join(fields, " ")
+}
+
+// MusicBrainzDiscID returns the MusicBrainz disc ID of the TOC, a base64 SHA-1 of its hexadecimal fields
+func (toc *DiscTOC) MusicBrainzDiscID() string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%02X%02X", toc.FirstTrack, toc.LastTrack)
+	// The lead-out is stored as offset 0, followed by the 99 possible track offsets
+	for i := 0; i < 100; i++ {
+		offset := 0
+		switch {
+		case i == 0:
+			offset = toc.LeadOut
+		case i >= toc.FirstTrack && i <= toc.LastTrack:
+			offset = toc.Offsets[i-toc.FirstTrack]
+		}
+		fmt.Fprintf(hash, "%08X", offset)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	return strings.NewReplacer("+", ".", "/", "_", "=", "-").Replace(encoded)
+}
+
+// FreeDBDiscID returns the FreeDB (CDDB) disc ID of the TOC as 8 hexadecimal digits
+func (toc *DiscTOC) FreeDBDiscID() string {
+	digitSum := func(n int) int {
+		sum := 0
+		for ; n > 0; n /= 10 {
+			sum += n % 10
+		}
+		return sum
+	}
+
+	checksum := 0
+	for _, offset := range toc.Offsets {
+		checksum += digitSum(offset / 75)
+	}
+	length := toc.LeadOut/75 - toc.Offsets[0]/75
+
+	return fmt.Sprintf("%08x", (checksum%255)<<24|length<<8|len(toc.Offsets))
+}
+
+// MusicBrainzDiscID returns the MusicBrainz disc ID of the CD the cuesheet was ripped from, see TOC
+func (cueSheet *CueSheet) MusicBrainzDiscID() (string, error) {
+	toc, err := cueSheet.TOC()
+	if err != nil {
+		return "", err
+	}
+	return toc.MusicBrainzDiscID(), nil
+}
+
+// FreeDBDiscID returns the FreeDB disc ID of the CD the cuesheet was ripped from.
+// Unlike MusicBrainz, FreeDB counts data tracks too.
+func (cueSheet *CueSheet) FreeDBDiscID() (string, error) {
+	toc, err := cueSheet.toc(true)
+	if err != nil {
+		return "", err
+	}
+	return toc.FreeDBDiscID(), nil
+}
@@ -0,0 +1,162 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// DumpBlock writes the block at the given position in the block order, starting at 0, to w:
+// its header, the fields parsed from its body for the known block types and a hex dump of the body.
+// Reserved and invalid block types are dumped too, for forensic inspection.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) DumpBlock(w io.Writer, index int) error {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+	if index < 0 || index >= len(blocks) {
+		return fmt.Errorf("no block #%d, the file has %d blocks", index, len(blocks))
+	}
+
+	block := &blocks[index]
+	if err := flac.loadBlockData(block); err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	blockType := block.BlockType
+	if blockType == "" {
+		blockType = "RESERVED"
+	}
+	fmt.Fprintf(&out, "block #%d: %s (type %d)\n", index, blockType, block.BlockHeader.BlockType)
+	fmt.Fprintf(&out, "  offset: %d\n  length: %d\n  last: %t\n", block.Index, block.BlockHeader.BlockLength, block.IsLastBlock)
+
+	if err := dumpFields(&out, block); err != nil {
+		fmt.Fprintf(&out, "  unparsable: %v\n", err)
+	}
+
+	if len(block.BlockData) > 0 {
+		out.WriteString("  data:\n")
+		dumper := hex.Dumper(&indentWriter{w: &out, indent: "    "})
+		dumper.Write(block.BlockData)
+		dumper.Close()
+	}
+
+	if _, err := io.WriteString(w, out.String()); err != nil {
+		return fmt.Errorf("unable to write dump: %w", err)
+	}
+	return nil
+}
+
+// dumpFields writes the fields of the known block types
+func dumpFields(out *strings.Builder, block *MetadataBlock) error {
+	data := block.BlockData
+
+	switch block.BlockType {
+	case "STREAMINFO":
+		streamInfo, err := parseStreamInfoBlock(data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  block size: %d-%d\n  frame size: %d-%d\n", streamInfo.MinBlockSize, streamInfo.MaxBlockSize, streamInfo.MinFrameSize, streamInfo.MaxFrameSize)
+		fmt.Fprintf(out, "  sample rate: %d\n  channels: %d\n  bits per sample: %d\n", streamInfo.SampleRate, streamInfo.Channels, streamInfo.BitsPerSample)
+		fmt.Fprintf(out, "  total samples: %d\n  MD5: %x\n", streamInfo.TotalSamples, streamInfo.MD5)
+	case "PADDING":
+		zeroes := bytes.Count(data, []byte{0})
+		fmt.Fprintf(out, "  all zeroes: %t\n", zeroes == len(data))
+	case "APPLICATION":
+		if len(data) < 4 {
+			return fmt.Errorf("APPLICATION block too short for its id")
+		}
+		fmt.Fprintf(out, "  application id: %q (%x)\n", printable(data[:4]), data[:4])
+		if string(data[:4]) == HistoryApplicationID {
+			var entries []HistoryEntry
+			if err := json.Unmarshal(data[4:], &entries); err != nil {
+				return fmt.Errorf("unable to parse edit history: %w", err)
+			}
+			fmt.Fprintf(out, "  history entries: %d\n", len(entries))
+		}
+	case "SEEKTABLE":
+		if len(data)%18 != 0 {
+			return fmt.Errorf("SEEKTABLE length %d is not a multiple of 18", len(data))
+		}
+		fmt.Fprintf(out, "  seek points: %d\n", len(data)/18)
+		for offset := 0; offset < len(data); offset += 18 {
+			sample := binary.BigEndian.Uint64(data[offset:])
+			if sample == 0xFFFFFFFFFFFFFFFF {
+				out.WriteString("    placeholder\n")
+				continue
+			}
+			fmt.Fprintf(out, "    sample=%d offset=%d samples=%d\n", sample, binary.BigEndian.Uint64(data[offset+8:]), binary.BigEndian.Uint16(data[offset+16:]))
+		}
+	case "VORBIS_COMMENT":
+		// Raw values, without the fallback encoding
+		comments, err := (&Flac{}).parseVorbisBlock(data)
+		if err != nil {
+			return err
+		}
+		vendor, _, _, _ := vorbisHeader(data)
+		fmt.Fprintf(out, "  vendor: %q\n  comments: %d\n", vendor, len(comments))
+		for _, comment := range comments {
+			fmt.Fprintf(out, "    %s=%q\n", comment.Title, comment.Value)
+		}
+	case "CUESHEET":
+		cueSheet, err := parseCueSheetBlock(data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  media catalog number: %q\n  lead-in: %d\n  CD: %t\n  tracks: %d\n", cueSheet.MediaCatalogNumber, cueSheet.LeadIn, cueSheet.IsCD, len(cueSheet.Tracks))
+		for _, track := range cueSheet.Tracks {
+			fmt.Fprintf(out, "    track %d offset=%d audio=%t pre-emphasis=%t isrc=%q indices=%d\n", track.Number, track.Offset, track.IsAudio, track.PreEmphasis, track.ISRC, len(track.Indices))
+		}
+	case "PICTURE":
+		picture, err := parsePictureBlock(data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  picture type: %d\n  MIME type: %q\n  description: %q\n", picture.PictureType, picture.MimeType, picture.Description)
+		fmt.Fprintf(out, "  size: %dx%d\n  depth: %d\n  colors: %d\n  data length: %d\n", picture.Width, picture.Height, picture.Depth, picture.Colors, len(picture.Data))
+	}
+	return nil
+}
+
+// printable replaces the non printable bytes of data with dots
+func printable(data []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return '.'
+		}
+		return r
+	}, string(data))
+}
+
+// indentWriter prefixes every line written to w with indent
+type indentWriter struct {
+	w       io.Writer
+	indent  string
+	midLine bool
+}
+
+func (iw *indentWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !iw.midLine {
+			if _, err := io.WriteString(iw.w, iw.indent); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := iw.w.Write(line); err != nil {
+			return 0, err
+		}
+		iw.midLine = line[len(line)-1] != '\n'
+	}
+	return len(p), nil
+}
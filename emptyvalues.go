@@ -0,0 +1,51 @@
+package flacgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyValue is returned by the SetMetadata methods for an empty value under EmptyValuesError
+var ErrEmptyValue = errors.New("empty tag value")
+
+// EmptyValuePolicy tells what the SetMetadata methods do with empty values
+type EmptyValuePolicy int
+
+const (
+	// EmptyValuesWrite writes comments with an empty value, e.g. "COMMENT="
+	EmptyValuesWrite EmptyValuePolicy = iota
+	// EmptyValuesDelete drops the empty values, a tag left without value is removed like RemoveMetadata does
+	EmptyValuesDelete
+	// EmptyValuesError rejects empty values with an error wrapping ErrEmptyValue
+	EmptyValuesError
+)
+
+// WithEmptyValues sets what the SetMetadata methods do with empty values, they are written by default.
+// With WithSanitizedValues, values are checked once cleaned, so a value of spaces counts as empty.
+func WithEmptyValues(policy EmptyValuePolicy) Option {
+	return func(o *options) {
+		o.emptyValues = policy
+	}
+}
+
+// prepareValues returns the values to stage for title, cleaned with SanitizeValue if the file was opened
+// with WithSanitizedValues, and checked against the empty value policy. It reports whether the tag has
+// to be removed instead, when there are no values or every value was dropped.
+func (flac *Flac) prepareValues(title string, values []string) ([]string, bool, error) {
+	prepared := make([]string, 0, len(values))
+	for _, value := range values {
+		if flac.options.sanitizeValues {
+			value = SanitizeValue(value)
+		}
+		if value == "" {
+			switch flac.options.emptyValues {
+			case EmptyValuesDelete:
+				continue
+			case EmptyValuesError:
+				return nil, false, fmt.Errorf("unable to set %s: %w", title, ErrEmptyValue)
+			}
+		}
+		prepared = append(prepared, value)
+	}
+	return prepared, len(prepared) == 0, nil
+}
@@ -0,0 +1,850 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// DefaultPadding is the size of the PADDING block written by the encoder, leaving room to add tags later
+const DefaultPadding = 8192
+
+// EncoderOptions configures the FLAC encoder
+type EncoderOptions struct {
+	// BlockSize is the number of samples per channel in each frame
+	BlockSize int
+	// MaxLPCOrder is the highest order of the linear predictors tried, 0 only uses fixed predictors
+	MaxLPCOrder int
+	// MaxPartitionOrder is the highest order of the partitions the residual is split into, each with
+	// its own Rice parameter, up to 15. 0 codes the residual of a subframe with a single parameter.
+	MaxPartitionOrder int
+	// Apodization are the windows weighting the samples before the LPC analysis, the predictors of
+	// every window are tried, see ParseApodization. DefaultWindow is used if nil.
+	Apodization []Window
+	// Comments are written to the VORBIS_COMMENT block, the comments of the PCM are used if nil
+	Comments []VorbisComment
+	// Padding is the size of the PADDING block, negative for none
+	Padding int
+}
+
+// EncoderLevel returns the options of compression levels 0 (fastest) to 8 (smallest),
+// which mirror the block sizes, predictor orders and partition orders of the reference encoder presets
+func EncoderLevel(level int) EncoderOptions {
+	level = max(0, min(level, 8))
+	opts := EncoderOptions{BlockSize: 4096, Padding: DefaultPadding}
+
+	switch {
+	case level <= 2:
+		opts.BlockSize = 1152
+	case level == 3:
+		opts.MaxLPCOrder = 6
+	case level <= 6:
+		opts.MaxLPCOrder = 8
+	default:
+		opts.MaxLPCOrder = 12
+	}
+
+	switch {
+	case level <= 2:
+		opts.MaxPartitionOrder = 3
+	case level <= 4:
+		opts.MaxPartitionOrder = 4
+	case level == 5:
+		opts.MaxPartitionOrder = 5
+	default:
+		opts.MaxPartitionOrder = 6
+	}
+
+	return opts
+}
+
+// DefaultEncoderOptions are the options of compression level 5, the default of the reference encoder
+var DefaultEncoderOptions = EncoderLevel(5)
+
+// Encode compresses pcm into a FLAC stream written to w
+func Encode(w io.Writer, pcm *PCM, opts EncoderOptions) error {
+	if err := pcm.validate(); err != nil {
+		return fmt.Errorf("unable to encode: %w", err)
+	}
+	opts, err := prepareEncoderOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	// The frames are buffered since STREAMINFO comes first and holds their sizes
+	var frames bytes.Buffer
+	enc := newEncoder(&frames, pcm.SampleRate, pcm.BitsPerSample, pcm.Channels(), opts)
+	block := make([][]int32, pcm.Channels())
+	for start := 0; start < pcm.Length(); start += opts.BlockSize {
+		end := min(start+opts.BlockSize, pcm.Length())
+		for ch := range block {
+			block[ch] = pcm.Samples[ch][start:end]
+		}
+		if err := enc.encodeBlock(block); err != nil {
+			return err
+		}
+	}
+	copy(enc.streamInfo.MD5[:], enc.signature.Sum(nil))
+
+	comments := opts.Comments
+	if comments == nil {
+		comments = pcm.Comments
+	}
+	if err := writeEncoderMetadata(w, enc.streamInfo, comments, opts.Padding); err != nil {
+		return err
+	}
+	if _, err := frames.WriteTo(w); err != nil {
+		return fmt.Errorf("unable to write frames: %w", err)
+	}
+
+	return nil
+}
+
+// prepareEncoderOptions checks opts, filling in the default block size
+func prepareEncoderOptions(opts EncoderOptions) (EncoderOptions, error) {
+	if opts.BlockSize == 0 {
+		opts.BlockSize = DefaultEncoderOptions.BlockSize
+	}
+	if opts.BlockSize < 16 || opts.BlockSize > 65535 {
+		return opts, fmt.Errorf("unable to encode: invalid block size %d", opts.BlockSize)
+	}
+	if opts.MaxLPCOrder < 0 || opts.MaxLPCOrder > 32 {
+		return opts, fmt.Errorf("unable to encode: invalid LPC order %d", opts.MaxLPCOrder)
+	}
+	if opts.MaxPartitionOrder < 0 || opts.MaxPartitionOrder > maxPartitionOrder {
+		return opts, fmt.Errorf("unable to encode: invalid partition order %d", opts.MaxPartitionOrder)
+	}
+	if len(opts.Apodization) > maxApodizationWindows {
+		return opts, fmt.Errorf("unable to encode: too many apodization windows %d", len(opts.Apodization))
+	}
+	for _, window := range opts.Apodization {
+		if err := window.validate(); err != nil {
+			return opts, fmt.Errorf("unable to encode: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// writeEncoderMetadata writes the magic header, STREAMINFO, VORBIS_COMMENT and, unless padding is
+// negative, a PADDING block of padding bytes
+func writeEncoderMetadata(w io.Writer, streamInfo *StreamInfo, comments []VorbisComment, padding int) error {
+	var metadata bytes.Buffer
+	metadata.WriteString("fLaC")
+	writeMetadataBlock(&metadata, 0, streamInfo.marshal(), false)
+	writeMetadataBlock(&metadata, 4, marshalVorbisComments(comments), padding < 0)
+	if padding >= 0 {
+		writeMetadataBlock(&metadata, 1, make([]byte, min(padding, maxBlockLength)), true)
+	}
+
+	if _, err := metadata.WriteTo(w); err != nil {
+		return fmt.Errorf("unable to write metadata: %w", err)
+	}
+	return nil
+}
+
+// writeMetadataBlock writes a metadata block header followed by its body
+func writeMetadataBlock(buf *bytes.Buffer, blockType byte, body []byte, isLast bool) {
+	if isLast {
+		blockType |= 0x80
+	}
+	length := len(body)
+	buf.Write([]byte{blockType, byte(length >> 16), byte(length >> 8), byte(length)})
+	buf.Write(body)
+}
+
+// marshalVorbisComments returns the body of a VORBIS_COMMENT block holding comments
+func marshalVorbisComments(comments []VorbisComment) []byte {
+	var body bytes.Buffer
+	binary.Write(&body, binary.LittleEndian, uint32(len(vendorString)))
+	body.WriteString(vendorString)
+	binary.Write(&body, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&body, binary.LittleEndian, uint32(len(comment.Title)+1+len(comment.Value)))
+		body.WriteString(comment.Title + "=" + comment.Value)
+	}
+	return body.Bytes()
+}
+
+// frameEncoder encodes blocks of samples into frames
+type frameEncoder struct {
+	opts          EncoderOptions
+	sampleRate    uint32
+	bitsPerSample uint
+	bw            bitWriter
+	// subframes are the candidate subframes of a frame, left, right, mid and side for stereo blocks
+	subframes [4]subframe
+	// scratch buffer reused across subframes
+	residual []int64
+	// windows are the apodization windows computed for blocks of windowLength samples
+	windows      [][]float64
+	windowLength int
+}
+
+// Subframe types picked by analyzeSubframe
+const (
+	subframeConstant = iota
+	subframeVerbatim
+	subframeFixed
+	subframeLPC
+)
+
+// subframe is the cheapest coding found for the samples of a channel
+type subframe struct {
+	// samples are shifted right by wasted, bps is the sample size left
+	samples []int64
+	bps     uint
+	wasted  uint
+	kind    int
+	order   int
+	lpc     *lpcPredictor
+	// bits is the size of the coded subframe
+	bits uint64
+}
+
+// encodeFrame encodes a block of samples, stereo blocks are coded as left/right, left/side,
+// side/right or mid/side, whichever is smallest, other channels independently
+func (enc *frameEncoder) encodeFrame(block [][]int32, number uint64) ([]byte, error) {
+	bw := &enc.bw
+	bw.reset()
+	blockSize := len(block[0])
+
+	assignment := len(block) - 1
+	var channels []*subframe
+	if len(block) == 2 {
+		var err error
+		if assignment, channels, err = enc.analyzeStereo(block); err != nil {
+			return nil, err
+		}
+	}
+
+	blockSizeCode, blockSizeBits := blockSizeCode(blockSize)
+	sampleRateCode := sampleRateCode(enc.sampleRate)
+
+	// Sync code and fixed block size strategy
+	bw.writeBits(0xFFF8, 16)
+	bw.writeBits(uint64(blockSizeCode), 4)
+	bw.writeBits(uint64(sampleRateCode), 4)
+	bw.writeBits(uint64(assignment), 4)
+	bw.writeBits(uint64(sampleSizeCode(enc.bitsPerSample)), 3)
+	bw.writeBits(0, 1)
+	writeUTF8Number(bw, number)
+	if blockSizeBits > 0 {
+		bw.writeBits(uint64(blockSize-1), blockSizeBits)
+	}
+
+	bw.writeBits(uint64(updateCRC8(0, bw.bytes())), 8)
+
+	if channels != nil {
+		for _, sf := range channels {
+			enc.writeSubframe(sf)
+		}
+	} else {
+		for ch, samples := range block {
+			sf := &enc.subframes[0]
+			if err := enc.analyzeSubframe(sf, samples, enc.bitsPerSample); err != nil {
+				return nil, fmt.Errorf("channel %d: %w", ch, err)
+			}
+			enc.writeSubframe(sf)
+		}
+	}
+
+	bw.align()
+	bw.writeBits(uint64(updateCRC16(0, bw.bytes())), 16)
+
+	return bw.bytes(), nil
+}
+
+// analyzeStereo returns the channel assignment of the smallest coding of a stereo block and its two subframes
+func (enc *frameEncoder) analyzeStereo(block [][]int32) (int, []*subframe, error) {
+	left, right, mid, side := &enc.subframes[0], &enc.subframes[1], &enc.subframes[2], &enc.subframes[3]
+	for ch, sf := range []*subframe{left, right} {
+		if err := enc.analyzeSubframe(sf, block[ch], enc.bitsPerSample); err != nil {
+			return 0, nil, fmt.Errorf("channel %d: %w", ch, err)
+		}
+	}
+	// Assignment 1 is two independent channels
+	independent := []*subframe{left, right}
+	// The side channel of 32-bit audio would need 33 bits
+	if enc.bitsPerSample >= 32 {
+		return 1, independent, nil
+	}
+
+	mid.samples, side.samples = mid.samples[:0], side.samples[:0]
+	for i := range block[0] {
+		l, r := int64(block[0][i]), int64(block[1][i])
+		mid.samples = append(mid.samples, (l+r)>>1)
+		side.samples = append(side.samples, l-r)
+	}
+	enc.analyzeSamples(mid, enc.bitsPerSample)
+	enc.analyzeSamples(side, enc.bitsPerSample+1)
+
+	assignment, channels := 1, independent
+	bestBits := left.bits + right.bits
+	for _, candidate := range []struct {
+		assignment int
+		channels   []*subframe
+	}{
+		{channelLeftSide, []*subframe{left, side}},
+		{channelSideRight, []*subframe{side, right}},
+		{channelMidSide, []*subframe{mid, side}},
+	} {
+		if bits := candidate.channels[0].bits + candidate.channels[1].bits; bits < bestBits {
+			assignment, channels, bestBits = candidate.assignment, candidate.channels, bits
+		}
+	}
+	return assignment, channels, nil
+}
+
+// analyzeSubframe checks the samples of a channel fit in bps bits and finds their cheapest coding
+func (enc *frameEncoder) analyzeSubframe(sf *subframe, block []int32, bps uint) error {
+	limit := int64(1) << (bps - 1)
+	sf.samples = sf.samples[:0]
+	for _, sample := range block {
+		if int64(sample) < -limit || int64(sample) >= limit {
+			return fmt.Errorf("sample %d doesn't fit in %d bits", sample, bps)
+		}
+		sf.samples = append(sf.samples, int64(sample))
+	}
+	enc.analyzeSamples(sf, bps)
+	return nil
+}
+
+// analyzeSamples picks the cheapest of constant, verbatim, fixed and LPC coding for the samples of sf
+func (enc *frameEncoder) analyzeSamples(sf *subframe, bps uint) {
+	samples := sf.samples
+	sf.wasted, sf.order, sf.lpc = 0, 0, nil
+
+	constant := true
+	var used int64
+	for _, sample := range samples {
+		constant = constant && sample == samples[0]
+		used |= sample
+	}
+
+	// Constant blocks, e.g. digital silence, only store their value
+	if constant {
+		sf.kind, sf.bps, sf.bits = subframeConstant, bps, 8+uint64(bps)
+		return
+	}
+
+	// The low bits unused by every sample, e.g. of 16-bit audio stored in 24 bits, are counted once
+	// in the subframe header and not coded
+	wasted := uint(bits.TrailingZeros64(uint64(used)))
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] >>= wasted
+		}
+		bps -= wasted
+	}
+	sf.bps, sf.wasted = bps, wasted
+
+	// Verbatim is the fallback, any predictor must beat it
+	sf.kind, sf.bits = subframeVerbatim, uint64(8+len(samples)*int(bps))
+
+	for order := 0; order <= min(4, len(samples)-1); order++ {
+		enc.residual = fixedResidual(enc.residual[:0], samples, order)
+		bits := 8 + uint64(order)*uint64(bps) + riceCost(enc.residual, order, enc.opts.MaxPartitionOrder)
+		if bits < sf.bits {
+			sf.kind, sf.order, sf.bits = subframeFixed, order, bits
+		}
+	}
+
+	if enc.opts.MaxLPCOrder > 0 && len(samples) > enc.opts.MaxLPCOrder {
+		for _, window := range enc.apodization(len(samples)) {
+			for _, predictor := range computeLPC(samples, window, enc.opts.MaxLPCOrder, lpcPrecision(len(samples))) {
+				enc.residual = predictor.residual(enc.residual[:0], samples)
+				order := len(predictor.coefficients)
+				bits := 8 + uint64(order)*uint64(bps) + 4 + 5 + uint64(order)*uint64(predictor.precision) + riceCost(enc.residual, order, enc.opts.MaxPartitionOrder)
+				if bits < sf.bits {
+					sf.kind, sf.order, sf.lpc, sf.bits = subframeLPC, order, predictor, bits
+				}
+			}
+		}
+	}
+
+	// The wasted bits count is written in unary
+	sf.bits += uint64(wasted)
+}
+
+// writeSubframe writes a subframe found by analyzeSamples
+func (enc *frameEncoder) writeSubframe(sf *subframe) {
+	bw := &enc.bw
+	samples, bps := sf.samples, sf.bps
+	writeHeader := func(subframeType uint64) {
+		if sf.wasted == 0 {
+			bw.writeBits(subframeType<<1, 8)
+			return
+		}
+		bw.writeBits(subframeType<<1|1, 8)
+		bw.writeUnary(uint64(sf.wasted - 1))
+	}
+
+	switch sf.kind {
+	case subframeConstant:
+		bw.writeBits(0, 8)
+		bw.writeSigned(samples[0], bps)
+	case subframeFixed:
+		writeHeader(uint64(0x08 | sf.order))
+		for _, sample := range samples[:sf.order] {
+			bw.writeSigned(sample, bps)
+		}
+		writeResidual(bw, fixedResidual(enc.residual[:0], samples, sf.order), sf.order, enc.opts.MaxPartitionOrder)
+	case subframeLPC:
+		writeHeader(uint64(0x20 | (sf.order - 1)))
+		for _, sample := range samples[:sf.order] {
+			bw.writeSigned(sample, bps)
+		}
+		bw.writeBits(uint64(sf.lpc.precision-1), 4)
+		bw.writeSigned(int64(sf.lpc.shift), 5)
+		for _, coefficient := range sf.lpc.coefficients {
+			bw.writeSigned(coefficient, sf.lpc.precision)
+		}
+		writeResidual(bw, sf.lpc.residual(enc.residual[:0], samples), sf.order, enc.opts.MaxPartitionOrder)
+	default:
+		writeHeader(0x01)
+		for _, sample := range samples {
+			bw.writeSigned(sample, bps)
+		}
+	}
+}
+
+// fixedResidual appends to residual the prediction error of the fixed predictor of the given order
+func fixedResidual(residual []int64, samples []int64, order int) []int64 {
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		switch order {
+		case 1:
+			prediction = samples[i-1]
+		case 2:
+			prediction = 2*samples[i-1] - samples[i-2]
+		case 3:
+			prediction = 3*samples[i-1] - 3*samples[i-2] + samples[i-3]
+		case 4:
+			prediction = 4*samples[i-1] - 6*samples[i-2] + 4*samples[i-3] - samples[i-4]
+		}
+		residual = append(residual, samples[i]-prediction)
+	}
+	return residual
+}
+
+// lpcPredictor is a quantized linear predictor
+type lpcPredictor struct {
+	coefficients []int64
+	precision    uint
+	shift        int
+}
+
+// residual appends to residual the prediction error of the predictor
+func (predictor *lpcPredictor) residual(residual []int64, samples []int64) []int64 {
+	order := len(predictor.coefficients)
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, coefficient := range predictor.coefficients {
+			prediction += coefficient * samples[i-j-1]
+		}
+		residual = append(residual, samples[i]-prediction>>predictor.shift)
+	}
+	return residual
+}
+
+// lpcPrecision returns the precision of the quantized coefficients used by the reference encoder for a block size
+func lpcPrecision(blockSize int) uint {
+	switch {
+	case blockSize <= 192:
+		return 7
+	case blockSize <= 384:
+		return 8
+	case blockSize <= 576:
+		return 9
+	case blockSize <= 1152:
+		return 10
+	case blockSize <= 2304:
+		return 11
+	case blockSize <= 4608:
+		return 12
+	}
+	return 13
+}
+
+// apodization returns the windows of the options over blocks of length samples
+func (enc *frameEncoder) apodization(length int) [][]float64 {
+	if enc.windows != nil && enc.windowLength == length {
+		return enc.windows
+	}
+
+	windows := enc.opts.Apodization
+	if windows == nil {
+		windows = []Window{DefaultWindow}
+	}
+	enc.windows = make([][]float64, len(windows))
+	for i, window := range windows {
+		enc.windows[i] = window.coefficients(length)
+	}
+	enc.windowLength = length
+	return enc.windows
+}
+
+// computeLPC returns the quantized predictors of orders 1 to maxOrder computed with the
+// Levinson-Durbin recursion over the autocorrelation of the samples weighted by window
+func computeLPC(samples []int64, window []float64, maxOrder int, precision uint) []*lpcPredictor {
+	windowed := make([]float64, len(samples))
+	for i, sample := range samples {
+		windowed[i] = float64(sample) * window[i]
+	}
+
+	autocorrelation := make([]float64, maxOrder+1)
+	for lag := range autocorrelation {
+		var sum float64
+		for i := lag; i < len(windowed); i++ {
+			sum += windowed[i] * windowed[i-lag]
+		}
+		autocorrelation[lag] = sum
+	}
+	if autocorrelation[0] == 0 {
+		return nil
+	}
+
+	var predictors []*lpcPredictor
+	coefficients := make([]float64, maxOrder)
+	previous := make([]float64, maxOrder)
+	err := autocorrelation[0]
+
+	for order := 1; order <= maxOrder; order++ {
+		reflection := autocorrelation[order]
+		for j := 0; j < order-1; j++ {
+			reflection -= coefficients[j] * autocorrelation[order-1-j]
+		}
+		reflection /= err
+
+		copy(previous, coefficients)
+		coefficients[order-1] = reflection
+		for j := 0; j < order-1; j++ {
+			coefficients[j] = previous[j] - reflection*previous[order-2-j]
+		}
+		err *= 1 - reflection*reflection
+		if err <= 0 {
+			break
+		}
+
+		if predictor := quantizeLPC(coefficients[:order], precision); predictor != nil {
+			predictors = append(predictors, predictor)
+		}
+	}
+
+	return predictors
+}
+
+// quantizeLPC converts coefficients to integers of the given precision with a common shift,
+// it returns nil if they can't be represented
+func quantizeLPC(coefficients []float64, precision uint) *lpcPredictor {
+	maxCoefficient := 0.0
+	for _, coefficient := range coefficients {
+		maxCoefficient = math.Max(maxCoefficient, math.Abs(coefficient))
+	}
+	if maxCoefficient == 0 {
+		return nil
+	}
+
+	_, exponent := math.Frexp(maxCoefficient)
+	shift := int(precision) - 1 - exponent
+	shift = min(shift, 15)
+	if shift < 0 {
+		return nil
+	}
+
+	limit := int64(1) << (precision - 1)
+	quantized := make([]int64, len(coefficients))
+	var carry float64
+	for i, coefficient := range coefficients {
+		// Feed the rounding error forward so it doesn't add up
+		value := coefficient*float64(int64(1)<<shift) + carry
+		rounded := math.Round(value)
+		carry = value - rounded
+		quantized[i] = max(-limit, min(int64(rounded), limit-1))
+	}
+
+	return &lpcPredictor{coefficients: quantized, precision: precision, shift: shift}
+}
+
+// tukeyWindow returns a Tukey window of the given length, p is the fraction of the window inside the cosine tapers
+func tukeyWindow(length int, p float64) []float64 {
+	window := make([]float64, length)
+	taper := int(p / 2 * float64(length))
+	for i := range window {
+		switch {
+		case taper > 0 && i < taper:
+			window[i] = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(taper))
+		case taper > 0 && i >= length-taper:
+			window[i] = 0.5 - 0.5*math.Cos(math.Pi*float64(length-1-i)/float64(taper))
+		default:
+			window[i] = 1
+		}
+	}
+	return window
+}
+
+// foldResidual maps signed residuals to unsigned values: 0, -1, 1, -2, 2...
+func foldResidual(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
+
+// riceParameter returns the Rice parameter up to maxParameter coding residual in the fewest bits and how many bits it takes
+func riceParameter(residual []int64, maxParameter uint) (uint, uint64) {
+	var sum uint64
+	for _, value := range residual {
+		sum += foldResidual(value)
+	}
+
+	// The best parameter is close to log2 of the mean, only its neighbours need to be checked
+	estimate := min(riceEstimate(len(residual), sum), maxParameter)
+
+	bestParameter, bestBits := uint(0), uint64(math.MaxUint64)
+	for parameter := max(estimate, 1) - 1; parameter <= min(estimate+1, maxParameter); parameter++ {
+		bits := uint64(len(residual)) * uint64(parameter+1)
+		for _, value := range residual {
+			bits += foldResidual(value) >> parameter
+		}
+		if bits < bestBits {
+			bestParameter, bestBits = parameter, bits
+		}
+	}
+
+	return bestParameter, bestBits
+}
+
+// riceEstimate returns the log2 of the mean of count folded residuals adding up to sum
+func riceEstimate(count int, sum uint64) uint {
+	estimate := uint(0)
+	if count > 0 && sum > uint64(count) {
+		for uint64(count)<<(estimate+1) <= sum {
+			estimate++
+		}
+	}
+	return estimate
+}
+
+// residualWidth returns the number of bits holding value as a two's complement number, 0 for 0
+func residualWidth(value int64) uint {
+	if value == 0 {
+		return 0
+	}
+	return uint(bits.Len64(uint64(value^value>>63))) + 1
+}
+
+// maxPartitionOrder is the highest partition order of the residual coding
+const maxPartitionOrder = 15
+
+// maxEscapeWidth is the largest number of bits per residual of an escaped partition, stored in 5 bits
+const maxEscapeWidth = 31
+
+// residualPartition sums up a partition of the residual to estimate its cost
+type residualPartition struct {
+	count int
+	// sum of the folded residuals
+	sum uint64
+	// width is the number of bits needed to store every residual unencoded
+	width uint
+}
+
+// bits estimates the bits the partition takes with Rice parameters of parameterBits bits, could it be
+// cheaper the partition is escaped and stored unencoded
+func (partition residualPartition) bits(parameterBits uint) uint64 {
+	// The highest parameter value is the escape code
+	parameter := min(riceEstimate(partition.count, partition.sum), uint(1)<<parameterBits-2)
+	count := uint64(partition.count)
+	best := uint64(math.MaxUint64)
+	for k := max(parameter, 1) - 1; k <= min(parameter+1, uint(1)<<parameterBits-2); k++ {
+		// Every residual takes the unary stop bit and k bits, plus its folded value shifted by k: the
+		// shift drops half a unit on average, nothing for k = 0
+		quotients := partition.sum >> k
+		if k > 0 {
+			quotients -= min(quotients, count/2)
+		}
+		best = min(best, count*uint64(k+1)+quotients)
+	}
+	if partition.width <= maxEscapeWidth {
+		best = min(best, 5+count*uint64(partition.width))
+	}
+	return uint64(parameterBits) + best
+}
+
+// residualPartitions returns the partition order up to maxOrder coding in the fewest bits the residual
+// of a predictor of the given order, and how many bits the residual takes, coding method and partition
+// order included. The cost of each order is estimated from the sums of the partitions, merging them
+// pairwise from the highest order, down to the single partition.
+func residualPartitions(residual []int64, predictorOrder, maxOrder int) (int, uint64) {
+	blockSize := len(residual) + predictorOrder
+
+	// The partitions must divide the block evenly and the first one must hold some residual
+	top := 0
+	for top < maxOrder && blockSize%(2<<top) == 0 && blockSize>>(top+1) > predictorOrder {
+		top++
+	}
+
+	partitions := make([]residualPartition, 1<<top)
+	i := 0
+	for p := range partitions {
+		count := blockSize >> top
+		if p == 0 {
+			count -= predictorOrder
+		}
+		partition := residualPartition{count: count}
+		for _, value := range residual[i : i+count] {
+			partition.sum += foldResidual(value)
+			partition.width = max(partition.width, residualWidth(value))
+		}
+		partitions[p] = partition
+		i += count
+	}
+
+	bestOrder, bestBits := 0, uint64(math.MaxUint64)
+	for order := top; order >= 0; order-- {
+		var narrow, wide uint64
+		for _, partition := range partitions {
+			narrow += partition.bits(4)
+			wide += partition.bits(5)
+		}
+		if bits := 2 + 4 + min(narrow, wide); bits <= bestBits {
+			bestOrder, bestBits = order, bits
+		}
+
+		for p := range len(partitions) / 2 {
+			a, b := partitions[2*p], partitions[2*p+1]
+			partitions[p] = residualPartition{count: a.count + b.count, sum: a.sum + b.sum, width: max(a.width, b.width)}
+		}
+		partitions = partitions[:len(partitions)/2]
+	}
+
+	return bestOrder, bestBits
+}
+
+// riceCost returns the number of bits writeResidual takes for residual, estimated
+func riceCost(residual []int64, predictorOrder, maxPartitionOrder int) uint64 {
+	_, bits := residualPartitions(residual, predictorOrder, maxPartitionOrder)
+	return bits
+}
+
+// writeResidual writes the residual of a predictor of the given order Rice coded in up to
+// 2^maxPartitionOrder partitions, escaping the partitions cheaper to store unencoded
+func writeResidual(bw *bitWriter, residual []int64, predictorOrder, maxPartitionOrder int) {
+	partitionOrder, _ := residualPartitions(residual, predictorOrder, maxPartitionOrder)
+	blockSize := len(residual) + predictorOrder
+
+	// Split the residual and find the exact parameters of both coding methods: parameters above 14
+	// need the 5 bits parameters of the second one
+	partitions := make([][]int64, 1<<partitionOrder)
+	for p, i := 0, 0; p < len(partitions); p++ {
+		count := blockSize >> partitionOrder
+		if p == 0 {
+			count -= predictorOrder
+		}
+		partitions[p] = residual[i : i+count]
+		i += count
+	}
+	plan := func(parameterBits uint) ([]uint, uint64) {
+		escape := uint(1)<<parameterBits - 1
+		parameters := make([]uint, len(partitions))
+		var total uint64
+		for p, partition := range partitions {
+			parameter, bits := riceParameter(partition, escape-1)
+			width := uint(0)
+			for _, value := range partition {
+				width = max(width, residualWidth(value))
+			}
+			if escaped := 5 + uint64(len(partition))*uint64(width); width <= maxEscapeWidth && escaped < bits {
+				parameter, bits = escape, escaped
+			}
+			parameters[p] = parameter
+			total += uint64(parameterBits) + bits
+		}
+		return parameters, total
+	}
+	parameterBits := uint(4)
+	parameters, narrow := plan(4)
+	if wideParameters, wide := plan(5); wide < narrow {
+		parameterBits, parameters = 5, wideParameters
+	}
+
+	bw.writeBits(uint64(parameterBits-4), 2)
+	bw.writeBits(uint64(partitionOrder), 4)
+	escape := uint(1)<<parameterBits - 1
+	for p, partition := range partitions {
+		parameter := parameters[p]
+		bw.writeBits(uint64(parameter), parameterBits)
+		if parameter == escape {
+			width := uint(0)
+			for _, value := range partition {
+				width = max(width, residualWidth(value))
+			}
+			bw.writeBits(uint64(width), 5)
+			for _, value := range partition {
+				bw.writeSigned(value, width)
+			}
+			continue
+		}
+		for _, value := range partition {
+			folded := foldResidual(value)
+			bw.writeUnary(folded >> parameter)
+			bw.writeBits(folded, parameter)
+		}
+	}
+}
+
+// writeUTF8Number writes a frame or sample number coded like an UTF-8 character
+func writeUTF8Number(bw *bitWriter, value uint64) {
+	if value < 0x80 {
+		bw.writeBits(value, 8)
+		return
+	}
+
+	// Each length holds 5 more bits than the previous one: 11, 16, 21, 26, 31 and 36 bits
+	length := 2
+	for value >= 1<<(5*length+1) {
+		length++
+	}
+
+	bw.writeBits(uint64(0xFF<<(8-length))&0xFF|value>>(6*(length-1)), 8)
+	for i := length - 2; i >= 0; i-- {
+		bw.writeBits(0x80|(value>>(6*i))&0x3F, 8)
+	}
+}
+
+// blockSizeCode returns the frame header code of a block size and the size of the field following
+// the header when the block size has no code of its own
+func blockSizeCode(blockSize int) (uint8, uint) {
+	switch blockSize {
+	case 192:
+		return 1, 0
+	case 576, 1152, 2304, 4608:
+		return uint8(2 + math.Log2(float64(blockSize/576))), 0
+	case 256, 512, 1024, 2048, 4096, 8192, 16384, 32768:
+		return uint8(8 + math.Log2(float64(blockSize/256))), 0
+	}
+	if blockSize <= 256 {
+		return 6, 8
+	}
+	return 7, 16
+}
+
+// sampleRateCode returns the frame header code of a sample rate, 0 meaning it's read from STREAMINFO
+func sampleRateCode(sampleRate uint32) uint8 {
+	for code, rate := range []uint32{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000} {
+		if code > 0 && rate == sampleRate {
+			return uint8(code)
+		}
+	}
+	return 0
+}
+
+// sampleSizeCode returns the frame header code of a sample size, 0 meaning it's read from STREAMINFO
+func sampleSizeCode(bitsPerSample uint) uint8 {
+	for code, size := range []uint{0, 8, 12, 0, 16, 20, 24, 32} {
+		if code > 0 && size == bitsPerSample {
+			return uint8(code)
+		}
+	}
+	return 0
+}
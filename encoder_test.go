@@ -0,0 +1,93 @@
+package flacgo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodeAndDecode encodes pcm to a temporary file and decodes it back, it returns the decoded audio and the file size
+func encodeAndDecode(t *testing.T, pcm *PCM, opts EncoderOptions) (*PCM, int64) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, pcm, opts); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "encoded.flac")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flac, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+	if err := flac.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := flac.DecodePCM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded, int64(buf.Len())
+}
+
+// stereoTestSignal returns a second of two channels sharing a sine and noise, with a little
+// independent noise on each
+func stereoTestSignal(bitsPerSample uint8, sign int32) *PCM {
+	random := rand.New(rand.NewSource(1))
+	scale := float64(int64(1)<<(bitsPerSample-2)) - 1
+	left, right := make([]int32, 44100), make([]int32, 44100)
+	for i := range left {
+		common := math.Sin(float64(i)*2*math.Pi*440/44100)*0.8 + random.Float64()*0.05
+		left[i] = int32(common*scale) + int32(random.Intn(16))
+		right[i] = sign*int32(common*scale) + int32(random.Intn(16))
+	}
+	return &PCM{SampleRate: 44100, BitsPerSample: bitsPerSample, Samples: [][]int32{left, right}}
+}
+
+func TestEncodeStereoRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		pcm  *PCM
+	}{
+		{"correlated 16-bit", stereoTestSignal(16, 1)},
+		{"opposite phase 16-bit", stereoTestSignal(16, -1)},
+		{"correlated 24-bit", stereoTestSignal(24, 1)},
+		{"full scale 32-bit", &PCM{SampleRate: 44100, BitsPerSample: 32, Samples: [][]int32{
+			{math.MaxInt32, math.MinInt32, 0, math.MaxInt32},
+			{math.MinInt32, math.MaxInt32, -1, math.MinInt32},
+		}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, level := range []int{0, 5, 8} {
+				decoded, _ := encodeAndDecode(t, test.pcm, EncoderLevel(level))
+				for ch := range test.pcm.Samples {
+					for i, want := range test.pcm.Samples[ch] {
+						if got := decoded.Samples[ch][i]; got != want {
+							t.Fatalf("level %d: sample %d of channel %d is %d, expected %d", level, i, ch, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeStereoDecorrelates(t *testing.T) {
+	pcm := stereoTestSignal(16, 1)
+	mono := &PCM{SampleRate: pcm.SampleRate, BitsPerSample: pcm.BitsPerSample, Samples: pcm.Samples[:1]}
+	identical := &PCM{SampleRate: pcm.SampleRate, BitsPerSample: pcm.BitsPerSample, Samples: [][]int32{pcm.Samples[0], pcm.Samples[0]}}
+
+	opts := EncoderLevel(5)
+	opts.Padding = -1
+	_, monoSize := encodeAndDecode(t, mono, opts)
+	_, stereoSize := encodeAndDecode(t, identical, opts)
+	// The side channel of identical channels is digital silence
+	if stereoSize > monoSize+monoSize/20 {
+		t.Errorf("identical channels take %d bytes, one channel alone %d", stereoSize, monoSize)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func main() {
+	reader, err := flacgo.Open("examples/sample.flac")
+
+	if err != nil {
+		panic(err)
+	}
+
+	err = reader.SetChapters([]flacgo.Chapter{
+		{Start: 0, Title: "Intro"},
+		{Start: 500 * time.Millisecond, Title: "Chapter 1"},
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	outputPath := "with_chapters.flac"
+	err = reader.Save(&outputPath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	withChapters, err := flacgo.Open(outputPath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// Print the chapters as an FFmpeg metadata file
+	err = withChapters.ExportChapters(os.Stdout, flacgo.ChapterFormatFFMetadata)
+
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("[+] DONE")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+func main() {
+	reader, err := flacgo.Open("examples/samplewithmetadata.flac")
+
+	if err != nil {
+		panic(err)
+	}
+
+	duplicates, err := reader.DuplicatePictures()
+
+	if err != nil {
+		panic(err)
+	}
+
+	for _, group := range duplicates {
+		fmt.Printf("[+] Found %d copies of a %s picture\n", len(group), group[0].MimeType)
+	}
+
+	removed, err := reader.PruneDuplicatePictures()
+
+	if err != nil {
+		panic(err)
+	}
+
+	outputPath := "without_duplicates.flac"
+	err = reader.Save(&outputPath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("[+] DONE, removed %d pictures\n", removed)
+}
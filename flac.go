@@ -6,10 +6,15 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"image"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 var BlockMapping = map[uint8]string{
@@ -23,6 +28,24 @@ var BlockMapping = map[uint8]string{
 	127: "INVALID",
 }
 
+// vendorString is written as vendor of the VORBIS_COMMENT blocks created by flacgo
+const vendorString = "flacgo1.1"
+
+// maxBlockLength is the largest body a metadata block can have, its length is stored in 24 bits
+const maxBlockLength = 1<<24 - 1
+
+// BlockTooLargeError is returned when staged changes would make a metadata block longer than its
+// 24 bits length field allows, 16777215 bytes
+type BlockTooLargeError struct {
+	BlockType string
+	// Size is the size the body of the block would have in bytes
+	Size int
+}
+
+func (err *BlockTooLargeError) Error() string {
+	return fmt.Sprintf("%s block would be %d bytes, the limit is %d", err.BlockType, err.Size, maxBlockLength)
+}
+
 // MetadataBlockHeader represents the header bytes of a MetadataBlock
 type MetadataBlockHeader struct {
 	BlockType   uint8
@@ -38,6 +61,9 @@ type MetadataBlock struct {
 	IsLastBlock bool
 	BlockHeader MetadataBlockHeader
 	BlockData   []byte
+
+	// stream is set for a picture staged from a file, whose body is streamed while saving
+	stream *pendingPicture
 }
 
 // VorbisComment holds key and values to add a new VORBIS_COMMENT
@@ -46,89 +72,253 @@ type VorbisComment struct {
 	Value string
 }
 
+// FlacMetadatas holds the tags usually written by rippers, see BulkAddMetadata and BulkReadMetadata
 type FlacMetadatas struct {
-	Title  string
-	Artist string
-	Album  string
-	Date   string
-	Cover  []byte
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Date        string
+	Genre       string
+	TrackNumber string
+	TrackTotal  string
+	DiscNumber  string
+	DiscTotal   string
+	Composer    string
+	Comment     string
+	Lyrics      string
+	Cover       []byte
+	// Extra holds the other comments, along with the values following the first one of the tags above
+	Extra []VorbisComment
+}
+
+// flacMetadatasFields maps the tags of FlacMetadatas to its fields, in the order they are written
+var flacMetadatasFields = []struct {
+	key   string
+	field func(meta *FlacMetadatas) *string
+}{
+	{"TITLE", func(meta *FlacMetadatas) *string { return &meta.Title }},
+	{"ARTIST", func(meta *FlacMetadatas) *string { return &meta.Artist }},
+	{"ALBUM", func(meta *FlacMetadatas) *string { return &meta.Album }},
+	{"ALBUMARTIST", func(meta *FlacMetadatas) *string { return &meta.AlbumArtist }},
+	{"DATE", func(meta *FlacMetadatas) *string { return &meta.Date }},
+	{"GENRE", func(meta *FlacMetadatas) *string { return &meta.Genre }},
+	{"TRACKNUMBER", func(meta *FlacMetadatas) *string { return &meta.TrackNumber }},
+	{"TRACKTOTAL", func(meta *FlacMetadatas) *string { return &meta.TrackTotal }},
+	{"DISCNUMBER", func(meta *FlacMetadatas) *string { return &meta.DiscNumber }},
+	{"DISCTOTAL", func(meta *FlacMetadatas) *string { return &meta.DiscTotal }},
+	{"COMPOSER", func(meta *FlacMetadatas) *string { return &meta.Composer }},
+	{"COMMENT", func(meta *FlacMetadatas) *string { return &meta.Comment }},
+	{"LYRICS", func(meta *FlacMetadatas) *string { return &meta.Lyrics }},
+}
+
+// source is the minimal set of IO operations needed to read a FLAC file.
+// Every read is done at an explicit offset, so there is no shared cursor to keep in sync.
+type source interface {
+	io.ReaderAt
 }
 
 // Flac is the main struct holding a pointer to the currently opened file
 type Flac struct {
-	file                *os.File
-	fileName            string
-	fileSize            int64
-	vorbisIndex         *int64
-	vorbisLength        int
-	pendingComments     []VorbisComment
-	parsedComments      []VorbisComment
-	removedComments     map[string]bool
-	parsedCoverPicture  *MetadataBlock
+	file            source
+	fileName        string
+	fileSize        int64
+	audioOffset     int64
+	vorbisIndex     *int64
+	vorbisLength    int
+	pendingComments []VorbisComment
+	parsedComments  []VorbisComment
+	// decodedComments is set when values were decoded with the fallback encoding, Save writes them as UTF-8
+	decodedComments    bool
+	removedComments    map[string]bool
+	parsedCoverPicture *MetadataBlock
+	// parsedPictures are the PICTURE blocks of the file in order, their bodies not loaded
+	parsedPictures      []MetadataBlock
 	pendingCoverPicture []byte
+	pendingCoverStream  *pendingPicture
+	pendingPictures     []*pendingPicture
 	removeCoverPicture  bool
+	removedPictures     map[int64]bool
+	apeTag              *apeTag
+	stripAPETag         bool
+	purgeHistory        bool
+	pendingStreamInfo   *StreamInfo
+	undoStack           []stagedState
+	undoDepth           int
+	options             options
+	// diskSize and modTime are the state of the file opened with Open, see ChangedOnDisk
+	diskSize int64
+	modTime  time.Time
 }
 
 // Open a file from a given path
-func Open(path string) (*Flac, error) {
+func Open(path string, opts ...Option) (*Flac, error) {
 	f, err := os.Open(path)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize flacgo: %w", err)
 	}
 
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to stat file %w", err)
+	}
+
+	var src source = f
+	if applyOptions(opts).mmap && fileInfo.Size() > 0 {
+		mapped, err := mmapFile(f, fileInfo.Size())
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to memory-map file: %w", err)
+		}
+		src = mapped
+	}
+
+	flac, err := newFlac(src, f.Name(), fileInfo.Size(), opts)
+	if err != nil {
+		// Closing src closes f, unmapping its view first if it was memory-mapped
+		src.(io.Closer).Close()
+		return nil, err
+	}
+	flac.diskSize, flac.modTime = fileInfo.Size(), fileInfo.ModTime()
+	return flac, nil
+}
+
+// OpenReader reads a whole FLAC stream from r and keeps it in memory.
+// Since there is no file backing the stream, Save requires an output path;
+// use WriteTo to send the result to any io.Writer.
+func OpenReader(r io.Reader, opts ...Option) (*Flac, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize flacgo: %w", err)
+	}
+
+	return newFlac(bytes.NewReader(data), "", int64(len(data)), opts)
+}
+
+// Close releases the file opened by Open, it's a no-op for streams opened with OpenReader
+func (flac *Flac) Close() error {
+	if closer, ok := flac.file.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// newFlac parses the metadata blocks of the given source
+func newFlac(f source, fileName string, fileSize int64, opts []Option) (*Flac, error) {
 	// Check if the opened file is a valid FLAC file.
 	magicHeader := make([]byte, 4)
-	f.Read(magicHeader)
+	f.ReadAt(magicHeader, 0)
 	if GetAsText(magicHeader) != "fLaC" {
 		return nil, fmt.Errorf("invalid FLAC format file, found '%s' instead", GetAsText(magicHeader))
 	}
 
-	fileInfo, err := f.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("unable to stat file %w", err)
+	options := applyOptions(opts)
+	if options.throttle != nil {
+		f = &throttledSource{source: f, throttle: options.throttle}
 	}
 
 	flacRef := &Flac{
 		file:               f,
-		fileName:           f.Name(),
-		fileSize:           fileInfo.Size(),
+		fileName:           fileName,
+		fileSize:           fileSize,
 		removeCoverPicture: false,
+		options:            options,
 	}
 
-	vorbisBlock, _ := flacRef.getBlock("VORBIS_COMMENT")
-	pictureBlock, _ := flacRef.getBlock("PICTURE")
+	apeTag, err := findAPETag(f, fileSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read APEv2 tag: %w", err)
+	}
+	flacRef.apeTag = apeTag
 
-	flacRef.parsedCoverPicture = pictureBlock
+	if flacRef.options.strict {
+		if err := flacRef.checkStrict(); err != nil {
+			return nil, fmt.Errorf("file violates the FLAC specification: %w", err)
+		}
+	}
+
+	if err := flacRef.parseMetadata(); err != nil {
+		return nil, err
+	}
+	return flacRef, nil
+}
+
+// parseMetadata reads the metadata blocks and sets up the state for staging changes
+func (flac *Flac) parseMetadata() error {
+	if !flac.options.readOnly {
+		flac.removedPictures = make(map[int64]bool)
+	}
+
+	// Read all the blocks once and keep the last VORBIS_COMMENT and PICTURE, like getBlock does
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+
+	last := blocks[len(blocks)-1]
+	flac.audioOffset = last.Index + 4 + int64(last.BlockHeader.BlockLength)
+
+	var streamInfoBlock, vorbisBlock, pictureBlock *MetadataBlock
+	var pictures []MetadataBlock
+	for i := range blocks {
+		switch blocks[i].BlockType {
+		case "STREAMINFO":
+			streamInfoBlock = &blocks[i]
+		case "VORBIS_COMMENT":
+			vorbisBlock = &blocks[i]
+		case "PICTURE":
+			pictureBlock = &blocks[i]
+			pictures = append(pictures, blocks[i])
+		}
+	}
+
+	// Every save path copies STREAMINFO and the frames can't be decoded without it
+	if streamInfoBlock == nil {
+		return fmt.Errorf("missing STREAMINFO block")
+	}
+
+	flac.parsedCoverPicture = pictureBlock
+	flac.parsedPictures = pictures
 
 	if vorbisBlock == nil {
-		flacRef.vorbisIndex = nil
-		flacRef.parsedComments = make([]VorbisComment, 0)
-		flacRef.pendingComments = make([]VorbisComment, 0)
-		return flacRef, nil
+		flac.vorbisIndex = nil
+		flac.parsedComments = make([]VorbisComment, 0)
+		flac.pendingComments = make([]VorbisComment, 0)
+		if !flac.options.readOnly {
+			flac.removedComments = make(map[string]bool)
+		}
+		return nil
 	}
 
-	flacRef.vorbisIndex = &vorbisBlock.Index
-	flacRef.vorbisLength = int(vorbisBlock.BlockHeader.BlockLength)
+	flac.vorbisIndex = &vorbisBlock.Index
+	flac.vorbisLength = int(vorbisBlock.BlockHeader.BlockLength)
 
-	parsedComments, err := flacRef.parseVorbisBlock(vorbisBlock.BlockData)
+	parsedComments, err := flac.parseVorbisBlock(vorbisBlock.BlockData)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse vorbis blocks %w", err)
+		return fmt.Errorf("unable to parse vorbis blocks %w", err)
 	}
 
-	flacRef.parsedComments = parsedComments
+	flac.parsedComments = parsedComments
 	// Fill new comments to write with the parsed one, if no changes are made then it will write the same as before
 	// NOTE: TODO: now the best because it write even tho is not necessary, fix??
-	flacRef.pendingComments = parsedComments
-	flacRef.removedComments = make(map[string]bool)
+	flac.pendingComments = parsedComments
+	if !flac.options.readOnly {
+		flac.removedComments = make(map[string]bool)
+	}
 
-	return flacRef, nil
+	return nil
 }
 
-// ReadBytes tries to read `bytesNum` amount of bytes from the currently open file
-func (flac *Flac) readBytes(bytesNum int) ([]byte, error) {
+// ReadBytesAt tries to read `bytesNum` amount of bytes from the currently open file starting at offset
+func (flac *Flac) readBytesAt(offset int64, bytesNum int) ([]byte, error) {
 	data := make([]byte, bytesNum)
-	_, err := io.ReadFull(flac.file, data)
+	n, err := flac.file.ReadAt(data, offset)
+	if n == bytesNum {
+		// ReadAt may report io.EOF along with a full read at the end of the file
+		err = nil
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("unable to read %d bytes from file: %w", bytesNum, err)
@@ -162,13 +352,18 @@ func (flac *Flac) getBlock(blockType string) (*MetadataBlock, error) {
 		}
 	}
 
+	if fullBlock != nil {
+		if err := flac.loadBlockData(fullBlock); err != nil {
+			return nil, err
+		}
+	}
+
 	return fullBlock, nil
 }
 
 // ReadMetadataBlock reads a metadata block from the given offset
 func (flac *Flac) readMetadataBlock(offset int64) (*MetadataBlock, error) {
-	flac.file.Seek(offset, io.SeekStart)
-	headerBytes, err := flac.readBytes(4)
+	headerBytes, err := flac.readBytesAt(offset, 4)
 
 	if err != nil {
 		return nil, fmt.Errorf("unable to read header bytes from offset '%d': %w", offset, err)
@@ -181,10 +376,13 @@ func (flac *Flac) readMetadataBlock(offset int64) (*MetadataBlock, error) {
 	// Uint32 requires 4 bytes slice to convert to Uint32 so I add one before as 0x00
 	blockLength := binary.BigEndian.Uint32(append([]byte{0}, lengthBytes...))
 
-	blockContent, err := flac.readBytes(int(blockLength))
+	var blockContent []byte
+	if flac.readsBody(BlockMapping[blockType]) {
+		blockContent, err = flac.readBytesAt(offset+4, int(blockLength))
 
-	if err != nil {
-		return nil, fmt.Errorf("unable to read metadata block with offset %d: %w", offset, err)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read metadata block with offset %d: %w", offset, err)
+		}
 	}
 
 	return &MetadataBlock{
@@ -212,7 +410,7 @@ func (flac *Flac) readAllMetadataBlocks() ([]MetadataBlock, error) {
 			return nil, fmt.Errorf("unable to read metadata block with offset %d: %w", offset, err)
 		}
 
-		offset += 4 + len(data.BlockData)
+		offset += 4 + int(data.BlockHeader.BlockLength)
 		blocks = append(blocks, *data)
 
 		if data.IsLastBlock {
@@ -223,73 +421,171 @@ func (flac *Flac) readAllMetadataBlocks() ([]MetadataBlock, error) {
 	return blocks, nil
 }
 
-// ParseVorbisBlock tries to parse bytes from a vorbis block into a human readable structure
-func (flac *Flac) parseVorbisBlock(vorbisBlock []byte) ([]VorbisComment, error) {
-	var vorbisComments []VorbisComment
+// readsBody tells whether the body of blocks of the given type is read along with their header.
+// PICTURE bodies are never read upfront since they can weigh several megabytes: the block is kept
+// as an offset and a length until the picture is actually requested.
+func (flac *Flac) readsBody(blockType string) bool {
+	if blockType == "PICTURE" {
+		return false
+	}
+	return !flac.options.headerOnly || blockType == "STREAMINFO" || blockType == "VORBIS_COMMENT"
+}
+
+// loadBlockData reads the body of a block whose body was skipped while reading its header
+func (flac *Flac) loadBlockData(block *MetadataBlock) error {
+	if block.BlockData != nil || block.BlockHeader.BlockLength == 0 {
+		return nil
+	}
+
+	data, err := flac.readBytesAt(block.Index+4, int(block.BlockHeader.BlockLength))
+	if err != nil {
+		return fmt.Errorf("unable to read metadata block with offset %d: %w", block.Index, err)
+	}
+	block.BlockData = data
+
+	return nil
+}
+
+// readAllMetadataBlocksWithData reads all metadata blocks including the bodies skipped by the header only mode
+func (flac *Flac) readAllMetadataBlocksWithData() ([]MetadataBlock, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range blocks {
+		if err := flac.loadBlockData(&blocks[i]); err != nil {
+			return nil, err
+		}
+	}
 
-	if len(vorbisBlock) < 8 {
-		return nil, fmt.Errorf("vorbis block is too short")
+	return blocks, nil
+}
+
+// AudioOffset returns the offset of the first audio frame, where the metadata region ends
+func (flac *Flac) AudioOffset() int64 {
+	return flac.audioOffset
+}
+
+// MetadataBytes returns the metadata region as stored in the file, from the "fLaC" marker to the
+// first audio frame, so that it can be hashed or archived apart from the audio.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) MetadataBytes() ([]byte, error) {
+	data, err := flac.readBytesAt(0, int(flac.audioOffset))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read metadata region: %w", err)
+	}
+	return data, nil
+}
+
+// Blocks returns all the metadata blocks as they are stored in the file, in order.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) Blocks() ([]MetadataBlock, error) {
+	return flac.readAllMetadataBlocksWithData()
+}
+
+// vorbisHeader returns the vendor string of a VORBIS_COMMENT body, the number of comments and the offset
+// they start at. An empty body, or one ending right after the vendor string, has no comments.
+func vorbisHeader(vorbisBlock []byte) ([]byte, uint64, uint64, error) {
+	blockLength := uint64(len(vorbisBlock))
+	if blockLength == 0 {
+		return nil, 0, 0, nil
+	}
+	if blockLength < 4 {
+		return nil, 0, 0, fmt.Errorf("vorbis block is too short")
+	}
+
+	vendorLength := uint64(binary.LittleEndian.Uint32(vorbisBlock[0:4]))
+	if blockLength < 4+vendorLength {
+		return nil, 0, 0, fmt.Errorf("vorbis block too short for vendor length")
+	}
+	vendor := vorbisBlock[4 : 4+vendorLength]
+	if blockLength == 4+vendorLength {
+		return vendor, 0, blockLength, nil
+	}
+	if blockLength < 4+4+vendorLength {
+		return nil, 0, 0, fmt.Errorf("vorbis block too short for the number of comments")
 	}
 
-	vendorLength := binary.LittleEndian.Uint32(vorbisBlock[0:4])
+	numberOfComments := uint64(binary.LittleEndian.Uint32(vorbisBlock[4+vendorLength : 4+4+vendorLength]))
+	return vendor, numberOfComments, 4 + 4 + vendorLength, nil
+}
 
-	if len(vorbisBlock) < int(4+4+vendorLength) {
-		return nil, fmt.Errorf("vorbis block too short for vendor length")
+// ParseVorbisBlock tries to parse bytes from a vorbis block into a human readable structure.
+// The block is converted to a string only once and every title and value is a substring of it,
+// so large blocks (lyrics, cuesheets) are parsed without copying each comment around.
+// Values decoded with the fallback encoding set decodedComments.
+func (flac *Flac) parseVorbisBlock(vorbisBlock []byte) ([]VorbisComment, error) {
+	_, numberOfComments, offset, err := vorbisHeader(vorbisBlock)
+	if err != nil {
+		return nil, err
 	}
+	blockLength := uint64(len(vorbisBlock))
 
-	numberOfComments := binary.LittleEndian.Uint32(vorbisBlock[4+vendorLength : 4+4+vendorLength])
+	// Every comment takes at least 4 bytes, so a corrupted counter can't make us allocate too much
+	vorbisComments := make([]VorbisComment, 0, min(numberOfComments, (blockLength-offset)/4))
+	text := string(vorbisBlock)
 
-	iteration := 0
-	offset := 4 + 4 + vendorLength
-	for iteration < int(numberOfComments) {
-		if len(vorbisBlock) < int(offset)+4 {
+	for iteration := uint64(0); iteration < numberOfComments; iteration++ {
+		if blockLength < offset+4 {
 			return nil, fmt.Errorf("unexpected end of vorbis block while reading comment length")
 		}
 
-		commentLength := binary.LittleEndian.Uint32(vorbisBlock[offset : offset+4])
+		commentStart := offset + 4
+		commentEnd := commentStart + uint64(binary.LittleEndian.Uint32(vorbisBlock[offset:commentStart]))
 
-		if len(vorbisBlock) < int(offset)+int(commentLength) {
+		if blockLength < commentEnd {
 			return nil, fmt.Errorf("unexpected end of vorbis block while reading comment content")
 		}
 
-		commentContent := string(vorbisBlock[offset+4 : offset+4+commentLength])
-
-		values := strings.Split(commentContent, "=")
+		// Only the first '=' separates the field name, values are free to contain more of them
+		separator := strings.IndexByte(text[commentStart:commentEnd], '=')
 
-		if len(values) != 2 {
-			return nil, fmt.Errorf("malformed comment (no '=' found): %q", commentContent)
+		if separator < 0 {
+			return nil, fmt.Errorf("malformed comment (no '=' found): %q", text[commentStart:commentEnd])
 		}
 
+		titleEnd := commentStart + uint64(separator)
+		value := text[titleEnd+1 : commentEnd]
+		if flac.options.fallbackEncoding != nil && !utf8.ValidString(value) {
+			decoded, err := flac.options.fallbackEncoding.Decode([]byte(value))
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode the value of %s: %w", text[commentStart:titleEnd], err)
+			}
+			value = decoded
+			flac.decodedComments = true
+		}
 		vorbisComments = append(vorbisComments, VorbisComment{
-			Title: values[0],
-			Value: values[1],
+			Title: text[commentStart:titleEnd],
+			Value: value,
 		})
 
-		offset += commentLength + 4
-		iteration += 1
+		offset = commentEnd
 	}
 
 	return vorbisComments, nil
 }
 
-func (flac *Flac) parseImageFromPath(imagePath string) (*string, []byte, error) {
-	_, imageType, err := ParseImage(imagePath)
-
-	if err != nil {
-		return nil, nil, fmt.Errorf("unable to read image: %w", err)
+func (flac *Flac) createPictureBlock(imageData []byte, pictureMimeType string) ([]byte, error) {
+	picture := &Picture{PictureType: PictureTypeFrontCover, MimeType: pictureMimeType}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(imageData)); err == nil {
+		picture.setImageConfig(config)
 	}
 
-	pictureMimeType := "image/" + imageType
-	imageData, err := os.ReadFile(imagePath)
-
+	header, err := flac.createPictureHeader(picture, len(imageData))
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to read image data %w", err)
+		return nil, err
 	}
 
-	return &pictureMimeType, imageData, nil
+	return append(header, imageData...), nil
 }
 
-func (flac *Flac) createPictureBlock(imageData []byte, pictureMimeType string) ([]byte, error) {
+// createPictureHeader creates the header of a PICTURE block followed by the fields of picture, up to the image data
+func (flac *Flac) createPictureHeader(picture *Picture, dataLength int) ([]byte, error) {
+	if err := flac.checkPictureSize(dataLength, picture.MimeType, picture.Description); err != nil {
+		return nil, err
+	}
+
 	var buf bytes.Buffer
 	var fullBuf bytes.Buffer
 
@@ -297,27 +593,25 @@ func (flac *Flac) createPictureBlock(imageData []byte, pictureMimeType string) (
 	header |= 0x80
 	fullBuf.WriteByte(header)
 
-	binary.Write(&buf, binary.BigEndian, uint32(3))
-	binary.Write(&buf, binary.BigEndian, uint32(len(pictureMimeType)))
-	buf.WriteString(pictureMimeType)
-	binary.Write(&buf, binary.BigEndian, uint32(len("")))
-	buf.WriteString("")
-	binary.Write(&buf, binary.BigEndian, uint32(600))
-	binary.Write(&buf, binary.BigEndian, uint32(600))
-	binary.Write(&buf, binary.BigEndian, uint32(24))
-	binary.Write(&buf, binary.BigEndian, uint32(0))
-	binary.Write(&buf, binary.BigEndian, uint32(len(imageData)))
-	buf.Write(imageData)
-
-	blockData := buf.Bytes()
-	length := uint32(len(blockData))
+	binary.Write(&buf, binary.BigEndian, picture.PictureType)
+	binary.Write(&buf, binary.BigEndian, uint32(len(picture.MimeType)))
+	buf.WriteString(picture.MimeType)
+	binary.Write(&buf, binary.BigEndian, uint32(len(picture.Description)))
+	buf.WriteString(picture.Description)
+	binary.Write(&buf, binary.BigEndian, picture.Width)
+	binary.Write(&buf, binary.BigEndian, picture.Height)
+	binary.Write(&buf, binary.BigEndian, picture.Depth)
+	binary.Write(&buf, binary.BigEndian, picture.Colors)
+	binary.Write(&buf, binary.BigEndian, uint32(dataLength))
+
+	length := uint32(buf.Len() + dataLength)
 
 	fullBuf.Write([]byte{
 		byte((length >> 16) & 0xFF),
 		byte((length >> 8) & 0xFF),
 		byte(length & 0xFF),
 	})
-	fullBuf.Write(blockData)
+	fullBuf.Write(buf.Bytes())
 
 	return fullBuf.Bytes(), nil
 }
@@ -325,14 +619,10 @@ func (flac *Flac) createPictureBlock(imageData []byte, pictureMimeType string) (
 // CreateVorbisBlock creates a new VORBIS_COMMENT metadata block inside the flac file
 func (flac *Flac) createVorbisBlock() ([]byte, error) {
 
-	if len(flac.pendingComments) == 0 {
-		return nil, nil
-	}
-
 	blockType := 4 // 4 = VORBIS_COMMENT
 
 	var body []byte
-	vendor := []byte("flacgo1.1")
+	vendor := []byte(vendorString)
 	vendorLength := ToBytes(uint32(len(vendor)), 4, binary.LittleEndian)
 
 	allMetadata := FilterDuplicatedComments(flac.parsedComments, flac.pendingComments, flac.removedComments)
@@ -346,6 +636,9 @@ func (flac *Flac) createVorbisBlock() ([]byte, error) {
 
 		body = AppendTo(body, [][]byte{commentLength, comment})
 	}
+	if len(body) > maxBlockLength {
+		return nil, &BlockTooLargeError{BlockType: "VORBIS_COMMENT", Size: len(body)}
+	}
 
 	isLast := 0
 	payloadLength := ToBytes(uint32(len(body)), 3, binary.BigEndian)
@@ -358,24 +651,31 @@ func (flac *Flac) createVorbisBlock() ([]byte, error) {
 	return header, nil
 }
 
+// vorbisBodySize returns the size of the VORBIS_COMMENT body createVorbisBlock builds for comments
+func vorbisBodySize(comments []VorbisComment) int {
+	size := 4 + len(vendorString) + 4
+	for _, cmt := range comments {
+		size += 4 + len(cmt.Title) + 1 + len(cmt.Value)
+	}
+	return size
+}
+
 // SplitByBlock splits the file into two parts exactly at the end of the block content
 func (flac *Flac) splitByBlock(block *MetadataBlock) ([]byte, []byte, error) {
 
 	blockIndex := block.Index
 
-	// Read from file start until the start of the current block we want to remov
-	flac.file.Seek(0, 0)
 	// Get the current block (the one we want to remove) size
 	currentBlockSize := int64(4 + block.BlockHeader.BlockLength)
-	previousData, err := flac.readBytes(int(blockIndex) + int(currentBlockSize))
+	// Read from file start until the end of the current block
+	previousData, err := flac.readBytesAt(0, int(blockIndex)+int(currentBlockSize))
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to read bytes: %w", err)
 	}
 
-	// Move to the end of the current block size we want to remove and read the rest of the file
-	flac.file.Seek(blockIndex+currentBlockSize, 0)
-	postData, err := flac.readBytes(int(flac.fileSize) - (int(blockIndex) + int(currentBlockSize)))
+	// Read the rest of the file starting at the end of the current block
+	postData, err := flac.readBytesAt(blockIndex+currentBlockSize, int(flac.fileSize)-(int(blockIndex)+int(currentBlockSize)))
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to split file: %w", err)
@@ -384,10 +684,10 @@ func (flac *Flac) splitByBlock(block *MetadataBlock) ([]byte, []byte, error) {
 	return previousData, postData, nil
 }
 
-// ReadMetadata from the currently open FLAC file
+// ReadMetadata from the currently open FLAC file, title is compared ignoring case
 func (flac *Flac) ReadMetadata(title string) (*string, error) {
 	for _, cmt := range flac.parsedComments {
-		if strings.ToLower(cmt.Title) == title {
+		if strings.EqualFold(cmt.Title, title) {
 			return &cmt.Value, nil
 		}
 	}
@@ -395,30 +695,95 @@ func (flac *Flac) ReadMetadata(title string) (*string, error) {
 	return nil, fmt.Errorf("no metadata found with title '%s'", title)
 }
 
+// Comments returns all the vorbis comments of the file, including the staged changes not saved yet
+func (flac *Flac) Comments() []VorbisComment {
+	return FilterDuplicatedComments(flac.parsedComments, flac.pendingComments, flac.removedComments)
+}
+
 // SetMetadata inserts a new metadata inside the FLAC file, if it doesn't exists it creates it otherwise it updates the value.
 func (flac *Flac) SetMetadata(title string, value string) error {
-	flac.pendingComments = append(flac.pendingComments, VorbisComment{
-		Title: title,
-		Value: value,
-	})
+	return flac.SetMetadataValues(title, []string{value})
+}
+
+// SetMetadataValues stages one comment per value for the given title, replacing all its current values,
+// e.g. a GENRE comment per genre. Without values the tag is removed.
+func (flac *Flac) SetMetadataValues(title string, values []string) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	values, remove, err := flac.prepareValues(title, values)
+	if err != nil {
+		return err
+	}
+	if remove {
+		return flac.RemoveMetadata(title, true)
+	}
+	defer flac.recordUndo()()
+
+	pending := make([]VorbisComment, 0, len(flac.pendingComments)+len(values))
+	for _, cmt := range flac.pendingComments {
+		if !strings.EqualFold(cmt.Title, title) {
+			pending = append(pending, cmt)
+		}
+	}
+	for _, value := range values {
+		pending = append(pending, VorbisComment{
+			Title: title,
+			Value: value,
+		})
+	}
+	if size := vorbisBodySize(FilterDuplicatedComments(flac.parsedComments, pending, flac.removedComments)); size > maxBlockLength {
+		return &BlockTooLargeError{BlockType: "VORBIS_COMMENT", Size: size}
+	}
+	flac.pendingComments = pending
 
 	return nil
 }
 
-func (flac *Flac) BulkAddMetadata(meta FlacMetadatas) error {
-	fields := map[string]string{
-		"title":  meta.Title,
-		"artist": meta.Artist,
-		"album":  meta.Album,
-		"date":   meta.Date,
+// MetadataValues returns all the values of the comments with the given title, including the staged changes
+func (flac *Flac) MetadataValues(title string) []string {
+	values := make([]string, 0)
+	for _, cmt := range flac.Comments() {
+		if strings.EqualFold(cmt.Title, title) {
+			values = append(values, cmt.Value)
+		}
 	}
+	return values
+}
 
-	for key, value := range fields {
-		if value == "" {
-			continue
+// BulkAddMetadata stages every tag set in meta, empty fields are left untouched. The comments of
+// meta.Extra are set as well, a tag of Extra also given by a field adds its values after the field's,
+// so the result of BulkReadMetadata is written back unchanged.
+func (flac *Flac) BulkAddMetadata(meta FlacMetadatas) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	// Tags are grouped ignoring case, under the first spelling found
+	values := make(map[string][]string)
+	order := make([]string, 0)
+	titles := make(map[string]string)
+	add := func(title string, value string) {
+		key := strings.ToUpper(title)
+		if _, found := values[key]; !found {
+			order = append(order, key)
+			titles[key] = title
+		}
+		values[key] = append(values[key], value)
+	}
+	for _, tag := range flacMetadatasFields {
+		if value := *tag.field(&meta); value != "" {
+			add(tag.key, value)
 		}
-		if err := flac.SetMetadata(key, value); err != nil {
-			return fmt.Errorf("unable to set %s in BulkAddMetadata: %w", key, err)
+	}
+	for _, comment := range meta.Extra {
+		add(comment.Title, comment.Value)
+	}
+
+	for _, key := range order {
+		if err := flac.SetMetadataValues(titles[key], values[key]); err != nil {
+			return fmt.Errorf("unable to set %s in BulkAddMetadata: %w", titles[key], err)
 		}
 	}
 
@@ -431,10 +796,40 @@ func (flac *Flac) BulkAddMetadata(meta FlacMetadatas) error {
 	return nil
 }
 
+// BulkReadMetadata returns the comments of the file, including the staged changes, as FlacMetadatas:
+// the first value of every tag it has a field for fills the field, the other comments go to Extra.
+// Cover is left empty, see CoverPicture.
+func (flac *Flac) BulkReadMetadata() FlacMetadatas {
+	var meta FlacMetadatas
+	seen := make(map[string]bool)
+	for _, comment := range flac.Comments() {
+		key := strings.ToUpper(comment.Title)
+		filled := false
+		for _, tag := range flacMetadatasFields {
+			// An empty first value goes to Extra too, an empty field meaning the tag is not set
+			if tag.key == key && !seen[key] && comment.Value != "" {
+				*tag.field(&meta) = comment.Value
+				filled = true
+				break
+			}
+		}
+		seen[key] = true
+		if !filled {
+			meta.Extra = append(meta.Extra, comment)
+		}
+	}
+	return meta
+}
+
 // RemoveMetadata from the currently opened flac file.
 // If IgnoreIfMissing is set to true then no error will be returned if the
 // metadata key is missing.
 func (flac *Flac) RemoveMetadata(title string, ignoreIfMissing bool) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
 	exists := false
 	if !ignoreIfMissing {
 		for _, cmt := range flac.parsedComments {
@@ -465,28 +860,40 @@ func (flac *Flac) RemoveMetadata(title string, ignoreIfMissing bool) error {
 // SetCoverPicture sets a cover picture for the current FLAC file, if already exists then it overwrites it
 // Also add the ability to add image directly from buffer not necessarily from a given downloaded file
 func (flac *Flac) SetCoverPictureFromPath(filePath string) error {
-	pictureMimeType, pictureBytes, err := flac.parseImageFromPath(filePath)
-
-	if err != nil {
-		return fmt.Errorf("unable to parse image with path: '%s': %w", filePath, err)
+	if err := flac.checkWritable(); err != nil {
+		return err
 	}
+	defer flac.recordUndo()()
 
-	pictureBlockBytes, err := flac.createPictureBlock(pictureBytes, *pictureMimeType)
-
+	pending, err := flac.stagePictureFromPath(filePath, PictureTypeFrontCover, "")
 	if err != nil {
-		return fmt.Errorf("unable to add cover picture: %w", err)
+		return err
 	}
 
-	flac.pendingCoverPicture = pictureBlockBytes
+	flac.pendingCoverPicture = nil
+	flac.pendingCoverStream = pending
 
 	return nil
 }
 
 func (flac *Flac) SetCoverPictureFromBytes(imgBytes []byte) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
 	if len(imgBytes) < 512 {
 		return fmt.Errorf("unable to detect content type, image is too small or format is broken")
 	}
 
+	if flac.options.stripImageMetadata {
+		stripped, err := StripImageMetadata(imgBytes)
+		if err != nil {
+			return fmt.Errorf("unable to add cover picture: %w", err)
+		}
+		imgBytes = stripped
+	}
+
 	pictureMimeType := http.DetectContentType(imgBytes[:512])
 	pictureBlockBytes, err := flac.createPictureBlock(imgBytes, pictureMimeType)
 
@@ -495,11 +902,36 @@ func (flac *Flac) SetCoverPictureFromBytes(imgBytes []byte) error {
 	}
 
 	flac.pendingCoverPicture = pictureBlockBytes
+	flac.pendingCoverStream = nil
 
 	return nil
 }
 
+// CoverPicture returns the cover picture stored in the file, nil if the file doesn't have one.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) CoverPicture() (*Picture, error) {
+	if flac.parsedCoverPicture == nil {
+		return nil, nil
+	}
+
+	if err := flac.loadBlockData(flac.parsedCoverPicture); err != nil {
+		return nil, fmt.Errorf("unable to read cover picture: %w", err)
+	}
+
+	picture, err := parsePictureBlock(flac.parsedCoverPicture.BlockData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cover picture: %w", err)
+	}
+
+	return picture, nil
+}
+
 func (flac *Flac) RemoveCoverPicture(ignoreIfMissing bool) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
 	if flac.parsedCoverPicture == nil {
 		if !ignoreIfMissing {
 			return fmt.Errorf("unable to remove cover picture: opened flac file doesn't have one")
@@ -533,22 +965,37 @@ func (flac *Flac) getMetadataEndOffset() (int64, error) {
 	return offset, nil
 }
 
-func (flac *Flac) Save(outputPath *string) error {
-	var metadataBuffer []byte
-	var rawAudioBuffer []byte
+// getAudioEndOffset returns the byte offset in the file where audio frames end, trailing APEv2 and ID3v1 tags excluded
+func (flac *Flac) getAudioEndOffset() int64 {
+	if flac.apeTag != nil {
+		return flac.apeTag.offset
+	}
+
+	if flac.fileSize >= 128 {
+		id3 := make([]byte, 3)
+		if _, err := flac.file.ReadAt(id3, flac.fileSize-128); err == nil && string(id3) == "TAG" {
+			return flac.fileSize - 128
+		}
+	}
 
+	return flac.fileSize
+}
+
+// outputBlocks returns all the metadata blocks to write, with the staged changes applied.
+// PICTURE bodies are not loaded: they are streamed from their source while writing.
+func (flac *Flac) outputBlocks() ([]MetadataBlock, error) {
 	// Read all metadata blocks
 	blocks, err := flac.readAllMetadataBlocks()
 	if err != nil {
-		return fmt.Errorf("unable to read all metadata blocks: %w", err)
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
 	}
-
-	// Rebuilding the FLAC file
-	// First thing first add the FLAC magic header 'fLaC'
-	flac.file.Seek(0, 0)
-	magicHeader, err := flac.readBytes(4)
-	if err != nil {
-		return fmt.Errorf("failed to read FLAC header: %w", err)
+	for i := range blocks {
+		if blocks[i].BlockType == "PICTURE" {
+			continue
+		}
+		if err := flac.loadBlockData(&blocks[i]); err != nil {
+			return nil, err
+		}
 	}
 
 	// Prepare new metadata blocks buffer
@@ -557,15 +1004,26 @@ func (flac *Flac) Save(outputPath *string) error {
 	// STREAMINFO block is mandatory
 	streamInfo, err := flac.getBlock("STREAMINFO")
 	if err != nil {
-		return fmt.Errorf("missing STREAMINFO block: %w", err)
+		return nil, fmt.Errorf("missing STREAMINFO block: %w", err)
+	}
+	if streamInfo == nil {
+		return nil, fmt.Errorf("missing STREAMINFO block")
+	}
+	if flac.pendingStreamInfo != nil {
+		newBlocks = append(newBlocks, MetadataBlock{
+			BlockType:   "STREAMINFO",
+			BlockHeader: MetadataBlockHeader{Data: []byte{0, 0, 0, 34}},
+			BlockData:   flac.pendingStreamInfo.marshal(),
+		})
+	} else {
+		newBlocks = append(newBlocks, *streamInfo)
 	}
-	newBlocks = append(newBlocks, *streamInfo)
 
-	// VORBIS_COMMENT
-	if len(flac.pendingComments) > 0 {
+	// VORBIS_COMMENT, rebuilt as soon as a comment is set or removed
+	if len(flac.pendingComments) > 0 || len(flac.removedComments) > 0 {
 		vorbisBlock, err := flac.createVorbisBlock()
 		if err != nil {
-			return fmt.Errorf("failed to create VORBIS_COMMENT: %w", err)
+			return nil, fmt.Errorf("failed to create VORBIS_COMMENT: %w", err)
 		}
 		newBlocks = append(newBlocks, MetadataBlock{
 			BlockHeader: MetadataBlockHeader{Data: vorbisBlock[:4]}, // placeholder header
@@ -576,23 +1034,38 @@ func (flac *Flac) Save(outputPath *string) error {
 		newBlocks = append(newBlocks, *vorbisBlock)
 	}
 
-	// Cover picture
-	if len(flac.pendingCoverPicture) > 0 {
-		newBlocks = append(newBlocks, MetadataBlock{
-			BlockHeader: MetadataBlockHeader{Data: flac.pendingCoverPicture[:4]},
-			BlockData:   flac.pendingCoverPicture[4:],
-		})
-	} else if flac.parsedCoverPicture != nil && !flac.removeCoverPicture {
-		newBlocks = append(newBlocks, *flac.parsedCoverPicture)
+	// Pictures
+	pictureBlocks, err := flac.outputPictureBlocks(blocks)
+	if err != nil {
+		return nil, err
 	}
+	newBlocks = append(newBlocks, pictureBlocks...)
 
 	// Other filtered blocks
 	filteredBlocks := GetFilteredBlocks(blocks, []string{
 		"STREAMINFO", "VORBIS_COMMENT", "PICTURE",
 	})
+	var historyBlock *MetadataBlock
+	rewritesHistory := flac.rewritesHistory()
+	if rewritesHistory {
+		if historyBlock, err = flac.historyBlock(blocks); err != nil {
+			return nil, err
+		}
+	}
 	for _, b := range filteredBlocks {
+		if rewritesHistory && isHistoryBlock(&b) {
+			continue
+		}
+		// The edit history goes before the padding
+		if historyBlock != nil && b.BlockType == "PADDING" {
+			newBlocks = append(newBlocks, *historyBlock)
+			historyBlock = nil
+		}
 		newBlocks = append(newBlocks, b)
 	}
+	if historyBlock != nil {
+		newBlocks = append(newBlocks, *historyBlock)
+	}
 
 	// Mark the last block correctly
 	for i := range newBlocks {
@@ -605,18 +1078,242 @@ func (flac *Flac) Save(outputPath *string) error {
 			header[0] &^= 0x80
 		}
 		newBlocks[i].BlockHeader.Data = header
-		metadataBuffer = AppendTo(metadataBuffer, [][]byte{header, newBlocks[i].BlockData})
 	}
 
-	// Read raw audio starting after the original metadata
+	// Refuse to write blocks that would make the file unreadable
+	if err := flac.checkOutputBlocks(newBlocks); err != nil {
+		return nil, err
+	}
+
+	return newBlocks, nil
+}
+
+// hasPendingCover reports whether a new cover picture is staged
+func (flac *Flac) hasPendingCover() bool {
+	return flac.pendingCoverStream != nil || len(flac.pendingCoverPicture) > 0
+}
+
+// outputPictureBlocks returns the PICTURE blocks as they will be saved with the staged changes applied:
+// the cover picture first, then the other pictures of blocks in order
+func (flac *Flac) outputPictureBlocks(blocks []MetadataBlock) ([]MetadataBlock, error) {
+	pictureBlocks := []MetadataBlock{}
+
+	// Cover picture, the bodies of pictures read from the file are left to be streamed
+	switch {
+	case flac.pendingCoverStream != nil:
+		pictureBlocks = append(pictureBlocks, flac.pendingCoverStream.block())
+	case len(flac.pendingCoverPicture) > 0:
+		pictureBlocks = append(pictureBlocks, MetadataBlock{
+			Index:       -1,
+			BlockType:   "PICTURE",
+			BlockHeader: MetadataBlockHeader{Data: flac.pendingCoverPicture[:4]},
+			BlockData:   flac.pendingCoverPicture[4:],
+		})
+	case flac.parsedCoverPicture != nil && !flac.removeCoverPicture && !flac.removedPictures[flac.parsedCoverPicture.Index]:
+		pictureBlocks = append(pictureBlocks, *flac.parsedCoverPicture)
+	}
+
+	// Other pictures
+	for _, b := range blocks {
+		isCover := flac.parsedCoverPicture != nil && b.Index == flac.parsedCoverPicture.Index
+		if b.BlockType == "PICTURE" && !isCover && !flac.removedPictures[b.Index] {
+			pictureBlocks = append(pictureBlocks, b)
+		}
+	}
+
+	// New pictures
+	for _, pending := range flac.pendingPictures {
+		pictureBlocks = append(pictureBlocks, pending.block())
+	}
+
+	return pictureBlocks, nil
+}
+
+// audioSections returns the parts of the original file holding the raw audio, the one following
+// the original metadata minus the APEv2 tag if it has to be stripped
+func (flac *Flac) audioSections() ([]*io.SectionReader, error) {
 	metadataEnd, err := flac.getMetadataEndOffset()
 	if err != nil {
-		return fmt.Errorf("cannot get metadata end offset: %w", err)
+		return nil, fmt.Errorf("cannot get metadata end offset: %w", err)
+	}
+
+	// Cut out the APEv2 tag, keeping anything following it such as an ID3v1 tag
+	if flac.stripAPETag && flac.apeTag != nil && flac.apeTag.offset >= metadataEnd {
+		tagEnd := flac.apeTag.offset + flac.apeTag.length
+		return []*io.SectionReader{
+			io.NewSectionReader(flac.file, metadataEnd, flac.apeTag.offset-metadataEnd),
+			io.NewSectionReader(flac.file, tagEnd, flac.fileSize-tagEnd),
+		}, nil
+	}
+
+	return []*io.SectionReader{io.NewSectionReader(flac.file, metadataEnd, flac.fileSize-metadataEnd)}, nil
+}
+
+// outputPart is a piece of the output file: a range of the metadata buffer or a body streamed from a reader
+type outputPart struct {
+	start, end int
+	open       func() (io.ReadCloser, error)
+	length     int64
+}
+
+// output is a FLAC file ready to be written: the rebuilt metadata and the pieces to stream from
+// the original file, such as pictures and raw audio
+type output struct {
+	metadata *bytes.Buffer
+	parts    []outputPart
+}
+
+// prepareOutput rebuilds and validates the metadata, nothing is written until writeTo is called.
+// release must be called once the output is not needed anymore.
+func (flac *Flac) prepareOutput() (*output, error) {
+	// Without changes the original file is copied as is
+	if !flac.HasChanges() {
+		part := outputPart{
+			open: func() (io.ReadCloser, error) {
+				return sectionCloser{io.NewSectionReader(flac.file, 0, flac.fileSize)}, nil
+			},
+			length: flac.fileSize,
+		}
+		return &output{metadata: getMetadataBuffer(), parts: []outputPart{part}}, nil
 	}
-	flac.file.Seek(metadataEnd, 0)
-	rawAudioBuffer, err = io.ReadAll(flac.file)
+
+	blocks, err := flac.outputBlocks()
 	if err != nil {
-		return fmt.Errorf("unable to read raw audio: %w", err)
+		return nil, err
+	}
+
+	// Rebuilding the FLAC file
+	// First thing first add the FLAC magic header 'fLaC'
+	magicHeader, err := flac.readBytesAt(0, 4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FLAC header: %w", err)
+	}
+
+	sections, err := flac.audioSections()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &output{metadata: getMetadataBuffer()}
+	out.metadata.Write(magicHeader)
+	start := 0
+	flush := func() {
+		out.parts = append(out.parts, outputPart{start: start, end: out.metadata.Len()})
+		start = out.metadata.Len()
+	}
+
+	for i := range blocks {
+		block := &blocks[i]
+		out.metadata.Write(block.BlockHeader.Data)
+		if block.BlockData != nil {
+			out.metadata.Write(block.BlockData)
+			continue
+		}
+		if block.stream != nil {
+			// Fail now rather than halfway through writing
+			f, err := block.stream.open()
+			if err != nil {
+				out.release()
+				return nil, err
+			}
+			f.Close()
+		}
+
+		flush()
+		out.parts = append(out.parts, outputPart{
+			open:   func() (io.ReadCloser, error) { return flac.openBlockBody(block) },
+			length: block.bodyLength(),
+		})
+	}
+	flush()
+
+	for _, section := range sections {
+		out.parts = append(out.parts, outputPart{
+			open: func() (io.ReadCloser, error) {
+				return sectionCloser{io.NewSectionReader(section, 0, section.Size())}, nil
+			},
+			length: section.Size(),
+		})
+	}
+
+	return out, nil
+}
+
+// writeTo writes the whole FLAC file to w: magic header + metadata + raw audio.
+// Pictures and audio are streamed from their source through pooled buffers.
+func (out *output) writeTo(w io.Writer) (int64, error) {
+	copyBuffer := getCopyBuffer()
+	defer putCopyBuffer(copyBuffer)
+
+	var total int64
+	for _, part := range out.parts {
+		if part.open == nil {
+			n, err := w.Write(out.metadata.Bytes()[part.start:part.end])
+			total += int64(n)
+			if err != nil {
+				return total, fmt.Errorf("unable to write metadata: %w", err)
+			}
+			continue
+		}
+
+		n, err := out.copyPart(w, part, *copyBuffer)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// copyPart streams a part into w, failing if its source doesn't hold exactly the expected bytes
+func (out *output) copyPart(w io.Writer, part outputPart, copyBuffer []byte) (int64, error) {
+	r, err := part.open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	n, err := io.CopyBuffer(w, io.LimitReader(r, part.length), copyBuffer)
+	if err != nil {
+		return n, fmt.Errorf("unable to copy data: %w", err)
+	}
+	if n != part.length {
+		return n, fmt.Errorf("unable to copy data: expected %d bytes, got %d", part.length, n)
+	}
+
+	return n, nil
+}
+
+// release gives the metadata buffer back to the pool
+func (out *output) release() {
+	putMetadataBuffer(out.metadata)
+	out.metadata = nil
+}
+
+// HasChanges reports whether changes are staged. Staging values equal to the current ones,
+// e.g. setting a comment to its value, is not a change. Values decoded with WithFallbackEncoding
+// are, as Save rewrites them in UTF-8.
+func (flac *Flac) HasChanges() bool {
+	return !slices.Equal(flac.Comments(), flac.parsedComments) || flac.decodedComments ||
+		flac.hasPendingCover() || len(flac.pendingPictures) > 0 || len(flac.removedPictures) > 0 ||
+		(flac.removeCoverPicture && flac.parsedCoverPicture != nil) ||
+		(flac.stripAPETag && flac.apeTag != nil) || flac.rewritesHistory() || flac.pendingStreamInfo != nil
+}
+
+// Save writes the FLAC file with all the staged changes to outputPath,
+// or overwrites the original file if outputPath is nil.
+// The file is written to a temporary file in the same folder first and then renamed,
+// so the original is never left half written.
+// When overwriting the original file and the staged changes only edit comments keeping their
+// encoded length, e.g. dates or ReplayGain values, the comments are patched in place instead.
+// When the saved metadata takes no more room than the original one, e.g. after removing a picture,
+// it is written in place with the freed space turned into PADDING, so the audio is not copied.
+// Without staged changes, see HasChanges, the output is byte-identical to the original file:
+// same vendor string, block order and padding. Overwriting the original then writes nothing.
+func (flac *Flac) Save(outputPath *string) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
 	}
 
 	// Create output file
@@ -624,17 +1321,89 @@ func (flac *Flac) Save(outputPath *string) error {
 	if outputPath != nil {
 		outFileName = *outputPath
 	}
-	outFile, err := os.Create(outFileName)
+	if outFileName == "" {
+		return fmt.Errorf("unable to save: no output path given for a FLAC stream without file")
+	}
+	if err := flac.stageTotalTags(); err != nil {
+		return fmt.Errorf("unable to write FLAC file: %w", err)
+	}
+
+	if outFileName == flac.fileName {
+		if !flac.HasChanges() {
+			return nil
+		}
+		patches, err := flac.patchableComments()
+		if err != nil {
+			return fmt.Errorf("unable to write FLAC file: %w", err)
+		}
+		if patches != nil {
+			return flac.patchComments(patches)
+		}
+		metadata, err := flac.inPlaceMetadata()
+		if err != nil {
+			return fmt.Errorf("unable to write FLAC file: %w", err)
+		}
+		if metadata != nil {
+			return flac.writeMetadataInPlace(metadata)
+		}
+	}
+
+	// Rebuild and validate everything before touching the file system
+	out, err := flac.prepareOutput()
+	if err != nil {
+		return fmt.Errorf("unable to write FLAC file: %w", err)
+	}
+	defer out.release()
+
+	outFile, err := os.CreateTemp(filepath.Dir(outFileName), ".flacgo-*")
 	if err != nil {
 		return fmt.Errorf("unable to create file '%s': %w", outFileName, err)
 	}
-	defer outFile.Close()
+	tempName := outFile.Name()
+	defer os.Remove(tempName)
 
-	// Write FLAC file: magic header + metadata + raw audio
-	fullBuffer := AppendTo(nil, [][]byte{magicHeader, metadataBuffer, rawAudioBuffer})
-	if _, err := outFile.Write(fullBuffer); err != nil {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(outFileName); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	_, err = out.writeTo(flac.throttledWriter(outFile))
+	if err == nil {
+		err = outFile.Chmod(mode)
+	}
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		return fmt.Errorf("unable to write FLAC file: %w", err)
 	}
 
+	if err := os.Rename(tempName, outFileName); err != nil {
+		return fmt.Errorf("unable to create file '%s': %w", outFileName, err)
+	}
+	if outFileName == flac.fileName {
+		return flac.reopen()
+	}
+
 	return nil
 }
+
+// WriteTo writes the FLAC file with all the staged changes to w
+func (flac *Flac) WriteTo(w io.Writer) (int64, error) {
+	var out *output
+	err := flac.withTotalTags(func() (err error) {
+		out, err = flac.prepareOutput()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to write FLAC file: %w", err)
+	}
+	defer out.release()
+
+	n, err := out.writeTo(flac.throttledWriter(w))
+	if err != nil {
+		return n, fmt.Errorf("unable to write FLAC file: %w", err)
+	}
+
+	return n, nil
+}
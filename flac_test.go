@@ -0,0 +1,361 @@
+package flacgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var fixtures = []string{"examples/sample.flac", "examples/samplewithmetadata.flac"}
+
+// copyFixture copies the fixture at path to a temporary directory and returns the path of the copy
+func copyFixture(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := filepath.Join(t.TempDir(), filepath.Base(path))
+	if err := os.WriteFile(copied, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return copied
+}
+
+// assertSameFile fails if the files at got and want differ
+func assertSameFile(t *testing.T, got string, want string) {
+	t.Helper()
+	gotData, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotData, wantData) {
+		t.Errorf("%s is %d bytes and differs from %s, %d bytes", got, len(gotData), want, len(wantData))
+	}
+}
+
+func TestSaveWithoutChangesIsByteIdentical(t *testing.T) {
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			flac, err := Open(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer flac.Close()
+
+			if flac.HasChanges() {
+				t.Fatal("HasChanges is set on a file just opened")
+			}
+			output := filepath.Join(t.TempDir(), "output.flac")
+			if err := flac.Save(&output); err != nil {
+				t.Fatal(err)
+			}
+			assertSameFile(t, output, fixture)
+		})
+	}
+}
+
+func TestSaveWithUndoneChangesIsByteIdentical(t *testing.T) {
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			path := copyFixture(t, fixture)
+			flac, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer flac.Close()
+
+			if err := flac.SetMetadata("TITLE", "Undone title"); err != nil {
+				t.Fatal(err)
+			}
+			if err := flac.SetMetadataValues("GENRE", []string{"Rock", "Pop"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := flac.RemoveMetadata("ARTIST", true); err != nil {
+				t.Fatal(err)
+			}
+			if err := flac.SetCoverPictureFromPath("examples/test.jpg"); err != nil {
+				t.Fatal(err)
+			}
+			if !flac.HasChanges() {
+				t.Fatal("HasChanges is not set after staging changes")
+			}
+
+			flac.UndoAll()
+			if flac.HasChanges() {
+				t.Fatal("HasChanges is set after UndoAll")
+			}
+
+			output := filepath.Join(t.TempDir(), "output.flac")
+			if err := flac.Save(&output); err != nil {
+				t.Fatal(err)
+			}
+			assertSameFile(t, output, fixture)
+
+			// Saving in place writes nothing
+			if err := flac.Save(nil); err != nil {
+				t.Fatal(err)
+			}
+			assertSameFile(t, path, fixture)
+		})
+	}
+}
+
+func TestSaveWithChangesUndoneOneByOneIsByteIdentical(t *testing.T) {
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			flac, err := Open(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer flac.Close()
+
+			if err := flac.SetMetadata("COMMENT", "first"); err != nil {
+				t.Fatal(err)
+			}
+			if err := flac.SetMetadata("COMMENT", "second"); err != nil {
+				t.Fatal(err)
+			}
+			if err := flac.RemoveCoverPicture(true); err != nil {
+				t.Fatal(err)
+			}
+			for flac.Undo() {
+			}
+
+			output := filepath.Join(t.TempDir(), "output.flac")
+			if err := flac.Save(&output); err != nil {
+				t.Fatal(err)
+			}
+			assertSameFile(t, output, fixture)
+		})
+	}
+}
+
+// BenchmarkParseVorbisBlock parses a block of 200 short comments and a 50 KB LYRICS comment
+func BenchmarkParseVorbisBlock(b *testing.B) {
+	comments := make([]VorbisComment, 0, 201)
+	for i := 0; i < 200; i++ {
+		comments = append(comments, VorbisComment{Title: fmt.Sprintf("CUSTOM%03d", i), Value: fmt.Sprintf("value %d", i)})
+	}
+	comments = append(comments, VorbisComment{Title: "LYRICS", Value: strings.Repeat("la la la la\n", 50*1024/12)})
+	block := marshalVorbisComments(comments)
+
+	flac := &Flac{}
+	b.SetBytes(int64(len(block)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := flac.parseVorbisBlock(block); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSaveInPlaceClearsUndo(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		stage func(flac *Flac) error
+	}{
+		{"patched comment", func(flac *Flac) error { return flac.SetMetadata("ARTIST", "Flac Go") }},
+		{"metadata in place", func(flac *Flac) error { return flac.RemoveMetadata("TITLE", true) }},
+		{"full rewrite", func(flac *Flac) error { return flac.SetCoverPictureFromPath("examples/test.jpg") }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := copyFixture(t, "examples/samplewithmetadata.flac")
+			flac, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer flac.Close()
+
+			if err := test.stage(flac); err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(t.TempDir(), "output.flac")
+			if err := flac.Save(&output); err != nil {
+				t.Fatal(err)
+			}
+			if flac.UndoLen() != 1 {
+				t.Fatalf("UndoLen is %d after saving to another path, expected 1", flac.UndoLen())
+			}
+
+			if err := flac.Save(nil); err != nil {
+				t.Fatal(err)
+			}
+			if flac.UndoLen() != 0 || flac.HasChanges() || flac.Undo() {
+				t.Fatalf("UndoLen is %d after saving in place, expected 0", flac.UndoLen())
+			}
+			if err := flac.Verify(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsMissingStreamInfo(t *testing.T) {
+	// A single VORBIS_COMMENT block with an empty vendor string and no comments, then audio bytes
+	data := []byte("fLaC")
+	data = append(data, 0x84, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0)
+	data = append(data, 0xFF, 0xF8, 0, 0)
+
+	if _, err := OpenReader(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "missing STREAMINFO") {
+		t.Fatalf("Open of a file without STREAMINFO returned %v, expected a missing STREAMINFO error", err)
+	}
+}
+
+func TestSaveWritesFallbackDecodedCommentsAsUTF8(t *testing.T) {
+	path := copyFixture(t, "examples/sample.flac")
+	flac, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.SetMetadata("ARTIST", "Bjxrk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+	flac.Close()
+
+	// Turn the value into Latin-1 "Björk", as written by taggers using the system code page
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("Bjxrk"), []byte("Bj\xf6rk"), 1)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flac, err = Open(path, WithFallbackEncoding(Latin1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !flac.HasChanges() {
+		t.Error("HasChanges is not set for a value decoded with the fallback encoding")
+	}
+	if err := flac.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+	flac.Close()
+
+	flac, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+	artist := flac.Comments()[0].Value
+	if !utf8.ValidString(artist) || artist != "Björk" {
+		t.Errorf("ARTIST is %q after saving, expected \"Björk\" in UTF-8", artist)
+	}
+	if flac.HasChanges() {
+		t.Error("HasChanges is set after saving the decoded values")
+	}
+}
+
+func TestSetMetadataValuesWithoutValuesRemovesTag(t *testing.T) {
+	flac, err := Open("examples/samplewithmetadata.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+
+	for _, values := range [][]string{nil, {}} {
+		if err := flac.SetMetadataValues("ARTIST", values); err != nil {
+			t.Fatal(err)
+		}
+		if artists := flac.MetadataValues("ARTIST"); len(artists) > 0 || !flac.HasChanges() {
+			t.Errorf("ARTIST is %q after setting %#v, expected the tag to be removed", artists, values)
+		}
+		flac.UndoAll()
+	}
+}
+
+func TestOpenClosesFileOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.flac")
+	if err := os.WriteFile(path, []byte("not a FLAC file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	descriptors, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open file descriptors can't be counted:", err)
+	}
+
+	for _, opts := range [][]Option{nil, {WithMmap()}} {
+		for i := 0; i < 50; i++ {
+			if _, err := Open(path, opts...); err == nil {
+				t.Fatal("Open of an invalid file succeeded")
+			}
+		}
+	}
+	after, _ := os.ReadDir("/proc/self/fd")
+	if len(after) > len(descriptors) {
+		t.Errorf("%d file descriptors leaked by failed opens", len(after)-len(descriptors))
+	}
+}
+
+func TestTotalTagsLeaveHandleUnchangedUntilSave(t *testing.T) {
+	path := copyFixture(t, "examples/sample.flac")
+	flac, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.SetMetadata("TRACKTOTAL", "12"); err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+	flac.Close()
+
+	flac, err = Open(path, WithTotalTags(TotalTagsBoth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+	comments := flac.Comments()
+
+	if size := flac.PendingMetadataSize(); size <= int(flac.AudioOffset()) {
+		t.Errorf("PendingMetadataSize is %d, expected the added TOTALTRACKS to grow the metadata", size)
+	}
+	r, err := flac.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("TOTALTRACKS=12")) {
+		t.Error("NewReader output has no TOTALTRACKS")
+	}
+
+	if flac.HasChanges() || flac.UndoLen() != 0 || !slices.Equal(flac.Comments(), comments) {
+		t.Errorf("comments are %v after PendingMetadataSize and NewReader, expected %v unstaged", flac.Comments(), comments)
+	}
+}
+
+func TestPurgeHistoryOnReadOnlyFile(t *testing.T) {
+	flac, err := OpenReadOnly("examples/samplewithmetadata.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+
+	var readOnly *ReadOnlyError
+	if err := flac.PurgeHistory(); !errors.As(err, &readOnly) {
+		t.Fatalf("PurgeHistory on a read-only file returned %v, expected a *ReadOnlyError", err)
+	}
+}
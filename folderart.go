@@ -0,0 +1,133 @@
+package flacgo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFolderArtName is the name ExportFolderArt gives the image when no name is given
+const DefaultFolderArtName = "folder"
+
+// FolderArtNames are the images EmbedFolderArt looks for, in order, with a .jpg, .jpeg or .png extension
+var FolderArtNames = sidecarArtNames
+
+// ExportFolderArt writes the front cover of the first track of dir having one next to the tracks,
+// as name plus the extension of the image type when name has none, e.g. "folder.jpg" or "cover.png".
+// It returns the path of the image or an empty path if no track has artwork. An existing image is
+// only replaced if overwrite is set, otherwise an error wrapping fs.ErrExist is returned.
+func ExportFolderArt(dir string, name string, overwrite bool) (string, error) {
+	if name == "" {
+		name = DefaultFolderArtName
+	}
+
+	paths, err := listFLACFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		cover, err := readFrontCover(path)
+		if err != nil {
+			return "", err
+		}
+		if cover == nil {
+			continue
+		}
+
+		target := name
+		if filepath.Ext(target) == "" {
+			target += imageExtension(cover.MimeType)
+		}
+		target = filepath.Join(dir, target)
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if !overwrite {
+			flags |= os.O_EXCL
+		}
+		f, err := os.OpenFile(target, flags, 0644)
+		if err != nil {
+			if os.IsExist(err) {
+				return "", fmt.Errorf("'%s': %w", target, fs.ErrExist)
+			}
+			return "", fmt.Errorf("unable to create '%s': %w", target, err)
+		}
+		if _, err := f.Write(cover.Data); err != nil {
+			f.Close()
+			return "", fmt.Errorf("unable to write '%s': %w", target, err)
+		}
+		return target, f.Close()
+	}
+
+	return "", nil
+}
+
+// EmbedFolderArt embeds the folder image of dir, the first of FolderArtNames found, as front cover
+// of the tracks of dir without any picture and saves them. It returns the image used, empty if the
+// folder has none, and a result for every track changed. Failures of single files are reported in the results.
+func EmbedFolderArt(dir string) (string, []TransformResult, error) {
+	image := findFolderImage(dir, FolderArtNames)
+	if image == "" {
+		return "", nil, nil
+	}
+
+	paths, err := listFLACFiles(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	results := make([]TransformResult, 0)
+	for _, path := range paths {
+		changes, err := transformFile(path, func(flac *Flac) ([]TagChange, error) {
+			if flac.parsedCoverPicture != nil || flac.hasPendingCover() {
+				return nil, nil
+			}
+			if err := flac.SetCoverPictureFromPath(image); err != nil {
+				return nil, err
+			}
+			return []TagChange{{Key: AlbumArtworkKey, New: filepath.Base(image)}}, nil
+		})
+		if err != nil || len(changes) > 0 {
+			results = append(results, TransformResult{Path: path, Changes: changes, Err: err})
+		}
+	}
+
+	return image, results, nil
+}
+
+// readFrontCover returns the first front cover picture of the file at path, or its cover picture
+// when no picture is marked as front cover
+func readFrontCover(path string) (*Picture, error) {
+	flac, err := Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s': %w", path, err)
+	}
+	defer flac.Close()
+
+	pictures, err := flac.Pictures()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the pictures of '%s': %w", path, err)
+	}
+	for _, picture := range pictures {
+		if picture.PictureType == PictureTypeFrontCover {
+			return picture, nil
+		}
+	}
+
+	return flac.CoverPicture()
+}
+
+// imageExtension returns the file extension matching an image mime type
+func imageExtension(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".jpg"
+}
@@ -0,0 +1,81 @@
+//go:build ignore
+
+// gen_isocodes generates isocodes.go from the JSON files of the Debian iso-codes package:
+//
+//	go run gen_isocodes.go [/usr/share/iso-codes/json]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	dir := "/usr/share/iso-codes/json"
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+
+	var languages map[string][]map[string]string
+	readJSON(filepath.Join(dir, "iso_639-2.json"), &languages)
+	var countries map[string][]map[string]string
+	readJSON(filepath.Join(dir, "iso_3166-1.json"), &countries)
+
+	var out bytes.Buffer
+	fmt.Fprintln(&out, "// Code generated by gen_isocodes.go; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "package flacgo")
+	fmt.Fprintln(&out)
+
+	fmt.Fprintln(&out, "// isoLanguages are the ISO 639-2 languages, sorted by code")
+	fmt.Fprintln(&out, "var isoLanguages = []Language{")
+	entries := languages["639-2"]
+	sort.Slice(entries, func(i, j int) bool { return entries[i]["alpha_3"] < entries[j]["alpha_3"] })
+	for _, entry := range entries {
+		if strings.Contains(entry["alpha_3"], "-") {
+			// Ranges reserved for local use
+			continue
+		}
+		fmt.Fprintf(&out, "{%q, %q, %q, %q},\n", entry["alpha_3"], entry["bibliographic"], entry["alpha_2"], entry["name"])
+	}
+	fmt.Fprintln(&out, "}")
+	fmt.Fprintln(&out)
+
+	fmt.Fprintln(&out, "// isoCountries are the ISO 3166-1 countries, sorted by code")
+	fmt.Fprintln(&out, "var isoCountries = []Country{")
+	entries = countries["3166-1"]
+	sort.Slice(entries, func(i, j int) bool { return entries[i]["alpha_2"] < entries[j]["alpha_2"] })
+	for _, entry := range entries {
+		name := entry["common_name"]
+		if name == "" {
+			name = entry["name"]
+		}
+		fmt.Fprintf(&out, "{%q, %q, %q},\n", entry["alpha_2"], entry["alpha_3"], name)
+	}
+	fmt.Fprintln(&out, "}")
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("isocodes.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readJSON(path string, v any) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Fatalf("unable to parse '%s': %v", path, err)
+	}
+}
@@ -0,0 +1,173 @@
+package flacgo
+
+import (
+	"strings"
+)
+
+// DefaultGenreMap maps common spellings of genres, as normalized by NormalizeTag, to their canonical value
+var DefaultGenreMap = map[string]string{
+	"hip hop":             "Hip-Hop",
+	"hiphop":              "Hip-Hop",
+	"rap hip hop":         "Hip-Hop",
+	"hip hop rap":         "Hip-Hop",
+	"rap":                 "Rap",
+	"rnb":                 "R&B",
+	"r and b":             "R&B",
+	"rhythm and blues":    "R&B",
+	"rock":                "Rock",
+	"rock n roll":         "Rock & Roll",
+	"rock and roll":       "Rock & Roll",
+	"rocknroll":           "Rock & Roll",
+	"alt rock":            "Alternative Rock",
+	"alternative rock":    "Alternative Rock",
+	"alternative":         "Alternative",
+	"indie":               "Indie",
+	"indie rock":          "Indie Rock",
+	"punk":                "Punk",
+	"punk rock":           "Punk",
+	"post punk":           "Post-Punk",
+	"postpunk":            "Post-Punk",
+	"metal":               "Metal",
+	"heavy metal":         "Heavy Metal",
+	"pop":                 "Pop",
+	"synth pop":           "Synth-Pop",
+	"synthpop":            "Synth-Pop",
+	"k pop":               "K-Pop",
+	"kpop":                "K-Pop",
+	"electronic":          "Electronic",
+	"electronica":         "Electronic",
+	"electro":             "Electro",
+	"edm":                 "EDM",
+	"house":               "House",
+	"techno":              "Techno",
+	"trance":              "Trance",
+	"drum and bass":       "Drum & Bass",
+	"drum n bass":         "Drum & Bass",
+	"drumnbass":           "Drum & Bass",
+	"dnb":                 "Drum & Bass",
+	"d and b":             "Drum & Bass",
+	"dubstep":             "Dubstep",
+	"ambient":             "Ambient",
+	"trip hop":            "Trip-Hop",
+	"triphop":             "Trip-Hop",
+	"jazz":                "Jazz",
+	"blues":               "Blues",
+	"soul":                "Soul",
+	"funk":                "Funk",
+	"disco":               "Disco",
+	"reggae":              "Reggae",
+	"ska":                 "Ska",
+	"country":             "Country",
+	"folk":                "Folk",
+	"classical":           "Classical",
+	"classic":             "Classical",
+	"soundtrack":          "Soundtrack",
+	"ost":                 "Soundtrack",
+	"original soundtrack": "Soundtrack",
+	"world":               "World",
+	"world music":         "World",
+	"latin":               "Latin",
+	"gospel":              "Gospel",
+	"new age":             "New Age",
+	"singer songwriter":   "Singer-Songwriter",
+	"lo fi":               "Lo-Fi",
+	"lofi":                "Lo-Fi",
+}
+
+// DefaultGenreSeparators are the separators GenreTransform splits multi-genre values on
+var DefaultGenreSeparators = []string{"/", ";", ",", "|"}
+
+// GenreOptions configures CanonicalGenre and CanonicalizeGenres
+type GenreOptions struct {
+	// Map replaces DefaultGenreMap if not nil, its keys must be normalized with NormalizeTag
+	Map map[string]string
+	// Split stores a value holding several genres, like "Rock; Pop", as one GENRE comment per genre.
+	// A value that is itself a known genre, like "Rap/Hip-Hop", is never split.
+	Split bool
+	// Separators replaces DefaultGenreSeparators if not nil
+	Separators []string
+}
+
+// CanonicalGenre returns the canonical spelling of genre, or genre with its surrounding spaces
+// trimmed if it's not in the mapping table
+func CanonicalGenre(genre string, opts GenreOptions) string {
+	genre = strings.TrimSpace(genre)
+	if canonical, found := opts.lookup(genre); found {
+		return canonical
+	}
+	return genre
+}
+
+// lookup finds genre in the mapping table, also trying its normalized form without spaces
+// so that "HipHop" and "Hip Hop" match the same entry
+func (opts GenreOptions) lookup(genre string) (string, bool) {
+	genres := opts.Map
+	if genres == nil {
+		genres = DefaultGenreMap
+	}
+
+	normalized := NormalizeTag(genre)
+	if canonical, found := genres[normalized]; found {
+		return canonical, true
+	}
+	canonical, found := genres[strings.ReplaceAll(normalized, " ", "")]
+	return canonical, found
+}
+
+// splitGenres splits value on the separators, unless the whole value is a known genre
+func (opts GenreOptions) splitGenres(value string) []string {
+	if _, found := opts.lookup(value); found {
+		return []string{value}
+	}
+
+	separators := opts.Separators
+	if separators == nil {
+		separators = DefaultGenreSeparators
+	}
+	parts := []string{value}
+	for _, separator := range separators {
+		split := make([]string, 0, len(parts))
+		for _, part := range parts {
+			split = append(split, strings.Split(part, separator)...)
+		}
+		parts = split
+	}
+	return parts
+}
+
+// GenreTransform returns a Transform canonicalizing genres like CanonicalizeGenres. When splitting,
+// the genres of all the GENRE comments are merged in order with duplicates and empty values dropped.
+func GenreTransform(opts GenreOptions) Transform {
+	return TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		transformed := make([]VorbisComment, 0, len(comments))
+		seen := make(map[string]bool)
+		for _, comment := range comments {
+			if !strings.EqualFold(comment.Title, "GENRE") {
+				transformed = append(transformed, comment)
+				continue
+			}
+
+			genres := []string{comment.Value}
+			if opts.Split {
+				genres = opts.splitGenres(comment.Value)
+			}
+			for _, genre := range genres {
+				genre = CanonicalGenre(genre, opts)
+				if opts.Split {
+					if genre == "" || seen[strings.ToLower(genre)] {
+						continue
+					}
+					seen[strings.ToLower(genre)] = true
+				}
+				transformed = append(transformed, VorbisComment{Title: comment.Title, Value: genre})
+			}
+		}
+		return transformed
+	})
+}
+
+// CanonicalizeGenres stages the canonical spelling of the GENRE comments, optionally splitting
+// multi-genre values into several comments, and returns the changes sorted by key
+func (flac *Flac) CanonicalizeGenres(opts GenreOptions) ([]TagChange, error) {
+	return flac.ApplyTransform(GenreTransform(opts))
+}
@@ -0,0 +1,163 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryApplicationID is the id of the APPLICATION block flacgo keeps the edit history in
+const HistoryApplicationID = "FLGO"
+
+// DefaultHistoryTool is the tool recorded in the edit history when WithHistory is given no name
+const DefaultHistoryTool = "flacgo"
+
+// HistoryEntry is a change recorded in the edit history of a file
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+	Tool string    `json:"tool"`
+	// Keys are the comments changed, added or removed, upper case and sorted, plus PICTURE when pictures changed
+	Keys []string `json:"keys"`
+}
+
+// WithHistory makes Save and WriteTo append an entry to the edit history stored in the file
+// whenever comments or pictures change, recording the time, the tool name and the changed keys
+func WithHistory(tool string) Option {
+	return func(o *options) {
+		if tool == "" {
+			tool = DefaultHistoryTool
+		}
+		o.historyTool = tool
+	}
+}
+
+// isHistoryBlock reports whether block is an APPLICATION block holding flacgo edit history
+func isHistoryBlock(block *MetadataBlock) bool {
+	return block.BlockType == "APPLICATION" && block.BlockHeader.BlockLength >= 4 &&
+		(block.BlockData == nil || string(block.BlockData[:4]) == HistoryApplicationID)
+}
+
+// History returns the edit history stored in the file, oldest entry first.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) History() ([]HistoryEntry, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+	return flac.readHistory(blocks)
+}
+
+// readHistory parses the entries of the history blocks among blocks
+func (flac *Flac) readHistory(blocks []MetadataBlock) ([]HistoryEntry, error) {
+	history := make([]HistoryEntry, 0)
+	for i := range blocks {
+		block := &blocks[i]
+		if !isHistoryBlock(block) {
+			continue
+		}
+		if err := flac.loadBlockData(block); err != nil {
+			return nil, err
+		}
+		if string(block.BlockData[:4]) != HistoryApplicationID {
+			continue
+		}
+
+		var entries []HistoryEntry
+		if err := json.Unmarshal(block.BlockData[4:], &entries); err != nil {
+			return nil, fmt.Errorf("unable to parse edit history at offset %d: %w", block.Index, err)
+		}
+		history = append(history, entries...)
+	}
+	return history, nil
+}
+
+// PurgeHistory stages the removal of the edit history, the history of the changes saved along is kept
+func (flac *Flac) PurgeHistory() error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	flac.purgeHistory = true
+	return nil
+}
+
+// changedKeys returns the keys whose values differ between the parsed and the staged comments,
+// plus PICTURE when pictures are staged or removed
+func (flac *Flac) changedKeys() []string {
+	group := func(comments []VorbisComment) map[string][]string {
+		groups := make(map[string][]string)
+		for _, comment := range comments {
+			key := strings.ToUpper(comment.Title)
+			groups[key] = append(groups[key], comment.Value)
+		}
+		return groups
+	}
+	before, after := group(flac.parsedComments), group(flac.Comments())
+
+	keys := make([]string, 0)
+	for key, values := range after {
+		if !slices.Equal(values, before[key]) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, found := after[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	if flac.hasPendingCover() || len(flac.pendingPictures) > 0 || flac.removeCoverPicture || len(flac.removedPictures) > 0 {
+		keys = append(keys, "PICTURE")
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// rewritesHistory reports whether saving rewrites the history blocks
+func (flac *Flac) rewritesHistory() bool {
+	return flac.purgeHistory || (flac.options.historyTool != "" && len(flac.changedKeys()) > 0)
+}
+
+// historyBlock builds the history block to write in place of the ones among blocks, nil if there is none to write
+func (flac *Flac) historyBlock(blocks []MetadataBlock) (*MetadataBlock, error) {
+	history := make([]HistoryEntry, 0)
+	if !flac.purgeHistory {
+		var err error
+		if history, err = flac.readHistory(blocks); err != nil {
+			return nil, err
+		}
+	}
+	if keys := flac.changedKeys(); flac.options.historyTool != "" && len(keys) > 0 {
+		history = append(history, HistoryEntry{
+			Time: time.Now().UTC().Truncate(time.Second),
+			Tool: flac.options.historyTool,
+			Keys: keys,
+		})
+	}
+	if len(history) == 0 {
+		return nil, nil
+	}
+
+	entries, err := json.Marshal(history)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode edit history: %w", err)
+	}
+	body := append([]byte(HistoryApplicationID), entries...)
+	if len(body) > maxBlockLength {
+		return nil, fmt.Errorf("edit history is %d bytes, the limit is %d", len(body), maxBlockLength)
+	}
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(body)))
+	header[0] = 2 // 2 = APPLICATION
+	return &MetadataBlock{
+		BlockType:   "APPLICATION",
+		BlockHeader: MetadataBlockHeader{BlockType: 2, BlockLength: uint32(len(body)), Data: header},
+		BlockData:   body,
+	}, nil
+}
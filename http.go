@@ -0,0 +1,161 @@
+package flacgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpSource reads a remote file with HTTP Range requests
+type httpSource struct {
+	client *http.Client
+	url    string
+	size   int64
+	retry  retryPolicy
+}
+
+// errTransient marks failures worth retrying
+var errTransient = errors.New("transient failure")
+
+// OpenURL opens a FLAC file served over HTTP(S) without downloading it: every read is a Range
+// request, so only the metadata blocks are fetched unless the audio is decoded or saved.
+// Requests failing with transient errors are retried with exponential backoff, see WithRetry,
+// and a read interrupted halfway resumes from the last byte received.
+// Since there is no local file backing the stream, Save requires an output path.
+func OpenURL(url string, opts ...Option) (*Flac, error) {
+	o := applyOptions(opts)
+
+	src := &httpSource{client: o.httpClient, url: url, retry: o.retry}
+	if src.client == nil {
+		src.client = http.DefaultClient
+	}
+
+	size, err := src.fetchSize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize flacgo: %w", err)
+	}
+	src.size = size
+
+	return newFlac(src, "", size, opts)
+}
+
+// fetchSize asks the server for the first byte of the file and reads the total size from Content-Range
+func (src *httpSource) fetchSize() (int64, error) {
+	var size int64
+
+	err := src.withRetry(func() error {
+		resp, err := src.get(0, 0)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		// Content-Range: bytes 0-0/SIZE
+		_, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if !found || total == "*" {
+			return fmt.Errorf("unable to get size of '%s': missing Content-Range", src.url)
+		}
+		size, err = strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			return fmt.Errorf("unable to get size of '%s': %w", src.url, err)
+		}
+
+		return nil
+	})
+
+	return size, err
+}
+
+// get requests the bytes from first to last included
+func (src *httpSource) get(first, last int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, src.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransient, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		return resp, nil
+	case resp.StatusCode == http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("server of '%s' doesn't support range requests", src.url)
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: '%s' returned %s", errTransient, src.url, resp.Status)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("'%s' returned %s", src.url, resp.Status)
+	}
+}
+
+// ReadAt fills p with the bytes starting at off, resuming with a new Range request when the
+// connection drops in the middle of the body
+func (src *httpSource) ReadAt(p []byte, off int64) (int, error) {
+	if off >= src.size {
+		return 0, io.EOF
+	}
+
+	want := p
+	if remaining := src.size - off; int64(len(want)) > remaining {
+		want = want[:remaining]
+	}
+
+	read := 0
+	err := src.withRetry(func() error {
+		resp, err := src.get(off+int64(read), off+int64(len(want))-1)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		n, err := io.ReadFull(resp.Body, want[read:])
+		read += n
+		if err != nil {
+			return fmt.Errorf("%w: unable to read '%s': %w", errTransient, src.url, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return read, err
+	}
+
+	if len(want) < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// Size returns the size of the remote file
+func (src *httpSource) Size() int64 {
+	return src.size
+}
+
+// withRetry calls fn until it succeeds, fails with a permanent error or runs out of attempts
+func (src *httpSource) withRetry(fn func() error) error {
+	delay := src.retry.baseDelay
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !errors.Is(err, errTransient) || attempt >= src.retry.attempts {
+			return err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		if src.retry.maxDelay > 0 && delay > src.retry.maxDelay {
+			delay = src.retry.maxDelay
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// strippedPNGChunks are the PNG chunks removed by StripImageMetadata
+var strippedPNGChunks = map[string]bool{
+	"eXIf": true, "iCCP": true, "iTXt": true, "tEXt": true, "zTXt": true, "tIME": true,
+}
+
+// StripImageMetadata returns the image data without the metadata embedded by cameras and editors,
+// which may tell where and with what a picture was taken: EXIF, XMP, ICC profiles, IPTC and comments
+// of JPEG images, EXIF, ICC profiles, text and time chunks of PNG images. The pixels are untouched
+// and the JFIF and Adobe segments JPEG decoders need are kept. Other formats are returned as is.
+func StripImageMetadata(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return stripJPEGMetadata(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNGMetadata(data)
+	}
+	return data, nil
+}
+
+// stripJPEGMetadata drops the APP1 to APP13, APP15 and COM segments preceding the scan of a JPEG image
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	offset := 2
+	for {
+		// Markers may be preceded by fill bytes
+		for offset < len(data) && data[offset] == 0xFF && offset+1 < len(data) && data[offset+1] == 0xFF {
+			offset++
+		}
+		if offset+4 > len(data) || data[offset] != 0xFF {
+			return nil, fmt.Errorf("unable to strip image metadata: invalid JPEG segment at offset %d", offset)
+		}
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("unable to strip image metadata: truncated JPEG segment at offset %d", offset)
+		}
+
+		// The compressed data follows the start of scan, everything from there is copied as is
+		if marker == 0xDA {
+			out.Write(data[offset:])
+			return out.Bytes(), nil
+		}
+		isMetadata := (marker >= 0xE1 && marker <= 0xED) || marker == 0xEF || marker == 0xFE
+		if !isMetadata {
+			out.Write(data[offset:end])
+		}
+		offset = end
+	}
+}
+
+// stripPNGMetadata drops the chunks of strippedPNGChunks from a PNG image
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	offset := len(pngSignature)
+	for offset < len(data) {
+		if offset+12 > len(data) {
+			return nil, fmt.Errorf("unable to strip image metadata: truncated PNG chunk at offset %d", offset)
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if length < 0 || end > len(data) || end < offset {
+			return nil, fmt.Errorf("unable to strip image metadata: truncated PNG chunk at offset %d", offset)
+		}
+		if !strippedPNGChunks[string(data[offset+4:offset+8])] {
+			out.Write(data[offset:end])
+		}
+		offset = end
+	}
+	return out.Bytes(), nil
+}
+
+// WithStripImageMetadata makes the SetCoverPicture and SetPicture methods strip the images they
+// embed with StripImageMetadata. Images staged from a path are then read when staged.
+func WithStripImageMetadata() Option {
+	return func(o *options) {
+		o.stripImageMetadata = true
+	}
+}
+
+// StripPictureMetadata stages the pictures stored in the file stripped with StripImageMetadata,
+// e.g. to scrub the location of a photo used as artist picture before sharing a file, and returns
+// how many pictures had metadata. The pictures keep their type, description and order.
+func (flac *Flac) StripPictureMetadata() (int, error) {
+	return flac.restagePictures(func(picture *Picture) ([]byte, error) {
+		data, err := StripImageMetadata(picture.Data)
+		if err != nil || len(data) == len(picture.Data) {
+			return nil, err
+		}
+		return data, nil
+	})
+}
+
+// restagePictures stages the pictures stored in the file with the image data returned by transform,
+// nil leaving a picture unchanged, and returns how many pictures changed. The pictures keep their type,
+// description and order. Pictures already removed or replaced by a staged cover are skipped.
+func (flac *Flac) restagePictures(transform func(picture *Picture) ([]byte, error)) (int, error) {
+	if err := flac.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer flac.recordUndo()()
+
+	pictures, err := flac.Pictures()
+	if err != nil {
+		return 0, err
+	}
+
+	var kept []*Picture
+	changed := 0
+	for _, picture := range pictures {
+		isCover := flac.parsedCoverPicture != nil && picture.blockIndex == flac.parsedCoverPicture.Index
+		if flac.removedPictures[picture.blockIndex] || (isCover && (flac.hasPendingCover() || flac.removeCoverPicture)) {
+			continue
+		}
+		data, err := transform(picture)
+		if err != nil {
+			return 0, fmt.Errorf("unable to convert %s picture: %w", PictureTypeName(picture.PictureType), err)
+		}
+		if data != nil {
+			picture.Data = data
+			if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+				picture.setImageConfig(config)
+			}
+			changed++
+		}
+		kept = append(kept, picture)
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+
+	// All the pictures are staged again so they stay in order, the last one being the cover
+	staged := make([]*pendingPicture, 0, len(kept))
+	for _, picture := range kept {
+		prefix, err := flac.createPictureHeader(picture, len(picture.Data))
+		if err != nil {
+			return 0, err
+		}
+		staged = append(staged, &pendingPicture{
+			prefix:      prefix,
+			pictureType: picture.PictureType,
+			size:        int64(len(picture.Data)),
+			data:        picture.Data,
+		})
+	}
+	for _, picture := range kept {
+		flac.removedPictures[picture.blockIndex] = true
+	}
+	flac.pendingPictures = append(staged, flac.pendingPictures...)
+
+	return changed, nil
+}
@@ -0,0 +1,426 @@
+// Package index keeps the scan results of a FLAC library (tags, stream info, hashes and
+// modification times) in a SQLite database, so applications can query huge libraries
+// instantly and rescan only the files that changed.
+//
+// The package only depends on database/sql, the caller opens the database with the SQLite
+// driver of its choice, e.g. github.com/mattn/go-sqlite3 or modernc.org/sqlite:
+//
+//	db, err := sql.Open("sqlite3", "library.db")
+//	idx, err := index.New(db)
+//	stats, err := idx.Update(ctx, "/music")
+package index
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	flacgo "github.com/jacopo-degattis/flacgo"
+)
+
+// schemaVersion is stored in PRAGMA user_version, bump it when the tables change
+const schemaVersion = 1
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS files (
+		path TEXT PRIMARY KEY,
+		mod_time INTEGER NOT NULL,
+		size INTEGER NOT NULL,
+		sample_rate INTEGER NOT NULL,
+		channels INTEGER NOT NULL,
+		bits_per_sample INTEGER NOT NULL,
+		total_samples INTEGER NOT NULL,
+		duration REAL NOT NULL,
+		audio_md5 TEXT NOT NULL,
+		tag_hash TEXT NOT NULL,
+		pictures INTEGER NOT NULL,
+		scanned_at INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS tags (
+		path TEXT NOT NULL REFERENCES files(path) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (path, position)
+	)`,
+	`CREATE INDEX IF NOT EXISTS tags_key_value ON tags (key, value)`,
+}
+
+// ErrNotFound is returned by Get when the file is not in the index
+var ErrNotFound = errors.New("file not indexed")
+
+// Index is a library index stored in a SQLite database
+type Index struct {
+	db *sql.DB
+}
+
+// Entry is the indexed state of a file
+type Entry struct {
+	Path          string
+	ModTime       time.Time
+	Size          int64
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	TotalSamples  uint64
+	Duration      time.Duration
+	// AudioMD5 is the MD5 signature of the audio stored in STREAMINFO, hex encoded
+	AudioMD5 string
+	// TagHash is the flacgo TagHash of the file, hex encoded
+	TagHash  string
+	Pictures int
+	// Comments are in file order, with upper case keys
+	Comments []flacgo.VorbisComment
+	// ScannedAt is when the file was last read
+	ScannedAt time.Time
+}
+
+// UpdateStats counts what Update did
+type UpdateStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	// Failed holds the files that couldn't be scanned, they are left out of the index
+	Failed map[string]error
+}
+
+// New returns an index stored in db, creating its tables if needed
+func New(db *sql.DB) (*Index, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return nil, fmt.Errorf("unable to read schema version: %w", err)
+	}
+	if version > schemaVersion {
+		return nil, fmt.Errorf("index schema version %d is newer than the supported %d", version, schemaVersion)
+	}
+
+	for _, statement := range append(schema, fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)) {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("unable to create index schema: %w", err)
+		}
+	}
+
+	return &Index{db: db}, nil
+}
+
+// DB returns the underlying database, e.g. to run custom queries over the files and tags tables
+func (idx *Index) DB() *sql.DB {
+	return idx.db
+}
+
+// Update walks root indexing every .flac file found. Files whose size and modification time
+// didn't change since the last scan are skipped, and files under root that disappeared are removed.
+func (idx *Index) Update(ctx context.Context, root string) (*UpdateStats, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &UpdateStats{Failed: make(map[string]error)}
+	seen := make(map[string]bool)
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".flac") {
+			return nil
+		}
+
+		seen[path] = true
+		status, err := idx.updateFile(ctx, path)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			stats.Failed[path] = err
+		case status == statusAdded:
+			stats.Added++
+		case status == statusUpdated:
+			stats.Updated++
+		default:
+			stats.Unchanged++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("unable to walk '%s': %w", root, err)
+	}
+
+	paths, err := idx.paths(ctx)
+	if err != nil {
+		return stats, err
+	}
+	prefix := root + string(filepath.Separator)
+	for _, path := range paths {
+		if (path == root || strings.HasPrefix(path, prefix)) && !seen[path] {
+			if err := idx.Remove(ctx, path); err != nil {
+				return stats, err
+			}
+			stats.Removed++
+		}
+	}
+
+	return stats, nil
+}
+
+// UpdateFile indexes a single file if it changed since the last scan, it reports whether it was read
+func (idx *Index) UpdateFile(ctx context.Context, path string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	status, err := idx.updateFile(ctx, path)
+	return status != statusUnchanged, err
+}
+
+type updateStatus int
+
+const (
+	statusUnchanged updateStatus = iota
+	statusAdded
+	statusUpdated
+)
+
+// updateFile scans the file at the absolute path unless the index is up to date with it
+func (idx *Index) updateFile(ctx context.Context, path string) (updateStatus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return statusUnchanged, err
+	}
+
+	var modTime, size int64
+	err = idx.db.QueryRowContext(ctx, "SELECT mod_time, size FROM files WHERE path = ?", path).Scan(&modTime, &size)
+	found := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return statusUnchanged, fmt.Errorf("unable to query index: %w", err)
+	}
+	if found && modTime == info.ModTime().UnixNano() && size == info.Size() {
+		return statusUnchanged, nil
+	}
+
+	entry, err := scan(path, info)
+	if err != nil {
+		return statusUnchanged, err
+	}
+	if err := idx.put(ctx, entry); err != nil {
+		return statusUnchanged, err
+	}
+
+	if found {
+		return statusUpdated, nil
+	}
+	return statusAdded, nil
+}
+
+// scan reads the indexed fields of a file
+func scan(path string, info fs.FileInfo) (*Entry, error) {
+	flac, err := flacgo.Open(path, flacgo.WithHeaderOnly())
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	metadata, err := flac.Metadata()
+	if err != nil {
+		return nil, err
+	}
+	tagHash := flac.TagHash()
+
+	pictures := 0
+	for _, block := range metadata.Blocks {
+		if block.BlockType == "PICTURE" {
+			pictures++
+		}
+	}
+
+	streamInfo := metadata.StreamInfo
+	return &Entry{
+		Path:          path,
+		ModTime:       info.ModTime(),
+		Size:          info.Size(),
+		SampleRate:    streamInfo.SampleRate,
+		Channels:      streamInfo.Channels,
+		BitsPerSample: streamInfo.BitsPerSample,
+		TotalSamples:  streamInfo.TotalSamples,
+		Duration:      streamInfo.Duration(),
+		AudioMD5:      hex.EncodeToString(streamInfo.MD5[:]),
+		TagHash:       hex.EncodeToString(tagHash[:]),
+		Pictures:      pictures,
+		Comments:      metadata.Comments,
+		ScannedAt:     time.Now(),
+	}, nil
+}
+
+// put replaces the indexed state of a file
+func (idx *Index) put(ctx context.Context, entry *Entry) error {
+	tx, err := idx.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE path = ?", entry.Path); err != nil {
+		return fmt.Errorf("unable to delete tags: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO files (path, mod_time, size, sample_rate, channels,
+		bits_per_sample, total_samples, duration, audio_md5, tag_hash, pictures, scanned_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Path, entry.ModTime.UnixNano(), entry.Size, int64(entry.SampleRate), int64(entry.Channels),
+		int64(entry.BitsPerSample), int64(entry.TotalSamples), entry.Duration.Seconds(), entry.AudioMD5,
+		entry.TagHash, entry.Pictures, entry.ScannedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("unable to insert file: %w", err)
+	}
+
+	for i, comment := range entry.Comments {
+		_, err := tx.ExecContext(ctx, "INSERT INTO tags (path, position, key, value) VALUES (?, ?, ?, ?)",
+			entry.Path, i, strings.ToUpper(comment.Title), comment.Value)
+		if err != nil {
+			return fmt.Errorf("unable to insert tags: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Remove drops a file from the index
+func (idx *Index) Remove(ctx context.Context, path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := idx.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Foreign keys are off by default in SQLite, so tags are not deleted by the cascade
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE path = ?", path); err != nil {
+		return fmt.Errorf("unable to delete tags: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM files WHERE path = ?", path); err != nil {
+		return fmt.Errorf("unable to delete file: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// paths returns the paths of all the indexed files
+func (idx *Index) paths(ctx context.Context) ([]string, error) {
+	rows, err := idx.db.QueryContext(ctx, "SELECT path FROM files")
+	if err != nil {
+		return nil, fmt.Errorf("unable to query index: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("unable to read index: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// Get returns the indexed state of a file
+func (idx *Index) Get(ctx context.Context, path string) (*Entry, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := idx.entries(ctx, "WHERE path = ?", path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return entries[0], nil
+}
+
+// fileColumns are the columns of the files table read by entries, in the order of scanEntry
+const fileColumns = `path, mod_time, size, sample_rate, channels, bits_per_sample, total_samples,
+	duration, audio_md5, tag_hash, pictures, scanned_at`
+
+// entries returns the files selected by the where clause along with their tags
+func (idx *Index) entries(ctx context.Context, where string, args ...any) ([]*Entry, error) {
+	rows, err := idx.db.QueryContext(ctx, "SELECT "+fileColumns+" FROM files "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query index: %w", err)
+	}
+
+	var entries []*Entry
+	byPath := make(map[string]*Entry)
+	for rows.Next() {
+		var entry Entry
+		var modTime, scannedAt, sampleRate, channels, bitsPerSample, totalSamples int64
+		var duration float64
+		err := rows.Scan(&entry.Path, &modTime, &entry.Size, &sampleRate, &channels, &bitsPerSample,
+			&totalSamples, &duration, &entry.AudioMD5, &entry.TagHash, &entry.Pictures, &scannedAt)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("unable to read index: %w", err)
+		}
+		entry.ModTime = time.Unix(0, modTime)
+		entry.ScannedAt = time.Unix(0, scannedAt)
+		entry.SampleRate = uint32(sampleRate)
+		entry.Channels = uint8(channels)
+		entry.BitsPerSample = uint8(bitsPerSample)
+		entry.TotalSamples = uint64(totalSamples)
+		entry.Duration = time.Duration(duration * float64(time.Second))
+
+		entries = append(entries, &entry)
+		byPath[entry.Path] = &entry
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read index: %w", err)
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	// Tags are read in a second query rather than joined, a file has many tags
+	tagRows, err := idx.db.QueryContext(ctx, "SELECT path, key, value FROM tags WHERE path IN (SELECT path FROM files "+where+") ORDER BY path, position", args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query tags: %w", err)
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var path string
+		var comment flacgo.VorbisComment
+		if err := tagRows.Scan(&path, &comment.Title, &comment.Value); err != nil {
+			return nil, fmt.Errorf("unable to read tags: %w", err)
+		}
+		if entry := byPath[path]; entry != nil {
+			entry.Comments = append(entry.Comments, comment)
+		}
+	}
+
+	return entries, tagRows.Err()
+}
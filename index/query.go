@@ -0,0 +1,132 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Query selects indexed files, every field set must match and zero fields match everything.
+// Text comparisons ignore case for ASCII letters, as SQLite does.
+type Query struct {
+	// Artist matches the ARTIST or ALBUMARTIST tags exactly
+	Artist string
+	// Album matches the ALBUM tag exactly
+	Album string
+	// Year matches DATE or YEAR tags starting with it, e.g. 1999 matches "1999-05-01"
+	Year int
+	// Tags match the tag with the given key exactly, for any other tag
+	Tags          map[string]string
+	SampleRate    uint32
+	BitsPerSample uint8
+	Channels      uint8
+	// Text is searched in the TITLE tags, every word of it must appear
+	Text string
+	// Limit is the maximum number of files returned, 0 means no limit
+	Limit  int
+	Offset int
+}
+
+// Search returns the files matching q, sorted by path
+func (idx *Index) Search(ctx context.Context, q Query) ([]*Entry, error) {
+	where, args := q.where()
+	where += " ORDER BY path"
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := q.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		where += " LIMIT ? OFFSET ?"
+		args = append(args, limit, q.Offset)
+	}
+
+	return idx.entries(ctx, where, args...)
+}
+
+// Count returns the number of files matching q, ignoring its Limit and Offset
+func (idx *Index) Count(ctx context.Context, q Query) (int, error) {
+	where, args := q.where()
+
+	var count int
+	if err := idx.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM files "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("unable to query index: %w", err)
+	}
+	return count, nil
+}
+
+// Values returns the distinct values of a tag across the files matching q, sorted,
+// e.g. Values(ctx, "ALBUM", Query{Artist: "X"}) lists the albums of an artist
+func (idx *Index) Values(ctx context.Context, key string, q Query) ([]string, error) {
+	where, args := q.where()
+	args = append([]any{strings.ToUpper(key)}, args...)
+
+	rows, err := idx.db.QueryContext(ctx, "SELECT DISTINCT value FROM tags WHERE key = ? AND path IN (SELECT path FROM files "+where+") ORDER BY value COLLATE NOCASE", args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query index: %w", err)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("unable to read index: %w", err)
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// where returns the WHERE clause over the files table selecting the files matching the query
+func (q Query) where() (string, []any) {
+	var conditions []string
+	var args []any
+
+	tag := func(keys []string, condition string, value any) {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM tags WHERE tags.path = files.path AND tags.key IN ("+placeholders+") AND "+condition+")")
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		args = append(args, value)
+	}
+
+	if q.Artist != "" {
+		tag([]string{"ARTIST", "ALBUMARTIST"}, "tags.value = ? COLLATE NOCASE", q.Artist)
+	}
+	if q.Album != "" {
+		tag([]string{"ALBUM"}, "tags.value = ? COLLATE NOCASE", q.Album)
+	}
+	if q.Year != 0 {
+		tag([]string{"DATE", "YEAR"}, "substr(tags.value, 1, 4) = ?", fmt.Sprintf("%04d", q.Year))
+	}
+	for key, value := range q.Tags {
+		tag([]string{strings.ToUpper(key)}, "tags.value = ? COLLATE NOCASE", value)
+	}
+	for _, word := range strings.Fields(q.Text) {
+		tag([]string{"TITLE"}, `tags.value LIKE ? ESCAPE '\'`, "%"+escapeLike(word)+"%")
+	}
+
+	if q.SampleRate != 0 {
+		conditions = append(conditions, "sample_rate = ?")
+		args = append(args, int64(q.SampleRate))
+	}
+	if q.BitsPerSample != 0 {
+		conditions = append(conditions, "bits_per_sample = ?")
+		args = append(args, int64(q.BitsPerSample))
+	}
+	if q.Channels != 0 {
+		conditions = append(conditions, "channels = ?")
+		args = append(args, int64(q.Channels))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
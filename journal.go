@@ -0,0 +1,113 @@
+package flacgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Journal records the files a batch job completed, so that an interrupted job can be restarted
+// without processing them again
+type Journal interface {
+	// Completed reports whether path was completed by an earlier run
+	Completed(path string) bool
+	// Record marks path as completed
+	Record(path string) error
+}
+
+// JournalFunc is a Journal calling fn for every completed file, e.g. to store them in a database.
+// It never reports files as completed, the caller is expected to leave them out of the next run.
+type JournalFunc func(path string) error
+
+// Completed returns false
+func (fn JournalFunc) Completed(path string) bool {
+	return false
+}
+
+// Record calls fn
+func (fn JournalFunc) Record(path string) error {
+	return fn(path)
+}
+
+// FileJournal is a Journal stored as a text file with one completed path per line
+type FileJournal struct {
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]bool
+}
+
+// OpenJournal opens the journal at path, creating it if it doesn't exist. The paths recorded by earlier
+// runs are reported as completed, a last line cut short by a crash is ignored.
+func OpenJournal(path string) (*FileJournal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read journal '%s': %w", path, err)
+	}
+
+	// Only the complete lines are kept
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	journal := &FileJournal{completed: make(map[string]bool)}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			journal.completed[line] = true
+		}
+	}
+
+	journal.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open journal '%s': %w", path, err)
+	}
+	if err := journal.file.Truncate(int64(len(data))); err != nil {
+		journal.file.Close()
+		return nil, fmt.Errorf("unable to open journal '%s': %w", path, err)
+	}
+	if _, err := journal.file.Seek(0, io.SeekEnd); err != nil {
+		journal.file.Close()
+		return nil, fmt.Errorf("unable to open journal '%s': %w", path, err)
+	}
+
+	return journal, nil
+}
+
+// Completed reports whether path is recorded in the journal
+func (journal *FileJournal) Completed(path string) bool {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	return journal.completed[path]
+}
+
+// Record appends path to the journal and syncs it to disk
+func (journal *FileJournal) Record(path string) error {
+	if strings.ContainsAny(path, "\r\n") {
+		return fmt.Errorf("unable to record '%s' in journal: path contains a line break", path)
+	}
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.completed[path] {
+		return nil
+	}
+	if _, err := journal.file.WriteString(path + "\n"); err != nil {
+		return fmt.Errorf("unable to record '%s' in journal: %w", path, err)
+	}
+	if err := journal.file.Sync(); err != nil {
+		return fmt.Errorf("unable to record '%s' in journal: %w", path, err)
+	}
+	journal.completed[path] = true
+	return nil
+}
+
+// Len returns the number of completed paths in the journal
+func (journal *FileJournal) Len() int {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	return len(journal.completed)
+}
+
+// Close closes the journal file
+func (journal *FileJournal) Close() error {
+	return journal.file.Close()
+}
@@ -0,0 +1,130 @@
+package flacgo
+
+//go:generate go run gen_isocodes.go
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Language is an ISO 639-2 language, as stored in the LANGUAGE tag
+type Language struct {
+	// Code is the 3 letters terminology code, e.g. "fra"
+	Code string
+	// Bibliographic is the 3 letters bibliographic code when it differs from Code, e.g. "fre"
+	Bibliographic string
+	// Alpha2 is the ISO 639-1 code, if the language has one
+	Alpha2 string
+	Name   string
+}
+
+// Country is an ISO 3166-1 country, as stored in the RELEASECOUNTRY tag
+type Country struct {
+	// Code is the 2 letters code, e.g. "GB"
+	Code   string
+	Alpha3 string
+	Name   string
+}
+
+// musicBrainzCountries are the codes MusicBrainz uses besides ISO 3166-1 for worldwide and
+// regional releases or countries that no longer exist
+var musicBrainzCountries = []Country{
+	{"XW", "", "Worldwide"},
+	{"XE", "", "Europe"},
+	{"XC", "", "Czechoslovakia"},
+	{"XG", "", "East Germany"},
+	{"XU", "", "Soviet Union"},
+	{"YU", "", "Yugoslavia"},
+	{"CS", "", "Serbia and Montenegro"},
+}
+
+// languageIndex maps every code of the languages, lower case, to the language
+var languageIndex = sync.OnceValue(func() map[string]Language {
+	index := make(map[string]Language)
+	for _, language := range isoLanguages {
+		for _, code := range []string{language.Code, language.Bibliographic, language.Alpha2} {
+			if code != "" {
+				index[code] = language
+			}
+		}
+	}
+	return index
+})
+
+// countryIndex maps every code of the countries, upper case, to the country
+var countryIndex = sync.OnceValue(func() map[string]Country {
+	index := make(map[string]Country)
+	for _, country := range append(isoCountries[:len(isoCountries):len(isoCountries)], musicBrainzCountries...) {
+		index[country.Code] = country
+		if country.Alpha3 != "" {
+			index[country.Alpha3] = country
+		}
+	}
+	return index
+})
+
+// LookupLanguage returns the language with the given ISO 639-1 or ISO 639-2 code, ignoring case.
+// Besides the languages, "mul" (multiple languages), "zxx" (no lyrics) and "und" (undetermined) are valid codes.
+func LookupLanguage(code string) (Language, bool) {
+	language, found := languageIndex()[strings.ToLower(strings.TrimSpace(code))]
+	return language, found
+}
+
+// LookupCountry returns the country with the given ISO 3166-1 alpha-2 or alpha-3 code, ignoring case.
+// The MusicBrainz codes XW (worldwide), XE (Europe) and the ones of former countries are accepted too.
+func LookupCountry(code string) (Country, bool) {
+	country, found := countryIndex()[strings.ToUpper(strings.TrimSpace(code))]
+	return country, found
+}
+
+// Languages returns the languages of the LANGUAGE comments, including the staged changes.
+// It fails if a value is not a known language code.
+func (flac *Flac) Languages() ([]Language, error) {
+	languages := make([]Language, 0)
+	for _, value := range flac.MetadataValues("LANGUAGE") {
+		language, found := LookupLanguage(value)
+		if !found {
+			return nil, fmt.Errorf("invalid LANGUAGE %q, expected an ISO 639 code", value)
+		}
+		languages = append(languages, language)
+	}
+	return languages, nil
+}
+
+// SetLanguages stages one LANGUAGE comment per code, with the 3 letters ISO 639-2 code as MusicBrainz does
+func (flac *Flac) SetLanguages(codes ...string) error {
+	values := make([]string, len(codes))
+	for i, code := range codes {
+		language, found := LookupLanguage(code)
+		if !found {
+			return fmt.Errorf("invalid language %q, expected an ISO 639 code", code)
+		}
+		values[i] = language.Code
+	}
+	return flac.SetMetadataValues("LANGUAGE", values)
+}
+
+// ReleaseCountry returns the country of the RELEASECOUNTRY comment, including the staged changes.
+// It returns false if the file has none and fails if the value is not a known country code.
+func (flac *Flac) ReleaseCountry() (Country, bool, error) {
+	values := flac.MetadataValues("RELEASECOUNTRY")
+	if len(values) == 0 {
+		return Country{}, false, nil
+	}
+
+	country, found := LookupCountry(values[0])
+	if !found {
+		return Country{}, false, fmt.Errorf("invalid RELEASECOUNTRY %q, expected an ISO 3166 code", values[0])
+	}
+	return country, true, nil
+}
+
+// SetReleaseCountry stages the RELEASECOUNTRY comment, with the 2 letters ISO 3166-1 code as MusicBrainz does
+func (flac *Flac) SetReleaseCountry(code string) error {
+	country, found := LookupCountry(code)
+	if !found {
+		return fmt.Errorf("invalid country %q, expected an ISO 3166 code", code)
+	}
+	return flac.SetMetadata("RELEASECOUNTRY", country.Code)
+}
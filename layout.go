@@ -0,0 +1,66 @@
+package flacgo
+
+import "fmt"
+
+// Region types of Layout besides the metadata block types
+const (
+	RegionMagic = "fLaC"
+	// RegionAudio holds the audio frames
+	RegionAudio = "AUDIO"
+	// RegionAPETag is an APEv2 tag appended after the audio
+	RegionAPETag = "APEV2"
+	// RegionTrailer is anything following the audio and the APEv2 tag, such as an ID3v1 tag
+	RegionTrailer = "TRAILER"
+)
+
+// Region is a contiguous range of bytes of a file
+type Region struct {
+	// Type is a metadata block type, RESERVED for reserved block types, or one of the Region constants
+	Type string `json:"type"`
+	// Offset is the position of the first byte, the block header for metadata blocks
+	Offset int64 `json:"offset"`
+	// Length includes the header of metadata blocks
+	Length int64 `json:"length"`
+}
+
+// Layout returns the regions the file is made of in order: the magic header, every metadata block
+// and the audio, followed by the APEv2 tag and the trailer if any. The regions are contiguous and cover
+// the whole file, e.g. for streaming servers building byte-range responses that skip or substitute the metadata.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) Layout() ([]Region, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+
+	regions := make([]Region, 0, len(blocks)+2)
+	regions = append(regions, Region{Type: RegionMagic, Offset: 0, Length: 4})
+	for _, block := range blocks {
+		blockType := block.BlockType
+		if blockType == "" {
+			blockType = "RESERVED"
+		}
+		regions = append(regions, Region{
+			Type:   blockType,
+			Offset: block.Index,
+			Length: 4 + int64(block.BlockHeader.BlockLength),
+		})
+	}
+
+	audioEnd := flac.getAudioEndOffset()
+	if audioEnd < flac.audioOffset {
+		audioEnd = flac.fileSize
+	}
+	regions = append(regions, Region{Type: RegionAudio, Offset: flac.audioOffset, Length: audioEnd - flac.audioOffset})
+
+	trailerStart := audioEnd
+	if flac.apeTag != nil && flac.apeTag.offset == audioEnd {
+		trailerStart = flac.apeTag.offset + flac.apeTag.length
+		regions = append(regions, Region{Type: RegionAPETag, Offset: flac.apeTag.offset, Length: flac.apeTag.length})
+	}
+	if trailerStart < flac.fileSize {
+		regions = append(regions, Region{Type: RegionTrailer, Offset: trailerStart, Length: flac.fileSize - trailerStart})
+	}
+
+	return regions, nil
+}
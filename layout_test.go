@@ -0,0 +1,53 @@
+package flacgo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLayoutReportsID3v1Trailer(t *testing.T) {
+	data, err := os.ReadFile("examples/sample.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailer := append([]byte("TAG"), bytes.Repeat([]byte{0}, 125)...)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"ID3v1 only", append(bytes.Clone(data), trailer...), []string{RegionAudio, RegionTrailer}},
+		{"APEv2 and ID3v1", append(appendAPETag(bytes.Clone(data), map[string]string{"Artist": "Flac Go"}), trailer...),
+			[]string{RegionAudio, RegionAPETag, RegionTrailer}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "trailer.flac")
+			if err := os.WriteFile(path, test.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			flac, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer flac.Close()
+
+			regions, err := flac.Layout()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tail := regions[len(regions)-len(test.want):]
+			for i, region := range tail {
+				if region.Type != test.want[i] {
+					t.Fatalf("regions end with %v, expected types %v", tail, test.want)
+				}
+			}
+			last := tail[len(tail)-1]
+			if last.Length != 128 || last.Offset+last.Length != int64(len(test.data)) {
+				t.Errorf("trailer is %+v, expected the last 128 bytes", last)
+			}
+		})
+	}
+}
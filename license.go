@@ -0,0 +1,148 @@
+package flacgo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CreativeCommons is a Creative Commons license, or one of the CC0 and Public Domain Mark tools
+type CreativeCommons struct {
+	// Terms are the license elements like "by-nc-sa", or "zero" and "mark" for the public domain tools
+	Terms string
+	// Version of the license, e.g. "4.0"
+	Version string
+	// Jurisdiction is the country code of a ported license, e.g. "us" for 3.0/us, empty for international ones
+	Jurisdiction string
+}
+
+// creativeCommonsTerms are the license elements of the six Creative Commons licenses
+var creativeCommonsTerms = []string{"by", "by-sa", "by-nd", "by-nc", "by-nc-sa", "by-nc-nd"}
+
+var (
+	// creativeCommonsURL matches the deeds URLs, like creativecommons.org/licenses/by-sa/4.0/
+	creativeCommonsURL = regexp.MustCompile(`(?i)creativecommons\.org/(licenses|publicdomain)/([a-z-]+)/(\d\.\d)(?:/([a-z]{2,3}))?`)
+	// creativeCommonsName matches the short names, like "CC BY-SA 4.0", "CC-BY-NC-3.0" or "CC0 1.0"
+	creativeCommonsName = regexp.MustCompile(`(?i)\bCC[ -]?(0|BY(?:[ -](?:NC|SA|ND))*)(?:[ -]v?(\d\.\d))?\b`)
+)
+
+// ParseCreativeCommons finds a Creative Commons license in text, given as deed URL or short name,
+// e.g. "http://creativecommons.org/licenses/by-nc-sa/3.0/us/" or "CC BY-SA 4.0". The text may hold
+// more than the license, like the "Licensed to the public under ..." notices of netlabels.
+func ParseCreativeCommons(text string) (CreativeCommons, bool) {
+	if match := creativeCommonsURL.FindStringSubmatch(text); match != nil {
+		license := CreativeCommons{
+			Terms:        strings.ToLower(match[2]),
+			Version:      match[3],
+			Jurisdiction: strings.ToLower(match[4]),
+		}
+		if strings.EqualFold(match[1], "publicdomain") != (license.Terms == "zero" || license.Terms == "mark") {
+			return CreativeCommons{}, false
+		}
+		if !license.valid() {
+			return CreativeCommons{}, false
+		}
+		return license, true
+	}
+
+	if match := creativeCommonsName.FindStringSubmatch(text); match != nil {
+		license := CreativeCommons{Version: match[2]}
+		if match[1] == "0" {
+			license.Terms = "zero"
+		} else {
+			license.Terms = strings.ToLower(strings.ReplaceAll(match[1], " ", "-"))
+		}
+		if license.Version == "" {
+			// Without a version the short name refers to the latest license
+			license.Version = "4.0"
+			if license.Terms == "zero" {
+				license.Version = "1.0"
+			}
+		}
+		if !license.valid() {
+			return CreativeCommons{}, false
+		}
+		return license, true
+	}
+
+	return CreativeCommons{}, false
+}
+
+// valid reports whether the license terms exist
+func (license CreativeCommons) valid() bool {
+	switch license.Terms {
+	case "zero", "mark":
+		return license.Version == "1.0"
+	}
+	for _, terms := range creativeCommonsTerms {
+		if license.Terms == terms {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the canonical deed URL of the license
+func (license CreativeCommons) URL() string {
+	kind := "licenses"
+	if license.Terms == "zero" || license.Terms == "mark" {
+		kind = "publicdomain"
+	}
+	url := fmt.Sprintf("https://creativecommons.org/%s/%s/%s/", kind, license.Terms, license.Version)
+	if license.Jurisdiction != "" {
+		url += license.Jurisdiction + "/"
+	}
+	return url
+}
+
+// String returns the short name of the license, e.g. "CC BY-SA 4.0" or "CC0 1.0"
+func (license CreativeCommons) String() string {
+	switch license.Terms {
+	case "zero":
+		return "CC0 " + license.Version
+	case "mark":
+		return "Public Domain Mark " + license.Version
+	}
+	name := "CC " + strings.ToUpper(license.Terms) + " " + license.Version
+	if license.Jurisdiction != "" {
+		name += " " + strings.ToUpper(license.Jurisdiction)
+	}
+	return name
+}
+
+// Copyright returns the value of the COPYRIGHT comment, including the staged changes
+func (flac *Flac) Copyright() string {
+	return strings.Join(flac.MetadataValues("COPYRIGHT"), "; ")
+}
+
+// SetCopyright stages the COPYRIGHT comment, e.g. "2012 Artist"
+func (flac *Flac) SetCopyright(copyright string) error {
+	return flac.SetMetadata("COPYRIGHT", copyright)
+}
+
+// License returns the value of the LICENSE comment, including the staged changes
+func (flac *Flac) License() string {
+	return strings.Join(flac.MetadataValues("LICENSE"), "; ")
+}
+
+// SetLicense stages the LICENSE comment, usually the URL of the license
+func (flac *Flac) SetLicense(license string) error {
+	return flac.SetMetadata("LICENSE", license)
+}
+
+// CreativeCommonsLicense returns the Creative Commons license found in the LICENSE comment or,
+// since many netlabels put it there, in the COPYRIGHT comment
+func (flac *Flac) CreativeCommonsLicense() (CreativeCommons, bool) {
+	if license, found := ParseCreativeCommons(flac.License()); found {
+		return license, true
+	}
+	return ParseCreativeCommons(flac.Copyright())
+}
+
+// SetCreativeCommonsLicense stages the deed URL of license as LICENSE comment
+func (flac *Flac) SetCreativeCommonsLicense(license CreativeCommons) error {
+	if !license.valid() {
+		return fmt.Errorf("invalid Creative Commons license %q", license)
+	}
+	return flac.SetLicense(license.URL())
+}
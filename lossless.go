@@ -0,0 +1,185 @@
+package flacgo
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// spectrumSize is the number of samples of the windows the spectrum is averaged over
+const spectrumSize = 4096
+
+// LosslessReport is the result of AnalyzeLossless
+type LosslessReport struct {
+	SampleRate    uint32 `json:"sample_rate"`
+	BitsPerSample uint8  `json:"bits_per_sample"`
+	// EffectiveBitsPerSample is the number of bits actually used by the samples, 0 for silence
+	EffectiveBitsPerSample uint8 `json:"effective_bits_per_sample"`
+	// Bandwidth is the highest frequency in Hz whose content is above the quantization noise,
+	// 0 for silence or audio shorter than a single analysis window
+	Bandwidth float64 `json:"bandwidth"`
+	// Suspicious is set when the audio looks like a transcode from a lossy or lower resolution source,
+	// Reasons tells why
+	Suspicious bool     `json:"suspicious"`
+	Reasons    []string `json:"reasons,omitempty"`
+}
+
+// AnalyzeLossless decodes the audio estimating its effective bandwidth and bit depth, to flag files
+// that are likely transcodes from lossy sources: lossy encoders cut the spectrum well below the
+// Nyquist frequency, e.g. at 16 kHz for 128 kbps MP3, while padded files leave their lowest bits unused.
+// It's a heuristic, recordings with no high frequency content can be flagged as well.
+func (flac *Flac) AnalyzeLossless() (*LosslessReport, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer := newSpectrumAnalyzer(int(streamInfo.Channels), streamInfo.BitsPerSample)
+	err = flac.decodeOriginalFrames(streamInfo, func(samples [][]int32) error {
+		analyzer.add(samples)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LosslessReport{SampleRate: streamInfo.SampleRate, BitsPerSample: streamInfo.BitsPerSample}
+	if analyzer.used == 0 {
+		return report, nil
+	}
+	report.EffectiveBitsPerSample = streamInfo.BitsPerSample - uint8(bits.TrailingZeros32(analyzer.used))
+	report.Bandwidth = analyzer.bandwidth(report.EffectiveBitsPerSample) * float64(streamInfo.SampleRate) / 2
+
+	nyquist := float64(streamInfo.SampleRate) / 2
+	if report.Bandwidth > 0 && report.Bandwidth < 0.9*nyquist {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("the spectrum stops at %.1f kHz, below the Nyquist frequency of %.1f kHz", report.Bandwidth/1000, nyquist/1000))
+	}
+	if report.EffectiveBitsPerSample < report.BitsPerSample {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("only %d of %d bits per sample are used", report.EffectiveBitsPerSample, report.BitsPerSample))
+	}
+	report.Suspicious = len(report.Reasons) > 0
+
+	return report, nil
+}
+
+// spectrumAnalyzer averages the power spectrum of the channels over windows of spectrumSize samples
+type spectrumAnalyzer struct {
+	window  []float64
+	scale   float64
+	buffers [][]float64
+	filled  int
+	// power is the sum of the power of every bin up to the Nyquist frequency
+	power   []float64
+	windows int
+	// used has the bits set in any sample
+	used uint32
+}
+
+func newSpectrumAnalyzer(channels int, bitsPerSample uint8) *spectrumAnalyzer {
+	a := &spectrumAnalyzer{
+		window:  make([]float64, spectrumSize),
+		scale:   1 / float64(int64(1)<<(bitsPerSample-1)),
+		buffers: make([][]float64, channels),
+		power:   make([]float64, spectrumSize/2+1),
+	}
+	// Hann window
+	for i := range a.window {
+		a.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/spectrumSize)
+	}
+	for ch := range a.buffers {
+		a.buffers[ch] = make([]float64, spectrumSize)
+	}
+	return a
+}
+
+func (a *spectrumAnalyzer) add(samples [][]int32) {
+	for i := range samples[0] {
+		for ch := range samples {
+			a.used |= uint32(samples[ch][i])
+			a.buffers[ch][a.filled] = float64(samples[ch][i]) * a.scale
+		}
+		a.filled++
+		if a.filled == spectrumSize {
+			a.analyze()
+			a.filled = 0
+		}
+	}
+}
+
+// analyze adds the power spectrum of the full buffers
+func (a *spectrumAnalyzer) analyze() {
+	re := make([]float64, spectrumSize)
+	im := make([]float64, spectrumSize)
+	for _, buffer := range a.buffers {
+		for i := range re {
+			re[i], im[i] = buffer[i]*a.window[i], 0
+		}
+		fft(re, im)
+		for bin := range a.power {
+			a.power[bin] += re[bin]*re[bin] + im[bin]*im[bin]
+		}
+	}
+	a.windows++
+}
+
+// bandwidth returns the highest frequency, as a fraction of the Nyquist frequency, whose average
+// power is 10 dB above the power white quantization noise at effectiveBits would have
+func (a *spectrumAnalyzer) bandwidth(effectiveBits uint8) float64 {
+	if a.windows == 0 {
+		return 0
+	}
+
+	// The noise of a uniform quantizer has a variance of 1/12 of the step squared, each bin gets it
+	// multiplied by the energy of the window, 3/8 of its length for Hann
+	step := 1 / float64(int64(1)<<(effectiveBits-1))
+	floor := step * step / 12 * spectrumSize * 3 / 8
+	threshold := floor * 10 * float64(a.windows*len(a.buffers))
+
+	// Bins are averaged in bands of about 1/128 of the spectrum to smooth out single tones
+	const band = spectrumSize / 256
+	for end := len(a.power) - 1; end >= band; end -= band {
+		sum := 0.0
+		for _, power := range a.power[end-band+1 : end+1] {
+			sum += power
+		}
+		if sum/band > threshold {
+			return float64(end) / float64(len(a.power)-1)
+		}
+	}
+	return 0
+}
+
+// fft computes in place the discrete Fourier transform of the complex values re + i*im,
+// whose length is a power of 2
+func fft(re, im []float64) {
+	n := len(re)
+
+	// Bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		angle := -2 * math.Pi / float64(length)
+		wRe, wIm := math.Cos(angle), math.Sin(angle)
+		for start := 0; start < n; start += length {
+			uRe, uIm := 1.0, 0.0
+			for k := 0; k < length/2; k++ {
+				a, b := start+k, start+k+length/2
+				tRe := re[b]*uRe - im[b]*uIm
+				tIm := re[b]*uIm + im[b]*uRe
+				re[b], im[b] = re[a]-tRe, im[a]-tIm
+				re[a], im[a] = re[a]+tRe, im[a]+tIm
+				uRe, uIm = uRe*wRe-uIm*wIm, uRe*wIm+uIm*wRe
+			}
+		}
+	}
+}
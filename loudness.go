@@ -0,0 +1,153 @@
+package flacgo
+
+import "math"
+
+// biquad is a second order IIR filter in direct form I
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the two stages of the K-weighting filter of ITU-R BS.1770 for sampleRate:
+// a high shelf modeling the head followed by a high-pass filter
+func kWeighting(sampleRate uint32) [2]biquad {
+	rate := float64(sampleRate)
+
+	k := math.Tan(math.Pi * 1681.974450955533 / rate)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * 38.13547087602444 / rate)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return [2]biquad{shelf, highPass}
+}
+
+// channelWeight returns the weight of a channel in the FLAC channel order: 0 for the LFE channel,
+// 1.41 for the surround channels and 1 for the front ones
+func channelWeight(channels int, ch int) float64 {
+	switch {
+	case channels >= 6 && ch == 3:
+		return 0
+	case channels == 5 && ch >= 3, channels >= 6 && ch >= 4:
+		return 1.41
+	}
+	return 1
+}
+
+// loudnessMeter measures the loudness of audio as defined by ITU-R BS.1770 and EBU R128: the mean
+// square of the K-weighted samples over gating blocks of 400ms overlapping by 75%
+type loudnessMeter struct {
+	filters   [][2]biquad
+	weights   []float64
+	scale     float64
+	blockSize int
+
+	// energies are the weighted sums of squares of the complete 100ms steps
+	energies []float64
+	current  float64
+	counted  int
+
+	// peak is the highest absolute sample value, 1 being full scale
+	peak float64
+}
+
+func newLoudnessMeter(streamInfo *StreamInfo) *loudnessMeter {
+	channels := int(streamInfo.Channels)
+	m := &loudnessMeter{
+		filters:   make([][2]biquad, channels),
+		weights:   make([]float64, channels),
+		scale:     1 / float64(int64(1)<<(streamInfo.BitsPerSample-1)),
+		blockSize: max(1, int(streamInfo.SampleRate)/10),
+	}
+	for ch := range m.filters {
+		m.filters[ch] = kWeighting(streamInfo.SampleRate)
+		m.weights[ch] = channelWeight(channels, ch)
+	}
+	return m
+}
+
+// add measures the samples of a frame
+func (m *loudnessMeter) add(samples [][]int32) {
+	for i := range samples[0] {
+		for ch := range samples {
+			x := float64(samples[ch][i]) * m.scale
+			m.peak = max(m.peak, math.Abs(x))
+
+			filters := &m.filters[ch]
+			y := filters[1].filter(filters[0].filter(x))
+			m.current += m.weights[ch] * y * y
+		}
+
+		m.counted++
+		if m.counted == m.blockSize {
+			m.energies = append(m.energies, m.current)
+			m.current, m.counted = 0, 0
+		}
+	}
+}
+
+// blocks returns the mean square of every gating block
+func (m *loudnessMeter) blocks() []float64 {
+	var blocks []float64
+	for i := 3; i < len(m.energies); i++ {
+		sum := m.energies[i-3] + m.energies[i-2] + m.energies[i-1] + m.energies[i]
+		blocks = append(blocks, sum/float64(4*m.blockSize))
+	}
+	return blocks
+}
+
+// blockLoudness returns the loudness in LUFS of a mean square
+func blockLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// integratedLoudness returns the gated loudness in LUFS of blocks: the blocks below -70 LUFS, then
+// those 10 LU below the loudness of the remaining ones, are discarded. It returns false if no block
+// is left, i.e. for silence or audio shorter than 400ms.
+func integratedLoudness(blocks []float64) (float64, bool) {
+	gated := func(threshold float64) (float64, int) {
+		sum, count := 0.0, 0
+		for _, power := range blocks {
+			if power > 0 && blockLoudness(power) > threshold {
+				sum += power
+				count++
+			}
+		}
+		return sum, count
+	}
+
+	sum, count := gated(-70)
+	if count == 0 {
+		return 0, false
+	}
+	sum, count = gated(blockLoudness(sum/float64(count)) - 10)
+	if count == 0 {
+		return 0, false
+	}
+	return blockLoudness(sum / float64(count)), true
+}
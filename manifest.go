@@ -0,0 +1,231 @@
+package flacgo
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+const manifestHeader = "# flacgo manifest v1"
+
+// ManifestEntry holds the checksums of a single file of a manifest
+type ManifestEntry struct {
+	// Path is relative to the root the manifest was built from, with '/' separators
+	Path string
+	// AudioMD5 is the MD5 of the encoded audio frames as stored in the file
+	AudioMD5 string
+	// FileSHA256 is the SHA-256 of the whole file
+	FileSHA256 string
+	// TagHash is the SHA-256 of all the metadata blocks except STREAMINFO and PADDING
+	TagHash string
+}
+
+// Manifest is a list of checksums for a library of FLAC files
+type Manifest struct {
+	Entries []ManifestEntry
+}
+
+// ManifestStatus is the outcome of verifying a single manifest entry
+type ManifestStatus int
+
+const (
+	// ManifestOK means the file is byte-identical to when the manifest was built
+	ManifestOK ManifestStatus = iota
+	// ManifestLayoutChanged means audio and tags are the same but the file changed, e.g. padding or block order
+	ManifestLayoutChanged
+	// ManifestTagsChanged means the metadata changed while the audio is untouched
+	ManifestTagsChanged
+	// ManifestAudioChanged means the audio frames changed, which usually means corruption
+	ManifestAudioChanged
+	// ManifestMissing means the file doesn't exist anymore
+	ManifestMissing
+	// ManifestUnreadable means the file exists but can't be read as FLAC
+	ManifestUnreadable
+)
+
+func (status ManifestStatus) String() string {
+	switch status {
+	case ManifestOK:
+		return "ok"
+	case ManifestLayoutChanged:
+		return "layout changed"
+	case ManifestTagsChanged:
+		return "tags changed"
+	case ManifestAudioChanged:
+		return "audio changed"
+	case ManifestMissing:
+		return "missing"
+	case ManifestUnreadable:
+		return "unreadable"
+	}
+	return fmt.Sprintf("status(%d)", int(status))
+}
+
+// ManifestResult is the outcome of verifying a manifest entry against the file on disk
+type ManifestResult struct {
+	Entry  ManifestEntry
+	Status ManifestStatus
+	Err    error
+}
+
+// NewManifestEntry computes the checksums of the FLAC file at path
+func NewManifestEntry(path string) (*ManifestEntry, error) {
+	flac, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	fileHash := sha256.New()
+	if _, err := io.Copy(fileHash, io.NewSectionReader(flac.file, 0, flac.fileSize)); err != nil {
+		return nil, fmt.Errorf("unable to hash file: %w", err)
+	}
+
+	metadataEnd, err := flac.getMetadataEndOffset()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get metadata end offset: %w", err)
+	}
+	audioHash := md5.New()
+	audio := io.NewSectionReader(flac.file, metadataEnd, flac.getAudioEndOffset()-metadataEnd)
+	if _, err := io.Copy(audioHash, audio); err != nil {
+		return nil, fmt.Errorf("unable to hash audio: %w", err)
+	}
+
+	blocks, err := flac.readAllMetadataBlocksWithData()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+	tagHash := sha256.New()
+	for _, block := range GetFilteredBlocks(blocks, []string{"STREAMINFO", "PADDING"}) {
+		tagHash.Write([]byte{block.BlockHeader.BlockType})
+		tagHash.Write(block.BlockData)
+	}
+
+	return &ManifestEntry{
+		Path:       filepath.ToSlash(path),
+		AudioMD5:   hex.EncodeToString(audioHash.Sum(nil)),
+		FileSHA256: hex.EncodeToString(fileHash.Sum(nil)),
+		TagHash:    hex.EncodeToString(tagHash.Sum(nil)),
+	}, nil
+}
+
+// BuildManifest walks root and computes the checksums of every .flac file found
+func BuildManifest(root string) (*Manifest, error) {
+	manifest := &Manifest{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".flac") {
+			return nil
+		}
+
+		manifestEntry, err := NewManifestEntry(path)
+		if err != nil {
+			return fmt.Errorf("unable to add '%s' to manifest: %w", path, err)
+		}
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		manifestEntry.Path = filepath.ToSlash(relative)
+		manifest.Entries = append(manifest.Entries, *manifestEntry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// WriteTo writes the manifest as text, one tab separated line per file
+func (manifest *Manifest) WriteTo(w io.Writer) (int64, error) {
+	var out strings.Builder
+	out.WriteString(manifestHeader + "\n")
+	for _, entry := range manifest.Entries {
+		fmt.Fprintf(&out, "%s\t%s\t%s\t%s\n", entry.AudioMD5, entry.FileSHA256, entry.TagHash, entry.Path)
+	}
+
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+// ReadManifest parses a manifest written by Manifest.WriteTo
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	manifest := &Manifest{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if line == 1 {
+			if text != manifestHeader {
+				return nil, fmt.Errorf("invalid manifest header %q", text)
+			}
+			continue
+		}
+		if text == "" {
+			continue
+		}
+
+		fields := strings.SplitN(text, "\t", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("malformed manifest line %d", line)
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			AudioMD5:   fields[0],
+			FileSHA256: fields[1],
+			TagHash:    fields[2],
+			Path:       fields[3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Verify checks every entry of the manifest against the files found under root
+func (manifest *Manifest) Verify(root string) []ManifestResult {
+	results := make([]ManifestResult, 0, len(manifest.Entries))
+
+	for _, entry := range manifest.Entries {
+		result := ManifestResult{Entry: entry}
+		path := filepath.Join(root, filepath.FromSlash(entry.Path))
+
+		current, err := NewManifestEntry(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			result.Status = ManifestMissing
+			result.Err = err
+		case err != nil:
+			result.Status = ManifestUnreadable
+			result.Err = err
+		case current.AudioMD5 != entry.AudioMD5:
+			result.Status = ManifestAudioChanged
+		case current.TagHash != entry.TagHash:
+			result.Status = ManifestTagsChanged
+		case current.FileSHA256 != entry.FileSHA256:
+			result.Status = ManifestLayoutChanged
+		default:
+			result.Status = ManifestOK
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
@@ -0,0 +1,569 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Matroska element ids used to find the FLAC track, its frames and the tags
+const (
+	mkvEBML         = 0x1A45DFA3
+	mkvDocType      = 0x4282
+	mkvSegment      = 0x18538067
+	mkvTracks       = 0x1654AE6B
+	mkvTrackEntry   = 0xAE
+	mkvTrackNumber  = 0xD7
+	mkvCodecID      = 0x86
+	mkvCodecPrivate = 0x63A2
+	mkvCluster      = 0x1F43B675
+	mkvSimpleBlock  = 0xA3
+	mkvBlockGroup   = 0xA0
+	mkvBlock        = 0xA1
+	mkvTags         = 0x1254C367
+	mkvTag          = 0x7373
+	mkvSimpleTag    = 0x67C8
+	mkvTagName      = 0x45A3
+	mkvTagString    = 0x4487
+)
+
+// mkvTopLevel are the ids of the children of a Segment, they end a Cluster of unknown size
+var mkvTopLevel = map[uint32]bool{
+	0x114D9B74: true, // SeekHead
+	0x1549A966: true, // Info
+	mkvTracks:  true,
+	mkvCluster: true,
+	0x1C53BB6B: true, // Cues
+	0x1941A469: true, // Attachments
+	0x1043A770: true, // Chapters
+	mkvTags:    true,
+}
+
+// OpenMatroska opens the FLAC track of a Matroska audio file (.mka, .mkv) or WebM file, read-only.
+// The FLAC stream is served straight from the container: the metadata blocks of the codec private
+// data followed by the frames of the track, so stream info, tags, pictures and audio can be read and
+// WriteTo extracts a plain FLAC file. When the codec private data has no comments, the Matroska tags
+// of the file, like TITLE or ARTIST, are returned as comments instead.
+func OpenMatroska(path string, opts ...Option) (*Flac, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize flacgo: %w", err)
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to stat file %w", err)
+	}
+
+	src, err := readMatroska(f, fileInfo.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read Matroska file: %w", err)
+	}
+
+	flac, err := newFlac(src, path, src.Size(), append(opts, func(o *options) {
+		o.readOnly = true
+	}))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return flac, nil
+}
+
+// mkvReader walks the EBML elements of a Matroska file
+type mkvReader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// vint reads a variable length integer at offset, returning its value without the length marker,
+// its length and whether all its value bits are set, which means unknown for element sizes
+func (m *mkvReader) vint(offset int64) (uint64, int, bool, error) {
+	first := make([]byte, 1)
+	if _, err := m.r.ReadAt(first, offset); err != nil {
+		return 0, 0, false, fmt.Errorf("unable to read at offset %d: %w", offset, err)
+	}
+	length := 1
+	for length <= 8 && first[0]&(0x80>>(length-1)) == 0 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, false, fmt.Errorf("invalid variable length integer at offset %d", offset)
+	}
+
+	data := make([]byte, length)
+	if _, err := m.r.ReadAt(data, offset); err != nil {
+		return 0, 0, false, fmt.Errorf("unable to read at offset %d: %w", offset, err)
+	}
+	value := uint64(data[0] & (0xFF >> length))
+	for _, b := range data[1:] {
+		value = value<<8 | uint64(b)
+	}
+	return value, length, value == 1<<(7*length)-1, nil
+}
+
+// element reads the header of the element at offset: its id, the offset of its data and its end,
+// which is limit for elements of unknown size
+func (m *mkvReader) element(offset int64, limit int64) (uint32, int64, int64, error) {
+	// Ids keep their length marker
+	_, idLength, _, err := m.vint(offset)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	// EBML ids are at most 4 bytes long
+	if idLength > 4 {
+		return 0, 0, 0, fmt.Errorf("invalid element id at offset %d", offset)
+	}
+	idBytes := make([]byte, 4)
+	if _, err := m.r.ReadAt(idBytes[4-idLength:], offset); err != nil {
+		return 0, 0, 0, fmt.Errorf("unable to read element id at offset %d: %w", offset, err)
+	}
+	id := binary.BigEndian.Uint32(idBytes)
+
+	size, sizeLength, unknown, err := m.vint(offset + int64(idLength))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	dataStart := offset + int64(idLength+sizeLength)
+	if unknown {
+		return id, dataStart, limit, nil
+	}
+	end := dataStart + int64(size)
+	if end > limit || end < dataStart {
+		return 0, 0, 0, fmt.Errorf("element 0x%X at offset %d ends beyond its parent", id, offset)
+	}
+	return id, dataStart, end, nil
+}
+
+// children calls fn for every child element of the data between start and end
+func (m *mkvReader) children(start int64, end int64, fn func(id uint32, dataStart int64, dataEnd int64) error) error {
+	for offset := start; offset < end; {
+		id, dataStart, dataEnd, err := m.element(offset, end)
+		if err != nil {
+			return err
+		}
+		if err := fn(id, dataStart, dataEnd); err != nil {
+			return err
+		}
+		offset = dataEnd
+	}
+	return nil
+}
+
+// bytes reads the data of an element
+func (m *mkvReader) bytes(start int64, end int64) ([]byte, error) {
+	if end-start > maxBlockLength {
+		return nil, fmt.Errorf("element at offset %d is too large", start)
+	}
+	data := make([]byte, end-start)
+	if _, err := m.r.ReadAt(data, start); err != nil {
+		return nil, fmt.Errorf("unable to read at offset %d: %w", start, err)
+	}
+	return data, nil
+}
+
+// uint reads the data of an unsigned integer element
+func (m *mkvReader) uint(start int64, end int64) (uint64, error) {
+	data, err := m.bytes(start, end)
+	if err != nil || len(data) > 8 {
+		return 0, fmt.Errorf("invalid integer at offset %d", start)
+	}
+	value := uint64(0)
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
+// readMatroska finds the FLAC track of the Matroska file held by r and returns the FLAC stream it holds
+func readMatroska(r io.ReaderAt, size int64) (*concatSource, error) {
+	m := &mkvReader{r: r, size: size}
+
+	id, headerStart, headerEnd, err := m.element(0, size)
+	if err != nil || id != mkvEBML {
+		return nil, fmt.Errorf("not a Matroska file")
+	}
+	docType := ""
+	err = m.children(headerStart, headerEnd, func(id uint32, start int64, end int64) error {
+		if id == mkvDocType {
+			data, err := m.bytes(start, end)
+			docType = string(bytes.TrimRight(data, "\x00"))
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if docType != "matroska" && docType != "webm" {
+		return nil, fmt.Errorf("unsupported document type %q", docType)
+	}
+
+	id, segmentStart, segmentEnd, err := m.element(headerEnd, size)
+	if err != nil {
+		return nil, err
+	}
+	if id != mkvSegment {
+		return nil, fmt.Errorf("missing Segment element")
+	}
+
+	var codecPrivate []byte
+	var trackNumber uint64
+	var tags []VorbisComment
+	var frames []concatPiece
+
+	for offset := segmentStart; offset < segmentEnd; {
+		id, start, end, err := m.element(offset, segmentEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		switch id {
+		case mkvTracks:
+			if trackNumber == 0 {
+				if trackNumber, codecPrivate, err = m.flacTrack(start, end); err != nil {
+					return nil, err
+				}
+			}
+		case mkvCluster:
+			if trackNumber == 0 {
+				return nil, fmt.Errorf("Cluster found before the tracks")
+			}
+			if end, err = m.cluster(start, end, trackNumber, &frames); err != nil {
+				return nil, err
+			}
+		case mkvTags:
+			if tags, err = m.tags(start, end); err != nil {
+				return nil, err
+			}
+		}
+		offset = end
+	}
+
+	if trackNumber == 0 {
+		return nil, fmt.Errorf("no FLAC track found")
+	}
+
+	metadata, err := matroskaMetadata(codecPrivate, tags)
+	if err != nil {
+		return nil, err
+	}
+	return newConcatSource(r, append([]concatPiece{{data: metadata}}, frames...)), nil
+}
+
+// flacTrack returns the number and the codec private data of the first FLAC track of a Tracks element
+func (m *mkvReader) flacTrack(start int64, end int64) (uint64, []byte, error) {
+	var trackNumber uint64
+	var codecPrivate []byte
+
+	err := m.children(start, end, func(id uint32, start int64, end int64) error {
+		if id != mkvTrackEntry || trackNumber != 0 {
+			return nil
+		}
+
+		var number uint64
+		var codecID string
+		var private []byte
+		err := m.children(start, end, func(id uint32, start int64, end int64) error {
+			var err error
+			switch id {
+			case mkvTrackNumber:
+				number, err = m.uint(start, end)
+			case mkvCodecID:
+				var data []byte
+				data, err = m.bytes(start, end)
+				codecID = string(bytes.TrimRight(data, "\x00"))
+			case mkvCodecPrivate:
+				private, err = m.bytes(start, end)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if codecID == "A_FLAC" {
+			trackNumber, codecPrivate = number, private
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if trackNumber != 0 && !bytes.HasPrefix(codecPrivate, []byte("fLaC")) {
+		return 0, nil, fmt.Errorf("FLAC track %d has no FLAC header in its codec private data", trackNumber)
+	}
+	return trackNumber, codecPrivate, nil
+}
+
+// cluster appends the frames of the track of a Cluster to frames and returns the end of the cluster,
+// found at the next top level element when its size is unknown
+func (m *mkvReader) cluster(start int64, end int64, trackNumber uint64, frames *[]concatPiece) (int64, error) {
+	for offset := start; offset < end; {
+		id, dataStart, dataEnd, err := m.element(offset, end)
+		if err != nil {
+			return 0, err
+		}
+		if mkvTopLevel[id] {
+			return offset, nil
+		}
+
+		switch id {
+		case mkvSimpleBlock:
+			if err := m.block(dataStart, dataEnd, trackNumber, frames); err != nil {
+				return 0, err
+			}
+		case mkvBlockGroup:
+			err := m.children(dataStart, dataEnd, func(id uint32, start int64, end int64) error {
+				if id == mkvBlock {
+					return m.block(start, end, trackNumber, frames)
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+		offset = dataEnd
+	}
+	return end, nil
+}
+
+// block appends the frames of a Block or SimpleBlock of the track to frames. Laced frames are stored
+// one after the other, so the frames of a block are always a single range after the lacing sizes.
+func (m *mkvReader) block(start int64, end int64, trackNumber uint64, frames *[]concatPiece) error {
+	number, length, _, err := m.vint(start)
+	if err != nil {
+		return err
+	}
+	if number != trackNumber {
+		return nil
+	}
+
+	// Timecode (2 bytes) and flags
+	offset := start + int64(length) + 2
+	header, err := m.bytes(offset, min(offset+1, end))
+	if err != nil || len(header) == 0 {
+		return fmt.Errorf("truncated block at offset %d", start)
+	}
+	lacing := header[0] & 0x06
+	offset++
+
+	if lacing != 0 {
+		count, err := m.bytes(offset, min(offset+1, end))
+		if err != nil || len(count) == 0 {
+			return fmt.Errorf("truncated block at offset %d", start)
+		}
+		offset++
+		laced := int(count[0]) + 1
+
+		switch lacing {
+		case 0x02: // Xiph lacing, every size but the last one as a sum of bytes up to the first below 255
+			for i := 0; i < laced-1; i++ {
+				for {
+					b, err := m.bytes(offset, min(offset+1, end))
+					if err != nil || len(b) == 0 {
+						return fmt.Errorf("truncated lacing at offset %d", start)
+					}
+					offset++
+					if b[0] != 255 {
+						break
+					}
+				}
+			}
+		case 0x06: // EBML lacing, the first size then differences, all as variable length integers
+			for i := 0; i < laced-1; i++ {
+				_, length, _, err := m.vint(offset)
+				if err != nil {
+					return err
+				}
+				offset += int64(length)
+			}
+		}
+	}
+
+	if offset > end {
+		return fmt.Errorf("truncated block at offset %d", start)
+	}
+	*frames = append(*frames, concatPiece{offset: offset, length: end - offset})
+	return nil
+}
+
+// tags returns the simple tags of a Tags element as comments
+func (m *mkvReader) tags(start int64, end int64) ([]VorbisComment, error) {
+	var comments []VorbisComment
+	err := m.children(start, end, func(id uint32, start int64, end int64) error {
+		if id != mkvTag {
+			return nil
+		}
+		return m.children(start, end, func(id uint32, start int64, end int64) error {
+			if id != mkvSimpleTag {
+				return nil
+			}
+			var comment VorbisComment
+			err := m.children(start, end, func(id uint32, start int64, end int64) error {
+				if id != mkvTagName && id != mkvTagString {
+					return nil
+				}
+				data, err := m.bytes(start, end)
+				value := string(bytes.TrimRight(data, "\x00"))
+				if id == mkvTagName {
+					comment.Title = value
+				} else {
+					comment.Value = value
+				}
+				return err
+			})
+			if err == nil && comment.Title != "" {
+				comments = append(comments, comment)
+			}
+			return err
+		})
+	})
+	return comments, err
+}
+
+// matroskaMetadata returns the FLAC header and metadata blocks of the codec private data, with a
+// VORBIS_COMMENT block holding tags after STREAMINFO if the codec private data has no comments
+func matroskaMetadata(codecPrivate []byte, tags []VorbisComment) ([]byte, error) {
+	type block struct {
+		header []byte
+		body   []byte
+	}
+	var blocks []block
+	for offset := 4; ; {
+		if len(codecPrivate) < offset+4 {
+			return nil, fmt.Errorf("truncated metadata block in codec private data")
+		}
+		header := codecPrivate[offset : offset+4]
+		length := int(binary.BigEndian.Uint32([]byte{0, header[1], header[2], header[3]}))
+		if len(codecPrivate) < offset+4+length {
+			return nil, fmt.Errorf("truncated metadata block in codec private data")
+		}
+		blocks = append(blocks, block{bytes.Clone(header), codecPrivate[offset+4 : offset+4+length]})
+		offset += 4 + length
+		if header[0]&0x80 != 0 {
+			break
+		}
+	}
+	if blocks[0].header[0]&0x7F != 0 {
+		return nil, fmt.Errorf("codec private data doesn't start with STREAMINFO")
+	}
+
+	hasComments := false
+	for i, b := range blocks {
+		if b.header[0]&0x7F != 4 {
+			continue
+		}
+		if comments, err := (&Flac{}).parseVorbisBlock(b.body); err == nil && len(comments) > 0 {
+			hasComments = true
+		} else if len(tags) > 0 {
+			// Replaced by the Matroska tags
+			blocks = append(blocks[:i], blocks[i+1:]...)
+		}
+		break
+	}
+	if !hasComments && len(tags) > 0 {
+		body := binary.LittleEndian.AppendUint32(nil, uint32(len(vendorString)))
+		body = append(body, vendorString...)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(tags)))
+		for _, tag := range tags {
+			body = binary.LittleEndian.AppendUint32(body, uint32(len(tag.Title)+1+len(tag.Value)))
+			body = append(body, tag.Title+"="+tag.Value...)
+		}
+		if len(body) > maxBlockLength {
+			return nil, &BlockTooLargeError{BlockType: "VORBIS_COMMENT", Size: len(body)}
+		}
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(body)))
+		header[0] = 4 // 4 = VORBIS_COMMENT
+		blocks = append(blocks[:1], append([]block{{header, body}}, blocks[1:]...)...)
+	}
+
+	metadata := []byte("fLaC")
+	for i, b := range blocks {
+		b.header[0] &^= 0x80
+		if i == len(blocks)-1 {
+			b.header[0] |= 0x80
+		}
+		metadata = append(metadata, b.header...)
+		metadata = append(metadata, b.body...)
+	}
+	return metadata, nil
+}
+
+// concatPiece is a part of a concatSource, data or a range of the underlying file
+type concatPiece struct {
+	data   []byte
+	offset int64
+	length int64
+}
+
+// concatSource is a virtual file made of pieces of data and ranges of another file
+type concatSource struct {
+	r      io.ReaderAt
+	pieces []concatPiece
+	starts []int64
+	size   int64
+}
+
+// newConcatSource lays pieces one after the other, the ranges are read from r
+func newConcatSource(r io.ReaderAt, pieces []concatPiece) *concatSource {
+	src := &concatSource{r: r, pieces: pieces, starts: make([]int64, len(pieces))}
+	for i := range pieces {
+		if pieces[i].data != nil {
+			pieces[i].length = int64(len(pieces[i].data))
+		}
+		src.starts[i] = src.size
+		src.size += pieces[i].length
+	}
+	return src
+}
+
+// Size returns the length of the virtual file
+func (src *concatSource) Size() int64 {
+	return src.size
+}
+
+// ReadAt implements io.ReaderAt over the pieces
+func (src *concatSource) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+
+	n := 0
+	i := sort.Search(len(src.starts), func(i int) bool {
+		return src.starts[i]+src.pieces[i].length > off
+	})
+	for ; n < len(p) && i < len(src.pieces); i++ {
+		piece := src.pieces[i]
+		within := off + int64(n) - src.starts[i]
+		want := p[n:min(len(p), n+int(piece.length-within))]
+
+		if piece.data != nil {
+			n += copy(want, piece.data[within:])
+			continue
+		}
+		read, err := src.r.ReadAt(want, piece.offset+within)
+		n += read
+		if err != nil && !(err == io.EOF && read == len(want)) {
+			return n, err
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close closes the underlying file
+func (src *concatSource) Close() error {
+	if closer, ok := src.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package flacgo
+
+import "os"
+
+// mmapFile falls back to regular file IO where memory-mapping is not supported
+func mmapFile(f *os.File, size int64) (source, error) {
+	return f, nil
+}
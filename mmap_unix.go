@@ -0,0 +1,35 @@
+//go:build unix
+
+package flacgo
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+// mappedFile is a read-only memory-mapped view of a file
+type mappedFile struct {
+	*bytes.Reader
+	data []byte
+	file *os.File
+}
+
+// mmapFile maps the first size bytes of f in memory
+func mmapFile(f *os.File, size int64) (source, error) {
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mappedFile{Reader: bytes.NewReader(data), data: data, file: f}, nil
+}
+
+// Close unmaps the view and closes the underlying file
+func (m *mappedFile) Close() error {
+	err := syscall.Munmap(m.data)
+	if closeErr := m.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Header type flags of an Ogg page
+const (
+	oggContinued = 0x01
+	oggBOS       = 0x02
+	oggEOS       = 0x04
+)
+
+// oggMaxSegments is the number of lacing values an Ogg page can hold, of up to 255 bytes each
+const oggMaxSegments = 255
+
+// oggCRCTable is the lookup table of the CRC-32 of Ogg pages (polynomial 0x04C11DB7, not reflected)
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// oggCRC returns the CRC-32 of an Ogg page, whose checksum field must be zero
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggPacketWriter splits the packets of a logical Ogg stream into pages
+type oggPacketWriter struct {
+	w        io.Writer
+	serial   uint32
+	sequence uint32
+	started  bool
+}
+
+// writePacket writes packet on pages of its own, granule is the position of the last page,
+// which is flagged as the end of the stream if eos is set
+func (ow *oggPacketWriter) writePacket(packet []byte, granule int64, eos bool) error {
+	continued := false
+	for {
+		// A packet ends with a lacing value lower than 255, zero when its length is a multiple of 255
+		segments := min(len(packet)/255+1, oggMaxSegments)
+		complete := len(packet)/255+1 <= oggMaxSegments
+		size := min(len(packet), segments*255)
+
+		page := make([]byte, 27+segments, 27+segments+size)
+		copy(page, "OggS")
+		if continued {
+			page[5] |= oggContinued
+		}
+		if !ow.started {
+			page[5] |= oggBOS
+			ow.started = true
+		}
+		position := int64(-1)
+		if complete {
+			position = granule
+			if eos {
+				page[5] |= oggEOS
+			}
+		}
+		binary.LittleEndian.PutUint64(page[6:], uint64(position))
+		binary.LittleEndian.PutUint32(page[14:], ow.serial)
+		binary.LittleEndian.PutUint32(page[18:], ow.sequence)
+		page[26] = byte(segments)
+		for i := 0; i < segments; i++ {
+			page[27+i] = byte(min(255, size-i*255))
+		}
+		page = append(page, packet[:size]...)
+		binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+
+		if _, err := ow.w.Write(page); err != nil {
+			return err
+		}
+		ow.sequence++
+		packet = packet[size:]
+		if complete {
+			return nil
+		}
+		continued = true
+	}
+}
+
+// oggPage is the header of an Ogg page of a file
+type oggPage struct {
+	offset     int64
+	headerType byte
+	granule    int64
+	serial     uint32
+	sequence   uint32
+	// segments are the lacing values, the data of the page follows them at dataOffset
+	segments   []byte
+	dataOffset int64
+}
+
+// length returns the size of the whole page, header included
+func (page *oggPage) length() int64 {
+	size := int64(0)
+	for _, segment := range page.segments {
+		size += int64(segment)
+	}
+	return page.dataOffset - page.offset + size
+}
+
+// readOggPage reads the header of the page at offset
+func readOggPage(r io.ReaderAt, offset int64) (*oggPage, error) {
+	header := make([]byte, 27)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, fmt.Errorf("unable to read Ogg page at offset %d: %w", offset, err)
+	}
+	if string(header[:4]) != "OggS" {
+		return nil, fmt.Errorf("missing Ogg page at offset %d", offset)
+	}
+	if header[4] != 0 {
+		return nil, fmt.Errorf("unsupported Ogg version %d at offset %d", header[4], offset)
+	}
+
+	page := &oggPage{
+		offset:     offset,
+		headerType: header[5],
+		granule:    int64(binary.LittleEndian.Uint64(header[6:])),
+		serial:     binary.LittleEndian.Uint32(header[14:]),
+		sequence:   binary.LittleEndian.Uint32(header[18:]),
+		segments:   make([]byte, header[26]),
+		dataOffset: offset + 27 + int64(header[26]),
+	}
+	if _, err := r.ReadAt(page.segments, offset+27); err != nil {
+		return nil, fmt.Errorf("unable to read Ogg page at offset %d: %w", offset, err)
+	}
+	return page, nil
+}
+
+// oggPacket is a packet of a logical stream as the ranges of the file holding it
+type oggPacket struct {
+	pieces []concatPiece
+	length int64
+	// page is the index, among the pages of its stream, of the page where the packet ends
+	page int
+}
+
+// oggPackets splits the data of the pages of a logical stream into packets, a packet left
+// unfinished by the last page is dropped
+func oggPackets(pages []*oggPage) []*oggPacket {
+	var packets []*oggPacket
+	current := &oggPacket{}
+	for i, page := range pages {
+		offset := page.dataOffset
+		for _, segment := range page.segments {
+			if segment > 0 {
+				last := len(current.pieces) - 1
+				if last >= 0 && current.pieces[last].offset+current.pieces[last].length == offset {
+					current.pieces[last].length += int64(segment)
+				} else {
+					current.pieces = append(current.pieces, concatPiece{offset: offset, length: int64(segment)})
+				}
+				current.length += int64(segment)
+				offset += int64(segment)
+			}
+			if segment < 255 {
+				current.page = i
+				packets = append(packets, current)
+				current = &oggPacket{}
+			}
+		}
+	}
+	return packets
+}
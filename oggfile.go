@@ -0,0 +1,386 @@
+package flacgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// OggFile is an Ogg FLAC file (.oga, .ogg), possibly chained: several logical streams one after the
+// other, e.g. the tracks of a recorded radio stream, each with its own STREAMINFO and tags
+type OggFile struct {
+	file  *os.File
+	path  string
+	pages []oggFilePage
+	// Links are the FLAC streams of the file in order, logical streams of other codecs are kept as
+	// they are but not listed
+	Links []*OggLink
+}
+
+// oggFilePage is a page of an OggFile along the link it belongs to, nil for other codecs
+type oggFilePage struct {
+	page  *oggPage
+	link  *OggLink
+	index int
+}
+
+// OggLink is a FLAC logical stream of an Ogg file
+type OggLink struct {
+	// Serial is the serial number of the logical stream
+	Serial uint32
+	// Offset is the position of the first page of the link in the file
+	Offset int64
+	// Flac reads the link as a FLAC stream and stages the changes to its metadata, written by
+	// OggFile.Save. Its WriteTo extracts the link as a plain FLAC file.
+	Flac *Flac
+
+	pages []*oggPage
+	// headerEnd is the index of the page where the header packets end, aligned tells whether the
+	// audio starts on the following page, as the Ogg FLAC mapping requires
+	headerEnd int
+	aligned   bool
+}
+
+// readerAtOnly hides the Close method of a file shared by several sources
+type readerAtOnly struct {
+	io.ReaderAt
+}
+
+// OpenOgg opens an Ogg FLAC file, every FLAC logical stream of the chain becomes an OggLink whose
+// metadata can be read and edited separately, see Save. The audio is read straight from the pages.
+func OpenOgg(path string, opts ...Option) (*OggFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize flacgo: %w", err)
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to stat file %w", err)
+	}
+
+	ogg := &OggFile{file: f, path: path}
+	if err := ogg.readLinks(fileInfo.Size(), opts); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read Ogg file: %w", err)
+	}
+	return ogg, nil
+}
+
+// readLinks reads the pages of the file and opens its FLAC logical streams
+func (ogg *OggFile) readLinks(size int64, opts []Option) error {
+	// Logical streams begin with a BOS page and end with an EOS page, the serial number of
+	// a stream over can be used again by a later link
+	type stream struct {
+		link  *OggLink
+		first int
+	}
+	var streams []*stream
+	active := map[uint32]*stream{}
+
+	for offset := int64(0); offset < size; {
+		page, err := readOggPage(ogg.file, offset)
+		if err != nil {
+			return err
+		}
+		current := active[page.serial]
+		if page.headerType&oggBOS != 0 {
+			if current != nil {
+				return fmt.Errorf("stream %08x begins again at offset %d", page.serial, offset)
+			}
+			current = &stream{link: &OggLink{Serial: page.serial, Offset: offset}, first: len(ogg.pages)}
+			active[page.serial] = current
+			streams = append(streams, current)
+		}
+		if current == nil {
+			return fmt.Errorf("page at offset %d belongs to no stream", offset)
+		}
+		if page.headerType&oggEOS != 0 {
+			delete(active, page.serial)
+		}
+
+		current.link.pages = append(current.link.pages, page)
+		ogg.pages = append(ogg.pages, oggFilePage{page: page, link: current.link, index: len(current.link.pages) - 1})
+		offset += page.length()
+	}
+
+	for _, s := range streams {
+		isFLAC, err := s.link.open(ogg.file, opts)
+		if err != nil {
+			return fmt.Errorf("stream %08x at offset %d: %w", s.link.Serial, s.link.Offset, err)
+		}
+		if isFLAC {
+			ogg.Links = append(ogg.Links, s.link)
+			continue
+		}
+		for i := s.first; i < len(ogg.pages); i++ {
+			if ogg.pages[i].link == s.link {
+				ogg.pages[i].link = nil
+			}
+		}
+	}
+	return nil
+}
+
+// open parses the header packets of the link and opens it as a FLAC stream, it reports false
+// for logical streams of other codecs
+func (link *OggLink) open(r io.ReaderAt, opts []Option) (bool, error) {
+	packets := oggPackets(link.pages)
+	if len(packets) == 0 {
+		return false, nil
+	}
+	first, err := readOggPacket(r, packets[0])
+	if err != nil {
+		return false, err
+	}
+	if len(first) < 9 || string(first[:5]) != "\x7FFLAC" {
+		return false, nil
+	}
+	if first[5] != 1 {
+		return false, fmt.Errorf("unsupported Ogg FLAC mapping version %d.%d", first[5], first[6])
+	}
+	if len(first) < 9+4+4 || string(first[9:13]) != "fLaC" {
+		return false, fmt.Errorf("invalid Ogg FLAC header packet")
+	}
+
+	// The metadata blocks follow one per packet up to the one flagged as the last, the number
+	// of header packets of the mapping header can be 0 for unknown
+	metadata := first[9:]
+	isLast := first[13]&0x80 != 0
+	next := 1
+	for ; !isLast; next++ {
+		if next >= len(packets) {
+			return false, fmt.Errorf("the header packets end before the last metadata block")
+		}
+		packet, err := readOggPacket(r, packets[next])
+		if err != nil {
+			return false, err
+		}
+		if len(packet) < 4 {
+			return false, fmt.Errorf("invalid metadata block packet of %d bytes", len(packet))
+		}
+		metadata = append(metadata, packet...)
+		isLast = packet[0]&0x80 != 0
+	}
+
+	header := packets[next-1]
+	headerPage := link.pages[header.page]
+	lastPiece := header.pieces[len(header.pieces)-1]
+	link.headerEnd = header.page
+	link.aligned = lastPiece.offset+lastPiece.length == headerPage.offset+headerPage.length()
+
+	pieces := []concatPiece{{data: metadata}}
+	for _, packet := range packets[next:] {
+		for _, piece := range packet.pieces {
+			last := &pieces[len(pieces)-1]
+			if last.data == nil && last.offset+last.length == piece.offset {
+				last.length += piece.length
+			} else {
+				pieces = append(pieces, piece)
+			}
+		}
+	}
+
+	src := newConcatSource(readerAtOnly{r}, pieces)
+	link.Flac, err = newFlac(src, "", src.Size(), opts)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// readOggPacket reads the data of packet
+func readOggPacket(r io.ReaderAt, packet *oggPacket) ([]byte, error) {
+	data := make([]byte, 0, packet.length)
+	for _, piece := range packet.pieces {
+		buf := make([]byte, piece.length)
+		if _, err := r.ReadAt(buf, piece.offset); err != nil {
+			return nil, fmt.Errorf("unable to read Ogg packet at offset %d: %w", piece.offset, err)
+		}
+		data = append(data, buf...)
+	}
+	return data, nil
+}
+
+// Close closes the file
+func (ogg *OggFile) Close() error {
+	return ogg.file.Close()
+}
+
+// HasChanges reports whether changes are staged on any link
+func (ogg *OggFile) HasChanges() bool {
+	for _, link := range ogg.Links {
+		if link.Flac.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the Ogg file with the changes staged on its links to outputPath, or overwrites the
+// original file if outputPath is nil, through a temporary file like Flac.Save. The header pages of
+// the links with changes are rebuilt and their audio pages renumbered, the other links and the
+// logical streams of other codecs are copied as they are.
+func (ogg *OggFile) Save(outputPath *string) error {
+	outFileName := ogg.path
+	if outputPath != nil {
+		outFileName = *outputPath
+	}
+	if outFileName == ogg.path && !ogg.HasChanges() {
+		return nil
+	}
+	for _, link := range ogg.Links {
+		if link.Flac.HasChanges() {
+			if err := link.Flac.checkWritable(); err != nil {
+				return err
+			}
+		}
+	}
+
+	outFile, err := os.CreateTemp(filepath.Dir(outFileName), ".flacgo-*")
+	if err != nil {
+		return fmt.Errorf("unable to create file '%s': %w", outFileName, err)
+	}
+	tempName := outFile.Name()
+	defer os.Remove(tempName)
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(outFileName); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	_, err = ogg.WriteTo(outFile)
+	if err == nil {
+		err = outFile.Chmod(mode)
+	}
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempName, outFileName); err != nil {
+		return fmt.Errorf("unable to create file '%s': %w", outFileName, err)
+	}
+	return nil
+}
+
+// WriteTo writes the Ogg file with the changes staged on its links to w
+func (ogg *OggFile) WriteTo(w io.Writer) (int64, error) {
+	// Rebuild the headers of every changed link before writing anything
+	headers := map[*OggLink][][]byte{}
+	writers := map[*OggLink]*oggPacketWriter{}
+	for _, link := range ogg.Links {
+		if !link.Flac.HasChanges() {
+			continue
+		}
+		if !link.aligned {
+			return 0, fmt.Errorf("unable to write Ogg file: the audio of stream %08x starts on a header page", link.Serial)
+		}
+		packets, err := link.headerPackets()
+		if err != nil {
+			return 0, fmt.Errorf("unable to write Ogg file: %w", err)
+		}
+		headers[link] = packets
+	}
+
+	counter := &countingWriter{w: w}
+	bw := bufio.NewWriter(counter)
+	for _, filePage := range ogg.pages {
+		link, page := filePage.link, filePage.page
+		packets, changed := headers[link]
+		if changed && filePage.index <= link.headerEnd {
+			if filePage.index > 0 {
+				continue
+			}
+			writer := &oggPacketWriter{w: bw, serial: link.Serial}
+			writers[link] = writer
+			eos := link.headerEnd == len(link.pages)-1 && link.pages[link.headerEnd].headerType&oggEOS != 0
+			for i, packet := range packets {
+				if err := writer.writePacket(packet, 0, eos && i == len(packets)-1); err != nil {
+					return counter.n, fmt.Errorf("unable to write Ogg file: %w", err)
+				}
+			}
+			continue
+		}
+
+		data := make([]byte, page.length())
+		if _, err := ogg.file.ReadAt(data, page.offset); err != nil {
+			return counter.n, fmt.Errorf("unable to read Ogg page at offset %d: %w", page.offset, err)
+		}
+		if changed {
+			// The header pages changed in number, the audio pages follow them
+			writer := writers[link]
+			binary.LittleEndian.PutUint32(data[18:], writer.sequence)
+			binary.LittleEndian.PutUint32(data[22:], 0)
+			binary.LittleEndian.PutUint32(data[22:], oggCRC(data))
+			writer.sequence++
+		}
+		if _, err := bw.Write(data); err != nil {
+			return counter.n, fmt.Errorf("unable to write Ogg file: %w", err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return counter.n, fmt.Errorf("unable to write Ogg file: %w", err)
+	}
+	return counter.n, nil
+}
+
+// headerPackets returns the header packets of the link with the staged changes applied: the
+// mapping header holding STREAMINFO, then one packet per metadata block
+func (link *OggLink) headerPackets() ([][]byte, error) {
+	flac := link.Flac
+	if err := flac.stageTotalTags(); err != nil {
+		return nil, err
+	}
+	blocks, err := flac.outputBlocks()
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks)-1 > 0xFFFF {
+		return nil, fmt.Errorf("too many metadata blocks %d", len(blocks))
+	}
+
+	packets := make([][]byte, 0, len(blocks))
+	for i := range blocks {
+		var packet bytes.Buffer
+		if i == 0 {
+			packet.Write([]byte{0x7F, 'F', 'L', 'A', 'C', 1, 0, byte((len(blocks) - 1) >> 8), byte(len(blocks) - 1)})
+			packet.WriteString("fLaC")
+		}
+		packet.Write(blocks[i].BlockHeader.Data)
+		if blocks[i].BlockData != nil {
+			packet.Write(blocks[i].BlockData)
+		} else {
+			body, err := flac.openBlockBody(&blocks[i])
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.CopyN(&packet, body, blocks[i].bodyLength())
+			body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s block: %w", blocks[i].BlockType, err)
+			}
+		}
+		packets = append(packets, packet.Bytes())
+	}
+	return packets, nil
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
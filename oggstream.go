@@ -0,0 +1,196 @@
+package flacgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+// OggWriter encodes live audio to a chained Ogg FLAC stream, e.g. the source stream of an Icecast
+// mount: every track is a link of the chain, a logical stream starting with its own STREAMINFO and
+// VORBIS_COMMENT, which is how players and Icecast servers learn the now-playing tags of Ogg streams.
+// Frames are written as soon as they are encoded, one behind, so the last one can end the link.
+type OggWriter struct {
+	w             io.Writer
+	sampleRate    uint32
+	bitsPerSample uint8
+	channels      int
+	opts          EncoderOptions
+	serial        uint32
+
+	// link writes the pages of the current track, enc encodes its frames into frames
+	link *oggPacketWriter
+	// comments is the VORBIS_COMMENT packet of the link, written along its first frame so a link
+	// without audio can end on it
+	comments []byte
+	enc      *Encoder
+	frames   *oggFrames
+	granule  int64
+	// err is the first error, returned by every following call
+	err    error
+	closed bool
+}
+
+// oggFrames collects the frames written by an Encoder, one frame per Write
+type oggFrames struct {
+	pending [][]byte
+}
+
+func (frames *oggFrames) Write(frame []byte) (int, error) {
+	frames.pending = append(frames.pending, bytes.Clone(frame))
+	return len(frame), nil
+}
+
+// NewOggWriter returns an OggWriter of audio of the given format starting a first track tagged with
+// opts.Comments, see StartTrack. opts.Padding is ignored, STREAMINFO leaves the length, the frame
+// sizes and the MD5 signature unknown as they are for live streams.
+func NewOggWriter(w io.Writer, sampleRate uint32, bitsPerSample uint8, channels int, opts EncoderOptions) (*OggWriter, error) {
+	format := &PCM{SampleRate: sampleRate, BitsPerSample: bitsPerSample, Samples: make([][]int32, channels)}
+	if err := format.validate(); err != nil {
+		return nil, fmt.Errorf("unable to encode: %w", err)
+	}
+	opts, err := prepareEncoderOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ow := &OggWriter{
+		w:             w,
+		sampleRate:    sampleRate,
+		bitsPerSample: bitsPerSample,
+		channels:      channels,
+		opts:          opts,
+		serial:        rand.Uint32(),
+	}
+	if err := ow.startLink(opts.Comments); err != nil {
+		return nil, err
+	}
+	return ow, nil
+}
+
+// StartTrack ends the link of the current track and starts a new one tagged with comments, e.g.
+// TITLE and ARTIST for the now-playing information of an Icecast stream. The samples of the current
+// track not filling a frame yet are encoded in a last shorter frame.
+func (ow *OggWriter) StartTrack(comments []VorbisComment) error {
+	if ow.closed {
+		return fmt.Errorf("unable to encode: the Ogg writer is closed")
+	}
+	if ow.err != nil {
+		return ow.err
+	}
+	if ow.err = ow.endLink(); ow.err != nil {
+		return ow.err
+	}
+	ow.err = ow.startLink(comments)
+	return ow.err
+}
+
+// Write adds samples interleaved like in a WAVE file to the current track, see Encoder.Write
+func (ow *OggWriter) Write(samples []int32) error {
+	if ow.closed {
+		return fmt.Errorf("unable to encode: the Ogg writer is closed")
+	}
+	if ow.err != nil {
+		return ow.err
+	}
+	if err := ow.enc.Write(samples); err != nil {
+		ow.err = err
+		return err
+	}
+
+	// The last frame is held back so the end of the link can be flagged on it
+	for len(ow.frames.pending) > 1 {
+		if ow.err = ow.writeFrame(int64(ow.opts.BlockSize), false); ow.err != nil {
+			return ow.err
+		}
+	}
+	return nil
+}
+
+// Close ends the current track and the stream. It doesn't close w.
+func (ow *OggWriter) Close() error {
+	if ow.closed {
+		return ow.err
+	}
+	ow.closed = true
+	if ow.err != nil {
+		return ow.err
+	}
+	ow.err = ow.endLink()
+	return ow.err
+}
+
+// startLink writes the header packets of a new link tagged with comments: the Ogg FLAC mapping
+// header holding STREAMINFO, then VORBIS_COMMENT
+func (ow *OggWriter) startLink(comments []VorbisComment) error {
+	// Consecutive links must have different serial numbers
+	ow.serial++
+	ow.link = &oggPacketWriter{w: ow.w, serial: ow.serial}
+	ow.frames = &oggFrames{}
+	ow.enc = newEncoder(ow.frames, ow.sampleRate, ow.bitsPerSample, ow.channels, ow.opts)
+	ow.granule = 0
+
+	// 0x7F "FLAC", mapping version 1.0, one header packet following, then the native header.
+	// The header packets start pages of their own, as the mapping requires.
+	var header bytes.Buffer
+	header.Write([]byte{0x7F, 'F', 'L', 'A', 'C', 1, 0, 0, 1})
+	header.WriteString("fLaC")
+	streamInfo := ow.enc.StreamInfo()
+	writeMetadataBlock(&header, 0, streamInfo.marshal(), false)
+	if err := ow.link.writePacket(header.Bytes(), 0, false); err != nil {
+		return fmt.Errorf("unable to write Ogg FLAC header: %w", err)
+	}
+
+	var vorbis bytes.Buffer
+	writeMetadataBlock(&vorbis, 4, marshalVorbisComments(comments), true)
+	ow.comments = vorbis.Bytes()
+	return nil
+}
+
+// writeComments writes the VORBIS_COMMENT packet of the link if it's still pending, ending the link if eos is set
+func (ow *OggWriter) writeComments(eos bool) error {
+	if ow.comments == nil {
+		return nil
+	}
+	err := ow.link.writePacket(ow.comments, 0, eos)
+	ow.comments = nil
+	if err != nil {
+		return fmt.Errorf("unable to write Ogg FLAC header: %w", err)
+	}
+	return nil
+}
+
+// endLink encodes the samples left in the current link and writes its last page
+func (ow *OggWriter) endLink() error {
+	if err := ow.enc.Close(); err != nil {
+		return err
+	}
+	streamInfo := ow.enc.StreamInfo()
+	for len(ow.frames.pending) > 0 {
+		last := len(ow.frames.pending) == 1
+		samples := int64(ow.opts.BlockSize)
+		if last {
+			samples = int64(streamInfo.TotalSamples) - ow.granule
+		}
+		if err := ow.writeFrame(samples, last); err != nil {
+			return err
+		}
+	}
+	// A link without audio ends on its header
+	return ow.writeComments(true)
+}
+
+// writeFrame writes the first pending frame holding samples samples per channel, ending the link if eos is set
+func (ow *OggWriter) writeFrame(samples int64, eos bool) error {
+	if err := ow.writeComments(false); err != nil {
+		return err
+	}
+	frame := ow.frames.pending[0]
+	ow.frames.pending = ow.frames.pending[1:]
+	ow.granule += samples
+	if err := ow.link.writePacket(frame, ow.granule, eos); err != nil {
+		return fmt.Errorf("unable to write Ogg page: %w", err)
+	}
+	return nil
+}
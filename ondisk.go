@@ -0,0 +1,30 @@
+package flacgo
+
+import "os"
+
+// ChangedOnDisk reports whether the file opened with Open was modified, replaced or removed by someone
+// else since it was opened, comparing its size and modification time. Long-lived handles can check it
+// before saving so that concurrent edits are not overwritten. Saves made through flac don't count.
+// It is always false for streams and remote files.
+func (flac *Flac) ChangedOnDisk() bool {
+	if flac.modTime.IsZero() {
+		return false
+	}
+
+	info, err := os.Stat(flac.fileName)
+	if err != nil {
+		return true
+	}
+	return info.Size() != flac.diskSize || !info.ModTime().Equal(flac.modTime)
+}
+
+// recordDiskState keeps the size and modification time of the file after flac wrote it,
+// so that ChangedOnDisk doesn't report its own saves
+func (flac *Flac) recordDiskState() {
+	if flac.modTime.IsZero() {
+		return
+	}
+	if info, err := os.Stat(flac.fileName); err == nil {
+		flac.diskSize, flac.modTime = info.Size(), info.ModTime()
+	}
+}
@@ -0,0 +1,106 @@
+package flacgo
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures how a FLAC file is opened
+type Option func(*options)
+
+type options struct {
+	headerOnly bool
+	mmap       bool
+	httpClient *http.Client
+	retry      retryPolicy
+	// maxPictureSize is the largest image accepted as a picture, in bytes
+	maxPictureSize int
+	// artworkPolicy is checked by Validate if set
+	artworkPolicy *ArtworkPolicy
+	// fallbackEncoding decodes the comment values that aren't valid UTF-8 if set
+	fallbackEncoding Charmap
+	// totalTags selects the variants of the track and disc totals written on save
+	totalTags TotalTagsStyle
+	// historyTool is the tool recorded in the edit history, no history is kept if empty
+	historyTool string
+	// throttle paces reads and writes if set
+	throttle *throttle
+	// strict makes Open fail on specification violations
+	strict bool
+	// readOnly rejects every change, see OpenReadOnly
+	readOnly bool
+	// replayGain is applied by the decoding methods
+	replayGain replayGain
+	// channelMapping selects or mixes the channels returned by the decoding methods
+	channelMapping channelMapping
+	// decodeWorkers is the number of goroutines decoding the frames, see WithParallelDecode
+	decodeWorkers int
+	// stripImageMetadata strips the images embedded by the SetCoverPicture and SetPicture methods
+	stripImageMetadata bool
+	// sanitizeValues cleans the values staged by the SetMetadata methods with SanitizeValue
+	sanitizeValues bool
+	// emptyValues tells what the SetMetadata methods do with empty values
+	emptyValues EmptyValuePolicy
+}
+
+// retryPolicy tells how many times and how often a failed remote read is retried
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+// defaultRetryPolicy retries a failed remote read 3 times waiting 500ms, 1s and 2s
+var defaultRetryPolicy = retryPolicy{attempts: 3, baseDelay: 500 * time.Millisecond, maxDelay: 30 * time.Second}
+
+// WithHeaderOnly makes Open read only the headers of the metadata blocks, skipping the body of
+// every block but STREAMINFO and VORBIS_COMMENT. It speeds up scanning large libraries since
+// embedded pictures are not read; skipped bodies are loaded when something actually needs them.
+func WithHeaderOnly() Option {
+	return func(o *options) {
+		o.headerOnly = true
+	}
+}
+
+// WithMmap makes Open memory-map the file and parse blocks over the mapped view instead of
+// issuing seek and read syscalls, which speeds up scans of many files on fast disks.
+// On platforms without mmap support the file is read normally.
+func WithMmap() Option {
+	return func(o *options) {
+		o.mmap = true
+	}
+}
+
+// WithHTTPClient sets the client OpenURL uses for its requests, http.DefaultClient is used otherwise
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithRetry sets how many times OpenURL retries a request failing with a transient error
+// (network errors, 408, 429 and 5xx responses). The delay between attempts starts at
+// baseDelay and doubles every time up to maxDelay. Use 0 attempts to disable retries.
+func WithRetry(attempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(o *options) {
+		o.retry = retryPolicy{attempts: attempts, baseDelay: baseDelay, maxDelay: maxDelay}
+	}
+}
+
+// WithMaxPictureSize sets the largest image, in bytes, accepted by the SetCoverPicture methods.
+// It defaults to DefaultMaxPictureSize. Whatever the limit, a picture block can't exceed the
+// 16 MiB - 1 byte allowed by the 24-bit length of FLAC metadata blocks.
+func WithMaxPictureSize(size int) Option {
+	return func(o *options) {
+		o.maxPictureSize = size
+	}
+}
+
+// applyOptions returns the options resulting from opts
+func applyOptions(opts []Option) options {
+	o := options{retry: defaultRetryPolicy, maxPictureSize: DefaultMaxPictureSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
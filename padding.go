@@ -0,0 +1,167 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// inPlaceMetadata returns the metadata blocks to write over the original ones when they take no more
+// room, e.g. after removing a picture: the space freed goes to PADDING so the audio doesn't move.
+// It returns nil when they don't fit or the APEv2 tag has to be stripped.
+func (flac *Flac) inPlaceMetadata() ([]byte, error) {
+	if flac.stripAPETag && flac.apeTag != nil {
+		return nil, nil
+	}
+
+	blocks, err := flac.outputBlocks()
+	if err != nil {
+		return nil, err
+	}
+
+	freed := flac.audioOffset - 4 - blocksSize(blocks)
+	if freed < 0 {
+		return nil, nil
+	}
+
+	if freed > 0 {
+		padding := -1
+		for i := range blocks {
+			if blocks[i].BlockType == "PADDING" {
+				padding = i
+			}
+		}
+
+		switch {
+		case padding >= 0 && blocks[padding].bodyLength()+freed <= maxBlockLength:
+			// Grow the last PADDING block
+			block := &blocks[padding]
+			block.BlockData = append(slices.Clone(block.BlockData), make([]byte, freed)...)
+			block.BlockHeader.Data = paddingHeader(len(block.BlockData), padding == len(blocks)-1)
+		case freed >= 4 && freed-4 <= maxBlockLength:
+			// Add a PADDING block at the end
+			last := &blocks[len(blocks)-1]
+			last.BlockHeader.Data = slices.Clone(last.BlockHeader.Data)
+			last.BlockHeader.Data[0] &^= 0x80
+			blocks = append(blocks, MetadataBlock{
+				BlockType:   "PADDING",
+				BlockHeader: MetadataBlockHeader{Data: paddingHeader(int(freed-4), true)},
+				BlockData:   make([]byte, freed-4),
+			})
+		default:
+			return nil, nil
+		}
+	}
+
+	// Everything is read before writing, the blocks may be read from the region being overwritten
+	metadata := bytes.NewBuffer(make([]byte, 0, flac.audioOffset-4))
+	for i := range blocks {
+		metadata.Write(blocks[i].BlockHeader.Data)
+		body, err := flac.openBlockBody(&blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(metadata, io.LimitReader(body, blocks[i].bodyLength()))
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read metadata block: %w", err)
+		}
+		if n != blocks[i].bodyLength() {
+			return nil, fmt.Errorf("unable to read metadata block: expected %d bytes, got %d", blocks[i].bodyLength(), n)
+		}
+	}
+
+	return metadata.Bytes(), nil
+}
+
+// blocksSize returns the number of bytes blocks take in a file, headers included
+func blocksSize(blocks []MetadataBlock) int64 {
+	size := int64(0)
+	for i := range blocks {
+		size += 4 + blocks[i].bodyLength()
+	}
+	return size
+}
+
+// PendingMetadataSize returns the number of bytes before the audio once the staged changes are saved,
+// the magic header included, or -1 if they can't be saved. When saving over the original file, Save writes
+// the metadata in place if it is not larger than AudioOffset, otherwise the whole file is rewritten.
+func (flac *Flac) PendingMetadataSize() int {
+	size := -1
+	_ = flac.withTotalTags(func() error {
+		if !flac.HasChanges() {
+			size = int(flac.audioOffset)
+			return nil
+		}
+		blocks, err := flac.outputBlocks()
+		if err != nil {
+			return err
+		}
+		size = 4 + int(blocksSize(blocks))
+		return nil
+	})
+	return size
+}
+
+// paddingHeader returns the header of a PADDING block with a body of length bytes
+func paddingHeader(length int, isLast bool) []byte {
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(length))
+	header[0] = 1 // 1 = PADDING
+	if isLast {
+		header[0] |= 0x80
+	}
+	return header
+}
+
+// writeMetadataInPlace writes metadata over the blocks of the original file, leaving the audio untouched,
+// then parses the file again since the staged changes are now saved
+func (flac *Flac) writeMetadataInPlace(metadata []byte) error {
+	f, err := os.OpenFile(flac.fileName, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open file '%s': %w", flac.fileName, err)
+	}
+
+	_, err = flac.throttledWriter(io.NewOffsetWriter(f, 4)).Write(metadata)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write metadata in place: %w", err)
+	}
+
+	return flac.reset()
+}
+
+// reset drops the staged changes and the undo stack once they are saved in place, in the file still open,
+// and parses the file again
+func (flac *Flac) reset() error {
+	*flac = Flac{
+		file:     flac.file,
+		fileName: flac.fileName,
+		fileSize: flac.fileSize,
+		apeTag:   flac.apeTag,
+		options:  flac.options,
+		modTime:  flac.modTime,
+	}
+	flac.recordDiskState()
+	return flac.parseMetadata()
+}
+
+// reopen replaces the open file with the one Save renamed over it and parses it, since the file still
+// open is the replaced one: its block layout and audio offset no longer match the file on disk
+func (flac *Flac) reopen() error {
+	opts := flac.options
+	reopened, err := Open(flac.fileName, func(o *options) {
+		*o = opts
+	})
+	if err != nil {
+		return fmt.Errorf("unable to reopen '%s': %w", flac.fileName, err)
+	}
+	flac.Close()
+	*flac = *reopened
+	return nil
+}
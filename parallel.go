@@ -0,0 +1,213 @@
+package flacgo
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parallelChunkSize is the amount of encoded audio decoded at once by every worker of WithParallelDecode
+const parallelChunkSize = 512 * 1024
+
+// maxFrameHeaderLength is the length of the longest frame header, with a 36-bit coded number and
+// block size and sample rate stored at the end
+const maxFrameHeaderLength = 16
+
+// WithParallelDecode makes Verify, DecodePCM, ExportWAV, ExportAIFF and the analysis methods decode
+// the frames on workers goroutines, the number of CPUs if 0 or less. The audio is split in chunks at
+// the frame headers found by their sync code, decoded concurrently and reassembled in order, so the
+// results and the errors are the same as decoding the frames one after the other.
+func WithParallelDecode(workers int) Option {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return func(o *options) {
+		o.decodeWorkers = workers
+	}
+}
+
+// forEachFrame decodes the audio from the beginning calling fn with every frame in order, on the
+// workers of WithParallelDecode if set
+func (flac *Flac) forEachFrame(fn func(frame *Frame) error) error {
+	if flac.options.decodeWorkers > 1 {
+		return flac.forEachFrameParallel(flac.options.decodeWorkers, fn)
+	}
+
+	decoder, err := flac.NewDecoder()
+	if err != nil {
+		return err
+	}
+	for {
+		frame, err := decoder.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+}
+
+// decodedChunk holds the frames starting in a range of the audio, see decodeChunk
+type decodedChunk struct {
+	// start is the offset of the first frame, end the one following the last frame
+	start, end int64
+	frames     []*Frame
+	err        error
+}
+
+func (flac *Flac) forEachFrameParallel(workers int, fn func(frame *Frame) error) error {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return err
+	}
+	metadataEnd, err := flac.getMetadataEndOffset()
+	if err != nil {
+		return fmt.Errorf("cannot get metadata end offset: %w", err)
+	}
+	audioEnd := flac.getAudioEndOffset()
+
+	// Chunk i holds the frames starting from offset bounds[i] included to bounds[i+1] excluded
+	var bounds []int64
+	for offset := metadataEnd; offset < audioEnd; offset += parallelChunkSize {
+		bounds = append(bounds, offset)
+	}
+	bounds = append(bounds, audioEnd)
+	chunks := len(bounds) - 1
+
+	// Every chunk gets its own channel so frames are passed to fn in order as soon as they are ready,
+	// the tokens keep the decoded chunks waiting for fn to a few per worker
+	done := make([]chan *decodedChunk, chunks)
+	for i := range done {
+		done[i] = make(chan *decodedChunk, 1)
+	}
+	tokens := make(chan struct{}, 2*workers)
+	stop := make(chan struct{})
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(stop)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := bounds[i]
+				if i > 0 {
+					// The first chunk starts right after the metadata, the others at the first frame header
+					found, err := flac.findFrameHeader(streamInfo, start, audioEnd)
+					if err != nil {
+						done[i] <- &decodedChunk{start: -1, err: err}
+						continue
+					}
+					start = found
+				}
+				done[i] <- flac.decodeChunk(start, bounds[i+1])
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := 0; i < chunks; i++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	expected := metadataEnd
+	for i := 0; i < chunks; i++ {
+		chunk := <-done[i]
+		<-tokens
+		if chunk.start != expected {
+			// The header found by the worker was in the middle of a frame, or was missed: decode the
+			// chunk again from where the previous one actually ends
+			chunk = flac.decodeChunk(expected, bounds[i+1])
+		}
+		for _, frame := range chunk.frames {
+			if err := fn(frame); err != nil {
+				return err
+			}
+		}
+		if chunk.err != nil {
+			return chunk.err
+		}
+		expected = chunk.end
+	}
+
+	return nil
+}
+
+// decodeChunk decodes the frames from the one at offset start until the first one starting at limit
+// or later, or the end of the audio
+func (flac *Flac) decodeChunk(start, limit int64) *decodedChunk {
+	chunk := &decodedChunk{start: start, end: start}
+	metadataEnd, err := flac.getMetadataEndOffset()
+	if err != nil {
+		chunk.err = fmt.Errorf("cannot get metadata end offset: %w", err)
+		return chunk
+	}
+	decoder, err := flac.newDecoderAt(start - metadataEnd)
+	if err != nil {
+		chunk.err = err
+		return chunk
+	}
+
+	for chunk.end < limit {
+		frame, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			chunk.err = err
+			break
+		}
+		chunk.frames = append(chunk.frames, frame)
+		chunk.end = decoder.start + decoder.br.consumed
+	}
+	return chunk
+}
+
+// findFrameHeader returns the offset of the first valid frame header matching streamInfo from offset
+// from, or audioEnd if there is none. It's a guess: the sync code and a header with a valid CRC-8
+// can show up by chance in the middle of a frame.
+func (flac *Flac) findFrameHeader(streamInfo *StreamInfo, from, audioEnd int64) (int64, error) {
+	window := make([]byte, 64*1024)
+	for offset := from; offset < audioEnd; offset += int64(len(window) - maxFrameHeaderLength) {
+		want := window[:min(int64(len(window)), audioEnd-offset)]
+		n, err := flac.file.ReadAt(want, offset)
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("unable to read audio at offset %d: %w", offset, err)
+		}
+
+		data := window[:n]
+		for i := 0; i+1 < len(data); i++ {
+			if data[i] != 0xFF || data[i+1]&0xFE != 0xF8 {
+				continue
+			}
+			header := data[i:min(len(data), i+maxFrameHeaderLength)]
+			decoder := &Decoder{streamInfo: streamInfo, br: newBitReaderBytes(header)}
+			parsed, err := decoder.readFrameHeader()
+			if err == nil && parsed.Channels == int(streamInfo.Channels) && parsed.BitsPerSample == streamInfo.BitsPerSample {
+				return offset + int64(i), nil
+			}
+		}
+		if n < len(window) {
+			// The end of the audio
+			break
+		}
+	}
+	return audioEnd, nil
+}
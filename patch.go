@@ -0,0 +1,104 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// vorbisEntry is a "KEY=VALUE" comment of a VORBIS_COMMENT block along with its offset in the file
+type vorbisEntry struct {
+	offset int64
+	text   string
+}
+
+// vorbisEntries returns the comments of the VORBIS_COMMENT block with their offsets,
+// the block must have been parsed successfully already
+func vorbisEntries(block *MetadataBlock) []vorbisEntry {
+	data := block.BlockData
+	_, count, offset, _ := vorbisHeader(data)
+
+	entries := make([]vorbisEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		length := uint64(binary.LittleEndian.Uint32(data[offset : offset+4]))
+		entries = append(entries, vorbisEntry{
+			offset: block.Index + 4 + int64(offset) + 4,
+			text:   string(data[offset+4 : offset+4+length]),
+		})
+		offset += 4 + length
+	}
+
+	return entries
+}
+
+// patchableComments returns the comments to write over the existing ones when the staged changes
+// only touch comments and every comment keeps its position and encoded length, nil otherwise
+func (flac *Flac) patchableComments() ([]vorbisEntry, error) {
+	if flac.vorbisIndex == nil || flac.hasPendingCover() || len(flac.pendingPictures) > 0 || flac.removeCoverPicture ||
+		len(flac.removedPictures) > 0 || flac.stripAPETag || flac.rewritesHistory() || flac.pendingStreamInfo != nil {
+		return nil, nil
+	}
+
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+	vorbisBlocks := 0
+	for _, block := range blocks {
+		if block.BlockType == "VORBIS_COMMENT" {
+			vorbisBlocks++
+		}
+	}
+	if vorbisBlocks != 1 {
+		// Saving keeps a single VORBIS_COMMENT block, patching would keep them all
+		return nil, nil
+	}
+
+	vorbisBlock, err := flac.getBlock("VORBIS_COMMENT")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := vorbisEntries(vorbisBlock)
+	comments := flac.Comments()
+	if len(comments) != len(entries) {
+		return nil, nil
+	}
+
+	patches := []vorbisEntry{}
+	for i, comment := range comments {
+		text := comment.Title + "=" + comment.Value
+		if len(text) != len(entries[i].text) {
+			return nil, nil
+		}
+		if text != entries[i].text {
+			patches = append(patches, vorbisEntry{offset: entries[i].offset, text: text})
+		}
+	}
+
+	return patches, nil
+}
+
+// patchComments writes the changed comments directly at their offsets in the original file
+func (flac *Flac) patchComments(patches []vorbisEntry) error {
+	if len(patches) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(flac.fileName, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open file '%s': %w", flac.fileName, err)
+	}
+
+	for _, patch := range patches {
+		if _, err := f.WriteAt([]byte(patch.text), patch.offset); err != nil {
+			f.Close()
+			return fmt.Errorf("unable to patch comment at offset %d: %w", patch.offset, err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to patch comments: %w", err)
+	}
+	return flac.reset()
+}
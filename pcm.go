@@ -0,0 +1,126 @@
+package flacgo
+
+import "fmt"
+
+// PCM holds uncompressed audio, one slice of samples per channel
+type PCM struct {
+	SampleRate    uint32
+	BitsPerSample uint8
+	Samples       [][]int32
+	// Comments are the tags found along the audio, e.g. in the LIST INFO chunk of a WAVE file.
+	// Encode writes them unless EncoderOptions.Comments is set.
+	Comments []VorbisComment
+}
+
+// Channels returns the number of channels
+func (pcm *PCM) Channels() int {
+	return len(pcm.Samples)
+}
+
+// Length returns the number of samples per channel
+func (pcm *PCM) Length() int {
+	if len(pcm.Samples) == 0 {
+		return 0
+	}
+	return len(pcm.Samples[0])
+}
+
+// validate checks the audio can be stored in a FLAC stream
+func (pcm *PCM) validate() error {
+	if len(pcm.Samples) < 1 || len(pcm.Samples) > 8 {
+		return fmt.Errorf("unsupported number of channels %d, FLAC supports 1 to 8", len(pcm.Samples))
+	}
+	if pcm.BitsPerSample < 4 || pcm.BitsPerSample > 32 {
+		return fmt.Errorf("unsupported bits per sample %d, FLAC supports 4 to 32", pcm.BitsPerSample)
+	}
+	if pcm.SampleRate == 0 || pcm.SampleRate >= 1<<20 {
+		return fmt.Errorf("unsupported sample rate %d", pcm.SampleRate)
+	}
+	for ch, samples := range pcm.Samples {
+		if len(samples) != len(pcm.Samples[0]) {
+			return fmt.Errorf("channel %d has %d samples, channel 0 has %d", ch, len(samples), len(pcm.Samples[0]))
+		}
+	}
+
+	return nil
+}
+
+// DecodePCM decodes the whole audio stream in memory, with the channels of WithChannels or WithDownmix
+// and the gain of WithReplayGain applied
+func (flac *Flac) DecodePCM() (*PCM, error) {
+	scale, err := flac.replayGainScale()
+	if err != nil {
+		return nil, err
+	}
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	mixer, err := flac.newChannelMixer(streamInfo)
+	if err != nil {
+		return nil, err
+	}
+	pcm := &PCM{
+		SampleRate:    streamInfo.SampleRate,
+		BitsPerSample: streamInfo.BitsPerSample,
+		Samples:       make([][]int32, mixer.channels),
+	}
+	for ch := range pcm.Samples {
+		pcm.Samples[ch] = make([]int32, 0, streamInfo.TotalSamples)
+	}
+
+	err = flac.forEachFrame(func(frame *Frame) error {
+		if len(frame.Samples) != int(streamInfo.Channels) {
+			return fmt.Errorf("frame at offset %d has %d channels, STREAMINFO declares %d", frame.Header.Offset, len(frame.Samples), streamInfo.Channels)
+		}
+		samples := mixer.mix(frame.Samples)
+		applyGain(samples, scale, streamInfo.BitsPerSample)
+		for ch := range pcm.Samples {
+			pcm.Samples[ch] = append(pcm.Samples[ch], samples[ch]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pcm, nil
+}
+
+// decodeFrames decodes the audio calling fn with the samples of every frame, with the channels of
+// mixer and the gain of WithReplayGain applied, see decodeOriginalFrames
+func (flac *Flac) decodeFrames(streamInfo *StreamInfo, mixer *channelMixer, fn func(samples [][]int32) error) error {
+	scale, err := flac.replayGainScale()
+	if err != nil {
+		return err
+	}
+	return flac.decodeOriginalFrames(streamInfo, func(samples [][]int32) error {
+		samples = mixer.mix(samples)
+		applyGain(samples, scale, streamInfo.BitsPerSample)
+		return fn(samples)
+	})
+}
+
+// decodeOriginalFrames decodes the audio calling fn with the samples of every frame as they are stored,
+// it fails if the frames don't match the channels and sample count of streamInfo
+func (flac *Flac) decodeOriginalFrames(streamInfo *StreamInfo, fn func(samples [][]int32) error) error {
+	var decoded uint64
+	err := flac.forEachFrame(func(frame *Frame) error {
+		if len(frame.Samples) != int(streamInfo.Channels) {
+			return fmt.Errorf("frame at offset %d has %d channels, STREAMINFO declares %d", frame.Header.Offset, len(frame.Samples), streamInfo.Channels)
+		}
+		if err := fn(frame.Samples); err != nil {
+			return err
+		}
+		decoded += uint64(frame.Header.BlockSize)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if streamInfo.TotalSamples != 0 && decoded != streamInfo.TotalSamples {
+		return fmt.Errorf("decoded %d samples, STREAMINFO declares %d", decoded, streamInfo.TotalSamples)
+	}
+	return nil
+}
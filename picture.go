@@ -0,0 +1,240 @@
+package flacgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Picture holds the parsed content of a PICTURE metadata block
+type Picture struct {
+	PictureType uint32
+	MimeType    string
+	Description string
+	Width       uint32
+	Height      uint32
+	Depth       uint32
+	Colors      uint32
+	Data        []byte
+
+	// blockIndex is the offset of the PICTURE block the picture was read from
+	blockIndex int64
+}
+
+// parsePictureBlock tries to parse bytes from a picture block into a Picture structure
+func parsePictureBlock(pictureBlock []byte) (*Picture, error) {
+	offset := 0
+
+	readUint32 := func() (uint32, error) {
+		if len(pictureBlock) < offset+4 {
+			return 0, fmt.Errorf("unexpected end of picture block at offset %d", offset)
+		}
+		value := binary.BigEndian.Uint32(pictureBlock[offset : offset+4])
+		offset += 4
+		return value, nil
+	}
+
+	readString := func() (string, error) {
+		length, err := readUint32()
+		if err != nil {
+			return "", err
+		}
+		if len(pictureBlock) < offset+int(length) {
+			return "", fmt.Errorf("picture block too short for string of length %d", length)
+		}
+		value := string(pictureBlock[offset : offset+int(length)])
+		offset += int(length)
+		return value, nil
+	}
+
+	picture := &Picture{}
+	var err error
+
+	if picture.PictureType, err = readUint32(); err != nil {
+		return nil, err
+	}
+	if picture.MimeType, err = readString(); err != nil {
+		return nil, err
+	}
+	if picture.Description, err = readString(); err != nil {
+		return nil, err
+	}
+	for _, field := range []*uint32{&picture.Width, &picture.Height, &picture.Depth, &picture.Colors} {
+		if *field, err = readUint32(); err != nil {
+			return nil, err
+		}
+	}
+
+	dataLength, err := readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if len(pictureBlock) < offset+int(dataLength) {
+		return nil, fmt.Errorf("picture block too short for picture data of length %d", dataLength)
+	}
+	picture.Data = pictureBlock[offset : offset+int(dataLength)]
+
+	return picture, nil
+}
+
+// DefaultMaxPictureSize is the default largest image accepted as a picture, see WithMaxPictureSize
+const DefaultMaxPictureSize = 16 * 1000 * 1000
+
+// PictureTooLargeError is returned when an image doesn't fit in a PICTURE block
+type PictureTooLargeError struct {
+	// Size is the size of the image in bytes
+	Size int
+	// Limit is the largest size allowed in bytes
+	Limit int
+}
+
+func (err *PictureTooLargeError) Error() string {
+	return fmt.Sprintf("picture is %d bytes, the limit is %d", err.Size, err.Limit)
+}
+
+// checkPictureSize fails if an image of size bytes can't be embedded along with a mime type and a description
+func (flac *Flac) checkPictureSize(size int, mimeType string, description string) error {
+	// The block holds 8 uint32 fields besides the mime type, the description and the image
+	blockLimit := maxBlockLength - 32 - len(mimeType) - len(description)
+
+	limit := flac.options.maxPictureSize
+	if limit <= 0 || limit > blockLimit {
+		limit = blockLimit
+	}
+
+	if size > limit {
+		return &PictureTooLargeError{Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// Hash returns the SHA-256 of the encoded picture data
+func (picture *Picture) Hash() [32]byte {
+	return sha256.Sum256(picture.Data)
+}
+
+// PixelHash returns the SHA-256 of the decoded pixels, so the same image stored with a
+// different encoding or different embedded metadata gives the same hash.
+// It returns an error if the image format is not supported.
+func (picture *Picture) PixelHash() ([32]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(picture.Data))
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("unable to decode picture: %w", err)
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, uint32(bounds.Dx()))
+	binary.Write(hash, binary.BigEndian, uint32(bounds.Dy()))
+	hash.Write(rgba.Pix)
+
+	var sum [32]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
+
+// Pictures returns all the pictures stored in the file, in order.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) Pictures() ([]*Picture, error) {
+	blocks, err := flac.readAllMetadataBlocksWithData()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+
+	pictures := make([]*Picture, 0)
+	for _, block := range blocks {
+		if block.BlockType != "PICTURE" {
+			continue
+		}
+
+		picture, err := parsePictureBlock(block.BlockData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse picture at offset %d: %w", block.Index, err)
+		}
+		picture.blockIndex = block.Index
+		pictures = append(pictures, picture)
+	}
+
+	return pictures, nil
+}
+
+// DuplicatePictures returns the groups of pictures having the same picture type and a byte-identical
+// or visually identical image. Each group has at least two pictures, the first one being the original.
+func (flac *Flac) DuplicatePictures() ([][]*Picture, error) {
+	pictures, err := flac.Pictures()
+	if err != nil {
+		return nil, err
+	}
+
+	type pictureKey struct {
+		pictureType uint32
+		hash        [32]byte
+	}
+
+	// A picture joins the group of the first earlier picture sharing its byte hash or its pixel hash
+	groups := make([][]*Picture, 0)
+	groupByKey := make(map[pictureKey]int)
+
+	for _, picture := range pictures {
+		keys := []pictureKey{{picture.PictureType, picture.Hash()}}
+		if pixelHash, err := picture.PixelHash(); err == nil {
+			keys = append(keys, pictureKey{picture.PictureType, pixelHash})
+		}
+
+		group := -1
+		for _, key := range keys {
+			if index, found := groupByKey[key]; found {
+				group = index
+				break
+			}
+		}
+		if group == -1 {
+			group = len(groups)
+			groups = append(groups, nil)
+		}
+
+		groups[group] = append(groups[group], picture)
+		for _, key := range keys {
+			groupByKey[key] = group
+		}
+	}
+
+	duplicates := make([][]*Picture, 0)
+	for _, group := range groups {
+		if len(group) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+
+	return duplicates, nil
+}
+
+// PruneDuplicatePictures stages the removal of redundant pictures, keeping only the first one of
+// each group returned by DuplicatePictures. It returns the number of pictures that will be removed on Save.
+func (flac *Flac) PruneDuplicatePictures() (int, error) {
+	if err := flac.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer flac.recordUndo()()
+
+	duplicates, err := flac.DuplicatePictures()
+	if err != nil {
+		return 0, fmt.Errorf("unable to find duplicate pictures: %w", err)
+	}
+
+	removed := 0
+	for _, group := range duplicates {
+		for _, picture := range group[1:] {
+			flac.removedPictures[picture.blockIndex] = true
+			removed++
+		}
+	}
+
+	return removed, nil
+}
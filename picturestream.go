@@ -0,0 +1,260 @@
+package flacgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// pendingPicture is a picture staged from a file: its image data is not read until the FLAC file
+// is saved, when it's streamed straight into the output, unless the picture is generated in memory
+type pendingPicture struct {
+	// prefix is the block header followed by the picture fields preceding the image data
+	prefix      []byte
+	pictureType uint32
+	path        string
+	size        int64
+	modTime     time.Time
+	// data is the image of a picture generated in memory, which has no path
+	data []byte
+}
+
+// stagePictureFromPath prepares a picture of the given type for the image at filePath,
+// reading only the image header to fill its fields
+func (flac *Flac) stagePictureFromPath(filePath string, pictureType uint32, description string) (*pendingPicture, error) {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image with path: '%s': %w", filePath, err)
+	}
+
+	// Don't read an image that can't be embedded anyway
+	if err := flac.checkPictureSize(int(fileInfo.Size()), "", description); err != nil {
+		return nil, fmt.Errorf("unable to add picture: %w", err)
+	}
+
+	if flac.options.stripImageMetadata {
+		return flac.stagePictureData(filePath, pictureType, description)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image with path: '%s': %w", filePath, err)
+	}
+	defer f.Close()
+
+	config, imageType, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image with path: '%s': unable to read image: %w", filePath, err)
+	}
+
+	picture := &Picture{PictureType: pictureType, MimeType: "image/" + imageType, Description: description}
+	picture.setImageConfig(config)
+
+	prefix, err := flac.createPictureHeader(picture, int(fileInfo.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to add picture: %w", err)
+	}
+
+	return &pendingPicture{
+		prefix:      prefix,
+		pictureType: pictureType,
+		path:        filePath,
+		size:        fileInfo.Size(),
+		modTime:     fileInfo.ModTime(),
+	}, nil
+}
+
+// stagePictureData prepares a picture of the given type for the image at filePath stripped with
+// StripImageMetadata, kept in memory
+func (flac *Flac) stagePictureData(filePath string, pictureType uint32, description string) (*pendingPicture, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image with path: '%s': %w", filePath, err)
+	}
+	if data, err = StripImageMetadata(data); err != nil {
+		return nil, fmt.Errorf("unable to add picture: %w", err)
+	}
+
+	config, imageType, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image with path: '%s': unable to read image: %w", filePath, err)
+	}
+
+	picture := &Picture{PictureType: pictureType, MimeType: "image/" + imageType, Description: description}
+	picture.setImageConfig(config)
+
+	prefix, err := flac.createPictureHeader(picture, len(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to add picture: %w", err)
+	}
+
+	return &pendingPicture{prefix: prefix, pictureType: pictureType, size: int64(len(data)), data: data}, nil
+}
+
+// block returns the PICTURE block of the picture, whose image is streamed from its file unless it's in memory
+func (picture *pendingPicture) block() MetadataBlock {
+	block := MetadataBlock{
+		Index:       -1,
+		BlockType:   "PICTURE",
+		BlockHeader: MetadataBlockHeader{Data: picture.prefix[:4]},
+	}
+	if picture.data != nil {
+		block.BlockData = append(slices.Clone(picture.prefix[4:]), picture.data...)
+	} else {
+		block.stream = picture
+	}
+	return block
+}
+
+// open opens the image file, failing if it changed since it was staged
+func (picture *pendingPicture) open() (*os.File, error) {
+	f, err := os.Open(picture.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open picture: %w", err)
+	}
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to stat picture: %w", err)
+	}
+	if fileInfo.Size() != picture.size || !fileInfo.ModTime().Equal(picture.modTime) {
+		f.Close()
+		return nil, fmt.Errorf("picture '%s' changed since it was set", picture.path)
+	}
+
+	return f, nil
+}
+
+// PictureStream is a picture whose image data is read from the file on demand
+// instead of being loaded in memory
+type PictureStream struct {
+	// Picture holds the fields of the picture, its Data is nil
+	Picture *Picture
+	// Data reads the image data
+	Data *io.SectionReader
+}
+
+// StreamPictures returns all the pictures stored in the file, in order, like Pictures does but
+// without loading their image data, so large pictures can be extracted with flat memory usage.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) StreamPictures() ([]PictureStream, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+
+	pictures := make([]PictureStream, 0)
+	for _, block := range blocks {
+		if block.BlockType != "PICTURE" {
+			continue
+		}
+
+		body := io.NewSectionReader(flac.file, block.Index+4, int64(block.BlockHeader.BlockLength))
+		picture, dataLength, err := readPictureHeader(body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse picture at offset %d: %w", block.Index, err)
+		}
+
+		dataOffset, _ := body.Seek(0, io.SeekCurrent)
+		if dataOffset+int64(dataLength) > body.Size() {
+			return nil, fmt.Errorf("unable to parse picture at offset %d: picture block too short for picture data of length %d", block.Index, dataLength)
+		}
+		picture.blockIndex = block.Index
+
+		pictures = append(pictures, PictureStream{
+			Picture: picture,
+			Data:    io.NewSectionReader(flac.file, block.Index+4+dataOffset, int64(dataLength)),
+		})
+	}
+
+	return pictures, nil
+}
+
+// readPictureHeader reads the fields of a PICTURE block body preceding the image data,
+// leaving r at the start of the data whose length is returned
+func readPictureHeader(r io.Reader) (*Picture, uint32, error) {
+	readUint32 := func() (uint32, error) {
+		var value uint32
+		if err := binary.Read(r, binary.BigEndian, &value); err != nil {
+			return 0, fmt.Errorf("unexpected end of picture block: %w", unexpectedEOF(err))
+		}
+		return value, nil
+	}
+
+	readString := func() (string, error) {
+		length, err := readUint32()
+		if err != nil {
+			return "", err
+		}
+		if length > maxBlockLength {
+			return "", fmt.Errorf("picture block too short for string of length %d", length)
+		}
+		value := make([]byte, length)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return "", fmt.Errorf("picture block too short for string of length %d", length)
+		}
+		return string(value), nil
+	}
+
+	picture := &Picture{}
+	var err error
+
+	if picture.PictureType, err = readUint32(); err != nil {
+		return nil, 0, err
+	}
+	if picture.MimeType, err = readString(); err != nil {
+		return nil, 0, err
+	}
+	if picture.Description, err = readString(); err != nil {
+		return nil, 0, err
+	}
+	for _, field := range []*uint32{&picture.Width, &picture.Height, &picture.Depth, &picture.Colors} {
+		if *field, err = readUint32(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	dataLength, err := readUint32()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return picture, dataLength, nil
+}
+
+// openBlockBody returns a reader over the body of a block about to be written: the bytes in memory,
+// the image file of a picture staged from a path, or the section of the original file otherwise
+func (flac *Flac) openBlockBody(block *MetadataBlock) (io.ReadCloser, error) {
+	switch {
+	case block.stream != nil:
+		f, err := block.stream.open()
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(block.stream.prefix[4:]), f), f}, nil
+	case block.BlockData != nil:
+		return io.NopCloser(bytes.NewReader(block.BlockData)), nil
+	}
+
+	return sectionCloser{io.NewSectionReader(flac.file, block.Index+4, int64(block.BlockHeader.BlockLength))}, nil
+}
+
+// bodyLength returns the length of the body of a block about to be written
+func (block *MetadataBlock) bodyLength() int64 {
+	switch {
+	case block.stream != nil:
+		return int64(len(block.stream.prefix)-4) + block.stream.size
+	case block.BlockData != nil:
+		return int64(len(block.BlockData))
+	}
+	return int64(block.BlockHeader.BlockLength)
+}
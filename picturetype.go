@@ -0,0 +1,152 @@
+package flacgo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Picture types defined by the ID3v2 APIC frame, used by PICTURE blocks
+const (
+	PictureTypeOther uint32 = iota
+	PictureTypeFileIcon
+	PictureTypeOtherFileIcon
+	PictureTypeFrontCover
+	PictureTypeBackCover
+	PictureTypeLeaflet
+	PictureTypeMedia
+	PictureTypeLeadArtist
+	PictureTypeArtist
+	PictureTypeConductor
+	PictureTypeBand
+	PictureTypeComposer
+	PictureTypeLyricist
+	PictureTypeRecordingLocation
+	PictureTypeDuringRecording
+	PictureTypeDuringPerformance
+	PictureTypeScreenCapture
+	PictureTypeBrightColoredFish
+	PictureTypeIllustration
+	PictureTypeBandLogo
+	PictureTypePublisherLogo
+)
+
+// pictureTypeNames are the short names of the picture types, indexed by type
+var pictureTypeNames = []string{
+	"other", "file-icon", "other-file-icon", "front", "back", "leaflet", "media",
+	"lead-artist", "artist", "conductor", "band", "composer", "lyricist",
+	"recording-location", "during-recording", "during-performance", "screen-capture",
+	"fish", "illustration", "band-logo", "publisher-logo",
+}
+
+// PictureTypeName returns the short name of a picture type, e.g. "front" or "back"
+func PictureTypeName(pictureType uint32) string {
+	if int(pictureType) < len(pictureTypeNames) {
+		return pictureTypeNames[pictureType]
+	}
+	return fmt.Sprintf("type-%d", pictureType)
+}
+
+// ParsePictureType parses a picture type given by short name, as returned by PictureTypeName, or by number
+func ParsePictureType(name string) (uint32, error) {
+	for i, typeName := range pictureTypeNames {
+		if strings.EqualFold(name, typeName) {
+			return uint32(i), nil
+		}
+	}
+
+	number, err := strconv.ParseUint(name, 10, 32)
+	if err != nil || number >= uint64(len(pictureTypeNames)) {
+		return 0, fmt.Errorf("unknown picture type '%s'", name)
+	}
+	return uint32(number), nil
+}
+
+// setImageConfig fills the dimensions, color depth and number of colors of the picture
+func (picture *Picture) setImageConfig(config image.Config) {
+	picture.Width = uint32(config.Width)
+	picture.Height = uint32(config.Height)
+	picture.Colors = 0
+
+	if palette, ok := config.ColorModel.(color.Palette); ok {
+		picture.Depth = 8
+		picture.Colors = uint32(len(palette))
+		return
+	}
+
+	switch config.ColorModel {
+	case color.GrayModel:
+		picture.Depth = 8
+	case color.Gray16Model:
+		picture.Depth = 16
+	case color.RGBAModel, color.NRGBAModel, color.CMYKModel:
+		picture.Depth = 32
+	case color.RGBA64Model, color.NRGBA64Model:
+		picture.Depth = 64
+	default:
+		picture.Depth = 24
+	}
+}
+
+// SetPictureFromPath stages the image at filePath as a picture of the given type, replacing the
+// pictures of the same type already stored or staged. The image is read only while saving.
+func (flac *Flac) SetPictureFromPath(filePath string, pictureType uint32, description string) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	pending, err := flac.stagePictureFromPath(filePath, pictureType, description)
+	if err != nil {
+		return err
+	}
+
+	if _, err := flac.RemovePictures(pictureType); err != nil {
+		return err
+	}
+	flac.pendingPictures = append(flac.pendingPictures, pending)
+
+	return nil
+}
+
+// RemovePictures stages the removal of all the pictures of the given type, including the new ones
+// not saved yet, and returns how many were removed
+func (flac *Flac) RemovePictures(pictureType uint32) (int, error) {
+	if err := flac.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer flac.recordUndo()()
+
+	pictures, err := flac.StreamPictures()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, picture := range pictures {
+		if picture.Picture.PictureType == pictureType && !flac.removedPictures[picture.Picture.blockIndex] {
+			flac.removedPictures[picture.Picture.blockIndex] = true
+			removed++
+		}
+	}
+
+	kept := flac.pendingPictures[:0]
+	for _, pending := range flac.pendingPictures {
+		if pending.pictureType == pictureType {
+			removed++
+			continue
+		}
+		kept = append(kept, pending)
+	}
+	flac.pendingPictures = kept
+
+	if pictureType == PictureTypeFrontCover && flac.hasPendingCover() {
+		flac.pendingCoverPicture = nil
+		flac.pendingCoverStream = nil
+		removed++
+	}
+
+	return removed, nil
+}
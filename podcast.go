@@ -0,0 +1,200 @@
+package flacgo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Episode holds the metadata of a podcast or broadcast episode
+type Episode struct {
+	Show        string
+	Title       string
+	Author      string
+	Number      int
+	Season      int
+	Publisher   string
+	URL         string
+	Description string
+	// Date is the publication date as stored in the file, e.g. "2023-05-01"
+	Date string
+	GUID string
+}
+
+// PodcastProfile maps the fields of an Episode to Vorbis comments, an empty key leaves the field out
+type PodcastProfile struct {
+	Show        string
+	Title       string
+	Author      string
+	Number      string
+	Season      string
+	Publisher   string
+	URL         string
+	Description string
+	Date        string
+	GUID        string
+}
+
+// DefaultPodcastProfile stores the show as album, the episode and season numbers as track and disc
+// numbers, so music players list the episodes in order
+var DefaultPodcastProfile = PodcastProfile{
+	Show:        "ALBUM",
+	Title:       "TITLE",
+	Author:      "ARTIST",
+	Number:      "TRACKNUMBER",
+	Season:      "DISCNUMBER",
+	Publisher:   "PUBLISHER",
+	URL:         "PODCASTURL",
+	Description: "DESCRIPTION",
+	Date:        "DATE",
+	GUID:        "PODCASTGUID",
+}
+
+// fields pairs the keys of the profile with the text fields of episode
+func (profile PodcastProfile) fields(episode *Episode) []struct {
+	key   string
+	value *string
+} {
+	return []struct {
+		key   string
+		value *string
+	}{
+		{profile.Show, &episode.Show},
+		{profile.Title, &episode.Title},
+		{profile.Author, &episode.Author},
+		{profile.Publisher, &episode.Publisher},
+		{profile.URL, &episode.URL},
+		{profile.Description, &episode.Description},
+		{profile.Date, &episode.Date},
+		{profile.GUID, &episode.GUID},
+	}
+}
+
+// Episode reads the episode metadata mapped by profile, including the staged changes.
+// Numbers stored like "3/10" are read as 3, invalid numbers as 0.
+func (flac *Flac) Episode(profile PodcastProfile) Episode {
+	comments := flac.Comments()
+	value := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		comment, _ := findComment(comments, key)
+		return comment.Value
+	}
+	number := func(key string) int {
+		number, _, _ := strings.Cut(value(key), "/")
+		n, _ := strconv.Atoi(strings.TrimSpace(number))
+		return n
+	}
+
+	var episode Episode
+	for _, field := range profile.fields(&episode) {
+		*field.value = value(field.key)
+	}
+	episode.Number = number(profile.Number)
+	episode.Season = number(profile.Season)
+	return episode
+}
+
+// SetEpisode stages the episode metadata with the keys of profile. Empty fields and zero numbers
+// remove the mapped comments.
+func (flac *Flac) SetEpisode(episode Episode, profile PodcastProfile) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	set := func(key string, value string) error {
+		if key == "" {
+			return nil
+		}
+		if value == "" {
+			return flac.RemoveMetadata(key, true)
+		}
+		return flac.SetMetadata(key, value)
+	}
+	number := func(n int) string {
+		if n <= 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+
+	for _, field := range profile.fields(&episode) {
+		if err := set(field.key, *field.value); err != nil {
+			return fmt.Errorf("unable to set %s: %w", field.key, err)
+		}
+	}
+	if err := set(profile.Number, number(episode.Number)); err != nil {
+		return fmt.Errorf("unable to set %s: %w", profile.Number, err)
+	}
+	if err := set(profile.Season, number(episode.Season)); err != nil {
+		return fmt.Errorf("unable to set %s: %w", profile.Season, err)
+	}
+	return nil
+}
+
+// RSSItem is an episode as an RSS 2.0 item with the iTunes podcast extensions, ready for encoding/xml.
+// The itunes prefix must be declared by the enclosing feed as xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd".
+type RSSItem struct {
+	XMLName     xml.Name     `xml:"item"`
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link,omitempty"`
+	GUID        string       `xml:"guid,omitempty"`
+	PubDate     string       `xml:"pubDate,omitempty"`
+	Description string       `xml:"description,omitempty"`
+	Enclosure   RSSEnclosure `xml:"enclosure"`
+	Author      string       `xml:"itunes:author,omitempty"`
+	Episode     int          `xml:"itunes:episode,omitempty"`
+	Season      int          `xml:"itunes:season,omitempty"`
+	Duration    string       `xml:"itunes:duration,omitempty"`
+}
+
+// RSSEnclosure is the media file of an RSS item
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// rssDateLayouts are the DATE formats converted to RSS publication dates
+var rssDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"}
+
+// RSSItem returns the episode mapped by profile as an RSS item whose enclosure is the file served at
+// enclosureURL. The publication date is left empty if DATE is not an ISO 8601 date, the GUID defaults
+// to enclosureURL.
+func (flac *Flac) RSSItem(profile PodcastProfile, enclosureURL string) (*RSSItem, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read STREAMINFO: %w", err)
+	}
+
+	episode := flac.Episode(profile)
+	item := &RSSItem{
+		Title:       episode.Title,
+		Link:        episode.URL,
+		GUID:        episode.GUID,
+		Description: episode.Description,
+		Enclosure:   RSSEnclosure{URL: enclosureURL, Length: flac.fileSize, Type: "audio/flac"},
+		Author:      episode.Author,
+		Episode:     episode.Number,
+		Season:      episode.Season,
+	}
+	if item.GUID == "" {
+		item.GUID = enclosureURL
+	}
+	for _, layout := range rssDateLayouts {
+		if date, err := time.Parse(layout, strings.TrimSpace(episode.Date)); err == nil {
+			item.PubDate = date.Format(time.RFC1123Z)
+			break
+		}
+	}
+	if duration := streamInfo.Duration(); duration > 0 {
+		seconds := int(duration.Round(time.Second) / time.Second)
+		item.Duration = fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+
+	return item, nil
+}
@@ -0,0 +1,77 @@
+package flacgo
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBufferSize is the default size of the buffers used to copy audio data while saving
+const DefaultBufferSize = 256 * 1024
+
+// maxPooledMetadataBuffer is the largest metadata buffer kept in the pool, bigger ones are left to the GC
+const maxPooledMetadataBuffer = 16 * 1024 * 1024
+
+var bufferSize atomic.Int64
+
+var copyBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, BufferSize())
+		return &buf
+	},
+}
+
+var metadataBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// SetBufferSize sets the size of the pooled buffers used to copy audio data while saving.
+// A size of zero or less restores DefaultBufferSize.
+func SetBufferSize(size int) {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	bufferSize.Store(int64(size))
+}
+
+// BufferSize returns the size of the pooled buffers used to copy audio data while saving
+func BufferSize() int {
+	if size := bufferSize.Load(); size > 0 {
+		return int(size)
+	}
+	return DefaultBufferSize
+}
+
+// getCopyBuffer returns a buffer of BufferSize bytes from the pool
+func getCopyBuffer() *[]byte {
+	buf := copyBufferPool.Get().(*[]byte)
+	if len(*buf) != BufferSize() {
+		// The size changed since the buffer was pooled
+		fresh := make([]byte, BufferSize())
+		return &fresh
+	}
+	return buf
+}
+
+// putCopyBuffer gives a buffer back to the pool
+func putCopyBuffer(buf *[]byte) {
+	if len(*buf) == BufferSize() {
+		copyBufferPool.Put(buf)
+	}
+}
+
+// getMetadataBuffer returns an empty buffer from the pool to assemble metadata blocks into
+func getMetadataBuffer() *bytes.Buffer {
+	buf := metadataBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putMetadataBuffer gives a buffer back to the pool
+func putMetadataBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledMetadataBuffer {
+		metadataBufferPool.Put(buf)
+	}
+}
@@ -0,0 +1,174 @@
+package flacgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// sectionCloser is a part of the output read from a section of a file, it can be seeked
+type sectionCloser struct {
+	*io.SectionReader
+}
+
+func (sectionCloser) Close() error {
+	return nil
+}
+
+// Reader serves the FLAC file with the staged changes applied, as Save would write it, without any
+// temporary file: the rebuilt metadata is kept in memory while pictures and audio are streamed from
+// their sources. It implements io.ReadSeekCloser, e.g. for http.ServeContent, and it's not safe for
+// concurrent use.
+type Reader struct {
+	out    *output
+	starts []int64
+	size   int64
+	offset int64
+
+	// current is the open part being read, at currentOffset of the output
+	current       io.ReadCloser
+	currentPart   int
+	currentOffset int64
+}
+
+// NewReader returns a Reader of the file with the changes staged so far, e.g. to inject per-user tags
+// or strip pictures while serving downloads. Changes staged later are not taken into account.
+// Close must be called once done.
+func (flac *Flac) NewReader() (*Reader, error) {
+	var out *output
+	err := flac.withTotalTags(func() (err error) {
+		out, err = flac.prepareOutput()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare FLAC file: %w", err)
+	}
+
+	r := &Reader{out: out, starts: make([]int64, len(out.parts))}
+	for i := range out.parts {
+		r.starts[i] = r.size
+		r.size += r.partLength(i)
+	}
+	return r, nil
+}
+
+// partLength returns the length of the i-th part of the output
+func (r *Reader) partLength(i int) int64 {
+	part := r.out.parts[i]
+	if part.open == nil {
+		return int64(part.end - part.start)
+	}
+	return part.length
+}
+
+// Size returns the length of the whole file
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Read reads from the current offset
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.out == nil {
+		return 0, errors.New("read on closed reader")
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	i := sort.Search(len(r.starts), func(i int) bool {
+		return r.starts[i]+r.partLength(i) > r.offset
+	})
+	part := r.out.parts[i]
+	within := r.offset - r.starts[i]
+	remaining := r.partLength(i) - within
+
+	if part.open == nil {
+		n := copy(p, r.out.metadata.Bytes()[part.start+int(within):part.end])
+		r.offset += int64(n)
+		return n, nil
+	}
+
+	if r.current == nil || r.currentPart != i || r.currentOffset != r.offset {
+		if err := r.openPart(i, within); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.current.Read(p[:min(int64(len(p)), remaining)])
+	r.offset += int64(n)
+	r.currentOffset = r.offset
+	if err == io.EOF {
+		if int64(n) < remaining {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, fmt.Errorf("unable to read data: expected %d more bytes: %w", remaining, io.ErrUnexpectedEOF)
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// openPart opens the i-th part of the output positioned at within bytes from its start
+func (r *Reader) openPart(i int, within int64) error {
+	r.closeCurrent()
+
+	part, err := r.out.parts[i].open()
+	if err != nil {
+		return err
+	}
+	if seeker, ok := part.(io.Seeker); ok {
+		_, err = seeker.Seek(within, io.SeekStart)
+	} else {
+		// Staged pictures are streamed from their source, skip to the offset
+		_, err = io.CopyN(io.Discard, part, within)
+	}
+	if err != nil {
+		part.Close()
+		return fmt.Errorf("unable to seek data: %w", err)
+	}
+
+	r.current, r.currentPart, r.currentOffset = part, i, r.offset
+	return nil
+}
+
+// closeCurrent closes the open part, if any
+func (r *Reader) closeCurrent() {
+	if r.current != nil {
+		r.current.Close()
+		r.current = nil
+	}
+}
+
+// Seek sets the offset of the next Read like io.Seeker
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close releases the metadata and closes the sources being read
+func (r *Reader) Close() error {
+	if r.out == nil {
+		return nil
+	}
+	r.closeCurrent()
+	r.out.release()
+	r.out = nil
+	return nil
+}
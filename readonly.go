@@ -0,0 +1,33 @@
+package flacgo
+
+import "fmt"
+
+// ReadOnlyError is returned by the methods staging changes, and by Save, on a file opened with OpenReadOnly
+type ReadOnlyError struct {
+	// Path is the path of the file, empty for streams
+	Path string
+}
+
+func (err *ReadOnlyError) Error() string {
+	if err.Path == "" {
+		return "file is opened read-only"
+	}
+	return fmt.Sprintf("'%s' is opened read-only", err.Path)
+}
+
+// OpenReadOnly opens the FLAC file at path like Open, for reading only. Every method staging a change
+// and Save return a *ReadOnlyError, and no state for pending changes is allocated, which suits
+// scanners and guarantees the file is never rewritten by mistake. WriteTo still copies the file.
+func OpenReadOnly(path string, opts ...Option) (*Flac, error) {
+	return Open(path, append(opts, func(o *options) {
+		o.readOnly = true
+	})...)
+}
+
+// checkWritable fails if the file was opened with OpenReadOnly
+func (flac *Flac) checkWritable() error {
+	if flac.options.readOnly {
+		return &ReadOnlyError{Path: flac.fileName}
+	}
+	return nil
+}
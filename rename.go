@@ -0,0 +1,275 @@
+package flacgo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrRenameCollision is returned for the files a rename plan would move onto the same path,
+// or onto a file that is not part of the plan
+var ErrRenameCollision = errors.New("rename collision")
+
+// Rename is the move of one file planned by PlanRename
+type Rename struct {
+	Old string
+	New string
+	// Err is set when the file can't be renamed: unreadable, missing a tag of the template or colliding
+	Err error
+}
+
+// String returns the rename as "old -> new"
+func (rename Rename) String() string {
+	return rename.Old + " -> " + rename.New
+}
+
+// RenamePlan is the list of moves PlanRename computed, to review before calling Apply
+type RenamePlan struct {
+	Renames []Rename
+}
+
+// renamePart is a literal text or a placeholder of a rename template
+type renamePart struct {
+	literal string
+	// keys are the tags of the placeholder, the first one found is used
+	keys []string
+	// width zero pads numbers like TRACKNUMBER, "3/12" becomes "03" with a width of 2
+	width int
+}
+
+// parseRenameTemplate splits template in literal texts and placeholders
+func parseRenameTemplate(template string) ([]renamePart, error) {
+	var parts []renamePart
+	for template != "" {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			parts = append(parts, renamePart{literal: template})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, renamePart{literal: template[:start]})
+		}
+
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid rename template: unclosed '{' at %d", start)
+		}
+		placeholder := template[start+1 : start+end]
+		template = template[start+end+1:]
+
+		part := renamePart{}
+		if keys, width, found := strings.Cut(placeholder, ":"); found {
+			n, err := strconv.Atoi(width)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid rename template: invalid width in '{%s}'", placeholder)
+			}
+			placeholder, part.width = keys, n
+		}
+		for _, key := range strings.Split(placeholder, "|") {
+			if key = strings.TrimSpace(key); key == "" {
+				return nil, fmt.Errorf("invalid rename template: empty tag in '{%s}'", placeholder)
+			}
+			part.keys = append(part.keys, key)
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid rename template: empty template")
+	}
+	return parts, nil
+}
+
+// sanitizePathComponent replaces the characters that are not allowed in file names on common filesystems
+func sanitizePathComponent(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, value)
+	return strings.TrimRight(strings.TrimSpace(value), ".")
+}
+
+// expandRenameTemplate returns the path of the template for the given comments, relative to the file directory
+// unless the template is absolute
+func expandRenameTemplate(parts []renamePart, comments []VorbisComment) (string, error) {
+	var path strings.Builder
+	for _, part := range parts {
+		if part.keys == nil {
+			path.WriteString(part.literal)
+			continue
+		}
+
+		var value string
+		for _, key := range part.keys {
+			if comment, found := findComment(comments, key); found && strings.TrimSpace(comment.Value) != "" {
+				value = comment.Value
+				break
+			}
+		}
+		if value == "" {
+			return "", fmt.Errorf("missing %s", strings.Join(part.keys, " or "))
+		}
+		if part.width > 0 {
+			number, _, _ := strings.Cut(value, "/")
+			if n, err := strconv.Atoi(strings.TrimSpace(number)); err == nil {
+				value = fmt.Sprintf("%0*d", part.width, n)
+			}
+		}
+		path.WriteString(sanitizePathComponent(value))
+	}
+	return filepath.Clean(path.String()), nil
+}
+
+// PlanRename computes where the template moves each file, without touching them.
+// Placeholders are tag names in braces, e.g. "{ALBUMARTIST|ARTIST}/{ALBUM}/{TRACKNUMBER:2} {TITLE}":
+// "|" separates fallbacks and ":N" zero pads numbers to N digits. Slashes in the template create
+// directories, relative paths are relative to the directory of each file and the file extension
+// is kept. Files that can't be renamed, including collisions, have their Err set.
+func PlanRename(paths []string, template string) (*RenamePlan, error) {
+	parts, err := parseRenameTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RenamePlan{Renames: make([]Rename, len(paths))}
+	for i, path := range paths {
+		rename := &plan.Renames[i]
+		rename.Old, rename.New = path, path
+
+		flac, err := Open(path)
+		if err != nil {
+			rename.Err = err
+			continue
+		}
+		target, err := expandRenameTemplate(parts, flac.Comments())
+		flac.Close()
+		if err != nil {
+			rename.Err = err
+			continue
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		rename.New = target + filepath.Ext(path)
+	}
+
+	plan.detectCollisions()
+	return plan, nil
+}
+
+// detectCollisions sets the Err of the renames whose target is used by another file of the plan,
+// or exists and is not moved away. Paths are compared ignoring case for case-insensitive filesystems.
+func (plan *RenamePlan) detectCollisions() {
+	key := func(path string) string {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		return strings.ToLower(path)
+	}
+
+	// Every file ends up at New, the failed ones stay where they are
+	targets := make(map[string][]int)
+	sources := make(map[string]bool)
+	for i, rename := range plan.Renames {
+		final := rename.New
+		if rename.Err != nil {
+			final = rename.Old
+		}
+		targets[key(final)] = append(targets[key(final)], i)
+		sources[key(rename.Old)] = true
+	}
+
+	for i := range plan.Renames {
+		rename := &plan.Renames[i]
+		if rename.Err != nil || rename.New == rename.Old {
+			continue
+		}
+
+		for _, other := range targets[key(rename.New)] {
+			if other != i {
+				rename.Err = fmt.Errorf("%w: '%s' is renamed to the same path", ErrRenameCollision, plan.Renames[other].Old)
+				break
+			}
+		}
+		if rename.Err != nil || sources[key(rename.New)] {
+			continue
+		}
+
+		// The target may be the file itself when only the case changes
+		if info, err := os.Lstat(rename.New); err == nil {
+			if old, err := os.Stat(rename.Old); err != nil || !os.SameFile(info, old) {
+				rename.Err = fmt.Errorf("%w: '%s' already exists", ErrRenameCollision, rename.New)
+			}
+		}
+	}
+}
+
+// Conflicts returns the renames that can't be applied
+func (plan *RenamePlan) Conflicts() []Rename {
+	conflicts := make([]Rename, 0)
+	for _, rename := range plan.Renames {
+		if rename.Err != nil {
+			conflicts = append(conflicts, rename)
+		}
+	}
+	return conflicts
+}
+
+// Apply moves the files, creating the missing directories. Nothing is moved if the plan has conflicts.
+// Files are first moved to temporary names so that files swapping names are renamed too,
+// on failure the files already moved are put back.
+func (plan *RenamePlan) Apply() error {
+	if conflicts := plan.Conflicts(); len(conflicts) > 0 {
+		return fmt.Errorf("unable to rename files: %d conflicts, first is '%s': %w", len(conflicts), conflicts[0].Old, conflicts[0].Err)
+	}
+
+	type move struct {
+		rename Rename
+		temp   string
+	}
+	var moved, placed []move
+	rollback := func() {
+		for _, m := range placed {
+			os.Rename(m.rename.New, m.temp)
+		}
+		for _, m := range moved {
+			os.Rename(m.temp, m.rename.Old)
+		}
+	}
+
+	for i, rename := range plan.Renames {
+		if rename.New == rename.Old {
+			continue
+		}
+		temp := filepath.Join(filepath.Dir(rename.Old), fmt.Sprintf(".flacgo-rename-%d-%s", i, filepath.Base(rename.Old)))
+		if err := os.Rename(rename.Old, temp); err != nil {
+			rollback()
+			return fmt.Errorf("unable to rename '%s': %w", rename.Old, err)
+		}
+		moved = append(moved, move{rename, temp})
+	}
+
+	for _, m := range moved {
+		if _, err := os.Lstat(m.rename.New); err == nil {
+			rollback()
+			return fmt.Errorf("unable to rename '%s': %w: '%s' already exists", m.rename.Old, ErrRenameCollision, m.rename.New)
+		}
+		if err := os.MkdirAll(filepath.Dir(m.rename.New), 0755); err != nil {
+			rollback()
+			return fmt.Errorf("unable to create directory for '%s': %w", m.rename.New, err)
+		}
+		if err := os.Rename(m.temp, m.rename.New); err != nil {
+			rollback()
+			return fmt.Errorf("unable to rename '%s': %w", m.rename.Old, err)
+		}
+		placed = append(placed, m)
+	}
+
+	return nil
+}
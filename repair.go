@@ -0,0 +1,416 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// repairedBlock is a metadata block kept by a repair, its header is rebuilt when writing
+type repairedBlock struct {
+	blockType uint8
+	data      []byte
+}
+
+// repair is the result of scanning a damaged file: the blocks to write, where the audio starts
+// and the fixes the rewrite applies
+type repair struct {
+	src        io.ReaderAt
+	size       int64
+	blocks     []repairedBlock
+	audioStart int64
+	fixes      []Issue
+	streamInfo *StreamInfo
+}
+
+func (r *repair) fix(code string, format string, args ...any) {
+	r.fixes = append(r.fixes, Issue{SeverityInfo, code, fmt.Sprintf(format, args...)})
+}
+
+// read returns length bytes at offset, nil if they are past the end of the file
+func (r *repair) read(offset int64, length int64) []byte {
+	if offset < 0 || length < 0 || offset+length > r.size {
+		return nil
+	}
+	data := make([]byte, length)
+	if _, err := r.src.ReadAt(data, offset); err != nil {
+		return nil
+	}
+	return data
+}
+
+// isFrameStart reports whether a frame header with a valid CRC-8 starts at offset
+func (r *repair) isFrameStart(offset int64) bool {
+	// The longest frame header is 16 bytes
+	header := r.read(offset, min(16, r.size-offset))
+	if len(header) < 2 || header[0] != 0xFF || header[1]&0xFE != 0xF8 {
+		return false
+	}
+
+	streamInfo := r.streamInfo
+	if streamInfo == nil {
+		streamInfo = &StreamInfo{}
+	}
+	decoder := &Decoder{streamInfo: streamInfo, br: newBitReaderBytes(header)}
+	_, err := decoder.readFrameHeader()
+	return err == nil
+}
+
+// findFrameStart returns the offset of the first frame at or after offset, -1 if there is none
+func (r *repair) findFrameStart(offset int64) int64 {
+	const chunkSize = 64 * 1024
+	for ; offset < r.size; offset += chunkSize {
+		chunk := r.read(offset, min(chunkSize, r.size-offset))
+		for i := 0; i < len(chunk); i++ {
+			if chunk[i] == 0xFF && r.isFrameStart(offset+int64(i)) {
+				return offset + int64(i)
+			}
+		}
+	}
+	return -1
+}
+
+// isBlockStart reports whether a metadata block of a known type, fitting in the file, starts at offset
+func (r *repair) isBlockStart(offset int64) bool {
+	header := r.read(offset, 4)
+	if header == nil {
+		return false
+	}
+	blockType := header[0] & 0x7F
+	length := int64(binary.BigEndian.Uint32([]byte{0, header[1], header[2], header[3]}))
+	return blockType <= 6 && offset+4+length <= r.size
+}
+
+// naturalLength returns the length of the block body at offset computed from its content,
+// -1 for the block types whose length can't be deduced
+func (r *repair) naturalLength(blockType uint8, offset int64) int64 {
+	readUint32 := func(at int64, order binary.ByteOrder) (int64, bool) {
+		data := r.read(at, 4)
+		if data == nil {
+			return 0, false
+		}
+		return int64(order.Uint32(data)), true
+	}
+
+	switch BlockMapping[blockType] {
+	case "STREAMINFO":
+		return 34
+	case "VORBIS_COMMENT":
+		// Vendor string, comment count and comments, all little endian
+		vendor, ok := readUint32(offset, binary.LittleEndian)
+		if !ok {
+			return -1
+		}
+		at := offset + 4 + vendor
+		count, ok := readUint32(at, binary.LittleEndian)
+		if !ok {
+			return -1
+		}
+		at += 4
+		for i := int64(0); i < count; i++ {
+			length, ok := readUint32(at, binary.LittleEndian)
+			if !ok {
+				return -1
+			}
+			at += 4 + length
+		}
+		return at - offset
+	case "PICTURE":
+		// Type, MIME type, description, 4 numeric fields and the image data, all big endian
+		at := offset + 4
+		for i := 0; i < 3; i++ {
+			length, ok := readUint32(at, binary.BigEndian)
+			if !ok {
+				return -1
+			}
+			at += 4 + length
+			if i == 1 {
+				at += 16
+			}
+		}
+		return at - offset
+	}
+	return -1
+}
+
+// checkRepairedBody returns an error if the body of a block of a known type can't be parsed
+func checkRepairedBody(blockType uint8, data []byte) error {
+	switch BlockMapping[blockType] {
+	case "STREAMINFO":
+		_, err := parseStreamInfoBlock(data)
+		return err
+	case "VORBIS_COMMENT":
+		_, err := (&Flac{}).parseVorbisBlock(data)
+		return err
+	case "PICTURE":
+		_, err := parsePictureBlock(data)
+		return err
+	case "SEEKTABLE":
+		if len(data)%18 != 0 {
+			return fmt.Errorf("SEEKTABLE length %d is not a multiple of 18", len(data))
+		}
+	}
+	return nil
+}
+
+// scanRepair walks the metadata blocks of src as far as they can be read
+func scanRepair(src io.ReaderAt, size int64) (*repair, error) {
+	r := &repair{src: src, size: size}
+	if magic := r.read(0, 4); string(magic) != "fLaC" {
+		return nil, fmt.Errorf("invalid FLAC format file, no 'fLaC' header")
+	}
+
+	offset := int64(4)
+	for index := 0; ; index++ {
+		if r.isFrameStart(offset) {
+			// The audio starts without a block flagged as last
+			r.fix("fixed-last-block", "set the last-block flag on the last metadata block")
+			r.audioStart = offset
+			break
+		}
+
+		header := r.read(offset, 4)
+		if header == nil || !r.isBlockStart(offset) {
+			audioStart := r.findFrameStart(offset)
+			if audioStart < 0 {
+				return nil, fmt.Errorf("unable to find the audio frames after offset %d", offset)
+			}
+			r.fix("dropped-unreadable-blocks", "dropped %d unreadable bytes of metadata at offset %d", audioStart-offset, offset)
+			r.audioStart = audioStart
+			break
+		}
+
+		blockType := header[0] & 0x7F
+		isLast := header[0]&0x80 != 0
+		length := int64(binary.BigEndian.Uint32([]byte{0, header[1], header[2], header[3]}))
+		plausibleEnd := func(end int64) bool {
+			if isLast {
+				return r.isFrameStart(end)
+			}
+			return r.isFrameStart(end) || r.isBlockStart(end)
+		}
+		// The length deduced from the content wins, random bytes may look like a block header
+		if natural := r.naturalLength(blockType, offset+4); natural >= 0 && natural != length && plausibleEnd(offset+4+natural) {
+			r.fix("fixed-block-length", "%s block #%d declares %d bytes, its content has %d", BlockMapping[blockType], index, length, natural)
+			length = natural
+		}
+
+		data := r.read(offset+4, length)
+		if err := checkRepairedBody(blockType, data); err != nil {
+			if BlockMapping[blockType] == "STREAMINFO" {
+				return nil, fmt.Errorf("unable to repair STREAMINFO: %w", err)
+			}
+			r.fix("dropped-unreadable-blocks", "dropped %s block #%d: %v", BlockMapping[blockType], index, err)
+		} else {
+			if BlockMapping[blockType] == "STREAMINFO" && r.streamInfo == nil {
+				r.streamInfo, _ = parseStreamInfoBlock(data)
+			}
+			r.blocks = append(r.blocks, repairedBlock{blockType, data})
+		}
+		offset += 4 + length
+
+		if isLast {
+			if !r.isFrameStart(offset) {
+				audioStart := r.findFrameStart(offset)
+				if audioStart < 0 {
+					return nil, fmt.Errorf("unable to find the audio frames after offset %d", offset)
+				}
+				r.fix("dropped-unreadable-blocks", "dropped %d unreadable bytes after the metadata at offset %d", audioStart-offset, offset)
+				offset = audioStart
+			}
+			r.audioStart = offset
+			break
+		}
+	}
+
+	return r, r.normalize()
+}
+
+// normalize puts STREAMINFO first, merges the VORBIS_COMMENT blocks and drops duplicate blocks
+func (r *repair) normalize() error {
+	var streamInfo, vorbisComment *repairedBlock
+	var comments []VorbisComment
+	var others []repairedBlock
+	seekTables := 0
+
+	for i, block := range r.blocks {
+		switch BlockMapping[block.blockType] {
+		case "STREAMINFO":
+			if streamInfo != nil {
+				r.fix("dropped-duplicate-block", "dropped duplicate STREAMINFO block #%d", i)
+				continue
+			}
+			if i != 0 {
+				r.fix("reordered-blocks", "moved STREAMINFO from block #%d to the first block", i)
+			}
+			streamInfo = &r.blocks[i]
+		case "VORBIS_COMMENT":
+			parsed, _ := (&Flac{}).parseVorbisBlock(block.data)
+			if vorbisComment != nil {
+				r.fix("merged-vorbis-comments", "merged VORBIS_COMMENT block #%d into the first one", i)
+				for _, comment := range parsed {
+					if !containsComment(comments, comment) {
+						comments = append(comments, comment)
+					}
+				}
+				continue
+			}
+			vorbisComment = &repairedBlock{block.blockType, block.data}
+			comments = parsed
+			others = append(others, repairedBlock{})
+		case "SEEKTABLE":
+			if seekTables++; seekTables > 1 {
+				r.fix("dropped-duplicate-block", "dropped duplicate SEEKTABLE block #%d", i)
+				continue
+			}
+			others = append(others, block)
+		default:
+			others = append(others, block)
+		}
+	}
+	if streamInfo == nil {
+		return fmt.Errorf("unable to repair a file without STREAMINFO")
+	}
+
+	blocks := []repairedBlock{*streamInfo}
+	for _, block := range others {
+		if block.data == nil && vorbisComment != nil {
+			// The merged VORBIS_COMMENT takes the place of the first one
+			block = repairedBlock{vorbisComment.blockType, encodeVorbisBody(vorbisComment.data, comments)}
+		}
+		blocks = append(blocks, block)
+	}
+	r.blocks = blocks
+	return nil
+}
+
+// containsComment reports whether comments has the same title, ignoring case, and value
+func containsComment(comments []VorbisComment, comment VorbisComment) bool {
+	for _, other := range comments {
+		if strings.EqualFold(other.Title, comment.Title) && other.Value == comment.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeVorbisBody encodes comments as a VORBIS_COMMENT body with the vendor string of original
+func encodeVorbisBody(original []byte, comments []VorbisComment) []byte {
+	vendor, _, _, _ := vorbisHeader(original)
+	body := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	body = append(body, vendor...)
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(comments)))
+	for _, comment := range comments {
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(comment.Title)+1+len(comment.Value)))
+		body = append(body, comment.Title+"="+comment.Value...)
+	}
+	return body
+}
+
+// writeTo writes the repaired file: the magic header, the blocks with rebuilt headers and the audio
+func (r *repair) writeTo(w io.Writer) error {
+	metadata := []byte("fLaC")
+	for i, block := range r.blocks {
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(block.data)))
+		header[0] = block.blockType
+		if i == len(r.blocks)-1 {
+			header[0] |= 0x80
+		}
+		metadata = append(append(metadata, header...), block.data...)
+	}
+
+	if _, err := w.Write(metadata); err != nil {
+		return fmt.Errorf("unable to write metadata: %w", err)
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(r.src, r.audioStart, r.size-r.audioStart)); err != nil {
+		return fmt.Errorf("unable to write audio: %w", err)
+	}
+	return nil
+}
+
+// openRepair scans the file at path
+func openRepair(path string) (*os.File, *repair, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open '%s': %w", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("unable to stat '%s': %w", path, err)
+	}
+
+	r, err := scanRepair(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, r, nil
+}
+
+// PlanRepair returns the fixes Repair would apply to the file at path, without writing anything
+func PlanRepair(path string) ([]Issue, error) {
+	f, r, err := openRepair(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return r.fixes, nil
+}
+
+// Repair fixes the structural problems of the file at path that can be fixed, even when Open rejects it:
+// it moves STREAMINFO first, merges duplicate VORBIS_COMMENT blocks, drops duplicate STREAMINFO and
+// SEEKTABLE blocks, recomputes wrong block lengths from the content of the blocks, sets a missing
+// last-block flag and drops the unreadable blocks before the audio. The audio is copied unchanged.
+// It returns the fixes applied, as issues of severity info; the file is written only if there are any,
+// to outputPath if not nil, in place otherwise.
+func Repair(path string, outputPath *string) ([]Issue, error) {
+	f, r, err := openRepair(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if len(r.fixes) == 0 {
+		return nil, nil
+	}
+
+	outFileName := path
+	if outputPath != nil {
+		outFileName = *outputPath
+	}
+
+	outFile, err := os.CreateTemp(filepath.Dir(outFileName), ".flacgo-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file '%s': %w", outFileName, err)
+	}
+	tempName := outFile.Name()
+	defer os.Remove(tempName)
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(outFileName); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	err = r.writeTo(outFile)
+	if err == nil {
+		err = outFile.Chmod(mode)
+	}
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to write repaired file: %w", err)
+	}
+
+	if err := os.Rename(tempName, outFileName); err != nil {
+		return nil, fmt.Errorf("unable to create file '%s': %w", outFileName, err)
+	}
+
+	return r.fixes, nil
+}
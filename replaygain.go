@@ -0,0 +1,189 @@
+package flacgo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ReplayGainMode tells which ReplayGain tags are applied when decoding
+type ReplayGainMode int
+
+const (
+	// ReplayGainOff decodes the audio as it is
+	ReplayGainOff ReplayGainMode = iota
+	// ReplayGainTrack applies REPLAYGAIN_TRACK_GAIN
+	ReplayGainTrack
+	// ReplayGainAlbum applies REPLAYGAIN_ALBUM_GAIN, or the track gain for files without album gain
+	ReplayGainAlbum
+)
+
+// replayGain is the gain applied by the decoding methods, see WithReplayGain
+type replayGain struct {
+	mode   ReplayGainMode
+	preamp float64
+}
+
+// WithReplayGain makes DecodePCM, ExportWAV and ExportAIFF apply the track or album gain of the
+// ReplayGain tags plus preamp dB, e.g. to generate normalized previews. The gain is lowered so that
+// the peak of the tags doesn't clip, and samples still out of range are clamped. Files without
+// ReplayGain tags only get the preamp. Verify and Decoder always return the original samples.
+func WithReplayGain(mode ReplayGainMode, preamp float64) Option {
+	return func(o *options) {
+		o.replayGain = replayGain{mode: mode, preamp: preamp}
+	}
+}
+
+// replayGainScale returns the factor samples are multiplied by on decode, 1 without WithReplayGain
+func (flac *Flac) replayGainScale() (float64, error) {
+	settings := flac.options.replayGain
+	if settings.mode == ReplayGainOff {
+		return 1, nil
+	}
+
+	comments := flac.Comments()
+	gainKey, peakKey := "REPLAYGAIN_TRACK_GAIN", "REPLAYGAIN_TRACK_PEAK"
+	if _, found := findComment(comments, "REPLAYGAIN_ALBUM_GAIN"); found && settings.mode == ReplayGainAlbum {
+		gainKey, peakKey = "REPLAYGAIN_ALBUM_GAIN", "REPLAYGAIN_ALBUM_PEAK"
+	}
+
+	gain := settings.preamp
+	if comment, found := findComment(comments, gainKey); found {
+		value, err := parseReplayGain(comment.Value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", gainKey, err)
+		}
+		gain += value
+	}
+	scale := math.Pow(10, gain/20)
+
+	// Clipping protection
+	if comment, found := findComment(comments, peakKey); found {
+		peak, err := strconv.ParseFloat(strings.TrimSpace(comment.Value), 64)
+		if err != nil || peak < 0 {
+			return 0, fmt.Errorf("invalid %s %q", peakKey, comment.Value)
+		}
+		if peak > 0 && peak*scale > 1 {
+			scale = 1 / peak
+		}
+	}
+
+	return scale, nil
+}
+
+// parseReplayGain parses a gain like "-6.48 dB"
+func parseReplayGain(value string) (float64, error) {
+	number := strings.TrimSpace(value)
+	if len(number) > 2 && strings.EqualFold(number[len(number)-2:], "dB") {
+		number = strings.TrimSpace(number[:len(number)-2])
+	}
+	gain, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsInf(gain, 0) || math.IsNaN(gain) {
+		return 0, fmt.Errorf("%q is not a gain in dB", value)
+	}
+	return gain, nil
+}
+
+// applyGain multiplies samples by scale in place, clamping them to the range of bitsPerSample
+func applyGain(samples [][]int32, scale float64, bitsPerSample uint8) {
+	if scale == 1 {
+		return
+	}
+
+	maxSample := float64(int64(1)<<(bitsPerSample-1) - 1)
+	minSample := -maxSample - 1
+	for _, channel := range samples {
+		for i, sample := range channel {
+			channel[i] = int32(math.Max(minSample, math.Min(maxSample, math.Round(float64(sample)*scale))))
+		}
+	}
+}
+
+// ReplayGainReference is the loudness in LUFS a gain of 0 dB stands for, as in ReplayGain 2.0
+const ReplayGainReference = -18.0
+
+// AlbumGain is the result of ScanAlbumGain
+type AlbumGain struct {
+	// Gain is the album gain in dB
+	Gain float64
+	// Peak is the highest sample of the album, 1 being full scale
+	Peak float64
+	// Loudness is the integrated loudness of the album in LUFS
+	Loudness float64
+	// Results hold the tags written to each file, failures of single files are reported here
+	Results []TransformResult
+}
+
+// ScanAlbumGain measures the loudness of the files at paths as a single album, following ReplayGain 2.0
+// (EBU R128 loudness with a -18 LUFS reference), and writes the same REPLAYGAIN_ALBUM_GAIN and
+// REPLAYGAIN_ALBUM_PEAK to every file. Nothing is written if a file can't be decoded. The files are
+// read with opts, e.g. WithParallelDecode.
+func ScanAlbumGain(paths []string, opts ...Option) (*AlbumGain, error) {
+	var blocks []float64
+	peak := 0.0
+	for _, path := range paths {
+		meter, err := measureFile(path, opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan '%s': %w", path, err)
+		}
+		blocks = append(blocks, meter.blocks()...)
+		peak = max(peak, meter.peak)
+	}
+
+	loudness, ok := integratedLoudness(blocks)
+	if !ok {
+		return nil, fmt.Errorf("unable to compute album gain: the album is silent or shorter than 400ms")
+	}
+
+	album := &AlbumGain{Gain: ReplayGainReference - loudness, Peak: peak, Loudness: loudness}
+	tags := []VorbisComment{
+		{Title: "REPLAYGAIN_ALBUM_GAIN", Value: fmt.Sprintf("%.2f dB", album.Gain)},
+		{Title: "REPLAYGAIN_ALBUM_PEAK", Value: fmt.Sprintf("%.6f", album.Peak)},
+	}
+
+	album.Results = make([]TransformResult, 0, len(paths))
+	for _, path := range paths {
+		changes, err := transformFile(path, func(flac *Flac) ([]TagChange, error) {
+			var changes []TagChange
+			comments := flac.Comments()
+			for _, tag := range tags {
+				previous, _ := findComment(comments, tag.Title)
+				if previous.Value == tag.Value {
+					continue
+				}
+				if err := flac.SetMetadata(tag.Title, tag.Value); err != nil {
+					return nil, err
+				}
+				changes = append(changes, TagChange{Key: tag.Title, Old: previous.Value, New: tag.Value})
+			}
+			return changes, nil
+		})
+		album.Results = append(album.Results, TransformResult{Path: path, Changes: changes, Err: err})
+	}
+
+	return album, nil
+}
+
+// measureFile decodes the file at path, opened with opts, through a loudness meter
+func measureFile(path string, opts []Option) (*loudnessMeter, error) {
+	flac, err := OpenReadOnly(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	meter := newLoudnessMeter(streamInfo)
+	err = flac.decodeOriginalFrames(streamInfo, func(samples [][]int32) error {
+		meter.add(samples)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return meter, nil
+}
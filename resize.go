@@ -0,0 +1,82 @@
+package flacgo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// resizeImage scales img down so neither side exceeds maxDimension, keeping its aspect ratio.
+// Every pixel is the average of the source pixels it covers, which keeps covers sharp without
+// the aliasing of nearest neighbour sampling. Images already small enough are returned as is.
+func resizeImage(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return img
+	}
+
+	newWidth, newHeight := maxDimension, maxDimension
+	if width > height {
+		newHeight = max(1, height*maxDimension/width)
+	} else {
+		newWidth = max(1, width*maxDimension/height)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		top, bottom := y*height/newHeight, max((y+1)*height/newHeight, y*height/newHeight+1)
+		for x := 0; x < newWidth; x++ {
+			left, right := x*width/newWidth, max((x+1)*width/newWidth, x*width/newWidth+1)
+
+			var sum [4]int
+			for sy := top; sy < bottom; sy++ {
+				row := src.Pix[sy*src.Stride+left*4 : sy*src.Stride+right*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			count := (bottom - top) * (right - left)
+			offset := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[offset+i] = uint8((sum[i] + count/2) / count)
+			}
+		}
+	}
+	return dst
+}
+
+// resizeJPEG decodes the image data and returns it scaled down so neither side exceeds
+// maxDimension, encoded as JPEG of the given quality (jpeg.DefaultQuality if 0).
+// It returns nil if the image is small enough already.
+func resizeJPEG(data []byte, maxDimension int, quality int) ([]byte, error) {
+	if maxDimension <= 0 {
+		return nil, fmt.Errorf("invalid maximum dimension %d", maxDimension)
+	}
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode picture: %w", err)
+	}
+	resized := resizeImage(img, maxDimension)
+	if resized == img {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("unable to encode picture: %w", err)
+	}
+	return buf.Bytes(), nil
+}
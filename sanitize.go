@@ -0,0 +1,63 @@
+package flacgo
+
+import (
+	"strings"
+	"unicode"
+)
+
+// invisibleRunes are the zero-width and formatting characters dropped by SanitizeValue. Zero-width
+// joiners and non-joiners are kept since emoji sequences and some scripts need them.
+var invisibleRunes = map[rune]bool{
+	'\u00AD': true, // soft hyphen
+	'\u180E': true, // Mongolian vowel separator
+	'\u200B': true, // zero-width space
+	'\u2060': true, // word joiner
+	'\uFEFF': true, // byte order mark
+}
+
+// SanitizeValue returns value without the characters that make equal tags look different to players,
+// a common source of "duplicate artists": control characters and zero-width characters are dropped,
+// the other spaces (tabs, non-breaking spaces, ...) become plain spaces, runs of spaces are collapsed
+// to one and every line is trimmed. Line breaks are kept for multi-line tags such as LYRICS, but the
+// blank lines at the start and at the end of the value are dropped.
+func SanitizeValue(value string) string {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		var sanitized strings.Builder
+		space := false
+		for _, r := range line {
+			switch {
+			case unicode.IsSpace(r):
+				space = true
+			case unicode.IsControl(r) || invisibleRunes[r]:
+			default:
+				if space && sanitized.Len() > 0 {
+					sanitized.WriteByte(' ')
+				}
+				space = false
+				sanitized.WriteRune(r)
+			}
+		}
+		lines[i] = sanitized.String()
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SanitizeTransform returns a Transform applying SanitizeValue to the value of every comment
+func SanitizeTransform() Transform {
+	return MapValues(nil, SanitizeValue)
+}
+
+// WithSanitizedValues makes the SetMetadata methods stage values cleaned with SanitizeValue
+func WithSanitizedValues() Option {
+	return func(o *options) {
+		o.sanitizeValues = true
+	}
+}
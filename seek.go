@@ -0,0 +1,135 @@
+package flacgo
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// SeekPosition is the frame holding a point in time of the audio
+type SeekPosition struct {
+	// Sample is the first sample of the frame
+	Sample uint64 `json:"sample"`
+	// Time is the time of Sample, at or before the requested one
+	Time time.Duration `json:"time"`
+	// Offset is the position of the frame in the file, in bytes
+	Offset int64 `json:"offset"`
+}
+
+// SeekOffset returns the frame holding the sample at position, e.g. for a streaming server to turn a
+// time-based seek request into a byte offset. The search starts from the closest point of the SEEKTABLE
+// at or before position, if any, and goes on reading the frames from there, so the result is exact even
+// with a sparse SEEKTABLE. It fails if position is beyond the end of the audio.
+func (flac *Flac) SeekOffset(position time.Duration) (*SeekPosition, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	if position < 0 {
+		return nil, fmt.Errorf("invalid position %s", position)
+	}
+	sample := durationSamples(position, streamInfo.SampleRate)
+	if streamInfo.TotalSamples != 0 && sample >= streamInfo.TotalSamples {
+		return nil, fmt.Errorf("position %s is beyond the end of the audio, %s long", position, streamInfo.Duration())
+	}
+
+	start, end, err := flac.frameAt(streamInfo, sample)
+	if err != nil {
+		return nil, err
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("position %s is beyond the end of the audio", position)
+	}
+	return &SeekPosition{
+		Sample: start.Sample,
+		Time:   samplesDuration(start.Sample, streamInfo.SampleRate),
+		Offset: start.Offset,
+	}, nil
+}
+
+// TimeRange returns the bytes of the file holding the audio from start to end: offset is the first byte
+// of the frame holding start, length runs to the end of the frame holding the sample just before end.
+// An end of 0 or beyond the audio runs to the end of the audio. The frames can be served as an HTTP
+// byte range, e.g. "bytes=offset-(offset+length-1)"; players need the magic header and STREAMINFO
+// before them to decode, see MetadataBytes.
+func (flac *Flac) TimeRange(start, end time.Duration) (offset int64, length int64, err error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return 0, 0, err
+	}
+	if end != 0 && end <= start {
+		return 0, 0, fmt.Errorf("invalid range from %s to %s", start, end)
+	}
+
+	first, err := flac.SeekOffset(start)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	audioEnd := flac.getAudioEndOffset()
+	last := durationSamples(end, streamInfo.SampleRate)
+	if end == 0 || (streamInfo.TotalSamples != 0 && last >= streamInfo.TotalSamples) {
+		return first.Offset, audioEnd - first.Offset, nil
+	}
+	_, frameEnd, err := flac.frameAt(streamInfo, max(last, first.Sample+1)-1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameEnd < 0 {
+		frameEnd = audioEnd
+	}
+	return first.Offset, frameEnd - first.Offset, nil
+}
+
+// frameAt returns the position of the frame holding sample and the offset following it, -1 if the
+// audio ends before sample
+func (flac *Flac) frameAt(streamInfo *StreamInfo, sample uint64) (SeekPosition, int64, error) {
+	position, offset, err := flac.seekPoint(sample)
+	if err != nil {
+		return SeekPosition{}, 0, err
+	}
+	decoder, err := flac.newDecoderAt(int64(offset))
+	if err != nil {
+		return SeekPosition{}, 0, err
+	}
+
+	for first := true; ; first = false {
+		frame, err := decoder.Next()
+		if first && (position != 0 || offset != 0) && (err != nil || !frameStartsAt(streamInfo, frame, position)) {
+			// The seek point doesn't lead to the right frame, start over from the first one
+			position, offset = 0, 0
+			if decoder, err = flac.NewDecoder(); err != nil {
+				return SeekPosition{}, 0, err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return SeekPosition{}, -1, nil
+		}
+		if err != nil {
+			return SeekPosition{}, 0, err
+		}
+
+		frameEnd := position + uint64(frame.Header.BlockSize)
+		if sample < frameEnd {
+			next := frame.Header.Offset + int64(frame.Length)
+			return SeekPosition{Sample: position, Offset: frame.Header.Offset}, next, nil
+		}
+		position = frameEnd
+	}
+}
+
+// durationSamples returns the number of samples at sampleRate lasting d
+func durationSamples(d time.Duration, sampleRate uint32) uint64 {
+	seconds, fraction := uint64(d/time.Second), uint64(d%time.Second)
+	return seconds*uint64(sampleRate) + fraction*uint64(sampleRate)/uint64(time.Second)
+}
+
+// samplesDuration returns how long samples at sampleRate last
+func samplesDuration(samples uint64, sampleRate uint32) time.Duration {
+	if sampleRate == 0 {
+		return 0
+	}
+	seconds, rest := samples/uint64(sampleRate), samples%uint64(sampleRate)
+	return time.Duration(seconds)*time.Second + time.Duration(rest*uint64(time.Second)/uint64(sampleRate))
+}
@@ -0,0 +1,98 @@
+package flacgo
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// Silence is the silence found at the start and at the end of the audio, in samples per channel
+type Silence struct {
+	Leading  uint64
+	Trailing uint64
+	// Total is the length of the whole audio, Leading is Total when the audio is all silence
+	Total uint64
+}
+
+// silenceScanner finds the first and the last samples above a limit across frames
+type silenceScanner struct {
+	limit    int64
+	position uint64
+	// first and last are the positions of the first loud sample and following the last one
+	first, last uint64
+	loud        bool
+}
+
+// newSilenceScanner returns a scanner treating as silence the samples at or below threshold dBFS
+func newSilenceScanner(threshold float64, bitsPerSample uint8) *silenceScanner {
+	limit := math.Pow(10, threshold/20) * float64(int64(1)<<(bitsPerSample-1))
+	return &silenceScanner{limit: int64(math.Floor(limit))}
+}
+
+func (s *silenceScanner) add(samples [][]int32) {
+	for i := range samples[0] {
+		for ch := range samples {
+			sample := int64(samples[ch][i])
+			if sample > s.limit || -sample > s.limit {
+				if !s.loud {
+					s.first, s.loud = s.position, true
+				}
+				s.last = s.position + 1
+				break
+			}
+		}
+		s.position++
+	}
+}
+
+func (s *silenceScanner) silence() *Silence {
+	if !s.loud {
+		return &Silence{Leading: s.position, Total: s.position}
+	}
+	return &Silence{Leading: s.first, Trailing: s.position - s.last, Total: s.position}
+}
+
+// DetectSilence decodes the audio and returns the length of the silence at its edges: the samples
+// whose absolute value is at or below threshold dBFS in every channel, e.g. -60 for the noise floor
+// of a vinyl rip. Use math.Inf(-1) to only count digital silence, samples being exactly 0.
+func (flac *Flac) DetectSilence(threshold float64) (*Silence, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := newSilenceScanner(threshold, streamInfo.BitsPerSample)
+	err = flac.decodeOriginalFrames(streamInfo, func(samples [][]int32) error {
+		scanner.add(samples)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scanner.silence(), nil
+}
+
+// TrimSilence writes to w a new FLAC file without the silence at the edges of the audio, as found by
+// DetectSilence, and returns the silence removed. The audio is re-encoded with opts, keeping the
+// comments, unless opts.Comments is set, and the pictures with the staged changes applied.
+func (flac *Flac) TrimSilence(w io.Writer, threshold float64, opts EncoderOptions) (*Silence, error) {
+	pcm, err := flac.DecodePCM()
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := newSilenceScanner(threshold, pcm.BitsPerSample)
+	scanner.add(pcm.Samples)
+	silence := scanner.silence()
+	if silence.Leading == silence.Total {
+		return nil, fmt.Errorf("unable to trim silence: the audio is all silence")
+	}
+	for ch := range pcm.Samples {
+		pcm.Samples[ch] = pcm.Samples[ch][silence.Leading : silence.Total-silence.Trailing]
+	}
+
+	if err := flac.encodeCopy(w, pcm, opts); err != nil {
+		return nil, err
+	}
+	return silence, nil
+}
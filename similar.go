@@ -0,0 +1,196 @@
+package flacgo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Track identifies a file by the tags compared by GroupSimilarTracks
+type Track struct {
+	Path   string
+	Artist string
+	Title  string
+}
+
+// DefaultMaxTrackDistance is the edit distance under which FindSimilarTracks considers two tracks the same
+const DefaultMaxTrackDistance = 2
+
+// accentFolding maps accented latin letters to their base letter
+var accentFolding = func() map[rune]rune {
+	folding := make(map[rune]rune)
+	for base, accented := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'd': "ďđ", 'e': "èéêëēĕėęě", 'g': "ĝğġģ",
+		'h': "ĥħ", 'i': "ìíîïĩīĭįı", 'j': "ĵ", 'k': "ķ", 'l': "ĺļľŀł", 'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő", 'r': "ŕŗř", 's': "śŝşšß", 't': "ţťŧ", 'u': "ùúûüũūŭůűų",
+		'w': "ŵ", 'y': "ýÿŷ", 'z': "źżž",
+	} {
+		for _, r := range accented {
+			folding[r] = base
+		}
+	}
+	return folding
+}()
+
+// NormalizeTag folds a tag value for fuzzy comparisons: lower case, accents removed,
+// punctuation dropped, "&" read as "and" and whitespace collapsed, so "Beyoncé & Jay-Z"
+// and "beyonce and jay z" normalize the same
+func NormalizeTag(value string) string {
+	var normalized strings.Builder
+	space := false
+	emit := func(r rune) {
+		if space && normalized.Len() > 0 {
+			normalized.WriteByte(' ')
+		}
+		space = false
+		normalized.WriteRune(r)
+	}
+
+	for _, r := range strings.ToLower(value) {
+		if folded, found := accentFolding[r]; found {
+			r = folded
+		}
+		switch {
+		case r == '&':
+			space = true
+			for _, c := range "and" {
+				emit(c)
+			}
+			space = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			emit(r)
+		case r == '\'' || r == '’' || r == '.':
+			// "don't" and "A.B.C." keep their letters together
+		default:
+			space = true
+		}
+	}
+
+	return normalized.String()
+}
+
+// Levenshtein returns the number of single character insertions, deletions and substitutions turning a into b
+func Levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// GroupSimilarTracks returns the groups of tracks whose normalized artist and title are within
+// maxDistance edits of each other, 0 only grouping tracks that normalize the same.
+// Each group has at least two tracks, in input order, and similarity is transitive.
+func GroupSimilarTracks(tracks []Track, maxDistance int) [][]Track {
+	keys := make([]string, len(tracks))
+	for i, track := range tracks {
+		keys[i] = NormalizeTag(track.Artist) + "\x00" + NormalizeTag(track.Title)
+	}
+
+	parent := make([]int, len(tracks))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[max(ri, rj)] = min(ri, rj)
+		}
+	}
+
+	// Keys whose lengths differ by more than maxDistance can't match, so only neighbours
+	// in length order are compared
+	order := make([]int, len(tracks))
+	for i := range order {
+		order[i] = i
+	}
+	runeCount := func(i int) int { return len([]rune(keys[i])) }
+	sort.SliceStable(order, func(i, j int) bool { return runeCount(order[i]) < runeCount(order[j]) })
+
+	for x, i := range order {
+		for _, j := range order[x+1:] {
+			if runeCount(j)-runeCount(i) > maxDistance {
+				break
+			}
+			if keys[i] == keys[j] || (maxDistance > 0 && Levenshtein(keys[i], keys[j]) <= maxDistance) {
+				union(i, j)
+			}
+		}
+	}
+
+	groups := make(map[int][]Track)
+	var roots []int
+	for i, track := range tracks {
+		root := find(i)
+		if _, found := groups[root]; !found {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], track)
+	}
+
+	similar := make([][]Track, 0)
+	for _, root := range roots {
+		if len(groups[root]) > 1 {
+			similar = append(similar, groups[root])
+		}
+	}
+	return similar
+}
+
+// FindSimilarTracks reads the ARTIST and TITLE tags of the files at paths and groups the tracks
+// within maxDistance edits of each other, see GroupSimilarTracks. Files without a title are skipped.
+func FindSimilarTracks(paths []string, maxDistance int) ([][]Track, error) {
+	tracks := make([]Track, 0, len(paths))
+	for _, path := range paths {
+		flac, err := Open(path, WithHeaderOnly())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read '%s': %w", path, err)
+		}
+
+		track := Track{Path: path}
+		for _, comment := range flac.Comments() {
+			switch strings.ToUpper(comment.Title) {
+			case "ARTIST":
+				if track.Artist == "" {
+					track.Artist = comment.Value
+				}
+			case "TITLE":
+				if track.Title == "" {
+					track.Title = comment.Value
+				}
+			}
+		}
+		flac.Close()
+
+		if track.Title != "" {
+			tracks = append(tracks, track)
+		}
+	}
+
+	return GroupSimilarTracks(tracks, maxDistance), nil
+}
@@ -0,0 +1,131 @@
+package flacgo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"slices"
+)
+
+// NewPicture returns a picture of the given type for the image data, with its mime type, dimensions and
+// color depth read from the image header
+func NewPicture(data []byte, pictureType uint32, description string) (*Picture, error) {
+	config, imageType, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image: %w", err)
+	}
+
+	picture := &Picture{PictureType: pictureType, MimeType: "image/" + imageType, Description: description, Data: data}
+	picture.setImageConfig(config)
+	return picture, nil
+}
+
+// StagedPictures returns the pictures as Save writes them, with the staged changes applied: the cover
+// picture first, then the other pictures in order. Their Data is loaded, images staged from a path included.
+func (flac *Flac) StagedPictures() ([]*Picture, error) {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+	pictureBlocks, err := flac.outputPictureBlocks(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	pictures := make([]*Picture, 0, len(pictureBlocks))
+	for _, block := range pictureBlocks {
+		if block.stream != nil {
+			picture, err := readStreamedPicture(block.stream)
+			if err != nil {
+				return nil, err
+			}
+			pictures = append(pictures, picture)
+			continue
+		}
+
+		if err := flac.loadBlockData(&block); err != nil {
+			return nil, err
+		}
+		picture, err := parsePictureBlock(block.BlockData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse picture at offset %d: %w", block.Index, err)
+		}
+		picture.blockIndex = block.Index
+		pictures = append(pictures, picture)
+	}
+
+	return pictures, nil
+}
+
+// readStreamedPicture reads the fields and the image of a picture staged from a file
+func readStreamedPicture(pending *pendingPicture) (*Picture, error) {
+	picture, _, err := readPictureHeader(bytes.NewReader(pending.prefix[4:]))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse staged picture: %w", err)
+	}
+
+	f, err := pending.open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if picture.Data, err = io.ReadAll(f); err != nil {
+		return nil, fmt.Errorf("unable to read picture: %w", err)
+	}
+	return picture, nil
+}
+
+// SetPictures stages pictures in place of all the pictures of the file, in order, e.g. the ones returned by
+// StagedPictures after changing the type or description of some and removing others. The pictures are
+// written as they are, see NewPicture to fill the fields of a new image. Nothing is staged if the pictures
+// are the ones StagedPictures returns.
+func (flac *Flac) SetPictures(pictures []*Picture) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+
+	current, err := flac.StagedPictures()
+	if err != nil {
+		return err
+	}
+	if slices.EqualFunc(current, pictures, samePicture) {
+		return nil
+	}
+
+	staged := make([]*pendingPicture, 0, len(pictures))
+	for _, picture := range pictures {
+		prefix, err := flac.createPictureHeader(picture, len(picture.Data))
+		if err != nil {
+			return fmt.Errorf("unable to set %s picture: %w", PictureTypeName(picture.PictureType), err)
+		}
+		staged = append(staged, &pendingPicture{
+			prefix:      prefix,
+			pictureType: picture.PictureType,
+			size:        int64(len(picture.Data)),
+			data:        picture.Data,
+		})
+	}
+
+	stored, err := flac.StreamPictures()
+	if err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	for _, picture := range stored {
+		flac.removedPictures[picture.Picture.blockIndex] = true
+	}
+	flac.pendingCoverPicture, flac.pendingCoverStream = nil, nil
+	flac.removeCoverPicture = false
+	flac.pendingPictures = staged
+
+	return nil
+}
+
+// samePicture reports whether a and b have the same fields and image
+func samePicture(a *Picture, b *Picture) bool {
+	return a.PictureType == b.PictureType && a.MimeType == b.MimeType && a.Description == b.Description &&
+		a.Width == b.Width && a.Height == b.Height && a.Depth == b.Depth && a.Colors == b.Colors &&
+		bytes.Equal(a.Data, b.Data)
+}
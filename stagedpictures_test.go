@@ -0,0 +1,72 @@
+package flacgo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetPictures(t *testing.T) {
+	path := copyFixture(t, "examples/sample.flac")
+	flac, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+
+	if err := flac.SetCoverPictureFromPath("examples/test.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if staged, err := flac.StagedPictures(); err != nil || len(staged) != 1 || len(staged[0].Data) == 0 {
+		t.Fatalf("staged pictures are %+v after setting the cover from a path: %v", staged, err)
+	}
+	if err := flac.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	pictures, err := flac.StagedPictures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.SetPictures(pictures); err != nil {
+		t.Fatal(err)
+	}
+	if flac.HasChanges() {
+		t.Fatal("HasChanges is set after staging the pictures unchanged")
+	}
+
+	data, err := os.ReadFile("examples/test.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := NewPicture(data, PictureTypeBackCover, "back")
+	if err != nil {
+		t.Fatal(err)
+	}
+	front := *pictures[0]
+	front.Description = "front"
+	if err := flac.SetPictures([]*Picture{back, &front}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := flac.Pictures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved[0].PictureType != PictureTypeBackCover || saved[1].Description != "front" ||
+		saved[1].Width != pictures[0].Width || len(saved[1].Data) != len(data) {
+		t.Fatalf("saved pictures are %+v", saved)
+	}
+
+	if err := flac.SetPictures(saved[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if staged, err := flac.StagedPictures(); err != nil || len(staged) != 1 || staged[0].Description != "front" {
+		t.Fatalf("staged pictures are %+v after removing the back cover: %v", staged, err)
+	}
+	if err := flac.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,184 @@
+package flacgo
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Encoder compresses audio given a few samples at a time into a FLAC stream, e.g. while recording.
+// The metadata is written by NewEncoder and every frame as soon as its block is full.
+type Encoder struct {
+	w          io.Writer
+	opts       EncoderOptions
+	frames     *frameEncoder
+	streamInfo *StreamInfo
+	signature  hash.Hash
+	// pending holds the samples of every channel not filling a block yet
+	pending [][]int32
+	number  uint64
+	// streamInfoOffset is the offset of the body of STREAMINFO in w, -1 if w can't seek
+	streamInfoOffset int64
+	// err is the first error, returned by every following call
+	err    error
+	closed bool
+}
+
+// NewEncoder writes to w the metadata of a FLAC stream of the given format and returns an Encoder
+// compressing the samples given to Write. STREAMINFO is written with an unknown length, frame sizes
+// and MD5 signature: when w is an io.WriteSeeker, e.g. an *os.File, Close writes them back, otherwise
+// they are left unknown as the FLAC format allows for streams. opts.Comments are written as tags.
+func NewEncoder(w io.Writer, sampleRate uint32, bitsPerSample uint8, channels int, opts EncoderOptions) (*Encoder, error) {
+	format := &PCM{SampleRate: sampleRate, BitsPerSample: bitsPerSample, Samples: make([][]int32, channels)}
+	if err := format.validate(); err != nil {
+		return nil, fmt.Errorf("unable to encode: %w", err)
+	}
+	opts, err := prepareEncoderOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := newEncoder(w, sampleRate, bitsPerSample, channels, opts)
+	if seeker, ok := w.(io.Seeker); ok {
+		// Seeking fails on pipes and terminals, which are written as streams
+		if position, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			enc.streamInfoOffset = position + 4 + 4
+		}
+	}
+	if err := writeEncoderMetadata(w, enc.streamInfo, opts.Comments, opts.Padding); err != nil {
+		return nil, err
+	}
+
+	return enc, nil
+}
+
+// newEncoder returns an Encoder writing the frames to w, the metadata is left to the caller
+func newEncoder(w io.Writer, sampleRate uint32, bitsPerSample uint8, channels int, opts EncoderOptions) *Encoder {
+	enc := &Encoder{
+		w:    w,
+		opts: opts,
+		frames: &frameEncoder{
+			opts:          opts,
+			sampleRate:    sampleRate,
+			bitsPerSample: uint(bitsPerSample),
+		},
+		streamInfo: &StreamInfo{
+			MinBlockSize:  uint16(opts.BlockSize),
+			MaxBlockSize:  uint16(opts.BlockSize),
+			SampleRate:    sampleRate,
+			Channels:      uint8(channels),
+			BitsPerSample: bitsPerSample,
+		},
+		signature:        md5.New(),
+		pending:          make([][]int32, channels),
+		streamInfoOffset: -1,
+	}
+	for ch := range enc.pending {
+		enc.pending[ch] = make([]int32, 0, opts.BlockSize)
+	}
+	return enc
+}
+
+// Write adds samples interleaved like in a WAVE file, the first sample of every channel, then the
+// second one and so on, encoding the frames they complete. Any number of whole samples per channel
+// can be given at a time.
+func (enc *Encoder) Write(samples []int32) error {
+	if enc.closed {
+		return fmt.Errorf("unable to encode: the encoder is closed")
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	channels := len(enc.pending)
+	if len(samples)%channels != 0 {
+		return fmt.Errorf("unable to encode: %d samples don't divide evenly in %d channels", len(samples), channels)
+	}
+
+	for len(samples) > 0 {
+		count := min(enc.opts.BlockSize-len(enc.pending[0]), len(samples)/channels)
+		for ch := range enc.pending {
+			for i := 0; i < count; i++ {
+				enc.pending[ch] = append(enc.pending[ch], samples[i*channels+ch])
+			}
+		}
+		samples = samples[count*channels:]
+
+		if len(enc.pending[0]) == enc.opts.BlockSize {
+			if enc.err = enc.encodeBlock(enc.pending); enc.err != nil {
+				return enc.err
+			}
+			for ch := range enc.pending {
+				enc.pending[ch] = enc.pending[ch][:0]
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close encodes the samples left in a last shorter frame and, when the stream can seek, rewrites
+// STREAMINFO with the length, frame sizes and MD5 signature of the audio. It doesn't close w.
+func (enc *Encoder) Close() error {
+	if enc.closed {
+		return enc.err
+	}
+	enc.closed = true
+	if enc.err != nil {
+		return enc.err
+	}
+
+	if len(enc.pending[0]) > 0 {
+		if enc.err = enc.encodeBlock(enc.pending); enc.err != nil {
+			return enc.err
+		}
+	}
+	copy(enc.streamInfo.MD5[:], enc.signature.Sum(nil))
+
+	seeker, ok := enc.w.(io.WriteSeeker)
+	if !ok || enc.streamInfoOffset < 0 {
+		return nil
+	}
+	end, err := seeker.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = seeker.Seek(enc.streamInfoOffset, io.SeekStart)
+	}
+	if err == nil {
+		_, err = seeker.Write(enc.streamInfo.marshal())
+	}
+	if err == nil {
+		_, err = seeker.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		enc.err = fmt.Errorf("unable to update STREAMINFO: %w", err)
+	}
+	return enc.err
+}
+
+// StreamInfo returns the stream info of the audio encoded so far, its MD5 signature is only set by Close
+func (enc *Encoder) StreamInfo() StreamInfo {
+	return *enc.streamInfo
+}
+
+// encodeBlock encodes a block of samples into the next frame, written to w
+func (enc *Encoder) encodeBlock(block [][]int32) error {
+	frame, err := enc.frames.encodeFrame(block, enc.number)
+	if err != nil {
+		return fmt.Errorf("unable to encode frame %d: %w", enc.number, err)
+	}
+	if _, err := enc.w.Write(frame); err != nil {
+		return fmt.Errorf("unable to write frame %d: %w", enc.number, err)
+	}
+	enc.number++
+
+	streamInfo := enc.streamInfo
+	size := uint32(len(frame))
+	if streamInfo.MinFrameSize == 0 || size < streamInfo.MinFrameSize {
+		streamInfo.MinFrameSize = size
+	}
+	streamInfo.MaxFrameSize = max(streamInfo.MaxFrameSize, size)
+	streamInfo.TotalSamples += uint64(len(block[0]))
+
+	writeSamplesMD5(enc.signature, &Frame{Header: FrameHeader{BlockSize: len(block[0])}, Samples: block}, streamInfo.BitsPerSample)
+	return nil
+}
@@ -0,0 +1,190 @@
+package flacgo
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// StreamInfo holds the parsed content of the STREAMINFO metadata block
+type StreamInfo struct {
+	MinBlockSize  uint16
+	MaxBlockSize  uint16
+	MinFrameSize  uint32
+	MaxFrameSize  uint32
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	TotalSamples  uint64
+	MD5           [16]byte
+}
+
+// parseStreamInfoBlock tries to parse bytes from a STREAMINFO block into a StreamInfo structure
+func parseStreamInfoBlock(streamInfoBlock []byte) (*StreamInfo, error) {
+	if len(streamInfoBlock) != 34 {
+		return nil, fmt.Errorf("invalid STREAMINFO length %d, expected 34", len(streamInfoBlock))
+	}
+
+	// Sample rate (20 bits), channels - 1 (3 bits), bits per sample - 1 (5 bits)
+	// and total samples (36 bits) are packed together in 8 bytes
+	packed := binary.BigEndian.Uint64(streamInfoBlock[10:18])
+
+	streamInfo := &StreamInfo{
+		MinBlockSize:  binary.BigEndian.Uint16(streamInfoBlock[0:2]),
+		MaxBlockSize:  binary.BigEndian.Uint16(streamInfoBlock[2:4]),
+		MinFrameSize:  binary.BigEndian.Uint32(append([]byte{0}, streamInfoBlock[4:7]...)),
+		MaxFrameSize:  binary.BigEndian.Uint32(append([]byte{0}, streamInfoBlock[7:10]...)),
+		SampleRate:    uint32(packed >> 44),
+		Channels:      uint8((packed>>41)&0x07) + 1,
+		BitsPerSample: uint8((packed>>36)&0x1F) + 1,
+		TotalSamples:  packed & 0xFFFFFFFFF,
+	}
+	copy(streamInfo.MD5[:], streamInfoBlock[18:34])
+
+	return streamInfo, nil
+}
+
+// marshal returns the 34 bytes body of the STREAMINFO block
+func (streamInfo *StreamInfo) marshal() []byte {
+	data := make([]byte, 34)
+	binary.BigEndian.PutUint16(data[0:2], streamInfo.MinBlockSize)
+	binary.BigEndian.PutUint16(data[2:4], streamInfo.MaxBlockSize)
+	data[4], data[5], data[6] = byte(streamInfo.MinFrameSize>>16), byte(streamInfo.MinFrameSize>>8), byte(streamInfo.MinFrameSize)
+	data[7], data[8], data[9] = byte(streamInfo.MaxFrameSize>>16), byte(streamInfo.MaxFrameSize>>8), byte(streamInfo.MaxFrameSize)
+
+	packed := uint64(streamInfo.SampleRate)<<44 |
+		uint64(streamInfo.Channels-1)<<41 |
+		uint64(streamInfo.BitsPerSample-1)<<36 |
+		streamInfo.TotalSamples&0xFFFFFFFFF
+	binary.BigEndian.PutUint64(data[10:18], packed)
+	copy(data[18:34], streamInfo.MD5[:])
+
+	return data
+}
+
+// Duration returns the length of the audio stream, zero if the total samples are unknown
+func (streamInfo *StreamInfo) Duration() time.Duration {
+	if streamInfo.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(streamInfo.TotalSamples) * time.Second / time.Duration(streamInfo.SampleRate)
+}
+
+// HasMD5 reports whether the encoder stored the MD5 signature of the unencoded audio
+func (streamInfo *StreamInfo) HasMD5() bool {
+	return streamInfo.MD5 != [16]byte{}
+}
+
+// StreamInfo returns the parsed STREAMINFO block of the file, or the one staged by UpdateStreamInfo
+func (flac *Flac) StreamInfo() (*StreamInfo, error) {
+	if flac.pendingStreamInfo != nil {
+		streamInfo := *flac.pendingStreamInfo
+		return &streamInfo, nil
+	}
+
+	block, err := flac.getBlock("STREAMINFO")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read STREAMINFO block: %w", err)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("missing STREAMINFO block")
+	}
+
+	return parseStreamInfoBlock(block.BlockData)
+}
+
+// ScanStreamInfo decodes the audio and returns the STREAMINFO describing its frames as they are:
+// the smallest and largest block and frame sizes and the total samples. The MD5 signature is computed
+// when the file has none, a stored one is kept since a mismatch means corrupted audio, see Verify.
+// The minimum block size doesn't account for the last frame, which the FLAC format lets be shorter.
+func (flac *Flac) ScanStreamInfo() (*StreamInfo, error) {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	scanned := &StreamInfo{
+		SampleRate:    streamInfo.SampleRate,
+		Channels:      streamInfo.Channels,
+		BitsPerSample: streamInfo.BitsPerSample,
+		MD5:           streamInfo.MD5,
+	}
+	signature := md5.New()
+	last := 0
+	err = flac.forEachFrame(func(frame *Frame) error {
+		if len(frame.Samples) != int(streamInfo.Channels) {
+			return fmt.Errorf("frame at offset %d has %d channels, STREAMINFO declares %d", frame.Header.Offset, len(frame.Samples), streamInfo.Channels)
+		}
+		// The previous frame was not the last one
+		if last != 0 && (scanned.MinBlockSize == 0 || uint16(last) < scanned.MinBlockSize) {
+			scanned.MinBlockSize = uint16(last)
+		}
+		last = frame.Header.BlockSize
+		scanned.MaxBlockSize = max(scanned.MaxBlockSize, uint16(frame.Header.BlockSize))
+
+		size := uint32(frame.Length)
+		if scanned.MinFrameSize == 0 || size < scanned.MinFrameSize {
+			scanned.MinFrameSize = size
+		}
+		scanned.MaxFrameSize = max(scanned.MaxFrameSize, size)
+		scanned.TotalSamples += uint64(frame.Header.BlockSize)
+
+		if !streamInfo.HasMD5() {
+			writeSamplesMD5(signature, frame, streamInfo.BitsPerSample)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if scanned.MinBlockSize == 0 {
+		// A single frame
+		scanned.MinBlockSize = scanned.MaxBlockSize
+	}
+	if !streamInfo.HasMD5() && scanned.TotalSamples > 0 {
+		copy(scanned.MD5[:], signature.Sum(nil))
+	}
+	return scanned, nil
+}
+
+// UpdateStreamInfo stages the STREAMINFO found by ScanStreamInfo in place of the stored one, e.g.
+// after the audio was cut or joined by a tool leaving stale values, or for a stream recorded to a
+// pipe whose sizes, length and MD5 signature were left unknown. It returns the fields that change,
+// none when STREAMINFO is up to date.
+func (flac *Flac) UpdateStreamInfo() ([]Issue, error) {
+	if err := flac.checkWritable(); err != nil {
+		return nil, err
+	}
+	defer flac.recordUndo()()
+
+	current, err := flac.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	scanned, err := flac.ScanStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Issue
+	change := func(field string, from, to uint64) {
+		if from != to {
+			changes = append(changes, Issue{SeverityInfo, "updated-streaminfo", fmt.Sprintf("%s changed from %d to %d", field, from, to)})
+		}
+	}
+	change("minimum block size", uint64(current.MinBlockSize), uint64(scanned.MinBlockSize))
+	change("maximum block size", uint64(current.MaxBlockSize), uint64(scanned.MaxBlockSize))
+	change("minimum frame size", uint64(current.MinFrameSize), uint64(scanned.MinFrameSize))
+	change("maximum frame size", uint64(current.MaxFrameSize), uint64(scanned.MaxFrameSize))
+	change("total samples", current.TotalSamples, scanned.TotalSamples)
+	if current.MD5 != scanned.MD5 {
+		changes = append(changes, Issue{SeverityInfo, "updated-streaminfo", "computed the missing MD5 signature"})
+	}
+
+	if len(changes) > 0 {
+		flac.pendingStreamInfo = scanned
+	}
+	return changes, nil
+}
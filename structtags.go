@@ -0,0 +1,205 @@
+package flacgo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// structTag is a field of a struct mapped to a comment by a `flac:"KEY"` struct tag
+type structTag struct {
+	key       string
+	omitEmpty bool
+	value     reflect.Value
+}
+
+// structTags returns the fields of the struct v, or the struct v points to if settable is set, having
+// a flac struct tag, those of embedded structs included. Fields tagged "-" are skipped.
+func structTags(v any, settable bool) ([]structTag, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	} else if settable {
+		return nil, fmt.Errorf("expected a pointer to a struct, got %T", v)
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %T", v)
+	}
+	return appendStructTags(nil, value), nil
+}
+
+// appendStructTags appends the tagged fields of the struct value to tags
+func appendStructTags(tags []structTag, value reflect.Value) []structTag {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, tagged := field.Tag.Lookup("flac")
+		if field.Anonymous && !tagged && field.Type.Kind() == reflect.Struct {
+			tags = appendStructTags(tags, value.Field(i))
+			continue
+		}
+		if !tagged || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		key, options, _ := strings.Cut(tag, ",")
+		if key == "" {
+			key = strings.ToUpper(field.Name)
+		}
+		tags = append(tags, structTag{key: key, omitEmpty: options == "omitempty", value: value.Field(i)})
+	}
+	return tags
+}
+
+// SetFromStruct stages the fields of the struct v, or the struct v points to, that have a `flac:"KEY"`
+// struct tag as the comments named KEY, e.g. `flac:"ARTIST"`, or the field name in upper case for
+// `flac:""`. Strings, numbers and booleans are written as text, a slice of them as one comment per value
+// and a pointer as the value it points to. Like encoding/json, `flac:"KEY,omitempty"` skips zero values; otherwise a nil
+// pointer or an empty slice removes the tag and other zero values are written.
+func (flac *Flac) SetFromStruct(v any) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	tags, err := structTags(v, false)
+	if err != nil {
+		return fmt.Errorf("unable to set metadata from struct: %w", err)
+	}
+	defer flac.recordUndo()()
+
+	for _, tag := range tags {
+		if tag.omitEmpty && tag.value.IsZero() {
+			continue
+		}
+
+		value := tag.value
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				if err := flac.RemoveMetadata(tag.key, true); err != nil {
+					return err
+				}
+				continue
+			}
+			value = value.Elem()
+		}
+
+		var values []string
+		if value.Kind() == reflect.Slice {
+			for i := 0; i < value.Len(); i++ {
+				text, err := formatStructValue(value.Index(i))
+				if err != nil {
+					return fmt.Errorf("unable to set %s from struct: %w", tag.key, err)
+				}
+				values = append(values, text)
+			}
+		} else {
+			text, err := formatStructValue(value)
+			if err != nil {
+				return fmt.Errorf("unable to set %s from struct: %w", tag.key, err)
+			}
+			values = []string{text}
+		}
+
+		if len(values) == 0 {
+			err = flac.RemoveMetadata(tag.key, true)
+		} else {
+			err = flac.SetMetadataValues(tag.key, values)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadIntoStruct fills the fields of the struct v points to that have a `flac:"KEY"` struct tag, see
+// SetFromStruct, with the comments named KEY including the staged changes: a slice gets all the values,
+// other fields the first one. Fields whose tag is missing are left untouched. It fails if a value
+// can't be parsed into its field, e.g. TRACKNUMBER "3/12" into an int.
+func (flac *Flac) ReadIntoStruct(v any) error {
+	tags, err := structTags(v, true)
+	if err != nil {
+		return fmt.Errorf("unable to read metadata into struct: %w", err)
+	}
+
+	for _, tag := range tags {
+		values := flac.MetadataValues(tag.key)
+		if len(values) == 0 {
+			continue
+		}
+
+		value := tag.value
+		if value.Kind() == reflect.Pointer {
+			value.Set(reflect.New(value.Type().Elem()))
+			value = value.Elem()
+		}
+
+		if value.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+			for i, text := range values {
+				if err := parseStructValue(slice.Index(i), text); err != nil {
+					return fmt.Errorf("unable to read %s into struct: %w", tag.key, err)
+				}
+			}
+			value.Set(slice)
+			continue
+		}
+		if err := parseStructValue(value, values[0]); err != nil {
+			return fmt.Errorf("unable to read %s into struct: %w", tag.key, err)
+		}
+	}
+
+	return nil
+}
+
+// formatStructValue returns the text of a string, number or boolean field
+func formatStructValue(value reflect.Value) (string, error) {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	}
+	return "", fmt.Errorf("unsupported field type %s", value.Type())
+}
+
+// parseStructValue parses text into a string, number or boolean field
+func parseStructValue(value reflect.Value, text string) error {
+	var err error
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var number int64
+		if number, err = strconv.ParseInt(strings.TrimSpace(text), 10, value.Type().Bits()); err == nil {
+			value.SetInt(number)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var number uint64
+		if number, err = strconv.ParseUint(strings.TrimSpace(text), 10, value.Type().Bits()); err == nil {
+			value.SetUint(number)
+		}
+	case reflect.Float32, reflect.Float64:
+		var number float64
+		if number, err = strconv.ParseFloat(strings.TrimSpace(text), value.Type().Bits()); err == nil {
+			value.SetFloat(number)
+		}
+	case reflect.Bool:
+		var flag bool
+		if flag, err = strconv.ParseBool(strings.TrimSpace(text)); err == nil {
+			value.SetBool(flag)
+		}
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", text, value.Type(), err)
+	}
+	return nil
+}
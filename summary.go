@@ -0,0 +1,107 @@
+package flacgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Summary returns a one-line description of the file for logging,
+// e.g. "44.1kHz/16bit stereo, 3:45, 12 tags, 1 picture, 24.1 MB".
+// Tags and pictures include the staged changes not saved yet.
+func (flac *Flac) Summary() string {
+	parts := []string{}
+
+	if streamInfo, err := flac.StreamInfo(); err == nil {
+		rate := strconv.FormatFloat(float64(streamInfo.SampleRate)/1000, 'f', -1, 64)
+		parts = append(parts,
+			fmt.Sprintf("%skHz/%dbit %s", rate, streamInfo.BitsPerSample, formatChannels(streamInfo.Channels)),
+			formatDuration(streamInfo.Duration()),
+		)
+	} else {
+		parts = append(parts, "invalid STREAMINFO")
+	}
+
+	parts = append(parts,
+		plural(len(flac.Comments()), "tag"),
+		plural(flac.pictureCount(), "picture"),
+		formatSize(flac.fileSize),
+	)
+
+	return strings.Join(parts, ", ")
+}
+
+// String implements fmt.Stringer, see Summary
+func (flac *Flac) String() string {
+	return flac.Summary()
+}
+
+// pictureCount returns the number of PICTURE blocks the file will have once saved
+func (flac *Flac) pictureCount() int {
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, block := range blocks {
+		if block.BlockType == "PICTURE" && !flac.removedPictures[block.Index] {
+			count++
+		}
+	}
+
+	count += len(flac.pendingPictures)
+
+	// The staged cover replaces the parsed one, like Save does
+	hasCover := flac.parsedCoverPicture != nil && !flac.removedPictures[flac.parsedCoverPicture.Index]
+	switch {
+	case flac.hasPendingCover() && !hasCover:
+		count++
+	case !flac.hasPendingCover() && flac.removeCoverPicture && hasCover:
+		count--
+	}
+
+	return count
+}
+
+func formatChannels(channels uint8) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	}
+	return fmt.Sprintf("%d channels", channels)
+}
+
+// formatDuration formats d as m:ss, or h:mm:ss when longer than an hour
+func formatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// formatSize formats a size in bytes with decimal units
+func formatSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
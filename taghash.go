@@ -0,0 +1,77 @@
+package flacgo
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TagHash returns a fingerprint of the tags and pictures of the file, including the staged changes,
+// so sync tools can tell whether metadata changed without comparing every field.
+// Comments are normalized before hashing: keys are compared case-insensitively, surrounding spaces
+// are ignored and their order doesn't matter. Pictures are hashed by type, mime type, description
+// and the SHA-256 of their data, in order, or by the position of their block or the path of their
+// image when it can't be read. Padding and audio never change the fingerprint.
+func (flac *Flac) TagHash() [32]byte {
+	comments := flac.Comments()
+	lines := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		key := strings.ToUpper(strings.TrimSpace(comment.Title))
+		lines = append(lines, key+"="+strings.TrimSpace(comment.Value))
+	}
+	sort.Strings(lines)
+
+	// The PICTURE blocks read while opening the file are enough, their bodies are streamed below
+	pictureBlocks, _ := flac.outputPictureBlocks(flac.parsedPictures)
+
+	hash := sha256.New()
+	for _, line := range lines {
+		// Values may contain anything but NUL, which can't appear in a UTF-8 tag
+		fmt.Fprintf(hash, "comment\x00%s\x00", line)
+	}
+	for i := range pictureBlocks {
+		flac.hashPicture(hash, &pictureBlocks[i])
+	}
+
+	var sum [32]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// hashPicture adds the fields of a picture and the SHA-256 of its data to tagHash, streaming the data.
+// A picture that can't be read is identified by its image file or the position of its block instead.
+func (flac *Flac) hashPicture(tagHash hash.Hash, block *MetadataBlock) {
+	digest, err := flac.pictureDigest(block)
+	switch {
+	case err == nil:
+		tagHash.Write(digest)
+	case block.stream != nil:
+		fmt.Fprintf(tagHash, "picture file\x00%s\x00%d\x00%d\x00", block.stream.path, block.stream.size, block.stream.modTime.UnixNano())
+	default:
+		fmt.Fprintf(tagHash, "picture block\x00%d\x00%d\x00", block.Index, block.bodyLength())
+	}
+}
+
+// pictureDigest returns the fields of a picture and the SHA-256 of its data as hashed by TagHash
+func (flac *Flac) pictureDigest(block *MetadataBlock) ([]byte, error) {
+	body, err := flac.openBlockBody(block)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	picture, dataLength, err := readPictureHeader(body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse picture: %w", err)
+	}
+
+	dataHash := sha256.New()
+	if n, err := io.Copy(dataHash, io.LimitReader(body, int64(dataLength))); err != nil || n != int64(dataLength) {
+		return nil, fmt.Errorf("unable to parse picture: picture block too short for picture data of length %d", dataLength)
+	}
+
+	return fmt.Appendf(nil, "picture\x00%d\x00%s\x00%s\x00%x\x00", picture.PictureType, picture.MimeType, picture.Description, dataHash.Sum(nil)), nil
+}
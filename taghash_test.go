@@ -0,0 +1,53 @@
+package flacgo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTagHashMatchesSavedFile(t *testing.T) {
+	flac, err := Open("examples/sample.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flac.Close()
+	original := flac.TagHash()
+
+	image := filepath.Join(t.TempDir(), "cover.jpg")
+	data, err := os.ReadFile("examples/test.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(image, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := flac.SetCoverPictureFromPath(image); err != nil {
+		t.Fatal(err)
+	}
+	staged := flac.TagHash()
+	if staged == original {
+		t.Fatal("TagHash didn't change with a staged cover picture")
+	}
+
+	output := filepath.Join(t.TempDir(), "output.flac")
+	if err := flac.Save(&output); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saved.Close()
+	if saved.TagHash() != staged {
+		t.Error("TagHash of the saved file differs from the one of the staged changes")
+	}
+
+	// The image can't be read anymore, the fingerprint still tells the pictures changed
+	if err := os.Remove(image); err != nil {
+		t.Fatal(err)
+	}
+	if hash := flac.TagHash(); hash == original {
+		t.Error("TagHash with an unreadable staged picture is the one of the original file")
+	}
+}
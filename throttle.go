@@ -0,0 +1,87 @@
+package flacgo
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// throttle paces reads and writes to a number of bytes per second, shared by all its users
+type throttle struct {
+	mu   sync.Mutex
+	rate int64
+	// next is when the bytes transferred so far are paid off
+	next time.Time
+}
+
+// wait blocks until transferring n more bytes keeps the throughput under the rate
+func (t *throttle) wait(n int) {
+	if n <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(n) * time.Second / time.Duration(t.rate))
+	until := t.next
+	t.mu.Unlock()
+
+	time.Sleep(time.Until(until))
+}
+
+// WithThrottle limits the throughput of reading and saving files to bytesPerSecond, so that
+// background library maintenance doesn't saturate the disks. The limit is shared by all the
+// files opened with the returned Option, e.g. by the workers of a batch.
+// A limit of zero or less disables throttling.
+func WithThrottle(bytesPerSecond int64) Option {
+	var t *throttle
+	if bytesPerSecond > 0 {
+		t = &throttle{rate: bytesPerSecond}
+	}
+	return func(o *options) {
+		o.throttle = t
+	}
+}
+
+// throttledSource paces the reads of a source
+type throttledSource struct {
+	source
+	throttle *throttle
+}
+
+func (s *throttledSource) ReadAt(p []byte, off int64) (int, error) {
+	n, err := s.source.ReadAt(p, off)
+	s.throttle.wait(n)
+	return n, err
+}
+
+// Close closes the underlying source if it can be closed
+func (s *throttledSource) Close() error {
+	if closer, ok := s.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// throttledWriter paces the writes to a writer
+type throttledWriter struct {
+	w        io.Writer
+	throttle *throttle
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.throttle.wait(n)
+	return n, err
+}
+
+// throttledWriter returns w paced by the throttle set with WithThrottle, w itself if there is none
+func (flac *Flac) throttledWriter(w io.Writer) io.Writer {
+	if flac.options.throttle == nil {
+		return w
+	}
+	return &throttledWriter{w: w, throttle: flac.options.throttle}
+}
@@ -0,0 +1,115 @@
+package flacgo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+)
+
+// ThumbnailDescription is the description of the pictures added by AddThumbnail
+const ThumbnailDescription = "thumbnail"
+
+// fileIconSize is the width and height of a PictureTypeFileIcon picture, which must be a PNG
+const fileIconSize = 32
+
+// AddThumbnail stages a copy of the front cover scaled down so neither side exceeds maxDimension, for
+// clients that can't afford to load the full size art, e.g. over a slow network. The thumbnail is a
+// PNG picture of type PictureTypeFileIcon when it's 32x32, as the format requires, otherwise a JPEG
+// picture of type PictureTypeOtherFileIcon; it replaces the pictures of that type. It's not stored as a
+// second front cover since readers would take it for the cover. The front cover staged, if any, is used.
+func (flac *Flac) AddThumbnail(maxDimension int) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	if maxDimension <= 0 {
+		return fmt.Errorf("unable to add thumbnail: invalid maximum dimension %d", maxDimension)
+	}
+
+	cover, err := flac.stagedFrontCover()
+	if err != nil {
+		return fmt.Errorf("unable to add thumbnail: %w", err)
+	}
+	if cover == nil {
+		return fmt.Errorf("unable to add thumbnail: the file has no front cover")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(cover.Data))
+	if err != nil {
+		return fmt.Errorf("unable to add thumbnail: unable to decode picture: %w", err)
+	}
+	thumbnail := resizeImage(img, maxDimension)
+	if thumbnail == img {
+		return fmt.Errorf("unable to add thumbnail: the front cover is %dx%d already", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	picture := &Picture{PictureType: PictureTypeOtherFileIcon, MimeType: "image/jpeg", Description: ThumbnailDescription}
+	var data bytes.Buffer
+	if bounds := thumbnail.Bounds(); bounds.Dx() == fileIconSize && bounds.Dy() == fileIconSize {
+		picture.PictureType, picture.MimeType = PictureTypeFileIcon, "image/png"
+		err = png.Encode(&data, thumbnail)
+	} else {
+		err = jpeg.Encode(&data, thumbnail, &jpeg.Options{Quality: jpeg.DefaultQuality})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add thumbnail: unable to encode picture: %w", err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		return fmt.Errorf("unable to add thumbnail: unable to read image: %w", err)
+	}
+	picture.setImageConfig(config)
+
+	prefix, err := flac.createPictureHeader(picture, data.Len())
+	if err != nil {
+		return fmt.Errorf("unable to add thumbnail: %w", err)
+	}
+	if _, err := flac.RemovePictures(picture.PictureType); err != nil {
+		return err
+	}
+	flac.pendingPictures = append(flac.pendingPictures, &pendingPicture{
+		prefix:      prefix,
+		pictureType: picture.PictureType,
+		size:        int64(data.Len()),
+		data:        data.Bytes(),
+	})
+
+	return nil
+}
+
+// stagedFrontCover returns the cover picture staged if any, otherwise the first front cover picture of
+// the file or its cover picture when no picture is marked as front cover, like readFrontCover
+func (flac *Flac) stagedFrontCover() (*Picture, error) {
+	switch {
+	case len(flac.pendingCoverPicture) > 0:
+		return parsePictureBlock(flac.pendingCoverPicture[4:])
+	case flac.pendingCoverStream != nil && flac.pendingCoverStream.data != nil:
+		return &Picture{PictureType: PictureTypeFrontCover, Data: flac.pendingCoverStream.data}, nil
+	case flac.pendingCoverStream != nil:
+		data, err := os.ReadFile(flac.pendingCoverStream.path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read picture: %w", err)
+		}
+		return &Picture{PictureType: PictureTypeFrontCover, Data: data}, nil
+	case flac.removeCoverPicture:
+		return nil, nil
+	}
+
+	pictures, err := flac.Pictures()
+	if err != nil {
+		return nil, err
+	}
+	for _, picture := range pictures {
+		if picture.PictureType == PictureTypeFrontCover && !flac.removedPictures[picture.blockIndex] {
+			return picture, nil
+		}
+	}
+	if flac.parsedCoverPicture == nil || flac.removedPictures[flac.parsedCoverPicture.Index] {
+		return nil, nil
+	}
+	return flac.CoverPicture()
+}
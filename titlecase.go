@@ -0,0 +1,170 @@
+package flacgo
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TagChange is a staged change of a tag value, as returned by the tag transformations
+type TagChange struct {
+	Key string
+	Old string
+	New string
+	// Removed is set when the tag is deleted, Old holds its value
+	Removed bool
+}
+
+func (change TagChange) String() string {
+	if change.Removed {
+		return fmt.Sprintf("%s: %q removed", change.Key, change.Old)
+	}
+	return fmt.Sprintf("%s: %q -> %q", change.Key, change.Old, change.New)
+}
+
+// DefaultTitleCaseKeys are the tags FixCapitalization changes when no keys are given
+var DefaultTitleCaseKeys = []string{"TITLE", "ALBUM"}
+
+// smallWords lists, per language, the words kept lower case inside titles
+var smallWords = map[string][]string{
+	"en": {"a", "an", "and", "as", "at", "but", "by", "for", "from", "in", "into", "nor", "of", "on", "or", "over", "so", "the", "to", "up", "via", "vs", "with", "yet"},
+	"fr": {"à", "au", "aux", "de", "des", "du", "en", "et", "la", "le", "les", "ou", "par", "pour", "sur", "un", "une"},
+	"de": {"am", "an", "auf", "aus", "das", "dem", "den", "der", "des", "die", "ein", "eine", "im", "in", "mit", "oder", "und", "vom", "von", "zu", "zum", "zur"},
+	"es": {"a", "al", "con", "de", "del", "el", "en", "la", "las", "los", "o", "para", "por", "un", "una", "y"},
+	"it": {"a", "al", "che", "con", "da", "del", "della", "di", "e", "gli", "il", "in", "la", "le", "lo", "o", "per", "su", "un", "una"},
+	"tr": {"ve", "ile", "ya", "da", "de", "ki", "mi"},
+}
+
+// DefaultAcronyms are kept upper case even when the whole value is shouted in capitals
+var DefaultAcronyms = []string{"DJ", "MC", "UK", "USA", "TV", "EP", "LP", "OK", "II", "III", "IV", "VI", "VII", "VIII", "IX", "XI", "XII"}
+
+// TitleCaseOptions configures TitleCase and FixCapitalization
+type TitleCaseOptions struct {
+	// Keys are the tags to fix, DefaultTitleCaseKeys if empty
+	Keys []string
+	// Locale selects the small words and case mapping: "en" (default), "fr", "de", "es", "it" or "tr"
+	Locale string
+	// SmallWords replaces the small words of the locale if not nil
+	SmallWords []string
+	// Acronyms replaces DefaultAcronyms if not nil
+	Acronyms []string
+}
+
+// caser returns the case mapping of the locale, Turkish maps i to İ and ı to I
+func (opts TitleCaseOptions) caser() unicode.SpecialCase {
+	if opts.Locale == "tr" {
+		return unicode.TurkishCase
+	}
+	return nil
+}
+
+// TitleCase capitalizes the words of value, keeping the small words of the locale lower case unless
+// they start or end the title or a subtitle. Words already in capitals (acronyms like "AC/DC") or with
+// inner capitals ("McCartney", "iPhone") are kept, unless the whole value is in capitals.
+func TitleCase(value string, opts TitleCaseOptions) string {
+	locale := opts.Locale
+	if locale == "" {
+		locale = "en"
+	}
+	small := opts.SmallWords
+	if small == nil {
+		small = smallWords[locale]
+	}
+	acronyms := opts.Acronyms
+	if acronyms == nil {
+		acronyms = DefaultAcronyms
+	}
+	caser := opts.caser()
+
+	isSmall := make(map[string]bool)
+	for _, word := range small {
+		isSmall[strings.ToLower(word)] = true
+	}
+	isAcronym := make(map[string]bool)
+	for _, word := range acronyms {
+		isAcronym[strings.ToUpper(word)] = true
+	}
+
+	shouted := strings.IndexFunc(value, unicode.IsLower) == -1
+
+	words := strings.Split(value, " ")
+	last := len(words) - 1
+	for last > 0 && words[last] == "" {
+		last--
+	}
+
+	subtitle := true
+	for i, word := range words {
+		if word == "" {
+			continue
+		}
+
+		// Hyphenated words are capitalized part by part, "rock-and-roll" becomes "Rock-and-Roll"
+		parts := strings.Split(word, "-")
+		for j, part := range parts {
+			first := (subtitle && j == 0) || (i == last && j == len(parts)-1)
+			parts[j] = titleCaseWord(part, first, shouted, isSmall, isAcronym, caser)
+		}
+		words[i] = strings.Join(parts, "-")
+
+		// A new subtitle starts after "Title: Subtitle", "Title - Subtitle" or "(Remix"
+		subtitle = strings.HasSuffix(word, ":") || word == "-" || word == "–" || word == "/"
+		if next := i + 1; next < len(words) && strings.HasPrefix(words[next], "(") {
+			subtitle = true
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// titleCaseWord capitalizes a single word, first is set when small words must be capitalized too
+func titleCaseWord(word string, first bool, shouted bool, isSmall map[string]bool, isAcronym map[string]bool, caser unicode.SpecialCase) string {
+	// Leading and trailing punctuation, e.g. quotes and brackets, is left alone
+	start := strings.IndexFunc(word, isWordRune)
+	if start == -1 {
+		return word
+	}
+	end := strings.LastIndexFunc(word, isWordRune)
+	end += runeLenAt(word, end)
+	prefix, core, suffix := word[:start], word[start:end], word[end:]
+
+	upper := strings.ToUpperSpecial(caser, core)
+	switch {
+	case isAcronym[upper]:
+		return prefix + upper + suffix
+	case !shouted && hasInnerCapital(core):
+		return word
+	}
+
+	lower := strings.ToLowerSpecial(caser, core)
+	if !first && isSmall[lower] {
+		return prefix + lower + suffix
+	}
+
+	r, size := utf8.DecodeRuneInString(lower)
+	return prefix + strings.ToUpperSpecial(caser, string(r)) + lower[size:] + suffix
+}
+
+// runeLenAt is utf8.RuneLen of the rune starting at index i of s
+func runeLenAt(s string, i int) int {
+	_, size := utf8.DecodeRuneInString(s[i:])
+	return size
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// hasInnerCapital reports whether an upper case letter follows the first letter of word,
+// which means its casing is deliberate: "AC/DC", "McCartney", "iPhone"
+func hasInnerCapital(word string) bool {
+	_, size := utf8.DecodeRuneInString(word)
+	return strings.IndexFunc(word[size:], unicode.IsUpper) != -1
+}
+
+// FixCapitalization stages the title-cased value of the selected tags and returns the changes
+// that will be written on Save, sorted by key. Tags already correctly cased are not touched.
+func (flac *Flac) FixCapitalization(opts TitleCaseOptions) ([]TagChange, error) {
+	return flac.ApplyTransform(TitleCaseTransform(opts))
+}
@@ -0,0 +1,192 @@
+package flacgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TotalTagsStyle tells which of the TRACKTOTAL/TOTALTRACKS and DISCTOTAL/TOTALDISCS variants are written
+type TotalTagsStyle int
+
+const (
+	// TotalTagsKeep writes the variants found in the file, TRACKTOTAL and DISCTOTAL for new values
+	TotalTagsKeep TotalTagsStyle = iota
+	// TotalTagsTrackTotal writes TRACKTOTAL and DISCTOTAL only
+	TotalTagsTrackTotal
+	// TotalTagsTotalTracks writes TOTALTRACKS and TOTALDISCS only
+	TotalTagsTotalTracks
+	// TotalTagsBoth writes both variants with the same value
+	TotalTagsBoth
+)
+
+// totalTags pairs the numbering tags with the two variants of their total
+var totalTags = []struct {
+	number string
+	keys   [2]string
+}{
+	{"TRACKNUMBER", [2]string{"TRACKTOTAL", "TOTALTRACKS"}},
+	{"DISCNUMBER", [2]string{"DISCTOTAL", "TOTALDISCS"}},
+}
+
+// WithTotalTags makes Save and WriteTo write the track and disc totals with the given variants,
+// whichever ones the file had. Players often read only one of them.
+func WithTotalTags(style TotalTagsStyle) Option {
+	return func(o *options) {
+		o.totalTags = style
+	}
+}
+
+// TrackTotal returns the total number of tracks read from TRACKTOTAL, TOTALTRACKS or a
+// TRACKNUMBER like "3/12", including the staged changes
+func (flac *Flac) TrackTotal() (int, bool) {
+	return flac.total(totalTags[0].number, totalTags[0].keys)
+}
+
+// DiscTotal returns the total number of discs read from DISCTOTAL, TOTALDISCS or a
+// DISCNUMBER like "1/2", including the staged changes
+func (flac *Flac) DiscTotal() (int, bool) {
+	return flac.total(totalTags[1].number, totalTags[1].keys)
+}
+
+// SetTrackTotal stages the total number of tracks, written with the variants chosen by WithTotalTags
+func (flac *Flac) SetTrackTotal(total int) error {
+	return flac.setTotal(totalTags[0].keys, total)
+}
+
+// SetDiscTotal stages the total number of discs, written with the variants chosen by WithTotalTags
+func (flac *Flac) SetDiscTotal(total int) error {
+	return flac.setTotal(totalTags[1].keys, total)
+}
+
+func (flac *Flac) total(number string, keys [2]string) (int, bool) {
+	comments := flac.Comments()
+	for _, key := range keys {
+		if comment, found := findComment(comments, key); found {
+			if total, err := strconv.Atoi(strings.TrimSpace(comment.Value)); err == nil {
+				return total, true
+			}
+		}
+	}
+
+	if comment, found := findComment(comments, number); found {
+		if _, value, found := strings.Cut(comment.Value, "/"); found {
+			if total, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return total, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (flac *Flac) setTotal(keys [2]string, total int) error {
+	if err := flac.checkWritable(); err != nil {
+		return err
+	}
+	defer flac.recordUndo()()
+
+	if total < 0 {
+		return fmt.Errorf("invalid total %d", total)
+	}
+	value := strconv.Itoa(total)
+
+	written := totalKeys(flac.options.totalTags, keys)
+	if flac.options.totalTags == TotalTagsKeep {
+		comments := flac.Comments()
+		written = nil
+		for _, key := range keys {
+			if _, found := findComment(comments, key); found {
+				written = append(written, key)
+			}
+		}
+		if len(written) == 0 {
+			written = keys[:1]
+		}
+	}
+
+	for _, key := range written {
+		if err := flac.SetMetadata(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// totalKeys returns the variants written by style
+func totalKeys(style TotalTagsStyle, keys [2]string) []string {
+	switch style {
+	case TotalTagsTrackTotal:
+		return keys[:1]
+	case TotalTagsTotalTracks:
+		return keys[1:]
+	case TotalTagsBoth:
+		return keys[:]
+	}
+	return nil
+}
+
+// TotalTagsTransform returns a Transform rewriting the track and disc totals with the variants of style,
+// the value of TRACKTOTAL and DISCTOTAL winning over a different TOTALTRACKS and TOTALDISCS.
+// TotalTagsKeep leaves the comments alone.
+func TotalTagsTransform(style TotalTagsStyle) Transform {
+	return TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		for _, tags := range totalTags {
+			written := totalKeys(style, tags.keys)
+			if written == nil {
+				continue
+			}
+
+			// The variants are written where the first one was found
+			value, found := findComment(comments, tags.keys[0])
+			if !found {
+				if value, found = findComment(comments, tags.keys[1]); !found {
+					continue
+				}
+			}
+
+			rewritten := make([]VorbisComment, 0, len(comments)+1)
+			emitted := false
+			for _, comment := range comments {
+				if !containsIgnoreCase(tags.keys[:], comment.Title) {
+					rewritten = append(rewritten, comment)
+					continue
+				}
+				if !emitted {
+					emitted = true
+					for _, key := range written {
+						rewritten = append(rewritten, VorbisComment{Title: key, Value: value.Value})
+					}
+				}
+			}
+			comments = rewritten
+		}
+		return comments
+	})
+}
+
+// stageTotalTags stages the total variants chosen with WithTotalTags before saving
+func (flac *Flac) stageTotalTags() error {
+	if flac.options.totalTags == TotalTagsKeep {
+		return nil
+	}
+	// Saving is not an operation to undo
+	flac.undoDepth++
+	defer func() { flac.undoDepth-- }()
+
+	if _, err := flac.ApplyTransform(TotalTagsTransform(flac.options.totalTags)); err != nil {
+		return fmt.Errorf("unable to write the total tags: %w", err)
+	}
+	return nil
+}
+
+// withTotalTags runs fn with the total variants chosen with WithTotalTags staged, then restores the
+// changes staged before, so the handle is left as it was
+func (flac *Flac) withTotalTags(fn func() error) error {
+	state := flac.staged()
+	defer flac.restore(state)
+
+	if err := flac.stageTotalTags(); err != nil {
+		return err
+	}
+	return fn()
+}
@@ -0,0 +1,235 @@
+package flacgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+)
+
+// TranscodeOptions configures Transcode
+type TranscodeOptions struct {
+	// Encoder configures the encoding, the comments of the source are kept unless its Comments are set
+	Encoder EncoderOptions
+	// SampleRate is the sample rate of the copy, the audio is resampled when it differs from the
+	// source. 0 keeps the one of the source.
+	SampleRate uint32
+	// BitsPerSample is the bit depth of the copy, 0 keeps the one of the source
+	BitsPerSample uint8
+	// Dither adds triangular dither when lowering the bit depth instead of only rounding the samples,
+	// masking the quantization distortion of quiet passages with a little noise
+	Dither bool
+}
+
+// Transcode writes to w a new FLAC file with the audio converted to the sample rate and bit depth
+// of opts, e.g. 44100 Hz and 16 bits for a distribution copy of a 96/24 master, re-encoded keeping
+// the comments and the pictures with the staged changes applied. The channels of WithChannels or
+// WithDownmix and the gain of WithReplayGain are applied as by DecodePCM.
+func (flac *Flac) Transcode(w io.Writer, opts TranscodeOptions) error {
+	pcm, err := flac.DecodePCM()
+	if err != nil {
+		return err
+	}
+	if err := pcm.Convert(opts.SampleRate, opts.BitsPerSample, opts.Dither); err != nil {
+		return err
+	}
+	return flac.encodeCopy(w, pcm, opts.Encoder)
+}
+
+// Convert resamples the audio to sampleRate and requantizes it to bitsPerSample, see Resample and
+// Requantize. 0 keeps the current sample rate or bit depth.
+func (pcm *PCM) Convert(sampleRate uint32, bitsPerSample uint8, dither bool) error {
+	if sampleRate != 0 {
+		if err := pcm.Resample(sampleRate); err != nil {
+			return err
+		}
+	}
+	if bitsPerSample != 0 {
+		if err := pcm.Requantize(bitsPerSample, dither); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resampleZeros is the number of zero crossings of the resampling filter on each side of its center
+const resampleZeros = 16
+
+// resampleResolution is the number of filter values computed between two zero crossings
+const resampleResolution = 512
+
+// Resample converts the audio to sampleRate with a windowed sinc filter, which also removes the
+// frequencies above the new Nyquist frequency when lowering the rate. The samples are rounded and
+// clipped to the current bit depth.
+func (pcm *PCM) Resample(sampleRate uint32) error {
+	if sampleRate == 0 || sampleRate >= 1<<20 {
+		return fmt.Errorf("unable to resample: unsupported sample rate %d", sampleRate)
+	}
+	if pcm.SampleRate == 0 {
+		return fmt.Errorf("unable to resample: unknown source sample rate")
+	}
+	if sampleRate == pcm.SampleRate {
+		return nil
+	}
+
+	ratio := float64(sampleRate) / float64(pcm.SampleRate)
+	// The cutoff is the lower Nyquist frequency, relative to the source one
+	cutoff := min(1, ratio)
+	radius := resampleZeros / cutoff
+	table := resampleTable()
+	length := int(uint64(pcm.Length()) * uint64(sampleRate) / uint64(pcm.SampleRate))
+	maxSample := float64(int64(1)<<(pcm.BitsPerSample-1) - 1)
+
+	for ch, samples := range pcm.Samples {
+		out := make([]int32, length)
+		for n := range out {
+			position := float64(n) / ratio
+			first := max(0, int(math.Ceil(position-radius)))
+			last := min(len(samples)-1, int(math.Floor(position+radius)))
+			sum := 0.0
+			for k := first; k <= last; k++ {
+				sum += float64(samples[k]) * resampleKernel(table, math.Abs(position-float64(k))*cutoff)
+			}
+			out[n] = int32(math.Max(-maxSample-1, math.Min(maxSample, math.Round(sum*cutoff))))
+		}
+		pcm.Samples[ch] = out
+	}
+	pcm.SampleRate = sampleRate
+
+	return nil
+}
+
+// resampleTable returns the values of the Blackman windowed sinc from 0 to resampleZeros, with
+// resampleResolution values per zero crossing
+func resampleTable() []float64 {
+	table := make([]float64, resampleZeros*resampleResolution+2)
+	for i := range table {
+		x := float64(i) / resampleResolution
+		if x >= resampleZeros {
+			continue
+		}
+		sinc := 1.0
+		if x > 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		window := 0.42 + 0.5*math.Cos(math.Pi*x/resampleZeros) + 0.08*math.Cos(2*math.Pi*x/resampleZeros)
+		table[i] = sinc * window
+	}
+	return table
+}
+
+// resampleKernel interpolates the filter of table at distance x from its center, in zero crossings
+func resampleKernel(table []float64, x float64) float64 {
+	position := x * resampleResolution
+	i := int(position)
+	if i >= len(table)-1 {
+		return 0
+	}
+	fraction := position - float64(i)
+	return table[i] + (table[i+1]-table[i])*fraction
+}
+
+// Requantize converts the samples to bitsPerSample. Raising the bit depth is lossless, lowering it
+// rounds the samples, adding triangular dither first when dither is set. The dither is seeded the
+// same way every time so a file always converts to the same samples.
+func (pcm *PCM) Requantize(bitsPerSample uint8, dither bool) error {
+	if bitsPerSample < 4 || bitsPerSample > 32 {
+		return fmt.Errorf("unable to requantize: unsupported bits per sample %d, FLAC supports 4 to 32", bitsPerSample)
+	}
+
+	switch {
+	case bitsPerSample > pcm.BitsPerSample:
+		shift := bitsPerSample - pcm.BitsPerSample
+		for _, samples := range pcm.Samples {
+			for i := range samples {
+				samples[i] <<= shift
+			}
+		}
+	case bitsPerSample < pcm.BitsPerSample:
+		scale := float64(int64(1) << (pcm.BitsPerSample - bitsPerSample))
+		maxSample := float64(int64(1)<<(bitsPerSample-1) - 1)
+		random := rand.New(rand.NewPCG(0, 0))
+		for _, samples := range pcm.Samples {
+			for i := range samples {
+				value := float64(samples[i]) / scale
+				if dither {
+					// The sum of two uniform values has a triangular distribution over ±1 LSB
+					value += random.Float64() - random.Float64()
+				}
+				samples[i] = int32(math.Max(-maxSample-1, math.Min(maxSample, math.Round(value))))
+			}
+		}
+	}
+	pcm.BitsPerSample = bitsPerSample
+
+	return nil
+}
+
+// encodeCopy encodes pcm with opts to w, keeping the comments of flac, unless opts.Comments is
+// set, and its pictures with the staged changes applied
+func (flac *Flac) encodeCopy(w io.Writer, pcm *PCM, opts EncoderOptions) error {
+	if opts.Comments == nil {
+		opts.Comments = flac.Comments()
+	}
+	padding := opts.Padding
+	opts.Padding = -1
+
+	// The encoded stream is the magic header, STREAMINFO, VORBIS_COMMENT and the frames:
+	// the pictures and the padding go after VORBIS_COMMENT
+	var encoded bytes.Buffer
+	if err := Encode(&encoded, pcm, opts); err != nil {
+		return err
+	}
+	data := encoded.Bytes()
+	vorbisEnd := 4 + 38 + 4 + int(binary.BigEndian.Uint32(data[4+38:])&0xFFFFFF)
+
+	blocks, err := flac.readAllMetadataBlocks()
+	if err != nil {
+		return fmt.Errorf("unable to read all metadata blocks: %w", err)
+	}
+	pictures, err := flac.outputPictureBlocks(blocks)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if len(pictures) > 0 || padding >= 0 {
+		data[4+38] &^= 0x80
+	}
+	bw.Write(data[:vorbisEnd])
+	for i, picture := range pictures {
+		length := picture.bodyLength()
+		if length > maxBlockLength {
+			return &BlockTooLargeError{BlockType: "PICTURE", Size: int(length)}
+		}
+		header := []byte{6, byte(length >> 16), byte(length >> 8), byte(length)}
+		if i == len(pictures)-1 && padding < 0 {
+			header[0] |= 0x80
+		}
+		bw.Write(header)
+
+		body, err := flac.openBlockBody(&picture)
+		if err != nil {
+			return fmt.Errorf("unable to read picture: %w", err)
+		}
+		_, err = io.CopyN(bw, body, length)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("unable to write picture: %w", err)
+		}
+	}
+	if padding >= 0 {
+		var paddingBlock bytes.Buffer
+		writeMetadataBlock(&paddingBlock, 1, make([]byte, min(padding, maxBlockLength)), true)
+		paddingBlock.WriteTo(bw)
+	}
+	bw.Write(data[vorbisEnd:])
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("unable to write FLAC file: %w", err)
+	}
+	return nil
+}
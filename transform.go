@@ -0,0 +1,308 @@
+package flacgo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Transform is a tag normalization step, it returns the comments to write in place of the given ones
+type Transform interface {
+	Apply(comments []VorbisComment) []VorbisComment
+}
+
+// TransformFunc adapts a function to the Transform interface
+type TransformFunc func(comments []VorbisComment) []VorbisComment
+
+// Apply calls fn
+func (fn TransformFunc) Apply(comments []VorbisComment) []VorbisComment {
+	return fn(comments)
+}
+
+// Pipeline is a Transform applying its steps in order
+type Pipeline []Transform
+
+// Apply runs every step on the output of the previous one
+func (pipeline Pipeline) Apply(comments []VorbisComment) []VorbisComment {
+	for _, transform := range pipeline {
+		comments = transform.Apply(comments)
+	}
+	return comments
+}
+
+// ApplyToFiles runs the pipeline on every file at paths, saving the files that changed
+func (pipeline Pipeline) ApplyToFiles(paths []string) []TransformResult {
+	results, _ := pipeline.ApplyToFilesContext(context.Background(), paths)
+	return results
+}
+
+// ApplyToFilesContext is ApplyToFiles stopping when ctx is canceled. The results cover the files
+// processed until then, in order, so that paths[len(results):] are the ones skipped; the returned
+// error is only set when ctx is canceled before all the files are processed.
+func (pipeline Pipeline) ApplyToFilesContext(ctx context.Context, paths []string) ([]TransformResult, error) {
+	return transformFiles(ctx, paths, nil, func(flac *Flac) ([]TagChange, error) {
+		return flac.ApplyTransform(pipeline)
+	})
+}
+
+// ApplyToFilesJournal is ApplyToFilesContext recording the files processed without error in journal.
+// The files the journal reports as completed are not opened again and have Skipped set in the results,
+// so that an interrupted job restarts where it stopped.
+func (pipeline Pipeline) ApplyToFilesJournal(ctx context.Context, paths []string, journal Journal) ([]TransformResult, error) {
+	return transformFiles(ctx, paths, journal, func(flac *Flac) ([]TagChange, error) {
+		return flac.ApplyTransform(pipeline)
+	})
+}
+
+// ApplyTransform stages the comments returned by transform in place of the current ones
+// and returns the resulting changes sorted by key. Tags with several values are compared as a
+// whole, their change lists the values joined by "; ".
+func (flac *Flac) ApplyTransform(transform Transform) ([]TagChange, error) {
+	if err := flac.checkWritable(); err != nil {
+		return nil, err
+	}
+	defer flac.recordUndo()()
+
+	current := flac.Comments()
+	transformed := transform.Apply(slices.Clone(current))
+
+	group := func(comments []VorbisComment) (map[string][]VorbisComment, []string) {
+		groups := make(map[string][]VorbisComment)
+		order := make([]string, 0)
+		for _, comment := range comments {
+			key := strings.ToUpper(comment.Title)
+			if _, found := groups[key]; !found {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], comment)
+		}
+		return groups, order
+	}
+	before, beforeOrder := group(current)
+	after, afterOrder := group(transformed)
+
+	values := func(comments []VorbisComment) []string {
+		values := make([]string, len(comments))
+		for i, comment := range comments {
+			values[i] = comment.Value
+		}
+		return values
+	}
+
+	changes := make([]TagChange, 0)
+	for _, key := range afterOrder {
+		previous, next := before[key], after[key]
+		if slices.Equal(previous, next) {
+			continue
+		}
+
+		title := next[0].Title
+		if len(previous) > 0 && previous[0].Title != title {
+			// The key spelling changed, drop the old one first
+			if err := flac.RemoveMetadata(previous[0].Title, true); err != nil {
+				return nil, fmt.Errorf("unable to remove %s: %w", previous[0].Title, err)
+			}
+		}
+		if err := flac.SetMetadataValues(title, values(next)); err != nil {
+			return nil, fmt.Errorf("unable to set %s: %w", title, err)
+		}
+		changes = append(changes, TagChange{
+			Key: title,
+			Old: strings.Join(values(previous), "; "),
+			New: strings.Join(values(next), "; "),
+		})
+	}
+
+	for _, key := range beforeOrder {
+		if _, found := after[key]; found {
+			continue
+		}
+		previous := before[key]
+		if err := flac.RemoveMetadata(previous[0].Title, true); err != nil {
+			return nil, fmt.Errorf("unable to remove %s: %w", previous[0].Title, err)
+		}
+		changes = append(changes, TagChange{Key: previous[0].Title, Old: strings.Join(values(previous), "; "), Removed: true})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return strings.ToUpper(changes[i].Key) < strings.ToUpper(changes[j].Key)
+	})
+	return changes, nil
+}
+
+// MapValues returns a Transform applying fn to the value of every comment whose key is in keys,
+// all comments if keys is empty
+func MapValues(keys []string, fn func(value string) string) Transform {
+	selected := make(map[string]bool)
+	for _, key := range keys {
+		selected[strings.ToUpper(key)] = true
+	}
+
+	return TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		for i, comment := range comments {
+			if len(selected) == 0 || selected[strings.ToUpper(comment.Title)] {
+				comments[i].Value = fn(comment.Value)
+			}
+		}
+		return comments
+	})
+}
+
+// TitleCaseTransform returns a Transform capitalizing tags like FixCapitalization
+func TitleCaseTransform(opts TitleCaseOptions) Transform {
+	keys := opts.Keys
+	if len(keys) == 0 {
+		keys = DefaultTitleCaseKeys
+	}
+	return MapValues(keys, func(value string) string {
+		return TitleCase(value, opts)
+	})
+}
+
+// RegexTransform returns a Transform replacing the matches of pattern like TransformTags
+func RegexTransform(pattern string, replacement string, keys []string) (Transform, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return MapValues(keys, func(value string) string {
+		return re.ReplaceAllString(value, replacement)
+	}), nil
+}
+
+// TrimSpaceTransform returns a Transform removing the leading and trailing whitespace of every value
+func TrimSpaceTransform() Transform {
+	return MapValues(nil, strings.TrimSpace)
+}
+
+// DefaultTagAliases maps the legacy or non standard tag names written by some taggers to the usual ones
+var DefaultTagAliases = map[string]string{
+	"YEAR":         "DATE",
+	"TRACK":        "TRACKNUMBER",
+	"DISC":         "DISCNUMBER",
+	"ALBUM ARTIST": "ALBUMARTIST",
+	"ALBUM_ARTIST": "ALBUMARTIST",
+	"DESCRIPTION":  "COMMENT",
+	"TOTALTRACKS":  "TRACKTOTAL",
+	"TOTALDISCS":   "DISCTOTAL",
+}
+
+// RenameTagsTransform returns a Transform migrating tags named like a key of aliases to the mapped name.
+// If the file already has the target tag it is kept and the alias is dropped.
+func RenameTagsTransform(aliases map[string]string) Transform {
+	renames := make(map[string]string)
+	for from, to := range aliases {
+		renames[strings.ToUpper(from)] = strings.ToUpper(to)
+	}
+
+	return TransformFunc(func(comments []VorbisComment) []VorbisComment {
+		present := make(map[string]bool)
+		for _, comment := range comments {
+			present[strings.ToUpper(comment.Title)] = true
+		}
+
+		renamed := make([]VorbisComment, 0, len(comments))
+		for _, comment := range comments {
+			if to, found := renames[strings.ToUpper(comment.Title)]; found {
+				if present[to] {
+					continue
+				}
+				present[to] = true
+				comment.Title = to
+			}
+			renamed = append(renamed, comment)
+		}
+		return renamed
+	})
+}
+
+// TransformTags stages the substitution of every match of the regular expression pattern by replacement
+// in the values of the selected tags, all tags if keys is empty, and returns the resulting changes.
+// Replacement can reference submatches like regexp.Regexp.ReplaceAllString, e.g. "$1".
+func (flac *Flac) TransformTags(pattern string, replacement string, keys []string) ([]TagChange, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	return flac.ApplyTransform(MapValues(keys, func(value string) string {
+		return re.ReplaceAllString(value, replacement)
+	}))
+}
+
+// TransformResult is the outcome of transforming the tags of a single file
+type TransformResult struct {
+	Path    string
+	Changes []TagChange
+	Err     error
+	// Skipped is set when the journal of ApplyToFilesJournal reported the file as completed by an earlier run
+	Skipped bool
+}
+
+// TransformTagsInFiles applies TransformTags to every file at paths, saving the files that changed.
+// Failures of single files are reported in the results, the returned error is only set for an invalid pattern.
+func TransformTagsInFiles(paths []string, pattern string, replacement string, keys []string) ([]TransformResult, error) {
+	return TransformTagsInFilesContext(context.Background(), paths, pattern, replacement, keys)
+}
+
+// TransformTagsInFilesContext is TransformTagsInFiles stopping when ctx is canceled, see ApplyToFilesContext
+func TransformTagsInFilesContext(ctx context.Context, paths []string, pattern string, replacement string, keys []string) ([]TransformResult, error) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	return transformFiles(ctx, paths, nil, func(flac *Flac) ([]TagChange, error) {
+		return flac.TransformTags(pattern, replacement, keys)
+	})
+}
+
+// transformFiles runs transformFile on every file at paths until ctx is canceled,
+// skipping and recording the completed files in journal if set
+func transformFiles(ctx context.Context, paths []string, journal Journal, fn func(flac *Flac) ([]TagChange, error)) ([]TransformResult, error) {
+	results := make([]TransformResult, 0, len(paths))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("canceled after %d of %d files: %w", len(results), len(paths), err)
+		}
+
+		result := TransformResult{Path: path}
+		if journal != nil && journal.Completed(path) {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+
+		result.Changes, result.Err = transformFile(path, fn)
+		results = append(results, result)
+
+		if journal != nil && result.Err == nil {
+			if err := journal.Record(path); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// transformFile opens the file at path, stages the changes of fn and saves the file if there are any
+func transformFile(path string, fn func(flac *Flac) ([]TagChange, error)) ([]TagChange, error) {
+	flac, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer flac.Close()
+
+	changes, err := fn(flac)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	if err := flac.Save(nil); err != nil {
+		return nil, fmt.Errorf("unable to save: %w", err)
+	}
+	return changes, nil
+}
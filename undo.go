@@ -0,0 +1,98 @@
+package flacgo
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// stagedState holds the changes staged on a file, saved on the undo stack before each operation
+type stagedState struct {
+	pendingComments     []VorbisComment
+	removedComments     map[string]bool
+	pendingCoverPicture []byte
+	pendingCoverStream  *pendingPicture
+	pendingPictures     []*pendingPicture
+	removeCoverPicture  bool
+	removedPictures     map[int64]bool
+	stripAPETag         bool
+	purgeHistory        bool
+	pendingStreamInfo   *StreamInfo
+}
+
+// staged returns a copy of the changes currently staged
+func (flac *Flac) staged() stagedState {
+	return stagedState{
+		pendingComments:     slices.Clone(flac.pendingComments),
+		removedComments:     maps.Clone(flac.removedComments),
+		pendingCoverPicture: flac.pendingCoverPicture,
+		pendingCoverStream:  flac.pendingCoverStream,
+		pendingPictures:     slices.Clone(flac.pendingPictures),
+		removeCoverPicture:  flac.removeCoverPicture,
+		removedPictures:     maps.Clone(flac.removedPictures),
+		stripAPETag:         flac.stripAPETag,
+		purgeHistory:        flac.purgeHistory,
+		pendingStreamInfo:   flac.pendingStreamInfo,
+	}
+}
+
+// restore replaces the staged changes with state
+func (flac *Flac) restore(state stagedState) {
+	flac.pendingComments = state.pendingComments
+	flac.removedComments = state.removedComments
+	flac.pendingCoverPicture = state.pendingCoverPicture
+	flac.pendingCoverStream = state.pendingCoverStream
+	flac.pendingPictures = state.pendingPictures
+	flac.removeCoverPicture = state.removeCoverPicture
+	flac.removedPictures = state.removedPictures
+	flac.stripAPETag = state.stripAPETag
+	flac.purgeHistory = state.purgeHistory
+	flac.pendingStreamInfo = state.pendingStreamInfo
+}
+
+// recordUndo starts an operation to undo as a whole, to be called as defer flac.recordUndo()().
+// The operations called by another one are part of it, an operation that changes nothing is not recorded.
+func (flac *Flac) recordUndo() func() {
+	flac.undoDepth++
+	if flac.undoDepth > 1 {
+		return func() { flac.undoDepth-- }
+	}
+
+	before := flac.staged()
+	return func() {
+		flac.undoDepth--
+		if !reflect.DeepEqual(before, flac.staged()) {
+			flac.undoStack = append(flac.undoStack, before)
+		}
+	}
+}
+
+// Undo reverts the last staged operation, e.g. a SetMetadata or an ApplyTransform, and reports
+// whether there was one. Saving over the opened file clears the operations whichever way it is
+// written, the saved state becomes the one opened. Saving to another path keeps them, undoing then
+// only changes what the next Save writes.
+func (flac *Flac) Undo() bool {
+	if len(flac.undoStack) == 0 {
+		return false
+	}
+
+	last := len(flac.undoStack) - 1
+	flac.restore(flac.undoStack[last])
+	flac.undoStack = flac.undoStack[:last]
+	return true
+}
+
+// UndoAll reverts all the staged operations, back to the file as it was opened
+func (flac *Flac) UndoAll() {
+	if len(flac.undoStack) == 0 {
+		return
+	}
+
+	flac.restore(flac.undoStack[0])
+	flac.undoStack = nil
+}
+
+// UndoLen returns the number of staged operations Undo can revert
+func (flac *Flac) UndoLen() int {
+	return len(flac.undoStack)
+}
@@ -7,6 +7,7 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"os"
+	"slices"
 	"strings"
 )
 
@@ -51,6 +52,16 @@ func containsIgnoreCase(slice []string, str string) bool {
 	return false
 }
 
+// findComment returns the first comment with the given title, ignoring case
+func findComment(comments []VorbisComment, title string) (VorbisComment, bool) {
+	for _, cmt := range comments {
+		if strings.EqualFold(cmt.Title, title) {
+			return cmt, true
+		}
+	}
+	return VorbisComment{}, false
+}
+
 // GetFilteredBlocks filters out all the blocks provided as second parameter to the function
 func GetFilteredBlocks(blocks []MetadataBlock, blockTypes []string) []MetadataBlock {
 	filteredBlocks := make([]MetadataBlock, 0)
@@ -70,24 +81,54 @@ func IncreaseCommentsCounter(fileBinary []byte, vorbisBlock []byte, amount int)
 }
 
 // If a duplicate exists this function will return the newComment value instead of the old one in order
-// to replace the previous value with the new one and avoid duplicate metadata inside the vorbis block
+// to replace the previous value with the new one and avoid duplicate metadata inside the vorbis block.
+// Titles are compared ignoring case, a title found in newComments replaces all its previous values
+// while a title with several values in the same list (e.g. one GENRE per genre) keeps them all.
+// Replaced values keep the spelling of the title found in previousComments, e.g. setting "artist"
+// on a file tagged "Artist" writes "Artist"; a title removed then set again takes the new spelling.
 func FilterDuplicatedComments(previousComments []VorbisComment, newComments []VorbisComment, removedComments map[string]bool) []VorbisComment {
-	comments := make(map[string]VorbisComment)
+	group := func(comments []VorbisComment) (map[string][]VorbisComment, []string) {
+		groups := make(map[string][]VorbisComment)
+		// Keep track of the first time each title is seen so the output order is stable
+		order := make([]string, 0)
+		for _, cmt := range comments {
+			title := strings.ToLower(cmt.Title)
+			if _, exists := groups[title]; !exists {
+				order = append(order, title)
+			}
+			groups[title] = append(groups[title], cmt)
+		}
+		return groups, order
+	}
+	previous, _ := group(previousComments)
+	replacements, newOrder := group(newComments)
 
+	merged := make([]VorbisComment, 0, len(previousComments)+len(newComments))
+	emitted := make(map[string]bool)
 	for _, oldComment := range previousComments {
 		title := strings.ToLower(oldComment.Title)
-		if !removedComments[title] {
-			comments[title] = oldComment
+		replacement, replaced := replacements[title]
+
+		switch {
+		case removedComments[title] && !replaced:
+		case !replaced || slices.Equal(replacement, previous[title]):
+			// Unchanged values keep their original positions
+			merged = append(merged, oldComment)
+			emitted[title] = true
+		case !emitted[title] && !removedComments[title]:
+			for _, cmt := range replacement {
+				merged = append(merged, VorbisComment{Title: oldComment.Title, Value: cmt.Value})
+			}
+			emitted[title] = true
 		}
 	}
 
-	for _, newComment := range newComments {
-		comments[strings.ToLower(newComment.Title)] = newComment
-	}
-
-	merged := make([]VorbisComment, 0, len(comments))
-	for _, c := range comments {
-		merged = append(merged, c)
+	// Titles that are new or that were removed and set again go at the end
+	for _, title := range newOrder {
+		if !emitted[title] {
+			merged = append(merged, replacements[title]...)
+			emitted[title] = true
+		}
 	}
 
 	return merged
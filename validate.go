@@ -0,0 +1,248 @@
+package flacgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity tells how serious a validation issue is
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(severity))
+}
+
+// Issue describes a single problem found while validating a FLAC file
+type Issue struct {
+	Severity Severity
+	// Code is a short stable identifier of the kind of issue, e.g. "zero-md5"
+	Code    string
+	Message string
+}
+
+func (issue Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", issue.Severity, issue.Message, issue.Code)
+}
+
+// Validate checks the structure of the metadata blocks stored in the file and returns all the issues found,
+// including the pictures not matching the policy set with WithArtworkPolicy.
+// Staged changes are not taken into account until the file is saved.
+func (flac *Flac) Validate() []Issue {
+	blocks, err := flac.readAllMetadataBlocksWithData()
+	if err != nil {
+		return []Issue{{SeverityError, "unreadable-metadata", err.Error()}}
+	}
+
+	issues := flac.validateBlocks(blocks)
+	if flac.options.artworkPolicy != nil {
+		for _, block := range blocks {
+			if block.BlockType != "PICTURE" {
+				continue
+			}
+			// Pictures that can't be parsed are already reported
+			if picture, err := parsePictureBlock(block.BlockData); err == nil {
+				issues = append(issues, flac.options.artworkPolicy.Check(picture)...)
+			}
+		}
+	}
+
+	return issues
+}
+
+// validateBlocks checks a list of metadata blocks against the FLAC format rules
+func (flac *Flac) validateBlocks(blocks []MetadataBlock) []Issue {
+	var issues []Issue
+	add := func(severity Severity, code string, format string, args ...any) {
+		issues = append(issues, Issue{severity, code, fmt.Sprintf(format, args...)})
+	}
+
+	counts := make(map[string]int)
+	for i, block := range blocks {
+		counts[block.BlockType]++
+
+		switch block.BlockType {
+		case "STREAMINFO":
+			if i != 0 {
+				add(SeverityError, "streaminfo-not-first", "STREAMINFO is block #%d, it must be the first one", i)
+			}
+			streamInfo, err := parseStreamInfoBlock(block.BlockData)
+			if err != nil {
+				add(SeverityError, "bad-streaminfo", "%v", err)
+				continue
+			}
+			if streamInfo.SampleRate == 0 {
+				add(SeverityError, "bad-streaminfo", "STREAMINFO has a sample rate of 0")
+			}
+			if streamInfo.MinBlockSize < 16 || streamInfo.MaxBlockSize < streamInfo.MinBlockSize {
+				add(SeverityError, "bad-streaminfo", "STREAMINFO has invalid block sizes %d-%d", streamInfo.MinBlockSize, streamInfo.MaxBlockSize)
+			}
+			if !streamInfo.HasMD5() {
+				add(SeverityWarning, "zero-md5", "STREAMINFO has no MD5 signature of the audio")
+			}
+		case "VORBIS_COMMENT":
+			// The raw values are checked, without the fallback encoding
+			comments, err := (&Flac{}).parseVorbisBlock(block.BlockData)
+			if err != nil {
+				add(SeverityError, "bad-vorbis-comment", "VORBIS_COMMENT block #%d can't be parsed: %v", i, err)
+				continue
+			}
+			if vendor, _, _, _ := vorbisHeader(block.BlockData); uint64(len(block.BlockData)) < 8+uint64(len(vendor)) {
+				add(SeverityWarning, "truncated-vorbis-comment", "VORBIS_COMMENT block #%d lacks the vendor string or the number of comments", i)
+			}
+			for _, comment := range comments {
+				if !validCommentName(comment.Title) {
+					add(SeverityWarning, "bad-comment-name", "comment name %q must only have ASCII characters from 0x20 to 0x7D", comment.Title)
+				}
+				if !utf8.ValidString(comment.Value) {
+					add(SeverityWarning, "non-utf8-comment", "the value of %s is not valid UTF-8", comment.Title)
+				}
+			}
+		case "PICTURE":
+			if block.BlockData == nil && block.BlockHeader.BlockLength > 0 {
+				// The body is not loaded, it's streamed from a picture already checked
+				continue
+			}
+			if _, err := parsePictureBlock(block.BlockData); err != nil {
+				add(SeverityError, "bad-picture", "PICTURE block #%d can't be parsed: %v", i, err)
+			}
+		case "SEEKTABLE":
+			if block.BlockHeader.BlockLength%18 != 0 {
+				add(SeverityError, "bad-seektable", "SEEKTABLE length %d is not a multiple of 18", block.BlockHeader.BlockLength)
+			}
+		case "INVALID":
+			add(SeverityError, "invalid-block", "block #%d has the invalid block type 127", i)
+		case "":
+			add(SeverityWarning, "reserved-block", "block #%d has the reserved block type %d", i, block.BlockHeader.BlockType)
+		}
+	}
+
+	if len(blocks) > 0 && counts["STREAMINFO"] == 0 {
+		add(SeverityError, "missing-streaminfo", "the file has no STREAMINFO block")
+	}
+	for _, blockType := range []string{"STREAMINFO", "VORBIS_COMMENT", "SEEKTABLE"} {
+		if counts[blockType] > 1 {
+			add(SeverityError, "duplicate-block", "found %d %s blocks, only one is allowed", counts[blockType], blockType)
+		}
+	}
+
+	return issues
+}
+
+// validCommentName reports whether name is a valid Vorbis comment field name
+func validCommentName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] > 0x7D || name[i] == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// strictIssues are the warnings WithStrict rejects besides errors, since they break the specification
+var strictIssues = []string{"bad-comment-name", "non-utf8-comment", "reserved-block", "truncated-vorbis-comment"}
+
+// WithStrict makes Open fail on any violation of the FLAC specification, like a first block other
+// than STREAMINFO, duplicate VORBIS_COMMENT blocks, comments that are not UTF-8 or pictures whose
+// lengths don't match their block. The error wraps a *ValidationError listing the violations.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// checkStrict returns the issues of Validate that WithStrict rejects
+func (flac *Flac) checkStrict() error {
+	var violations []Issue
+	for _, issue := range flac.Validate() {
+		if issue.Severity == SeverityError || slices.Contains(strictIssues, issue.Code) {
+			violations = append(violations, issue)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Issues: violations}
+	}
+	return nil
+}
+
+// ValidationError is returned by Save and WriteTo when the rebuilt metadata is not valid,
+// in which case nothing is written
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, len(err.Issues))
+	for i, issue := range err.Issues {
+		messages[i] = issue.String()
+	}
+	return "invalid metadata: " + strings.Join(messages, "; ")
+}
+
+// checkOutputBlocks validates the blocks about to be written, decoding them from their raw header
+// bytes so that the headers built while saving are checked against the actual bodies
+func (flac *Flac) checkOutputBlocks(blocks []MetadataBlock) error {
+	var issues []Issue
+	parsed := make([]MetadataBlock, len(blocks))
+
+	for i, block := range blocks {
+		header := block.BlockHeader.Data
+		if len(header) != 4 {
+			issues = append(issues, Issue{SeverityError, "bad-header", fmt.Sprintf("block #%d has a %d bytes header", i, len(header))})
+			continue
+		}
+
+		blockType := header[0] & 0x7F
+		blockLength := binary.BigEndian.Uint32([]byte{0, header[1], header[2], header[3]})
+		bodyLength := block.bodyLength()
+		if bodyLength > maxBlockLength {
+			issues = append(issues, Issue{SeverityError, "block-too-large", fmt.Sprintf("block #%d is %d bytes, the limit is %d", i, bodyLength, maxBlockLength)})
+		} else if int64(blockLength) != bodyLength {
+			issues = append(issues, Issue{SeverityError, "length-mismatch", fmt.Sprintf("block #%d header declares %d bytes but its body has %d", i, blockLength, bodyLength)})
+		}
+		if isLast := header[0]&0x80 != 0; isLast != (i == len(blocks)-1) {
+			issues = append(issues, Issue{SeverityError, "bad-last-block", fmt.Sprintf("block #%d has a wrong last-block flag", i)})
+		}
+
+		parsed[i] = MetadataBlock{
+			BlockType: BlockMapping[blockType],
+			BlockHeader: MetadataBlockHeader{
+				BlockType:   blockType,
+				BlockLength: blockLength,
+				Data:        header,
+			},
+			BlockData: block.BlockData,
+		}
+	}
+
+	for _, issue := range flac.validateBlocks(parsed) {
+		if issue.Severity == SeverityError {
+			issues = append(issues, issue)
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
@@ -0,0 +1,501 @@
+package flacgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// WAVE format tags
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavFormat holds the content of the fmt chunk of a WAVE file
+type wavFormat struct {
+	formatTag     uint16
+	channels      uint16
+	sampleRate    uint32
+	blockAlign    uint16
+	bitsPerSample uint16
+	// validBits is the number of bits actually used in each container of bitsPerSample bits
+	validBits uint16
+}
+
+// ReadWAV reads an uncompressed PCM WAVE file in memory
+func ReadWAV(r io.Reader) (*PCM, error) {
+	var pcm *PCM
+	comments, err := readWAVChunks(r, func(format *wavFormat, size uint32, _ []VorbisComment) (bool, error) {
+		parsed, err := readWAVData(r, format, size)
+		pcm = parsed
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	pcm.Comments = comments
+
+	return pcm, nil
+}
+
+// EncodeWAV compresses the PCM WAVE file read from r into a FLAC stream written to w as the samples
+// are read, without holding the audio in memory. The tags of the LIST INFO chunks before the data
+// chunk are used unless opts.Comments is set, the chunks after it are not read. As with NewEncoder,
+// the length, frame sizes and MD5 signature are only written to STREAMINFO when w can seek.
+func EncodeWAV(r io.Reader, w io.Writer, opts EncoderOptions) error {
+	_, err := readWAVChunks(r, func(format *wavFormat, size uint32, comments []VorbisComment) (bool, error) {
+		if opts.Comments == nil {
+			opts.Comments = comments
+		}
+		return false, encodeWAVData(r, w, format, size, opts)
+	})
+	return err
+}
+
+// readWAVChunks reads the chunks of a WAVE file, calling data at the data chunk with the fmt chunk and
+// the tags found so far to consume its size bytes from r. The chunks following it are read only if
+// data returns true. It returns the tags of all the chunks read.
+func readWAVChunks(r io.Reader, data func(format *wavFormat, size uint32, comments []VorbisComment) (bool, error)) ([]VorbisComment, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("unable to read RIFF header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAVE file")
+	}
+
+	var format *wavFormat
+	var comments []VorbisComment
+	read := false
+chunks:
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			// Chunks after the data chunk are optional, a truncated trailer only loses them
+			if err == io.EOF || (read && err == io.ErrUnexpectedEOF) {
+				break chunks
+			}
+			return nil, fmt.Errorf("unable to read chunk header: %w", err)
+		}
+		id := string(header[0:4])
+		size := binary.LittleEndian.Uint32(header[4:8])
+
+		switch id {
+		case "fmt ":
+			if size > wavMaxFormatSize {
+				return nil, fmt.Errorf("fmt chunk is %d bytes, expected at most %d", size, wavMaxFormatSize)
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("unable to read fmt chunk: %w", err)
+			}
+			parsed, err := parseWAVFormat(body)
+			if err != nil {
+				return nil, err
+			}
+			format = parsed
+		case "data":
+			if format == nil {
+				return nil, errors.New("WAVE data chunk comes before the fmt chunk")
+			}
+			more, err := data(format, size, comments)
+			if err != nil {
+				return nil, err
+			}
+			if !more {
+				return comments, nil
+			}
+			read = true
+		case "LIST":
+			// Other lists than INFO can be large, e.g. associated data lists, they are skipped
+			if size > wavMaxListSize {
+				if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+					if read {
+						break chunks
+					}
+					return nil, fmt.Errorf("unable to skip LIST chunk: %w", err)
+				}
+				break
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				if read {
+					break chunks
+				}
+				return nil, fmt.Errorf("unable to read LIST chunk: %w", err)
+			}
+			comments = append(comments, parseWAVInfo(body)...)
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+				if read {
+					break chunks
+				}
+				return nil, fmt.Errorf("unable to skip %q chunk: %w", id, err)
+			}
+		}
+
+		// Chunks are word aligned
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				break chunks
+			}
+		}
+	}
+
+	if !read {
+		return nil, errors.New("WAVE file has no data chunk")
+	}
+	return comments, nil
+}
+
+// wavInfoTags maps the RIFF INFO chunk identifiers to Vorbis comment names
+var wavInfoTags = map[string]string{
+	"INAM": "TITLE",
+	"IART": "ARTIST",
+	"IPRD": "ALBUM",
+	"ICRD": "DATE",
+	"IGNR": "GENRE",
+	"ITRK": "TRACKNUMBER",
+	"ICMT": "COMMENT",
+	"ICOP": "COPYRIGHT",
+}
+
+// parseWAVInfo returns the tags of a LIST chunk of type INFO as Vorbis comments, other lists are ignored
+func parseWAVInfo(body []byte) []VorbisComment {
+	if len(body) < 4 || string(body[0:4]) != "INFO" {
+		return nil
+	}
+
+	var comments []VorbisComment
+	for offset := 4; offset+8 <= len(body); {
+		id := string(body[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(body[offset+4 : offset+8]))
+		offset += 8
+		if size > len(body)-offset {
+			break
+		}
+
+		// Values are NUL terminated, in the system code page of whoever wrote them
+		value := strings.TrimSpace(strings.TrimRight(string(body[offset:offset+size]), "\x00"))
+		if !utf8.ValidString(value) {
+			value = decodeLatin1(value)
+		}
+		if title, found := wavInfoTags[id]; found && value != "" {
+			comments = append(comments, VorbisComment{Title: title, Value: value})
+		}
+
+		offset += size + size%2
+	}
+
+	return comments
+}
+
+// decodeLatin1 converts ISO-8859-1 text to UTF-8
+func decodeLatin1(text string) string {
+	runes := make([]rune, len(text))
+	for i := 0; i < len(text); i++ {
+		runes[i] = rune(text[i])
+	}
+	return string(runes)
+}
+
+// parseWAVFormat parses the body of a fmt chunk, only integer PCM is supported
+func parseWAVFormat(body []byte) (*wavFormat, error) {
+	if len(body) < 16 {
+		return nil, fmt.Errorf("fmt chunk is %d bytes, expected at least 16", len(body))
+	}
+
+	format := &wavFormat{
+		formatTag:     binary.LittleEndian.Uint16(body[0:2]),
+		channels:      binary.LittleEndian.Uint16(body[2:4]),
+		sampleRate:    binary.LittleEndian.Uint32(body[4:8]),
+		blockAlign:    binary.LittleEndian.Uint16(body[12:14]),
+		bitsPerSample: binary.LittleEndian.Uint16(body[14:16]),
+	}
+	format.validBits = format.bitsPerSample
+
+	if format.formatTag == wavFormatExtensible {
+		if len(body) < 40 {
+			return nil, fmt.Errorf("extensible fmt chunk is %d bytes, expected 40", len(body))
+		}
+		if valid := binary.LittleEndian.Uint16(body[18:20]); valid > 0 {
+			format.validBits = valid
+		}
+		// The sub format GUID starts with the format tag it stands for
+		format.formatTag = binary.LittleEndian.Uint16(body[24:26])
+	}
+
+	if format.formatTag != wavFormatPCM {
+		return nil, fmt.Errorf("unsupported WAVE format 0x%04x, only integer PCM is supported", format.formatTag)
+	}
+	switch format.bitsPerSample {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported WAVE sample size of %d bits", format.bitsPerSample)
+	}
+	if format.channels == 0 || format.validBits > format.bitsPerSample {
+		return nil, errors.New("invalid WAVE fmt chunk")
+	}
+	if int(format.blockAlign) != int(format.channels)*int(format.bitsPerSample/8) {
+		return nil, fmt.Errorf("WAVE block align is %d, expected %d", format.blockAlign, int(format.channels)*int(format.bitsPerSample/8))
+	}
+
+	return format, nil
+}
+
+// readWAVData reads the interleaved samples of a data chunk of size bytes, up to the end of r for
+// wavUnknownSize. The samples are appended as they are read, the size of the chunk is not trusted.
+func readWAVData(r io.Reader, format *wavFormat, size uint32) (*PCM, error) {
+	channels := int(format.channels)
+	bytesPerSample := int(format.bitsPerSample / 8)
+	frames := int64(size) / int64(format.blockAlign)
+	if size == wavUnknownSize {
+		frames = math.MaxInt64
+	}
+	// Valid bits are stored in the most significant bits of the container
+	shift := format.bitsPerSample - format.validBits
+
+	pcm := &PCM{
+		SampleRate:    format.sampleRate,
+		BitsPerSample: uint8(format.validBits),
+		Samples:       make([][]int32, channels),
+	}
+
+	data := r
+	if size != wavUnknownSize {
+		data = io.LimitReader(r, frames*int64(format.blockAlign))
+	}
+	br := bufio.NewReader(data)
+	buf := make([]byte, format.blockAlign)
+	for i := int64(0); i < frames; i++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			if size == wavUnknownSize && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, fmt.Errorf("unable to read WAVE samples: %w", err)
+		}
+
+		for ch := 0; ch < channels; ch++ {
+			pcm.Samples[ch] = append(pcm.Samples[ch], wavSample(buf[ch*bytesPerSample:(ch+1)*bytesPerSample])>>shift)
+		}
+	}
+
+	return pcm, nil
+}
+
+// wavMaxFormatSize is the size of the fmt chunk of WAVE_FORMAT_EXTENSIBLE, the largest one
+const wavMaxFormatSize = 40
+
+// wavMaxListSize bounds the LIST chunks read for their INFO tags, larger ones are skipped
+const wavMaxListSize = 64 * 1024
+
+// wavUnknownSize is the size of the data chunk of WAVE streams written before their length is known,
+// e.g. piped by ffmpeg
+const wavUnknownSize = 0xFFFFFFFF
+
+// encodeWAVData encodes the interleaved samples of a data chunk of size bytes read from r to w
+func encodeWAVData(r io.Reader, w io.Writer, format *wavFormat, size uint32, opts EncoderOptions) error {
+	encoder, err := NewEncoder(w, format.sampleRate, uint8(format.validBits), int(format.channels), opts)
+	if err != nil {
+		return err
+	}
+
+	blockAlign := int64(format.blockAlign)
+	bytesPerSample := int(format.bitsPerSample / 8)
+	shift := format.bitsPerSample - format.validBits
+	expected := int64(size) / blockAlign * blockAlign
+	if size == wavUnknownSize {
+		expected = math.MaxInt64
+	}
+
+	data := io.LimitReader(r, expected)
+	buf := make([]byte, 4096*format.blockAlign)
+	samples := make([]int32, 4096*int(format.channels))
+	var total int64
+	for {
+		n, err := io.ReadFull(data, buf)
+		n -= n % int(blockAlign)
+		total += int64(n)
+		for i := 0; i < n/bytesPerSample; i++ {
+			samples[i] = wavSample(buf[i*bytesPerSample:(i+1)*bytesPerSample]) >> shift
+		}
+		if err := encoder.Write(samples[:n/bytesPerSample]); err != nil {
+			return err
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if size != wavUnknownSize && total < expected {
+				return fmt.Errorf("unable to read WAVE samples: %w", io.ErrUnexpectedEOF)
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read WAVE samples: %w", err)
+		}
+	}
+
+	return encoder.Close()
+}
+
+// wavSample decodes a sample of a data chunk, stored in len(b) bytes
+func wavSample(b []byte) int32 {
+	switch len(b) {
+	case 1:
+		// 8 bits samples are unsigned
+		return int32(b[0]) - 128
+	case 2:
+		return int32(int16(binary.LittleEndian.Uint16(b)))
+	case 3:
+		return int32(uint32(b[0])|uint32(b[1])<<8|uint32(b[2])<<16) << 8 >> 8
+	}
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+// writeWAVHeader writes the RIFF header, the fmt chunk and the header of a data chunk of size bytes
+func writeWAVHeader(w io.Writer, channels int, bitsPerSample uint8, sampleRate uint32, size uint32) error {
+	containerBits := (uint16(bitsPerSample) + 7) / 8 * 8
+	blockAlign := uint16(channels) * containerBits / 8
+
+	// WAVE_FORMAT_EXTENSIBLE is required for more than two channels or samples not filling their container
+	extensible := channels > 2 || containerBits != uint16(bitsPerSample)
+
+	var fmtChunk bytes.Buffer
+	formatTag := uint16(wavFormatPCM)
+	if extensible {
+		formatTag = wavFormatExtensible
+	}
+	binary.Write(&fmtChunk, binary.LittleEndian, formatTag)
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(channels))
+	binary.Write(&fmtChunk, binary.LittleEndian, sampleRate)
+	binary.Write(&fmtChunk, binary.LittleEndian, sampleRate*uint32(blockAlign))
+	binary.Write(&fmtChunk, binary.LittleEndian, blockAlign)
+	binary.Write(&fmtChunk, binary.LittleEndian, containerBits)
+	if extensible {
+		binary.Write(&fmtChunk, binary.LittleEndian, uint16(22))
+		binary.Write(&fmtChunk, binary.LittleEndian, uint16(bitsPerSample))
+		binary.Write(&fmtChunk, binary.LittleEndian, defaultChannelMask(channels))
+		// KSDATAFORMAT_SUBTYPE_PCM
+		fmtChunk.Write([]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71})
+	}
+
+	var header bytes.Buffer
+	header.WriteString("RIFF")
+	binary.Write(&header, binary.LittleEndian, uint32(4+8+fmtChunk.Len()+8)+size+size%2)
+	header.WriteString("WAVEfmt ")
+	binary.Write(&header, binary.LittleEndian, uint32(fmtChunk.Len()))
+	fmtChunk.WriteTo(&header)
+	header.WriteString("data")
+	binary.Write(&header, binary.LittleEndian, size)
+
+	_, err := header.WriteTo(w)
+	return err
+}
+
+// defaultChannelMask returns the speaker positions FLAC assigns to a number of channels
+func defaultChannelMask(channels int) uint32 {
+	masks := []uint32{0, 0x4, 0x3, 0x7, 0x33, 0x37, 0x3F, 0x70F, 0x63F}
+	if channels < len(masks) {
+		return masks[channels]
+	}
+	return 0
+}
+
+// writeWAVSamples writes samples interleaved as little-endian integers in containers of whole bytes
+func writeWAVSamples(w *bufio.Writer, samples [][]int32, bitsPerSample uint8) error {
+	bytesPerSample := int(bitsPerSample+7) / 8
+	// Samples are stored in the most significant bits of their container
+	shift := uint(bytesPerSample*8) - uint(bitsPerSample)
+
+	var buf [4]byte
+	for i := range samples[0] {
+		for ch := range samples {
+			sample := samples[ch][i] << shift
+			if bytesPerSample == 1 {
+				sample += 128
+			}
+			binary.LittleEndian.PutUint32(buf[:], uint32(sample))
+			if _, err := w.Write(buf[:bytesPerSample]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteWAV writes pcm as a WAVE file
+func WriteWAV(w io.Writer, pcm *PCM) error {
+	if err := pcm.validate(); err != nil {
+		return fmt.Errorf("unable to write WAVE: %w", err)
+	}
+
+	size := uint64(pcm.Length()) * uint64(pcm.Channels()) * uint64((pcm.BitsPerSample+7)/8)
+	if size > 0xFFFFFFFF-64 {
+		return errors.New("unable to write WAVE: audio is larger than 4 GB")
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeWAVHeader(bw, pcm.Channels(), pcm.BitsPerSample, pcm.SampleRate, uint32(size)); err != nil {
+		return fmt.Errorf("unable to write WAVE header: %w", err)
+	}
+	if err := writeWAVSamples(bw, pcm.Samples, pcm.BitsPerSample); err != nil {
+		return fmt.Errorf("unable to write WAVE samples: %w", err)
+	}
+	if size%2 == 1 {
+		bw.WriteByte(0)
+	}
+
+	return bw.Flush()
+}
+
+// ExportWAV decodes the audio and writes it as a WAVE file, one frame at a time
+func (flac *Flac) ExportWAV(w io.Writer) error {
+	streamInfo, err := flac.StreamInfo()
+	if err != nil {
+		return err
+	}
+
+	// The data chunk size is written first, without a sample count it's only known after decoding
+	if streamInfo.TotalSamples == 0 {
+		pcm, err := flac.DecodePCM()
+		if err != nil {
+			return err
+		}
+		return WriteWAV(w, pcm)
+	}
+
+	mixer, err := flac.newChannelMixer(streamInfo)
+	if err != nil {
+		return err
+	}
+	size := streamInfo.TotalSamples * uint64(mixer.channels) * uint64((streamInfo.BitsPerSample+7)/8)
+	if size > 0xFFFFFFFF-64 {
+		return errors.New("unable to write WAVE: audio is larger than 4 GB")
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeWAVHeader(bw, mixer.channels, streamInfo.BitsPerSample, streamInfo.SampleRate, uint32(size)); err != nil {
+		return fmt.Errorf("unable to write WAVE header: %w", err)
+	}
+
+	err = flac.decodeFrames(streamInfo, mixer, func(samples [][]int32) error {
+		if err := writeWAVSamples(bw, samples, streamInfo.BitsPerSample); err != nil {
+			return fmt.Errorf("unable to write WAVE samples: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if size%2 == 1 {
+		bw.WriteByte(0)
+	}
+
+	return bw.Flush()
+}
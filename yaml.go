@@ -0,0 +1,414 @@
+package flacgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TagPatch is a set of tag changes, as read from a YAML document by ReadYAMLTags
+type TagPatch struct {
+	// Set are the comments replacing all the values of their tags, in order
+	Set []VorbisComment
+	// Remove are the tags to remove
+	Remove []string
+}
+
+// Apply returns comments without the tags of Remove and with the values of the tags of Set replaced.
+// Keys are compared case-insensitively, the tags already present keep their spelling.
+func (patch TagPatch) Apply(comments []VorbisComment) []VorbisComment {
+	spellings := make(map[string]string)
+	for _, comment := range comments {
+		if _, found := spellings[strings.ToUpper(comment.Title)]; !found {
+			spellings[strings.ToUpper(comment.Title)] = comment.Title
+		}
+	}
+
+	for _, key := range patch.Remove {
+		comments = slices.DeleteFunc(comments, func(comment VorbisComment) bool {
+			return strings.EqualFold(comment.Title, key)
+		})
+	}
+
+	overrides := make([]VorbisComment, len(patch.Set))
+	for i, comment := range patch.Set {
+		if spelling, found := spellings[strings.ToUpper(comment.Title)]; found {
+			comment.Title = spelling
+		}
+		overrides[i] = comment
+	}
+	return mergeComments(comments, overrides)
+}
+
+// isEmpty reports whether the patch changes nothing
+func (patch TagPatch) isEmpty() bool {
+	return len(patch.Set) == 0 && len(patch.Remove) == 0
+}
+
+// ExportYAML writes the comments of the file, staged changes included, as the YAML front matter of a
+// text file: a document between "---" lines mapping every key to its value, or to the list of its values
+// for tags having several. Multi-line values are written as literal blocks and values YAML would not
+// read back as the same string are quoted. ReadYAMLTags reads it back.
+func (flac *Flac) ExportYAML(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	out.WriteString("---\n")
+	writeYAMLTags(out, flac.Comments(), "")
+	out.WriteString("---\n")
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("unable to export YAML tags: %w", err)
+	}
+	return nil
+}
+
+// ReadYAMLTags reads a YAML document mapping tag keys to a value or a list of values, e.g. written by
+// ExportYAML. A document opened by "---" ends at the next "---", so the front matter of a Markdown file
+// can be read directly. Keys are read in upper case. A tag set to null (~) or to an empty list is removed,
+// the tags missing from the document are left untouched when the patch is applied.
+func ReadYAMLTags(r io.Reader) (*TagPatch, error) {
+	node, err := readYAMLDocument(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read YAML tags: %w", err)
+	}
+	patch, err := yamlTagPatch(node)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read YAML tags: %w", err)
+	}
+	return patch, nil
+}
+
+// ImportYAML stages the tag changes of the YAML document read from r, see ReadYAMLTags,
+// and returns them like ApplyTransform does
+func (flac *Flac) ImportYAML(r io.Reader) ([]TagChange, error) {
+	patch, err := ReadYAMLTags(r)
+	if err != nil {
+		return nil, err
+	}
+	return flac.ApplyTransform(patch)
+}
+
+// TagManifest describes the tags of the FLAC files of a directory in a single YAML document, so that they
+// can be kept under version control and applied again in one go:
+//
+//	tags:
+//	  ALBUM: Blue Train
+//	  GENRE: [Jazz, Hard Bop]
+//	files:
+//	  01 - Blue Train.flac:
+//	    TITLE: Blue Train
+//	    TRACKNUMBER: 1
+//
+// The tags section is applied to every FLAC file of the directory, the files section then overrides it
+// per file, by path relative to the directory. Both follow the rules of ReadYAMLTags.
+type TagManifest struct {
+	Tags  TagPatch
+	Files map[string]TagPatch
+}
+
+// ReadTagManifest reads a TagManifest from r
+func ReadTagManifest(r io.Reader) (*TagManifest, error) {
+	node, err := readYAMLDocument(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tag manifest: %w", err)
+	}
+
+	manifest := &TagManifest{Files: make(map[string]TagPatch)}
+	if node.kind == yamlNull {
+		return manifest, nil
+	}
+	if node.kind != yamlMapping {
+		return nil, fmt.Errorf("unable to read tag manifest: line %d: expected the tags and files sections", node.line)
+	}
+	for i, key := range node.keys {
+		section := node.items[i]
+		switch key {
+		case "tags":
+			patch, err := yamlTagPatch(section)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read tag manifest: %w", err)
+			}
+			manifest.Tags = *patch
+		case "files":
+			if section.kind == yamlNull {
+				continue
+			}
+			if section.kind != yamlMapping {
+				return nil, fmt.Errorf("unable to read tag manifest: line %d: expected a mapping of paths to tags", section.line)
+			}
+			for j, path := range section.keys {
+				patch, err := yamlTagPatch(section.items[j])
+				if err != nil {
+					return nil, fmt.Errorf("unable to read tag manifest: %s: %w", path, err)
+				}
+				if _, found := manifest.Files[path]; found {
+					return nil, fmt.Errorf("unable to read tag manifest: line %d: duplicated file '%s'", section.items[j].line, path)
+				}
+				manifest.Files[path] = *patch
+			}
+		default:
+			return nil, fmt.Errorf("unable to read tag manifest: line %d: unknown section '%s'", section.line, key)
+		}
+	}
+	return manifest, nil
+}
+
+// Paths returns the files of dir the manifest applies to: every FLAC file of dir if the tags section
+// is not empty, followed by the other files of the files section in lexical order
+func (manifest *TagManifest) Paths(dir string) ([]string, error) {
+	var paths []string
+	if !manifest.Tags.isEmpty() {
+		var err error
+		if paths, err = listFLACFiles(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(manifest.Files))
+	for name := range manifest.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Transform returns the Transform the manifest applies to the file at path in dir:
+// the tags section followed by the entry of the file in the files section
+func (manifest *TagManifest) Transform(dir string, path string) Transform {
+	pipeline := Pipeline{manifest.Tags}
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		if patch, found := manifest.Files[filepath.ToSlash(rel)]; found {
+			pipeline = append(pipeline, patch)
+		}
+	}
+	return pipeline
+}
+
+// Apply stages the tags of the manifest on the files of dir returned by Paths and saves the files that
+// changed. Failures of single files, e.g. a file of the files section missing, are reported in the results.
+func (manifest *TagManifest) Apply(dir string) ([]TransformResult, error) {
+	paths, err := manifest.Paths(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to apply tag manifest: %w", err)
+	}
+	results := make([]TransformResult, 0, len(paths))
+	for _, path := range paths {
+		changes, err := transformFile(path, func(flac *Flac) ([]TagChange, error) {
+			return flac.ApplyTransform(manifest.Transform(dir, path))
+		})
+		results = append(results, TransformResult{Path: path, Changes: changes, Err: err})
+	}
+	return results, nil
+}
+
+// WriteTagManifest writes the TagManifest of the FLAC files of dir: the tags having the same values in
+// every file, if there are several files, go in the tags section and the others in the files section
+func WriteTagManifest(w io.Writer, dir string) error {
+	paths, err := listFLACFiles(dir)
+	if err != nil {
+		return fmt.Errorf("unable to write tag manifest: %w", err)
+	}
+
+	files := make([][]VorbisComment, len(paths))
+	for i, path := range paths {
+		flac, err := OpenReadOnly(path, WithHeaderOnly())
+		if err != nil {
+			return fmt.Errorf("unable to write tag manifest: %w", err)
+		}
+		files[i] = flac.Comments()
+		flac.Close()
+	}
+
+	// Common tags are those of the first file having the same values in all the others
+	values := func(comments []VorbisComment, key string) []string {
+		var values []string
+		for _, comment := range comments {
+			if strings.EqualFold(comment.Title, key) {
+				values = append(values, comment.Value)
+			}
+		}
+		return values
+	}
+	common := make(map[string]bool)
+	var shared []VorbisComment
+	if len(files) > 1 {
+		for _, comment := range files[0] {
+			key := strings.ToUpper(comment.Title)
+			if common[key] {
+				shared = append(shared, comment)
+				continue
+			}
+			first := values(files[0], key)
+			if !slices.ContainsFunc(files[1:], func(comments []VorbisComment) bool {
+				return !slices.Equal(values(comments, key), first)
+			}) {
+				common[key] = true
+				shared = append(shared, comment)
+			}
+		}
+	}
+
+	out := bufio.NewWriter(w)
+	if len(shared) > 0 {
+		out.WriteString("tags:\n")
+		writeYAMLTags(out, shared, "  ")
+	}
+	wroteFiles := false
+	for i, path := range paths {
+		specific := slices.DeleteFunc(files[i], func(comment VorbisComment) bool {
+			return common[strings.ToUpper(comment.Title)]
+		})
+		if len(specific) == 0 {
+			continue
+		}
+		if !wroteFiles {
+			out.WriteString("files:\n")
+			wroteFiles = true
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("unable to write tag manifest: %w", err)
+		}
+		fmt.Fprintf(out, "  %s:\n", formatYAMLString(filepath.ToSlash(rel)))
+		writeYAMLTags(out, specific, "    ")
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("unable to write tag manifest: %w", err)
+	}
+	return nil
+}
+
+// yamlTagPatch returns the patch of a mapping of keys to a value or a list of values
+func yamlTagPatch(node *yamlNode) (*TagPatch, error) {
+	patch := &TagPatch{}
+	if node.kind == yamlNull {
+		return patch, nil
+	}
+	if node.kind != yamlMapping {
+		return nil, fmt.Errorf("line %d: expected a mapping of tags to values", node.line)
+	}
+
+	seen := make(map[string]bool)
+	for i, key := range node.keys {
+		value := node.items[i]
+		key = strings.ToUpper(key)
+		if key == "" || strings.Contains(key, "=") {
+			return nil, fmt.Errorf("line %d: invalid tag key '%s'", value.line, key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicated tag %s", value.line, key)
+		}
+		seen[key] = true
+
+		switch value.kind {
+		case yamlNull:
+			patch.Remove = append(patch.Remove, key)
+		case yamlScalar:
+			patch.Set = append(patch.Set, VorbisComment{Title: key, Value: value.value})
+		case yamlSequence:
+			if len(value.items) == 0 {
+				patch.Remove = append(patch.Remove, key)
+			}
+			for _, item := range value.items {
+				if item.kind != yamlScalar {
+					return nil, fmt.Errorf("line %d: expected a value in the list of %s", item.line, key)
+				}
+				patch.Set = append(patch.Set, VorbisComment{Title: key, Value: item.value})
+			}
+		default:
+			return nil, fmt.Errorf("line %d: expected a value or a list of values for %s", value.line, key)
+		}
+	}
+	return patch, nil
+}
+
+// writeYAMLTags writes comments as a YAML mapping indented by indent, the values of a key written together
+// under its first spelling
+func writeYAMLTags(out *bufio.Writer, comments []VorbisComment, indent string) {
+	groups := make(map[string][]string)
+	var keys []string
+	for _, comment := range comments {
+		key := strings.ToUpper(comment.Title)
+		if _, found := groups[key]; !found {
+			keys = append(keys, comment.Title)
+		}
+		groups[key] = append(groups[key], comment.Value)
+	}
+
+	for _, key := range keys {
+		values := groups[strings.ToUpper(key)]
+		out.WriteString(indent + formatYAMLString(key) + ":")
+		if len(values) == 1 {
+			writeYAMLValue(out, values[0], indent)
+			continue
+		}
+		out.WriteString("\n")
+		for _, value := range values {
+			out.WriteString(indent + "  -")
+			writeYAMLValue(out, value, indent+"  ")
+		}
+	}
+}
+
+// writeYAMLValue writes value after a key or list item indented by indent, as a literal block if it has
+// several lines and a literal block reads it back
+func writeYAMLValue(out *bufio.Writer, value string, indent string) {
+	lines := strings.Split(strings.TrimSuffix(value, "\n"), "\n")
+	// The first line with text sets the indentation of the block, unless the header gives it
+	first := ""
+	for _, line := range lines {
+		if line != "" {
+			first = line
+			break
+		}
+	}
+	literal := len(lines) > 1 && !strings.HasPrefix(first, "\t") && !strings.HasSuffix(value, "\n\n") &&
+		!strings.ContainsFunc(value, func(r rune) bool { return r != '\n' && r != '\t' && !unicode.IsPrint(r) })
+	// Trailing whitespace isn't kept by every reader of literal blocks, nor by editors
+	for _, line := range lines {
+		literal = literal && strings.TrimRight(line, " \t") == line
+	}
+	if !literal {
+		out.WriteString(" " + formatYAMLString(value) + "\n")
+		return
+	}
+
+	header := " |"
+	if strings.HasPrefix(first, " ") {
+		header += "2"
+	}
+	if !strings.HasSuffix(value, "\n") {
+		header += "-"
+	}
+	out.WriteString(header + "\n")
+	for _, line := range lines {
+		if line != "" {
+			out.WriteString(indent + "  " + line)
+		}
+		out.WriteString("\n")
+	}
+}
+
+// formatYAMLString returns value as a plain scalar, or double-quoted if YAML would read it differently,
+// e.g. "true", "- 1", "a: b" or a value with surrounding spaces
+func formatYAMLString(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.HasPrefix(value, "...") || strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") ||
+		strings.ContainsFunc(value, func(r rune) bool { return !unicode.IsPrint(r) }) || isYAMLNull(value) {
+		return strconv.Quote(value)
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(value)
+	}
+	return value
+}
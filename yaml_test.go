@@ -0,0 +1,43 @@
+package flacgo
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLTagsRoundTrip(t *testing.T) {
+	comments := []VorbisComment{
+		{Title: "TITLE", Value: "true"},
+		{Title: "LYRICS", Value: "first line\nsecond line\n"},
+		{Title: "COMMENT", Value: "trailing space \nand tab\t\nend"},
+		{Title: "DESCRIPTION", Value: "blank\n   \nline"},
+		{Title: "NOTES", Value: "\tindented\nby a tab"},
+		{Title: "VERSE", Value: "\n  verse\nchorus"},
+		{Title: "BRIDGE", Value: "  indented\n\n    more\n"},
+		{Title: "GENRE", Value: "Rock"},
+		{Title: "GENRE", Value: "two\nlines "},
+		{Title: "GENRE", Value: "\n  indented\nitem"},
+	}
+
+	var buf bytes.Buffer
+	out := bufio.NewWriter(&buf)
+	out.WriteString("---\n")
+	writeYAMLTags(out, comments, "")
+	out.WriteString("---\n")
+	out.Flush()
+	document := buf.String()
+
+	patch, err := ReadYAMLTags(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(patch.Set, comments) {
+		t.Errorf("read back %q from\n%s, expected %q", patch.Set, document, comments)
+	}
+	if strings.Contains(document, " \n") {
+		t.Errorf("trailing whitespace written in\n%s", document)
+	}
+}
@@ -0,0 +1,449 @@
+package flacgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlKind is the kind of a node of a YAML document
+type yamlKind int
+
+const (
+	yamlNull yamlKind = iota
+	yamlScalar
+	yamlSequence
+	yamlMapping
+)
+
+// yamlNode is a node of a YAML document: a scalar value, a sequence of items or a mapping of keys to items
+type yamlNode struct {
+	kind  yamlKind
+	line  int
+	value string
+	keys  []string
+	items []*yamlNode
+}
+
+// yamlParser reads the subset of YAML needed for tags: block mappings and sequences, plain, quoted and
+// block scalars, flow sequences of scalars and comments. Anchors, aliases, tags and flow mappings are not.
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// readYAMLDocument parses the first document read from r. A document opened by "---" ends at the next
+// "---" or "...", so that the front matter of a text file can be read, the rest of the file being ignored.
+func readYAMLDocument(r io.Reader) (*yamlNode, error) {
+	var lines []string
+	started, content := false, false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		isMarker := line == "---" || strings.HasPrefix(line, "--- ")
+		if !started && !content {
+			// Directives and comments may precede the start of the document
+			if isMarker {
+				started, lines = true, nil
+				continue
+			}
+			if strings.HasPrefix(line, "%") {
+				continue
+			}
+		}
+		if isMarker || line == "..." {
+			break
+		}
+		if text := strings.TrimSpace(line); text != "" && !strings.HasPrefix(text, "#") {
+			content = true
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	parser := &yamlParser{lines: lines}
+	node, err := parser.parseBlock(-1)
+	if err != nil {
+		return nil, err
+	}
+	if _, text, line, found := parser.peek(); found {
+		return nil, fmt.Errorf("line %d: unexpected '%s'", line, text)
+	}
+	return node, nil
+}
+
+// peek returns the indentation, the text and the line number of the next line that is neither blank nor a comment
+func (parser *yamlParser) peek() (int, string, int, bool) {
+	for ; parser.pos < len(parser.lines); parser.pos++ {
+		line := parser.lines[parser.pos]
+		text := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		return len(line) - len(text), strings.TrimRight(text, " \t"), parser.pos + 1, true
+	}
+	return 0, "", 0, false
+}
+
+// parseBlock parses the node made of the lines indented more than parent, null if there are none
+func (parser *yamlParser) parseBlock(parent int) (*yamlNode, error) {
+	indent, text, line, found := parser.peek()
+	if !found || indent <= parent {
+		return &yamlNode{kind: yamlNull, line: line}, nil
+	}
+	if isYAMLSequenceItem(text) {
+		return parser.parseSequence(indent)
+	}
+	return parser.parseMapping(indent)
+}
+
+// parseMapping parses the "key: value" lines at the given indentation
+func (parser *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	_, _, first, _ := parser.peek()
+	node := &yamlNode{kind: yamlMapping, line: first}
+	for {
+		lineIndent, text, line, found := parser.peek()
+		if !found || lineIndent < indent {
+			return node, nil
+		}
+		if lineIndent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line)
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", line)
+		}
+		if isYAMLSequenceItem(text) {
+			return nil, fmt.Errorf("line %d: expected a key, got a list item", line)
+		}
+
+		key, rest, err := parseYAMLKey(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		parser.pos++
+
+		var value *yamlNode
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			value, err = parser.parseBlock(indent)
+			// A list may be at the same indentation as its key
+			if err == nil && value.kind == yamlNull {
+				if nextIndent, nextText, _, found := parser.peek(); found && nextIndent == indent && isYAMLSequenceItem(nextText) {
+					value, err = parser.parseSequence(indent)
+				}
+			}
+		} else {
+			value, err = parser.parseValue(rest, indent, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.keys = append(node.keys, key)
+		node.items = append(node.items, value)
+	}
+}
+
+// parseSequence parses the "- item" lines at the given indentation
+func (parser *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	_, _, first, _ := parser.peek()
+	node := &yamlNode{kind: yamlSequence, line: first}
+	for {
+		lineIndent, text, line, found := parser.peek()
+		if !found || lineIndent < indent || (lineIndent == indent && !isYAMLSequenceItem(text)) {
+			return node, nil
+		}
+		if lineIndent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line)
+		}
+		parser.pos++
+
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "-"))
+		var item *yamlNode
+		var err error
+		switch {
+		case rest == "" || strings.HasPrefix(rest, "#"):
+			item, err = parser.parseBlock(indent)
+		case isYAMLSequenceItem(rest):
+			return nil, fmt.Errorf("line %d: nested lists are not supported", line)
+		default:
+			if _, _, keyErr := parseYAMLKey(rest); keyErr == nil {
+				return nil, fmt.Errorf("line %d: mappings in lists are not supported", line)
+			}
+			item, err = parser.parseValue(rest, indent, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+	}
+}
+
+// parseValue parses the value following a key or a list item on the same line
+func (parser *yamlParser) parseValue(text string, indent int, line int) (*yamlNode, error) {
+	if strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">") {
+		value, err := parser.parseBlockScalar(text, indent, line)
+		if err != nil {
+			return nil, err
+		}
+		return &yamlNode{kind: yamlScalar, line: line, value: value}, nil
+	}
+	if strings.HasPrefix(text, "[") {
+		return parseYAMLFlowSequence(text, line)
+	}
+	if strings.HasPrefix(text, "{") {
+		return nil, fmt.Errorf("line %d: flow mappings are not supported", line)
+	}
+
+	value, rest, quoted, err := parseYAMLScalar(text, false)
+	if err == nil && rest != "" && !strings.HasPrefix(rest, "#") {
+		err = fmt.Errorf("unexpected '%s' after value", rest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	if !quoted && isYAMLNull(value) {
+		return &yamlNode{kind: yamlNull, line: line}, nil
+	}
+	return &yamlNode{kind: yamlScalar, line: line, value: value}, nil
+}
+
+// parseBlockScalar reads the lines of a literal (|) or folded (>) block scalar introduced by header
+// after a key or list item indented by indent
+func (parser *yamlParser) parseBlockScalar(header string, indent int, line int) (string, error) {
+	style := header[0]
+	chomping := byte(0)
+	contentIndent := -1
+	indicators, _, _ := strings.Cut(header[1:], "#")
+	for _, c := range []byte(strings.TrimSpace(indicators)) {
+		switch {
+		case (c == '-' || c == '+') && chomping == 0:
+			chomping = c
+		case c >= '1' && c <= '9' && contentIndent < 0:
+			contentIndent = max(indent, 0) + int(c-'0')
+		default:
+			return "", fmt.Errorf("line %d: invalid block scalar header '%s'", line, header)
+		}
+	}
+
+	var lines []string
+	for ; parser.pos < len(parser.lines); parser.pos++ {
+		raw := parser.lines[parser.pos]
+		text := strings.TrimLeft(raw, " ")
+		lineIndent := len(raw) - len(text)
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if contentIndent < 0 {
+			contentIndent = lineIndent
+		}
+		if lineIndent <= indent || lineIndent < contentIndent {
+			break
+		}
+		lines = append(lines, raw[contentIndent:])
+	}
+
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var value string
+	if style == '|' {
+		value = strings.Join(lines, "\n")
+	} else {
+		value = foldYAMLLines(lines)
+	}
+	switch {
+	case chomping == '-' || len(lines) == 0:
+	case chomping == '+':
+		value += strings.Repeat("\n", trailing+1)
+	default:
+		value += "\n"
+	}
+	return value, nil
+}
+
+// foldYAMLLines joins the lines of a folded block scalar: single line breaks between lines of text become
+// spaces, the line breaks around blank and more indented lines are kept
+func foldYAMLLines(lines []string) string {
+	var value strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			previous := lines[i-1]
+			moreIndented := strings.HasPrefix(previous, " ") || strings.HasPrefix(line, " ")
+			switch {
+			case previous != "" && line != "" && !moreIndented:
+				value.WriteByte(' ')
+			case previous != "" && line == "" && !strings.HasPrefix(previous, " "):
+			default:
+				value.WriteByte('\n')
+			}
+		}
+		value.WriteString(line)
+	}
+	return value.String()
+}
+
+// parseYAMLFlowSequence parses a sequence of scalars written on a single line, e.g. [Rock, "Hip Hop"]
+func parseYAMLFlowSequence(text string, line int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSequence, line: line}
+	rest := strings.TrimSpace(text[1:])
+	for {
+		if strings.HasPrefix(rest, "]") {
+			rest = strings.TrimSpace(rest[1:])
+			break
+		}
+		value, next, _, err := parseYAMLScalar(rest, true)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") || strings.HasPrefix(next, "[") || strings.HasPrefix(next, "{") {
+			return nil, fmt.Errorf("line %d: nested collections are not supported", line)
+		}
+		node.items = append(node.items, &yamlNode{kind: yamlScalar, line: line, value: value})
+
+		next = strings.TrimSpace(next)
+		switch {
+		case strings.HasPrefix(next, ","):
+			rest = strings.TrimSpace(next[1:])
+		case strings.HasPrefix(next, "]"):
+			rest = next
+		default:
+			return nil, fmt.Errorf("line %d: unterminated list", line)
+		}
+	}
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("line %d: unexpected '%s' after list", line, rest)
+	}
+	return node, nil
+}
+
+// parseYAMLKey splits a "key: value" line into its key and the text of its value
+func parseYAMLKey(text string) (string, string, error) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		key, rest, _, err := parseYAMLScalar(text, false)
+		if err != nil {
+			return "", "", err
+		}
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ') {
+			return "", "", fmt.Errorf("expected ':' after key %q", key)
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+
+	if strings.HasPrefix(text, "? ") || strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!") {
+		return "", "", fmt.Errorf("complex keys, anchors, aliases and tags are not supported")
+	}
+	if strings.HasSuffix(text, ":") {
+		return strings.TrimSpace(text[:len(text)-1]), "", nil
+	}
+	key, rest, found := strings.Cut(text, ": ")
+	if !found || strings.Contains(key, " #") {
+		return "", "", fmt.Errorf("expected 'KEY: VALUE', got '%s'", text)
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(rest), nil
+}
+
+// parseYAMLScalar parses the quoted or plain scalar text starts with and returns it with the text following
+// it and whether it was quoted. A plain scalar ends at a comment or, inFlow, at a flow indicator such as ','.
+func parseYAMLScalar(text string, inFlow bool) (string, string, bool, error) {
+	switch {
+	case strings.HasPrefix(text, "\""):
+		return parseYAMLDoubleQuoted(text)
+	case strings.HasPrefix(text, "'"):
+		var value strings.Builder
+		for i := 1; i < len(text); i++ {
+			if text[i] != '\'' {
+				value.WriteByte(text[i])
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '\'' {
+				value.WriteByte('\'')
+				i++
+				continue
+			}
+			return value.String(), strings.TrimSpace(text[i+1:]), true, nil
+		}
+		return "", "", false, fmt.Errorf("unterminated quoted value")
+	case strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!"):
+		return "", "", false, fmt.Errorf("anchors, aliases and tags are not supported")
+	case strings.HasPrefix(text, "@") || strings.HasPrefix(text, "`"):
+		return "", "", false, fmt.Errorf("reserved character '%c' must be quoted", text[0])
+	}
+
+	end := len(text)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t') {
+			end = i
+			break
+		}
+		if inFlow && (c == ',' || c == ']' || c == '[' || c == '{' || c == '}') {
+			end = i
+			break
+		}
+	}
+	return strings.TrimSpace(text[:end]), strings.TrimSpace(text[end:]), false, nil
+}
+
+// yamlEscapes are the escape sequences of double-quoted scalars but the \x, \u and \U code points
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00A0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// parseYAMLDoubleQuoted parses a double-quoted scalar on a single line and its escape sequences
+func parseYAMLDoubleQuoted(text string) (string, string, bool, error) {
+	var value strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		if c == '"' {
+			return value.String(), strings.TrimSpace(text[i+1:]), true, nil
+		}
+		if c != '\\' {
+			value.WriteByte(c)
+			continue
+		}
+		if i+1 >= len(text) {
+			break
+		}
+		i++
+		if escaped, found := yamlEscapes[text[i]]; found {
+			value.WriteString(escaped)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[text[i]]
+		if digits == 0 || i+digits >= len(text) {
+			return "", "", false, fmt.Errorf("invalid escape sequence '\\%c'", text[i])
+		}
+		code, err := strconv.ParseUint(text[i+1:i+1+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", "", false, fmt.Errorf("invalid escape sequence '\\%s'", text[i:i+1+digits])
+		}
+		value.WriteRune(rune(code))
+		i += digits
+	}
+	return "", "", false, fmt.Errorf("unterminated quoted value, quoted values must fit on a single line")
+}
+
+// isYAMLSequenceItem reports whether text is a "- item" line
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isYAMLNull reports whether a plain scalar stands for null
+func isYAMLNull(value string) bool {
+	switch value {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	}
+	return false
+}